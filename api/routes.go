@@ -0,0 +1,27 @@
+package api
+
+import (
+	"net/http"
+	"skymates-api/internal/handler"
+	"skymates-api/pkg/auth"
+	"skymates-api/pkg/health"
+	"skymates-api/pkg/metrics"
+)
+
+// RegisterHealthRoutes 注册存活和就绪检查路由, 供编排系统和负载均衡器使用
+func RegisterHealthRoutes(mux *http.ServeMux, registry *health.Registry) {
+	mux.HandleFunc("GET /healthz", health.LivenessHandler())
+	mux.HandleFunc("GET /readyz", registry.ReadinessHandler())
+}
+
+// RegisterMetricsRoutes 注册 Prometheus 指标路由, 由 Prometheus 定期抓取
+func RegisterMetricsRoutes(mux *http.ServeMux, registry *metrics.Registry) {
+	mux.Handle("GET /metrics", registry.Handler())
+}
+
+// RegisterWellKnownRoutes 注册不区分 API 版本的 /.well-known 路由
+func RegisterWellKnownRoutes(mux *http.ServeMux, keys *auth.KeyManager) {
+	jwksHandler := handler.NewJWKSHandler(keys)
+
+	mux.HandleFunc("GET /.well-known/jwks.json", jwksHandler.GetJWKS)
+}
@@ -0,0 +1,39 @@
+package v1
+
+import (
+	"net/http"
+	"skymates-api/internal/authz"
+	"skymates-api/internal/handler"
+	"skymates-api/internal/middleware"
+	"skymates-api/internal/service"
+)
+
+// registerCategoryRoutes 注册V1版本的所有 Category API 路由, 只有管理员可以修改分类
+func registerCategoryRoutes(mux *http.ServeMux, categoryService service.CategoryService, authenticate, rateLimit middleware.Middleware) {
+	categoryHandler := handler.NewCategoryHandler(categoryService)
+
+	// 公开路由, /categories/tree 比 /categories/{categoryID} 更具体, 优先匹配
+	mux.Handle("GET /api/v1/categories", rateLimit(handler.Func(categoryHandler.ListCategories)))
+	mux.Handle("GET /api/v1/categories/tree", rateLimit(handler.Func(categoryHandler.GetCategoryTree)))
+	mux.Handle("GET /api/v1/categories/{categoryID}", rateLimit(handler.Func(categoryHandler.GetCategoryByID)))
+
+	// 管理员路由
+	mux.Handle("POST /api/v1/categories", middleware.Chain(
+		handler.Func(categoryHandler.CreateCategory),
+		authenticate,
+		middleware.Authorize(authz.AdminOnly()),
+		rateLimit,
+	))
+	mux.Handle("PUT /api/v1/categories/{categoryID}", middleware.Chain(
+		handler.Func(categoryHandler.UpdateCategory),
+		authenticate,
+		middleware.Authorize(authz.AdminOnly()),
+		rateLimit,
+	))
+	mux.Handle("DELETE /api/v1/categories/{categoryID}", middleware.Chain(
+		handler.Func(categoryHandler.DeleteCategory),
+		authenticate,
+		middleware.Authorize(authz.AdminOnly()),
+		rateLimit,
+	))
+}
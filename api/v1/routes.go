@@ -2,10 +2,26 @@ package v1
 
 import (
 	"net/http"
+	"skymates-api/internal/authz"
+	"skymates-api/internal/middleware"
 	"skymates-api/internal/service"
+	"skymates-api/pkg/auth"
 )
 
 // RegisterRoutes 注册V1版本的所有API路由
-func RegisterRoutes(mux *http.ServeMux, services *service.Services) {
-	registerUserRoutes(mux, services.UserService)
+// rateLimit 加在每个路由上, 按路由模式查找限流规则, 需要认证的路由在认证之后限流
+// requireVerifiedEmail 为 true 时只有验证过邮箱的用户才能创建和修改词条
+func RegisterRoutes(mux *http.ServeMux, services *service.Services, keys *auth.KeyManager, rateLimit middleware.Middleware, requireVerifiedEmail bool) {
+	// 所有需要登录的路由共用同一个认证中间件
+	authenticate := middleware.Auth(keys, services.TokenService)
+
+	// 创建和修改内容的权限
+	canPost := authz.Authenticated()
+	if requireVerifiedEmail {
+		canPost = authz.VerifiedEmail()
+	}
+
+	registerUserRoutes(mux, services, authenticate, rateLimit)
+	registerTermRoutes(mux, services.TermService, authenticate, canPost, rateLimit)
+	registerCategoryRoutes(mux, services.CategoryService, authenticate, rateLimit)
 }
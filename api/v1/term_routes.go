@@ -2,18 +2,44 @@ package v1
 
 import (
 	"net/http"
+	"skymates-api/internal/authz"
 	"skymates-api/internal/handler"
+	"skymates-api/internal/middleware"
 	"skymates-api/internal/service"
 )
 
-// RegisterTermRoutes 注册V1版本的所有 Term API 路由
-func RegisterTermRoutes(mux *http.ServeMux, termService service.TermService) {
+// registerTermRoutes 注册V1版本的所有 Term API 路由, canPost 决定哪些用户可以创建和修改术语
+func registerTermRoutes(mux *http.ServeMux, termService service.TermService, authenticate middleware.Middleware, canPost authz.Policy, rateLimit middleware.Middleware) {
 	termHandler := handler.NewTermHandler(termService)
 
 	// 公开路由
-	mux.HandleFunc("GET /api/v1/terms/search", termHandler.SearchTerms)
-	mux.HandleFunc("GET /api/v1/terms/{id}", termHandler.GetTermByID)
-	mux.HandleFunc("GET /api/v1/categories/{categoryID}/terms", termHandler.ListTermsByCategory)
-	mux.HandleFunc("POST /api/v1/terms", termHandler.CreateTerm)
-	mux.HandleFunc("PUT /api/v1/terms/{id}", termHandler.UpdateTerm)
+	mux.Handle("GET /api/v1/terms/search", rateLimit(handler.Func(termHandler.SearchTerms)))
+	mux.Handle("GET /api/v1/terms/suggest", rateLimit(handler.Func(termHandler.SuggestTerms)))
+	mux.Handle("GET /api/v1/terms/{id}", rateLimit(handler.Func(termHandler.GetTermByID)))
+	mux.Handle("GET /api/v1/categories/{categoryID}/terms", rateLimit(handler.Func(termHandler.ListTermsByCategory)))
+	mux.Handle("GET /api/v1/terms/{id}/revisions", rateLimit(handler.Func(termHandler.ListTermRevisions)))
+	mux.Handle("GET /api/v1/terms/{id}/revisions/diff", rateLimit(handler.Func(termHandler.DiffTermRevisions)))
+	mux.Handle("GET /api/v1/terms/{id}/revisions/{revision}", rateLimit(handler.Func(termHandler.GetTermRevision)))
+
+	// 需要认证和授权的路由: 满足 canPost 的用户可以创建术语, 服务层再校验只有创建者或管理员可以修改术语
+	mux.Handle("POST /api/v1/terms", middleware.Chain(
+		handler.Func(termHandler.CreateTerm),
+		authenticate,
+		middleware.Authorize(canPost),
+		rateLimit,
+	))
+	mux.Handle("PUT /api/v1/terms/{id}", middleware.Chain(
+		handler.Func(termHandler.UpdateTerm),
+		authenticate,
+		middleware.Authorize(canPost),
+		rateLimit,
+	))
+
+	// 只有管理员可以回滚术语, 回滚作为新的修订版本记录
+	mux.Handle("POST /api/v1/terms/{id}/revisions/{revision}/rollback", middleware.Chain(
+		handler.Func(termHandler.RollbackTerm),
+		authenticate,
+		middleware.Authorize(authz.AdminOnly()),
+		rateLimit,
+	))
 }
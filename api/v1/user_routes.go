@@ -3,14 +3,49 @@ package v1
 import (
 	"net/http"
 	"skymates-api/internal/handler"
+	"skymates-api/internal/middleware"
 	"skymates-api/internal/service"
 )
 
-// RegisterRoutes 注册V1版本的所有 User API 路由
-func registerUserRoutes(mux *http.ServeMux, userService service.UserService) {
-	userHandler := handler.NewUserHandler(userService)
+// registerUserRoutes 注册V1版本的所有 User API 路由
+func registerUserRoutes(mux *http.ServeMux, services *service.Services, authenticate, rateLimit middleware.Middleware) {
+	userHandler := handler.NewUserHandler(services.UserService, services.TokenService)
+	accountHandler := handler.NewAccountHandler(services.AccountService)
 
 	// 公开路由
-	mux.HandleFunc("POST /api/v1/users/login", userHandler.Login)
-	mux.HandleFunc("POST /api/v1/users/register", userHandler.Register)
+	mux.Handle("POST /api/v1/users/login", rateLimit(handler.Func(userHandler.Login)))
+	mux.Handle("POST /api/v1/users/register", rateLimit(handler.Func(userHandler.Register)))
+	mux.Handle("POST /api/v1/users/refresh", rateLimit(handler.Func(userHandler.Refresh)))
+	mux.Handle("POST /api/v1/users/password-reset/request", rateLimit(handler.Func(accountHandler.RequestPasswordReset)))
+	mux.Handle("POST /api/v1/users/password-reset/confirm", rateLimit(handler.Func(accountHandler.ResetPassword)))
+	mux.Handle("POST /api/v1/users/verify-email/confirm", rateLimit(handler.Func(accountHandler.VerifyEmail)))
+	// /users/me 比 /users/{username} 更具体, 优先匹配; 用户名至少 3 个字符, 不会与 me 冲突
+	mux.Handle("GET /api/v1/users/{username}", rateLimit(handler.Func(userHandler.GetUserByUsername)))
+
+	// 需要认证的路由
+	mux.Handle("POST /api/v1/users/logout", middleware.Chain(
+		handler.Func(userHandler.Logout),
+		authenticate,
+		rateLimit,
+	))
+	mux.Handle("GET /api/v1/users/me", middleware.Chain(
+		handler.Func(userHandler.GetMe),
+		authenticate,
+		rateLimit,
+	))
+	mux.Handle("PATCH /api/v1/users/me", middleware.Chain(
+		handler.Func(userHandler.UpdateMe),
+		authenticate,
+		rateLimit,
+	))
+	mux.Handle("PUT /api/v1/users/me/password", middleware.Chain(
+		handler.Func(userHandler.ChangePassword),
+		authenticate,
+		rateLimit,
+	))
+	mux.Handle("POST /api/v1/users/verify-email/request", middleware.Chain(
+		handler.Func(accountHandler.SendEmailVerification),
+		authenticate,
+		rateLimit,
+	))
 }
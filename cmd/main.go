@@ -1,56 +1,188 @@
 package main
 
 import (
-	"database/sql"
-	"github.com/jmoiron/sqlx"
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"skymates-api/api"
 	v1 "skymates-api/api/v1"
+	"skymates-api/config"
+	"skymates-api/internal/middleware"
+	"skymates-api/internal/migration"
 	"skymates-api/internal/repository"
 	"skymates-api/internal/service"
-	"skymates-api/pkg/middleware"
+	"skymates-api/pkg/auth"
+	"skymates-api/pkg/health"
+	"skymates-api/pkg/logging"
+	"skymates-api/pkg/mail"
+	"skymates-api/pkg/metrics"
+	"skymates-api/pkg/ratelimit"
+	"skymates-api/pkg/server"
+	"skymates-api/pkg/tracing"
+	"time"
+)
+
+const (
+	// tokenCleanupInterval 清理过期令牌的间隔
+	tokenCleanupInterval = time.Hour
+	// rateLimitSweepInterval 清理内存中已补充满的限流桶的间隔
+	rateLimitSweepInterval = time.Minute
+	// suggestIndexRefreshInterval 重建搜索建议索引的间隔, 多实例部署时其他实例修改的术语最多延迟这么久出现在建议中
+	suggestIndexRefreshInterval = 5 * time.Minute
 )
 
 func main() {
+	// 所有资源都在 run 中通过 defer 或 server.OnShutdown 释放, os.Exit 只在 run 返回后调用
+	if err := run(os.Args[1:]); err != nil {
+		slog.Error("server exited", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	// 0. 加载配置 (默认值 -> 配置文件 -> 环境变量 -> 命令行参数) 并初始化日志
+	cfg, args, err := config.Load(args)
+	if err != nil {
+		return fmt.Errorf("load config failed: %w", err)
+	}
+	logging.Setup(os.Stderr, cfg.Log)
+
 	// 1. 初始化数据库连接
-	db, err := repository.NewMySQLDatabase()
+	// *sqlx.DB 和底层的 *sql.DB 共享同一个连接池, 调用 db.Close() 会关闭整个连接池
+	// 正常退出时由 Server 在请求处理完成后关闭, 这里的 defer 用于启动失败的情况, 重复关闭是安全的
+	db, err := repository.NewDatabase(cfg.Database)
 	if err != nil {
-		log.Fatal("init database failed: ", err)
+		return fmt.Errorf("init database failed: %w", err)
 	}
+	defer db.Close()
 
-	defer func(db *sql.DB) {
-		err := db.Close()
-		if err != nil {
-			log.Fatal("close database failed: ", err)
+	// 2. 数据库迁移: migrate 子命令只执行迁移后退出, 不需要 JWT 密钥等服务端的配置;
+	// 开启 migrate_on_startup 时在启动服务前自动升级
+	dialect, err := migration.DialectFor(cfg.Database.Driver)
+	if err != nil {
+		return fmt.Errorf("init migrations failed: %w", err)
+	}
+	migrations, err := migration.NewRunner(db.DB, dialect)
+	if err != nil {
+		return fmt.Errorf("load migrations failed: %w", err)
+	}
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrate(context.Background(), migrations, args[1:]); err != nil {
+			return fmt.Errorf("migrate failed: %w", err)
+		}
+		return nil
+	}
+	if cfg.Database.MigrateOnStartup {
+		if _, err := migrations.Up(context.Background()); err != nil {
+			return fmt.Errorf("migrate on startup failed: %w", err)
 		}
-	}(db)
+	}
 
-	// 2. 将 *sql.DB 转换为 *sqlx.DB
-	// *sql.DB 和 *sqlx.DB 共享同一个底层连接池, 调用 db.Close() 会关闭整个连接池，因此只需关闭一次即可
-	sqlxDB := sqlx.NewDb(db, "mysql")
+	// 3. 初始化 JWT 密钥和追踪, 根据数据库驱动初始化仓库
+	keys, err := auth.NewKeyManager(cfg.JWT)
+	if err != nil {
+		return fmt.Errorf("init jwt keys failed: %w", err)
+	}
+	tracer, err := newTracer(cfg.Tracing)
+	if err != nil {
+		return fmt.Errorf("init tracing failed: %w", err)
+	}
+	tracing.SetDefault(tracer)
 
-	// 3. 初始化仓库
-	userRepository := repository.NewUserRepository(sqlxDB)
-	termRepository := repository.NewTermRepository(sqlxDB)
+	repositories, err := repository.NewRepositories(cfg.Database.Driver, db)
+	if err != nil {
+		return fmt.Errorf("init repositories failed: %w", err)
+	}
+
+	// 4. 注册指标并初始化服务, 连接池指标在每次抓取时读取
+	mailer, err := mail.New(cfg.Mail)
+	if err != nil {
+		return fmt.Errorf("init mailer failed: %w", err)
+	}
+	registry := metrics.NewRegistry()
+	registry.Register(metrics.NewDBStatsCollector(db.DB))
+	services := service.NewServices(repositories.User, repositories.Term, repositories.Category, repositories.Token, repositories.UserToken,
+		keys, mailer, cfg, service.NewMetrics(registry))
+	// 在开始接收请求之前补齐术语的检索列并建好搜索建议索引
+	if err := services.TermService.RefreshSearchColumns(context.Background()); err != nil {
+		return fmt.Errorf("refresh term search columns failed: %w", err)
+	}
+	if err := services.TermService.RebuildSuggestIndex(context.Background()); err != nil {
+		return fmt.Errorf("build term suggest index failed: %w", err)
+	}
 
-	// 4. 初始化服务
-	services := &service.Services{
-		UserService: service.NewUserService(userRepository),
-		TermService: service.NewTermService(termRepository),
+	// 5. 注册就绪检查, 开始退出后 server 检查失败, 负载均衡器不再转发新请求
+	var srv *server.Server
+	checks := health.NewRegistry(cfg.Health.CheckTimeout)
+	checks.Register(
+		health.NewChecker("server", func(context.Context) error {
+			if !srv.Ready() {
+				return errors.New("server is not accepting requests")
+			}
+			return nil
+		}),
+		health.PingChecker("database", db),
+		health.NewChecker("migrations", migrations.CheckVersion),
+	)
+	if cfg.Database.Driver != repository.DriverSQLite {
+		// SQLite 只有一个连接, 就绪检查本身就会占满连接池
+		checks.Register(health.PoolChecker("database_pool", db.DB, cfg.Health.PoolSaturation))
 	}
 
-	// 5. 创建 HTTP 路由
+	// 6. 创建 HTTP 路由, 限流的桶保存在内存中, 只在单个实例内生效
+	rateLimitStore := ratelimit.NewMemoryStore()
 	router := http.NewServeMux()
-	v1.RegisterRoutes(router, services)
+	api.RegisterHealthRoutes(router, checks)
+	api.RegisterMetricsRoutes(router, registry)
+	api.RegisterWellKnownRoutes(router, keys)
+	v1.RegisterRoutes(router, services, keys, middleware.RateLimit(cfg.RateLimit, rateLimitStore), cfg.Account.RequireVerifiedEmail)
 
-	// 6. 添加中间件
-	handler := addGlobalMiddlewares(router)
-	log.Fatal(http.ListenAndServe(":8080", handler))
+	// 7. 添加中间件, 注册后台任务和退出时的清理函数, 然后启动服务直到收到退出信号
+	srv = server.New(cfg.Server, addGlobalMiddlewares(router, cfg, registry))
+	srv.AddWorker("token-cleanup", server.Every(tokenCleanupInterval, services.TokenService.CleanupExpiredTokens))
+	srv.AddWorker("user-token-cleanup", server.Every(tokenCleanupInterval, services.AccountService.CleanupExpiredTokens))
+	srv.AddWorker("password-reset-mail", server.WorkerFunc(services.AccountService.SendPasswordResetMails))
+	srv.AddWorker("rate-limit-sweep", server.Every(rateLimitSweepInterval, rateLimitStore.Sweep))
+	// 索引在启动时已经建好, 第一次重建等待一个间隔
+	srv.AddWorker("term-suggest-refresh", server.EveryAfter(suggestIndexRefreshInterval, services.TermService.RebuildSuggestIndex))
+	// 后台任务在请求处理完成后才停止, 退出前会导出所有请求的 span
+	srv.AddWorker("tracing", tracer)
+	srv.OnShutdown("database", func(context.Context) error { return db.Close() })
+	return srv.Run(context.Background())
 }
 
-func addGlobalMiddlewares(handler http.Handler) http.Handler {
-	// 先应用日志中间件, 记录所有请求
+func addGlobalMiddlewares(handler http.Handler, cfg *config.Config, registry *metrics.Registry) http.Handler {
+	// 指标和追踪中间件必须包在路由外层, 才能读取到匹配的路由模式
+	// 日志中间件不替换请求, 放在两者之间不影响读取路由模式, 放在追踪内层访问日志才带有 trace_id
+	handler = middleware.Metrics(registry)(handler)
 	handler = middleware.Logger(handler)
-	handler = middleware.CORS(handler)
+	handler = middleware.Tracing(handler)
+	// 按请求路径选择跨域规则, 拒绝不允许的源发送的预检请求
+	handler = middleware.CORS(cfg.CORS)(handler)
+	// 根据 Accept-Language 选择响应消息的语言, 中间件返回的错误也需要翻译
+	handler = middleware.Locale(handler)
+	// 请求 ID 在最外层, 内层所有日志都带有 request_id
+	handler = middleware.RequestID(handler)
 	return handler
 }
+
+// newTracer 根据配置创建 Tracer, exporter 为 none 时不创建任何 span
+func newTracer(cfg config.TracingConfig) (*tracing.Tracer, error) {
+	var exporter tracing.Exporter
+	switch cfg.Exporter {
+	case "stdout":
+		exporter = tracing.NewWriterExporter(os.Stdout, cfg.ServiceName)
+	case "file":
+		fileExporter, err := tracing.NewFileExporter(cfg.File, cfg.ServiceName)
+		if err != nil {
+			return nil, err
+		}
+		exporter = fileExporter
+	case "otlp":
+		exporter = tracing.NewOTLPExporter(cfg.Endpoint, cfg.ServiceName, &http.Client{Timeout: 10 * time.Second})
+	}
+	return tracing.NewTracer(exporter, tracing.Options{SampleRatio: cfg.SampleRatio}), nil
+}
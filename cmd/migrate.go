@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"skymates-api/internal/migration"
+	"strconv"
+	"text/tabwriter"
+)
+
+const migrateUsage = "usage: skymates-api migrate up|down|status|to <version>"
+
+// runMigrate 执行 migrate 子命令
+//
+//	migrate up            执行所有尚未执行的迁移
+//	migrate down          回滚最近执行的一个迁移
+//	migrate status        列出所有迁移及其执行状态
+//	migrate to <version>  升级或回滚到指定版本, 0 表示回滚全部迁移
+func runMigrate(ctx context.Context, runner *migration.Runner, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		executed, err := runner.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied %d migration(s), now at version %d\n", len(executed), runner.LatestVersion())
+	case "down":
+		rolledBack, err := runner.Down(ctx)
+		if err != nil {
+			return err
+		}
+		if rolledBack == nil {
+			fmt.Println("no migration to roll back")
+			return nil
+		}
+		fmt.Printf("rolled back %d_%s\n", rolledBack.Version, rolledBack.Name)
+	case "status":
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	case "to":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		executed, err := runner.To(ctx, version)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("executed %d migration(s), now at version %d\n", len(executed), version)
+	default:
+		return errors.New(migrateUsage)
+	}
+	return nil
+}
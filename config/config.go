@@ -1,51 +1,355 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"net"
 	"os"
+	"time"
 
+	"github.com/joho/godotenv"
 	"gopkg.in/yaml.v3"
 )
 
+// DefaultFile 默认的配置文件路径, 文件不存在时只使用默认值, 环境变量和命令行参数
+const DefaultFile = "config/config.yaml"
+
 // Config 应用配置结构
+// 配置按以下顺序逐层覆盖: 默认值 -> YAML 配置文件 -> 环境变量 (.env) -> 命令行参数
 type Config struct {
-	Server struct {
-		Host string `yaml:"host"`
-		Port string `yaml:"port"`
-	} `yaml:"server"`
-
-	Database struct {
-		Host     string `yaml:"host"`
-		Port     string `yaml:"port"`
-		User     string `yaml:"user"`
-		Password string `yaml:"password"`
-		DbName   string `yaml:"dbname"`
-		SSLMode  string `yaml:"sslmode"`
-	} `yaml:"database"`
-
-	JWT struct {
-		Secret string `yaml:"secret"`
-	} `yaml:"jwt"`
-}
-
-// Load 从配置文件加载配置
-func Load() (*Config, error) {
-	// 读取配置文件
-	data, err := os.ReadFile("config/config.yaml")
-	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+	Server    ServerConfig    `yaml:"server"`
+	Database  DatabaseConfig  `yaml:"database"`
+	JWT       JWTConfig       `yaml:"jwt"`
+	CORS      CORSConfig      `yaml:"cors"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Lockout   LockoutConfig   `yaml:"lockout"`
+	Account   AccountConfig   `yaml:"account"`
+	Mail      MailConfig      `yaml:"mail"`
+	Log       LogConfig       `yaml:"log"`
+	Health    HealthConfig    `yaml:"health"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+}
+
+// ServerConfig HTTP 服务配置
+type ServerConfig struct {
+	Host              string        `yaml:"host"`
+	Port              int           `yaml:"port"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`        // 读取整个请求(包括请求体)的超时时间
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"` // 读取请求头的超时时间
+	WriteTimeout      time.Duration `yaml:"write_timeout"`       // 写响应的超时时间
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`        // keep-alive 连接的空闲超时时间
+
+	// 退出时先标记为未就绪并等待 ShutdownDelay, 让负载均衡器摘除实例,
+	// 然后在 ShutdownTimeout 内等待处理中的请求完成
+	ShutdownDelay   time.Duration `yaml:"shutdown_delay"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// Addr 返回 HTTP 服务监听的地址
+func (c ServerConfig) Addr() string {
+	return net.JoinHostPort(c.Host, fmt.Sprint(c.Port))
+}
+
+// DatabaseConfig 数据库配置
+type DatabaseConfig struct {
+	Driver   string `yaml:"driver"` // mysql, postgres 或 sqlite
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	DbName   string `yaml:"dbname"`
+	SSLMode  string `yaml:"sslmode"` // 仅 PostgreSQL 使用
+	Path     string `yaml:"path"`    // 仅 SQLite 使用, 数据库文件路径或 :memory:
+
+	// 连接池配置, SQLite 固定只使用一个连接, 忽略这些配置
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+
+	// MigrateOnStartup 为 true 时服务启动前自动执行所有尚未执行的迁移
+	// 迁移由数据库锁保护, 多个实例同时启动时不会重复执行
+	MigrateOnStartup bool `yaml:"migrate_on_startup"`
+}
+
+// JWTConfig JWT 签名配置
+// Keys 中可以同时存在多个密钥: ActiveKey 对应的密钥用于签发新令牌, 其余密钥只用于验证,
+// 轮换时先加入新密钥并切换 ActiveKey, 等旧令牌全部过期后再移除旧密钥
+type JWTConfig struct {
+	Issuer          string         `yaml:"issuer"`
+	ActiveKey       string         `yaml:"active_key"` // 当前签名密钥的 kid
+	Keys            []JWTKeyConfig `yaml:"keys"`
+	AccessTokenTTL  time.Duration  `yaml:"access_token_ttl"`  // 访问令牌有效期
+	RefreshTokenTTL time.Duration  `yaml:"refresh_token_ttl"` // 刷新令牌有效期, 也是一次登录会话的最长时间
+}
+
+// JWTKeyConfig 单个 JWT 密钥配置, 密钥文件均为 PEM 格式
+type JWTKeyConfig struct {
+	ID             string `yaml:"kid"`
+	Algorithm      string `yaml:"algorithm"`        // EdDSA 或 RS256
+	PrivateKeyFile string `yaml:"private_key_file"` // 签名密钥必须提供私钥
+	PublicKeyFile  string `yaml:"public_key_file"`  // 只用于验证的密钥可以只提供公钥
+}
+
+// CORSConfig 跨域配置, 顶层为默认策略, Groups 为指定路由组的策略
+type CORSConfig struct {
+	CORSPolicy `yaml:",inline"`
+	// Groups 按路径前缀覆盖默认策略, 请求匹配前缀最长的路由组
+	// 路由组的策略是完整的, 不继承默认策略
+	Groups []CORSGroup `yaml:"groups"`
+}
+
+// CORSPolicy 一组跨域规则
+type CORSPolicy struct {
+	// AllowOrigins 允许的源, 格式为 协议://域名[:端口], 不能带路径和结尾的 /
+	// 域名的第一段可以是 *, 匹配任意子域名, 如 https://*.vercel.app
+	// 只有一个 * 时允许任意源, 此时不能开启 AllowCredentials
+	AllowOrigins     []string      `yaml:"allow_origins"`
+	AllowCredentials bool          `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"` // 预检请求结果的缓存时间
+}
+
+// CORSGroup 路由组的跨域策略
+type CORSGroup struct {
+	PathPrefix string `yaml:"path_prefix"` // 如 /.well-known/, 以 / 开头
+	CORSPolicy `yaml:",inline"`
+}
+
+// RateLimitConfig 限流配置, 使用令牌桶算法, 每个路由可以单独配置
+type RateLimitConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// TrustedProxies 可信的反向代理地址 (IP 或 CIDR)
+	// 来自这些地址的请求按 X-Forwarded-For 中最后一个不可信的地址限流, 否则按连接的地址限流
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	// Routes 路由的限流规则, key 为注册路由时使用的模式, 如 "POST /api/v1/users/login"
+	// 配置文件中的规则与默认规则合并, limit 为 0 时不限流
+	Routes map[string]RateLimitRule `yaml:"routes"`
+}
+
+// RateLimitRule 单个路由的限流规则, 桶的容量为 Limit, 在 Period 内从空补充到满
+type RateLimitRule struct {
+	// Key 按什么限流: ip, user (已登录的用户, 未登录时按 ip) 或 api_key (X-API-Key 请求头, 同时按 ip 限流; 没有时只按 ip)
+	Key    string        `yaml:"key"`
+	Limit  int           `yaml:"limit"`
+	Period time.Duration `yaml:"period"`
+}
+
+// LockoutConfig 连续登录失败后锁定账号的配置
+// 连续失败 MaxAttempts 次后锁定 Duration, 之后每多失败一次锁定时长翻倍, 最长 MaxDuration
+type LockoutConfig struct {
+	MaxAttempts int           `yaml:"max_attempts"` // 0 表示不锁定
+	Duration    time.Duration `yaml:"duration"`
+	MaxDuration time.Duration `yaml:"max_duration"`
+}
+
+// AccountConfig 找回密码和邮箱验证配置
+type AccountConfig struct {
+	// AppURL 前端地址, 邮件中的链接为 AppURL/reset-password?token=... 和 AppURL/verify-email?token=...
+	AppURL               string        `yaml:"app_url"`
+	PasswordResetTTL     time.Duration `yaml:"password_reset_ttl"`     // 重置密码链接的有效期
+	EmailVerificationTTL time.Duration `yaml:"email_verification_ttl"` // 验证邮箱链接的有效期
+	// RequireVerifiedEmail 为 true 时只有验证过邮箱的用户才能创建和修改词条
+	RequireVerifiedEmail bool `yaml:"require_verified_email"`
+}
+
+// MailConfig 邮件配置
+type MailConfig struct {
+	Driver string     `yaml:"driver"` // smtp, file 或 memory
+	From   string     `yaml:"from"`   // 发件人, 如 Skymates <no-reply@skymates.local>
+	Dir    string     `yaml:"dir"`    // driver 为 file 时写入 .eml 文件的目录
+	SMTP   SMTPConfig `yaml:"smtp"`
+}
+
+// SMTPConfig SMTP 服务器配置, 服务器支持 STARTTLS 时自动加密
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"` // 为空时不认证
+	Password string `yaml:"password"`
+}
+
+// LogConfig 日志配置
+type LogConfig struct {
+	Level  string `yaml:"level"`  // debug, info, warn 或 error
+	Format string `yaml:"format"` // text 或 json
+}
+
+// HealthConfig 就绪检查配置
+type HealthConfig struct {
+	CheckTimeout time.Duration `yaml:"check_timeout"` // 每个检查的超时时间
+	// PoolSaturation 数据库连接池使用率 (0~1) 达到该值时就绪检查失败
+	PoolSaturation float64 `yaml:"pool_saturation"`
+}
+
+// TracingConfig 分布式追踪配置
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter"`     // none, stdout, file 或 otlp
+	File        string  `yaml:"file"`         // exporter 为 file 时写入的文件, 每行一批 OTLP JSON
+	Endpoint    string  `yaml:"endpoint"`     // exporter 为 otlp 时的 OTLP/HTTP 地址
+	ServiceName string  `yaml:"service_name"` // 导出的 service.name
+	SampleRatio float64 `yaml:"sample_ratio"` // 没有上游 traceparent 的请求的采样比例 (0~1)
+}
+
+// Default 返回默认配置, 默认值适合本地开发
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Host:              "0.0.0.0",
+			Port:              8080,
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      15 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Driver:          "mysql",
+			Host:            "127.0.0.1",
+			SSLMode:         "disable",
+			Path:            "skymates.db",
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 5 * time.Minute,
+		},
+		JWT: JWTConfig{
+			Issuer:          "skymates",
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 30 * 24 * time.Hour,
+		},
+		CORS: CORSConfig{
+			CORSPolicy: CORSPolicy{
+				AllowOrigins:     []string{"http://localhost:3000", "http://127.0.0.1:3000"},
+				AllowCredentials: true,
+				MaxAge:           24 * time.Hour,
+			},
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: true,
+			Routes: map[string]RateLimitRule{
+				"POST /api/v1/users/login":    {Key: "ip", Limit: 10, Period: time.Minute},
+				"POST /api/v1/users/register": {Key: "ip", Limit: 5, Period: time.Hour},
+				"POST /api/v1/users/refresh":  {Key: "ip", Limit: 30, Period: time.Minute},
+				"POST /api/v1/terms":          {Key: "user", Limit: 30, Period: time.Hour},
+				"PUT /api/v1/terms/{id}":      {Key: "user", Limit: 60, Period: time.Hour},
+
+				"POST /api/v1/users/password-reset/request": {Key: "ip", Limit: 5, Period: time.Hour},
+				"POST /api/v1/users/password-reset/confirm": {Key: "ip", Limit: 10, Period: time.Hour},
+				"POST /api/v1/users/verify-email/request":   {Key: "user", Limit: 5, Period: time.Hour},
+				"POST /api/v1/users/verify-email/confirm":   {Key: "ip", Limit: 10, Period: time.Hour},
+				"PATCH /api/v1/users/me":                    {Key: "user", Limit: 30, Period: time.Hour},
+				"PUT /api/v1/users/me/password":             {Key: "user", Limit: 10, Period: time.Hour},
+			},
+		},
+		Lockout: LockoutConfig{
+			MaxAttempts: 5,
+			Duration:    time.Minute,
+			MaxDuration: time.Hour,
+		},
+		Account: AccountConfig{
+			AppURL:               "http://localhost:3000",
+			PasswordResetTTL:     time.Hour,
+			EmailVerificationTTL: 48 * time.Hour,
+		},
+		Mail: MailConfig{
+			Driver: "file",
+			From:   "Skymates <no-reply@skymates.local>",
+			Dir:    "mail",
+			SMTP: SMTPConfig{
+				Host: "localhost",
+				Port: 1025,
+			},
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "text",
+		},
+		Health: HealthConfig{
+			CheckTimeout:   2 * time.Second,
+			PoolSaturation: 0.9,
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			File:        "traces.jsonl",
+			Endpoint:    "http://localhost:4318/v1/traces",
+			ServiceName: "skymates-api",
+			SampleRatio: 1,
+		},
+	}
+}
+
+// Load 按 默认值 -> 配置文件 -> 环境变量 -> 命令行参数 的顺序加载配置并校验
+// args 为不包含程序名的命令行参数, 返回解析参数后剩余的参数 (例如 migrate 子命令)
+// 配置文件路径由 -config 参数或 CONFIG_FILE 环境变量指定, 默认为 DefaultFile;
+// 显式指定的配置文件不存在时返回错误, 默认配置文件不存在时忽略
+func Load(args []string) (*Config, []string, error) {
+	cfg := Default()
+
+	// 1. 解析命令行参数, 参数最后才应用, 但需要先知道配置文件路径
+	flags := newFlagSet(cfg)
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
 	}
 
-	// 解析YAML
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	// 2. 加载 .env 文件, 已经存在的环境变量不会被覆盖
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("load .env file: %w", err)
 	}
 
-	// 从环境变量覆盖配置（如果存在）
-	if jwtSecret := os.Getenv("JWT_SECRET"); jwtSecret != "" {
-		cfg.JWT.Secret = jwtSecret
+	// 3. 读取配置文件
+	path, explicit := DefaultFile, false
+	if env := os.Getenv("CONFIG_FILE"); env != "" {
+		path, explicit = env, true
+	}
+	if flags.configFile != "" {
+		path, explicit = flags.configFile, true
+	}
+	if err := loadFile(cfg, path, explicit); err != nil {
+		return nil, nil, err
+	}
+
+	// 4. 环境变量覆盖配置文件
+	if err := applyEnv(cfg, os.LookupEnv); err != nil {
+		return nil, nil, err
+	}
+
+	// 5. 命令行参数覆盖环境变量
+	if err := flags.apply(cfg); err != nil {
+		return nil, nil, err
+	}
+
+	// 未指定数据库端口时使用驱动的默认端口
+	if cfg.Database.Port == 0 {
+		switch cfg.Database.Driver {
+		case "mysql":
+			cfg.Database.Port = 3306
+		case "postgres":
+			cfg.Database.Port = 5432
+		}
 	}
 
-	return &cfg, nil
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return cfg, flags.Args(), nil
+}
+
+// loadFile 将 YAML 配置文件合并到 cfg, 文件中没有出现的字段保持原值
+func loadFile(cfg *Config, path string, explicit bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) && !explicit {
+			return nil
+		}
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	// 拒绝未知字段, 避免拼错的配置项被静默忽略
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
 }
@@ -0,0 +1,333 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv 清除所有绑定的环境变量, 测试结束后恢复原值
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range append([]string{"CONFIG_FILE"}, envNames()...) {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+}
+
+func envNames() []string {
+	names := make([]string, len(envBindings))
+	for i, binding := range envBindings {
+		names[i] = binding.name
+	}
+	return names
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+	path := writeFile(t, `
+server:
+  port: 1000
+  read_timeout: 20s
+database:
+  driver: sqlite
+  path: from-file.db
+log:
+  level: debug
+  format: json
+`)
+	t.Setenv("SERVER_PORT", "2000")
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("DB_PATH", "from-env.db")
+
+	cfg, rest, err := Load([]string{"-config", path, "-port", "3000", "-db-path", "from-flag.db", "migrate", "up"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		field     string
+		got, want interface{}
+	}{
+		{"server.host (default)", cfg.Server.Host, "0.0.0.0"},
+		{"server.read_timeout (file)", cfg.Server.ReadTimeout, 20 * time.Second},
+		{"server.write_timeout (default, not in file)", cfg.Server.WriteTimeout, 15 * time.Second},
+		{"database.driver (file)", cfg.Database.Driver, "sqlite"},
+		{"log.format (file)", cfg.Log.Format, "json"},
+		{"log.level (env over file)", cfg.Log.Level, "warn"},
+		{"server.port (flag over env and file)", cfg.Server.Port, 3000},
+		{"database.path (flag over env and file)", cfg.Database.Path, "from-flag.db"},
+	} {
+		if c.got != c.want {
+			t.Errorf("%s = %v, want %v", c.field, c.got, c.want)
+		}
+	}
+	if !slices.Equal(rest, []string{"migrate", "up"}) {
+		t.Errorf("remaining args = %v, want [migrate up]", rest)
+	}
+}
+
+func TestLoadFlagsApplyInOrder(t *testing.T) {
+	clearEnv(t)
+	cfg, _, err := Load([]string{"-db-driver", "sqlite", "-log-level", "debug", "-log-level", "error", "-migrate-on-startup"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Log.Level != "error" || !cfg.Database.MigrateOnStartup {
+		t.Fatalf("log.level = %q, migrate_on_startup = %v; want the last -log-level and true", cfg.Log.Level, cfg.Database.MigrateOnStartup)
+	}
+}
+
+func TestLoadConfigFilePath(t *testing.T) {
+	clearEnv(t)
+	envFile := writeFile(t, "database:\n  driver: sqlite\n  path: env.db\n")
+	flagFile := writeFile(t, "database:\n  driver: sqlite\n  path: flag.db\n")
+
+	t.Setenv("CONFIG_FILE", envFile)
+	cfg, _, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.Path != "env.db" {
+		t.Fatalf("database.path = %q, want the file from CONFIG_FILE", cfg.Database.Path)
+	}
+	cfg, _, err = Load([]string{"-config", flagFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.Path != "flag.db" {
+		t.Fatalf("database.path = %q, want the file from -config", cfg.Database.Path)
+	}
+
+	// 显式指定的文件必须存在, 默认文件不存在时忽略
+	if _, _, err := Load([]string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}); err == nil || !strings.Contains(err.Error(), "read config file") {
+		t.Fatalf("err = %v, want a missing explicit file to fail", err)
+	}
+	os.Unsetenv("CONFIG_FILE")
+	if _, _, err := Load([]string{"-db-driver", "sqlite"}); err != nil {
+		t.Fatalf("load without a config file: %v", err)
+	}
+}
+
+func TestLoadDefaultDatabasePort(t *testing.T) {
+	clearEnv(t)
+	for driver, want := range map[string]int{"mysql": 3306, "postgres": 5432} {
+		t.Setenv("DB_USER", "skymates")
+		t.Setenv("DB_NAME", "skymates")
+		cfg, _, err := Load([]string{"-db-driver", driver})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Database.Port != want {
+			t.Errorf("%s port = %d, want %d", driver, cfg.Database.Port, want)
+		}
+	}
+	t.Setenv("DB_PORT", "13306")
+	cfg, _, err := Load([]string{"-db-driver", "mysql"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.Port != 13306 {
+		t.Errorf("port = %d, want the configured 13306", cfg.Database.Port)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		file    string
+		env     map[string]string
+		args    []string
+		wantErr string
+	}{
+		{name: "unknown yaml key", file: "server:\n  prot: 80\n", wantErr: "field prot not found"},
+		{name: "invalid yaml value", file: "server:\n  port: eighty\n", wantErr: "parse config file"},
+		{name: "invalid env integer", env: map[string]string{"SERVER_PORT": "eighty"}, wantErr: `environment variable SERVER_PORT: invalid integer "eighty"`},
+		{name: "invalid env duration", env: map[string]string{"SERVER_READ_TIMEOUT": "10"}, wantErr: "environment variable SERVER_READ_TIMEOUT: invalid duration"},
+		{name: "invalid env boolean", env: map[string]string{"RATE_LIMIT_ENABLED": "maybe"}, wantErr: "environment variable RATE_LIMIT_ENABLED: invalid boolean"},
+		{name: "invalid env number", env: map[string]string{"TRACING_SAMPLE_RATIO": "half"}, wantErr: "environment variable TRACING_SAMPLE_RATIO: invalid number"},
+		{name: "invalid flag", args: []string{"-port", "eighty"}, wantErr: `flag -port: invalid integer "eighty"`},
+		{name: "unknown flag", args: []string{"-prot", "80"}, wantErr: "flag provided but not defined"},
+		{name: "validation", args: []string{"-log-level", "loud"}, wantErr: "log.level: must be one of"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("DB_DRIVER", "sqlite")
+			for name, value := range c.env {
+				t.Setenv(name, value)
+			}
+			args := c.args
+			if c.file != "" {
+				args = append([]string{"-config", writeFile(t, c.file)}, args...)
+			}
+			if _, _, err := Load(args); err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("err = %v, want it to contain %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyEnvSplitsLists(t *testing.T) {
+	cfg := Default()
+	env := map[string]string{
+		"CORS_ALLOW_ORIGINS":         " https://a.example.com, ,https://b.example.com ",
+		"RATE_LIMIT_TRUSTED_PROXIES": "10.0.0.0/8",
+	}
+	if err := applyEnv(cfg, func(name string) (string, bool) { v, ok := env[name]; return v, ok }); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(cfg.CORS.AllowOrigins, []string{"https://a.example.com", "https://b.example.com"}) {
+		t.Fatalf("allow_origins = %q", cfg.CORS.AllowOrigins)
+	}
+	if !slices.Equal(cfg.RateLimit.TrustedProxies, []string{"10.0.0.0/8"}) {
+		t.Fatalf("trusted_proxies = %q", cfg.RateLimit.TrustedProxies)
+	}
+}
+
+// validConfig 返回能通过校验的配置, 默认的 mysql 驱动需要用户名和数据库名
+func validConfig() *Config {
+	cfg := Default()
+	cfg.Database.Driver = "sqlite"
+	return cfg
+}
+
+func TestValidateDefaults(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Fatalf("valid config: %v", err)
+	}
+	cfg := Default()
+	cfg.Database.Port, cfg.Database.User, cfg.Database.DbName = 3306, "skymates", "skymates"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("default mysql config with credentials: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		modify func(c *Config)
+		want   string
+	}{
+		{"port", func(c *Config) { c.Server.Port = 70000 }, "server.port: must be between 1 and 65535, got 70000"},
+		{"negative timeout", func(c *Config) { c.Server.ReadTimeout = -time.Second }, "server.read_timeout: must not be negative"},
+		{"shutdown timeout", func(c *Config) { c.Server.ShutdownTimeout = 0 }, "server.shutdown_timeout: must be positive"},
+		{"driver", func(c *Config) { c.Database.Driver = "oracle" }, `database.driver: must be one of mysql, postgres or sqlite, got "oracle"`},
+		{"mysql host", func(c *Config) { c.Database.Driver, c.Database.Host = "mysql", "" }, "database.host: is required for driver mysql"},
+		{"mysql port", func(c *Config) { c.Database.Driver = "mysql" }, "database.port: must be between 1 and 65535 for driver mysql"},
+		{"postgres user", func(c *Config) { c.Database.Driver = "postgres" }, "database.user: is required for driver postgres"},
+		{"postgres dbname", func(c *Config) { c.Database.Driver = "postgres" }, "database.dbname: is required for driver postgres"},
+		{"sqlite path", func(c *Config) { c.Database.Path = "" }, "database.path: is required for driver sqlite"},
+		{"max open conns", func(c *Config) { c.Database.MaxOpenConns = 0 }, "database.max_open_conns: must be at least 1"},
+		{"max idle conns", func(c *Config) { c.Database.MaxIdleConns = 26 }, "database.max_idle_conns: must be between 0 and max_open_conns (25), got 26"},
+		{"conn max lifetime", func(c *Config) { c.Database.ConnMaxLifetime = -1 }, "database.conn_max_lifetime: must not be negative"},
+		{"issuer", func(c *Config) { c.JWT.Issuer = "" }, "jwt.issuer: is required"},
+		{"access token ttl", func(c *Config) { c.JWT.AccessTokenTTL = 0 }, "jwt.access_token_ttl: must be positive"},
+		{"refresh token ttl", func(c *Config) { c.JWT.RefreshTokenTTL = c.JWT.AccessTokenTTL }, "jwt.refresh_token_ttl: must be longer than access_token_ttl (15m0s)"},
+		{"jwt kid", func(c *Config) { c.JWT.Keys = []JWTKeyConfig{{Algorithm: "EdDSA", PrivateKeyFile: "k.pem"}} }, "jwt.keys[0].kid: is required"},
+		{"jwt duplicate kid", func(c *Config) {
+			c.JWT.ActiveKey = "a"
+			c.JWT.Keys = []JWTKeyConfig{{ID: "a", Algorithm: "EdDSA", PrivateKeyFile: "a.pem"}, {ID: "a", Algorithm: "EdDSA", PublicKeyFile: "b.pem"}}
+		}, `jwt.keys[1].kid: duplicate kid "a"`},
+		{"jwt algorithm", func(c *Config) {
+			c.JWT.ActiveKey = "a"
+			c.JWT.Keys = []JWTKeyConfig{{ID: "a", Algorithm: "HS256", PrivateKeyFile: "a.pem"}}
+		}, `jwt.keys[0].algorithm: must be EdDSA or RS256, got "HS256"`},
+		{"jwt key file", func(c *Config) {
+			c.JWT.ActiveKey = "a"
+			c.JWT.Keys = []JWTKeyConfig{{ID: "a", Algorithm: "EdDSA"}}
+		}, "jwt.keys[0]: one of private_key_file or public_key_file is required"},
+		{"jwt active key missing", func(c *Config) { c.JWT.Keys = []JWTKeyConfig{{ID: "a", Algorithm: "EdDSA", PrivateKeyFile: "a.pem"}} }, "jwt.active_key: is required when jwt.keys is set"},
+		{"jwt active key unknown", func(c *Config) {
+			c.JWT.ActiveKey = "b"
+			c.JWT.Keys = []JWTKeyConfig{{ID: "a", Algorithm: "EdDSA", PrivateKeyFile: "a.pem"}}
+		}, `jwt.active_key: "b" does not match any kid in jwt.keys`},
+		{"jwt active key public only", func(c *Config) {
+			c.JWT.ActiveKey = "a"
+			c.JWT.Keys = []JWTKeyConfig{{ID: "a", Algorithm: "EdDSA", PublicKeyFile: "a.pem"}}
+		}, `jwt.active_key: key "a" has no private_key_file and cannot sign tokens`},
+		{"cors wildcard with others", func(c *Config) { c.CORS.AllowOrigins = []string{"*", "https://a.example.com"} }, "cors.allow_origins: * must be the only origin"},
+		{"cors wildcard with credentials", func(c *Config) { c.CORS.AllowOrigins = []string{"*"} }, "cors.allow_origins: * cannot be used together with allow_credentials"},
+		{"cors origin wildcard position", func(c *Config) { c.CORS.AllowOrigins = []string{"https://a.*.example.com"} }, "cors.allow_origins[0]: \"https://a.*.example.com\" may only use * as the first label"},
+		{"cors origin scheme", func(c *Config) { c.CORS.AllowOrigins = []string{"example.com"} }, `cors.allow_origins[0]: "example.com" must look like https://example.com[:port]`},
+		{"cors origin path", func(c *Config) { c.CORS.AllowOrigins = []string{"https://example.com/"} }, `cors.allow_origins[0]: "https://example.com/" must not contain a path`},
+		{"cors max age", func(c *Config) { c.CORS.MaxAge = -1 }, "cors.max_age: must not be negative"},
+		{"cors group prefix", func(c *Config) { c.CORS.Groups = []CORSGroup{{PathPrefix: "api"}} }, `cors.groups[0].path_prefix: "api" must start with /`},
+		{"cors group duplicate prefix", func(c *Config) { c.CORS.Groups = []CORSGroup{{PathPrefix: "/api"}, {PathPrefix: "/api"}} }, `cors.groups[1].path_prefix: "/api" is used by another group`},
+		{"cors group policy", func(c *Config) {
+			c.CORS.Groups = []CORSGroup{{PathPrefix: "/api", CORSPolicy: CORSPolicy{AllowOrigins: []string{"ftp://a.example.com"}}}}
+		}, `cors.groups[0].allow_origins[0]: "ftp://a.example.com" must look like`},
+		{"trusted proxy", func(c *Config) { c.RateLimit.TrustedProxies = []string{"10.0.0.0/8", "proxy.local"} }, `rate_limit.trusted_proxies[1]: "proxy.local" must be an IP address or CIDR`},
+		{"rate limit negative", func(c *Config) { c.RateLimit.Routes = map[string]RateLimitRule{"GET /": {Limit: -1}} }, `rate_limit.routes["GET /"].limit: must not be negative`},
+		{"rate limit key", func(c *Config) {
+			c.RateLimit.Routes = map[string]RateLimitRule{"GET /": {Key: "session", Limit: 1, Period: time.Second}}
+		}, `rate_limit.routes["GET /"].key: must be one of ip, user or api_key, got "session"`},
+		{"rate limit period", func(c *Config) { c.RateLimit.Routes = map[string]RateLimitRule{"GET /": {Key: "ip", Limit: 1}} }, `rate_limit.routes["GET /"].period: must be positive`},
+		{"lockout attempts", func(c *Config) { c.Lockout.MaxAttempts = -1 }, "lockout.max_attempts: must not be negative"},
+		{"lockout duration", func(c *Config) { c.Lockout.Duration = 0 }, "lockout.duration: must be positive"},
+		{"lockout max duration", func(c *Config) { c.Lockout.MaxDuration = time.Second }, "lockout.max_duration: must be at least lockout.duration (1m0s)"},
+		{"app url", func(c *Config) { c.Account.AppURL = "skymates.app" }, `account.app_url: "skymates.app" must be an http(s) URL`},
+		{"password reset ttl", func(c *Config) { c.Account.PasswordResetTTL = 0 }, "account.password_reset_ttl: must be positive"},
+		{"email verification ttl", func(c *Config) { c.Account.EmailVerificationTTL = 0 }, "account.email_verification_ttl: must be positive"},
+		{"mail from", func(c *Config) { c.Mail.From = "skymates" }, `mail.from: "skymates" must be an email address`},
+		{"mail driver", func(c *Config) { c.Mail.Driver = "sendmail" }, `mail.driver: must be one of smtp, file or memory, got "sendmail"`},
+		{"mail dir", func(c *Config) { c.Mail.Dir = "" }, "mail.dir: is required when mail.driver is file"},
+		{"smtp host", func(c *Config) { c.Mail.Driver, c.Mail.SMTP.Host = "smtp", "" }, "mail.smtp.host: is required when mail.driver is smtp"},
+		{"smtp port", func(c *Config) { c.Mail.Driver, c.Mail.SMTP.Port = "smtp", 0 }, "mail.smtp.port: must be between 1 and 65535, got 0"},
+		{"log level", func(c *Config) { c.Log.Level = "loud" }, `log.level: must be one of debug, info, warn or error, got "loud"`},
+		{"log format", func(c *Config) { c.Log.Format = "xml" }, `log.format: must be text or json, got "xml"`},
+		{"health timeout", func(c *Config) { c.Health.CheckTimeout = 0 }, "health.check_timeout: must be positive"},
+		{"pool saturation", func(c *Config) { c.Health.PoolSaturation = 1.5 }, "health.pool_saturation: must be greater than 0 and at most 1, got 1.5"},
+		{"tracing exporter", func(c *Config) { c.Tracing.Exporter = "zipkin" }, `tracing.exporter: must be one of none, stdout, file or otlp, got "zipkin"`},
+		{"tracing file", func(c *Config) { c.Tracing.Exporter, c.Tracing.File = "file", "" }, "tracing.file: is required when tracing.exporter is file"},
+		{"tracing endpoint", func(c *Config) { c.Tracing.Exporter, c.Tracing.Endpoint = "otlp", "localhost:4318" }, `tracing.endpoint: "localhost:4318" must be an http(s) URL`},
+		{"service name", func(c *Config) { c.Tracing.ServiceName = "" }, "tracing.service_name: must not be empty"},
+		{"sample ratio", func(c *Config) { c.Tracing.SampleRatio = -0.5 }, "tracing.sample_ratio: must be between 0 and 1, got -0.5"},
+	} {
+		cfg := validConfig()
+		c.modify(cfg)
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: err = %v, want it to contain %q", c.name, err, c.want)
+		}
+	}
+}
+
+func TestValidateAllowsDisabledRules(t *testing.T) {
+	cfg := validConfig()
+	// limit 为 0 的规则只用于关闭默认规则, 不检查其他字段
+	cfg.RateLimit.Routes["POST /api/v1/terms"] = RateLimitRule{}
+	cfg.Lockout = LockoutConfig{}
+	cfg.CORS.AllowOrigins = []string{"https://*.vercel.app", "http://localhost:3000"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("valid config: %v", err)
+	}
+}
+
+func TestValidateReportsAllProblemsSorted(t *testing.T) {
+	cfg := validConfig()
+	cfg.Log.Level = "loud"
+	cfg.Server.Port = 0
+	cfg.Database.MaxOpenConns = 0
+	cfg.Database.MaxIdleConns = 0
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	want := `invalid configuration:
+  database.max_open_conns: must be at least 1, got 0
+  log.level: must be one of debug, info, warn or error, got "loud"
+  server.port: must be between 1 and 65535, got 0`
+	if err.Error() != want {
+		t.Fatalf("err = %q, want %q", err, want)
+	}
+}
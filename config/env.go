@@ -0,0 +1,142 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envBinding 将一个环境变量绑定到配置字段
+type envBinding struct {
+	name  string
+	apply func(cfg *Config, value string) error
+}
+
+// envBindings 支持的环境变量
+// 数据库相关的变量沿用 .env 中的 DB_ 前缀, 方便把密码等敏感信息放在配置文件之外
+var envBindings = []envBinding{
+	{"SERVER_HOST", func(c *Config, v string) error { c.Server.Host = v; return nil }},
+	{"SERVER_PORT", func(c *Config, v string) error { return parseInt(v, &c.Server.Port) }},
+	{"SERVER_READ_TIMEOUT", func(c *Config, v string) error { return parseDuration(v, &c.Server.ReadTimeout) }},
+	{"SERVER_READ_HEADER_TIMEOUT", func(c *Config, v string) error { return parseDuration(v, &c.Server.ReadHeaderTimeout) }},
+	{"SERVER_WRITE_TIMEOUT", func(c *Config, v string) error { return parseDuration(v, &c.Server.WriteTimeout) }},
+	{"SERVER_IDLE_TIMEOUT", func(c *Config, v string) error { return parseDuration(v, &c.Server.IdleTimeout) }},
+	{"SERVER_SHUTDOWN_DELAY", func(c *Config, v string) error { return parseDuration(v, &c.Server.ShutdownDelay) }},
+	{"SERVER_SHUTDOWN_TIMEOUT", func(c *Config, v string) error { return parseDuration(v, &c.Server.ShutdownTimeout) }},
+
+	{"DB_DRIVER", func(c *Config, v string) error { c.Database.Driver = v; return nil }},
+	{"DB_HOST", func(c *Config, v string) error { c.Database.Host = v; return nil }},
+	{"DB_PORT", func(c *Config, v string) error { return parseInt(v, &c.Database.Port) }},
+	{"DB_USER", func(c *Config, v string) error { c.Database.User = v; return nil }},
+	{"DB_PASSWORD", func(c *Config, v string) error { c.Database.Password = v; return nil }},
+	{"DB_NAME", func(c *Config, v string) error { c.Database.DbName = v; return nil }},
+	{"DB_SSLMODE", func(c *Config, v string) error { c.Database.SSLMode = v; return nil }},
+	{"DB_PATH", func(c *Config, v string) error { c.Database.Path = v; return nil }},
+	{"DB_MAX_OPEN_CONNS", func(c *Config, v string) error { return parseInt(v, &c.Database.MaxOpenConns) }},
+	{"DB_MAX_IDLE_CONNS", func(c *Config, v string) error { return parseInt(v, &c.Database.MaxIdleConns) }},
+	{"DB_CONN_MAX_LIFETIME", func(c *Config, v string) error { return parseDuration(v, &c.Database.ConnMaxLifetime) }},
+	{"DB_MIGRATE_ON_STARTUP", func(c *Config, v string) error { return parseBool(v, &c.Database.MigrateOnStartup) }},
+
+	{"JWT_ISSUER", func(c *Config, v string) error { c.JWT.Issuer = v; return nil }},
+	{"JWT_ACTIVE_KEY", func(c *Config, v string) error { c.JWT.ActiveKey = v; return nil }},
+	{"JWT_ACCESS_TOKEN_TTL", func(c *Config, v string) error { return parseDuration(v, &c.JWT.AccessTokenTTL) }},
+	{"JWT_REFRESH_TOKEN_TTL", func(c *Config, v string) error { return parseDuration(v, &c.JWT.RefreshTokenTTL) }},
+
+	{"CORS_ALLOW_ORIGINS", func(c *Config, v string) error { c.CORS.AllowOrigins = splitList(v); return nil }},
+	{"CORS_ALLOW_CREDENTIALS", func(c *Config, v string) error { return parseBool(v, &c.CORS.AllowCredentials) }},
+	{"CORS_MAX_AGE", func(c *Config, v string) error { return parseDuration(v, &c.CORS.MaxAge) }},
+
+	{"RATE_LIMIT_ENABLED", func(c *Config, v string) error { return parseBool(v, &c.RateLimit.Enabled) }},
+	{"RATE_LIMIT_TRUSTED_PROXIES", func(c *Config, v string) error { c.RateLimit.TrustedProxies = splitList(v); return nil }},
+
+	{"LOCKOUT_MAX_ATTEMPTS", func(c *Config, v string) error { return parseInt(v, &c.Lockout.MaxAttempts) }},
+	{"LOCKOUT_DURATION", func(c *Config, v string) error { return parseDuration(v, &c.Lockout.Duration) }},
+	{"LOCKOUT_MAX_DURATION", func(c *Config, v string) error { return parseDuration(v, &c.Lockout.MaxDuration) }},
+
+	{"ACCOUNT_APP_URL", func(c *Config, v string) error { c.Account.AppURL = v; return nil }},
+	{"ACCOUNT_PASSWORD_RESET_TTL", func(c *Config, v string) error { return parseDuration(v, &c.Account.PasswordResetTTL) }},
+	{"ACCOUNT_EMAIL_VERIFICATION_TTL", func(c *Config, v string) error { return parseDuration(v, &c.Account.EmailVerificationTTL) }},
+	{"ACCOUNT_REQUIRE_VERIFIED_EMAIL", func(c *Config, v string) error { return parseBool(v, &c.Account.RequireVerifiedEmail) }},
+
+	{"MAIL_DRIVER", func(c *Config, v string) error { c.Mail.Driver = v; return nil }},
+	{"MAIL_FROM", func(c *Config, v string) error { c.Mail.From = v; return nil }},
+	{"MAIL_DIR", func(c *Config, v string) error { c.Mail.Dir = v; return nil }},
+	{"SMTP_HOST", func(c *Config, v string) error { c.Mail.SMTP.Host = v; return nil }},
+	{"SMTP_PORT", func(c *Config, v string) error { return parseInt(v, &c.Mail.SMTP.Port) }},
+	{"SMTP_USERNAME", func(c *Config, v string) error { c.Mail.SMTP.Username = v; return nil }},
+	{"SMTP_PASSWORD", func(c *Config, v string) error { c.Mail.SMTP.Password = v; return nil }},
+
+	{"LOG_LEVEL", func(c *Config, v string) error { c.Log.Level = v; return nil }},
+	{"LOG_FORMAT", func(c *Config, v string) error { c.Log.Format = v; return nil }},
+
+	{"HEALTH_CHECK_TIMEOUT", func(c *Config, v string) error { return parseDuration(v, &c.Health.CheckTimeout) }},
+	{"HEALTH_POOL_SATURATION", func(c *Config, v string) error { return parseFloat(v, &c.Health.PoolSaturation) }},
+
+	{"TRACING_EXPORTER", func(c *Config, v string) error { c.Tracing.Exporter = v; return nil }},
+	{"TRACING_FILE", func(c *Config, v string) error { c.Tracing.File = v; return nil }},
+	{"TRACING_ENDPOINT", func(c *Config, v string) error { c.Tracing.Endpoint = v; return nil }},
+	{"TRACING_SERVICE_NAME", func(c *Config, v string) error { c.Tracing.ServiceName = v; return nil }},
+	{"TRACING_SAMPLE_RATIO", func(c *Config, v string) error { return parseFloat(v, &c.Tracing.SampleRatio) }},
+}
+
+// applyEnv 使用已设置的环境变量覆盖配置, lookup 通常为 os.LookupEnv
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) error {
+	for _, binding := range envBindings {
+		value, ok := lookup(binding.name)
+		if !ok {
+			continue
+		}
+		if err := binding.apply(cfg, value); err != nil {
+			return fmt.Errorf("environment variable %s: %w", binding.name, err)
+		}
+	}
+	return nil
+}
+
+func parseInt(value string, target *int) error {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("invalid integer %q", value)
+	}
+	*target = n
+	return nil
+}
+
+func parseFloat(value string, target *float64) error {
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", value)
+	}
+	*target = f
+	return nil
+}
+
+func parseBool(value string, target *bool) error {
+	b, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("invalid boolean %q", value)
+	}
+	*target = b
+	return nil
+}
+
+func parseDuration(value string, target *time.Duration) error {
+	d, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("invalid duration %q, expected a value like 30s or 5m", value)
+	}
+	*target = d
+	return nil
+}
+
+// splitList 解析逗号分隔的列表, 忽略空白项
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
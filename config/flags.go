@@ -0,0 +1,77 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// flagSet 解析命令行参数
+// 参数在解析时只记录下来, 等配置文件和环境变量加载后再按出现的顺序应用, 保证命令行参数优先级最高
+type flagSet struct {
+	*flag.FlagSet
+	configFile string
+	pending    []func(cfg *Config) error
+}
+
+func newFlagSet(defaults *Config) *flagSet {
+	f := &flagSet{FlagSet: flag.NewFlagSet("skymates", flag.ContinueOnError)}
+	f.SetOutput(os.Stderr)
+	f.Usage = func() {
+		fmt.Fprintln(f.Output(), "Usage: skymates [flags] [migrate up|down|status|to <version>]")
+		f.PrintDefaults()
+	}
+
+	f.StringVar(&f.configFile, "config", "", "config file path (default "+DefaultFile+", env CONFIG_FILE)")
+	f.bind("host", fmt.Sprintf("server listen host (default %q, env SERVER_HOST)", defaults.Server.Host),
+		func(c *Config, v string) error { c.Server.Host = v; return nil })
+	f.bind("port", fmt.Sprintf("server listen port (default %d, env SERVER_PORT)", defaults.Server.Port),
+		func(c *Config, v string) error { return parseInt(v, &c.Server.Port) })
+	f.bind("db-driver", fmt.Sprintf("database driver: mysql, postgres or sqlite (default %q, env DB_DRIVER)", defaults.Database.Driver),
+		func(c *Config, v string) error { c.Database.Driver = v; return nil })
+	f.bind("db-path", fmt.Sprintf("sqlite database file (default %q, env DB_PATH)", defaults.Database.Path),
+		func(c *Config, v string) error { c.Database.Path = v; return nil })
+	f.bindBool("migrate-on-startup", "apply pending migrations before serving (env DB_MIGRATE_ON_STARTUP)",
+		func(c *Config, v string) error { return parseBool(v, &c.Database.MigrateOnStartup) })
+	f.bind("cors-allow-origins", "comma separated list of allowed CORS origins (env CORS_ALLOW_ORIGINS)",
+		func(c *Config, v string) error { c.CORS.AllowOrigins = splitList(v); return nil })
+	f.bind("log-level", fmt.Sprintf("log level: debug, info, warn or error (default %q, env LOG_LEVEL)", defaults.Log.Level),
+		func(c *Config, v string) error { c.Log.Level = v; return nil })
+	f.bind("log-format", fmt.Sprintf("log format: text or json (default %q, env LOG_FORMAT)", defaults.Log.Format),
+		func(c *Config, v string) error { c.Log.Format = v; return nil })
+	f.bind("tracing-exporter", fmt.Sprintf("trace exporter: none, stdout, file or otlp (default %q, env TRACING_EXPORTER)", defaults.Tracing.Exporter),
+		func(c *Config, v string) error { c.Tracing.Exporter = v; return nil })
+	return f
+}
+
+// bind 注册一个覆盖配置字段的命令行参数
+func (f *flagSet) bind(name, usage string, apply func(cfg *Config, value string) error) {
+	f.Func(name, usage, f.record(name, apply))
+}
+
+// bindBool 注册一个布尔参数, 可以只写 -name, 也可以写 -name=false
+func (f *flagSet) bindBool(name, usage string, apply func(cfg *Config, value string) error) {
+	f.BoolFunc(name, usage, f.record(name, apply))
+}
+
+func (f *flagSet) record(name string, apply func(cfg *Config, value string) error) func(string) error {
+	return func(value string) error {
+		f.pending = append(f.pending, func(cfg *Config) error {
+			if err := apply(cfg, value); err != nil {
+				return fmt.Errorf("flag -%s: %w", name, err)
+			}
+			return nil
+		})
+		return nil
+	}
+}
+
+// apply 按出现的顺序应用命令行参数
+func (f *flagSet) apply(cfg *Config) error {
+	for _, apply := range f.pending {
+		if err := apply(cfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Validate 校验配置, 一次返回所有错误, 每条错误以配置字段的 YAML 路径开头
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(field, format string, args ...interface{}) {
+		problems = append(problems, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	// server
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		add("server.port", "must be between 1 and 65535, got %d", c.Server.Port)
+	}
+	for field, value := range map[string]int64{
+		"server.read_timeout":        int64(c.Server.ReadTimeout),
+		"server.read_header_timeout": int64(c.Server.ReadHeaderTimeout),
+		"server.write_timeout":       int64(c.Server.WriteTimeout),
+		"server.idle_timeout":        int64(c.Server.IdleTimeout),
+		"server.shutdown_delay":      int64(c.Server.ShutdownDelay),
+	} {
+		if value < 0 {
+			add(field, "must not be negative")
+		}
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		add("server.shutdown_timeout", "must be positive")
+	}
+
+	// database
+	db := c.Database
+	switch db.Driver {
+	case "mysql", "postgres":
+		if db.Host == "" {
+			add("database.host", "is required for driver %s (env DB_HOST)", db.Driver)
+		}
+		if db.Port < 1 || db.Port > 65535 {
+			add("database.port", "must be between 1 and 65535 for driver %s (env DB_PORT), got %d", db.Driver, db.Port)
+		}
+		if db.User == "" {
+			add("database.user", "is required for driver %s (env DB_USER)", db.Driver)
+		}
+		if db.DbName == "" {
+			add("database.dbname", "is required for driver %s (env DB_NAME)", db.Driver)
+		}
+	case "sqlite":
+		if db.Path == "" {
+			add("database.path", "is required for driver sqlite (env DB_PATH)")
+		}
+	default:
+		add("database.driver", "must be one of mysql, postgres or sqlite, got %q", db.Driver)
+	}
+	if db.MaxOpenConns < 1 {
+		add("database.max_open_conns", "must be at least 1, got %d", db.MaxOpenConns)
+	}
+	if db.MaxIdleConns < 0 || db.MaxIdleConns > db.MaxOpenConns {
+		add("database.max_idle_conns", "must be between 0 and max_open_conns (%d), got %d", db.MaxOpenConns, db.MaxIdleConns)
+	}
+	if db.ConnMaxLifetime < 0 {
+		add("database.conn_max_lifetime", "must not be negative")
+	}
+
+	// jwt
+	if c.JWT.Issuer == "" {
+		add("jwt.issuer", "is required")
+	}
+	if c.JWT.AccessTokenTTL <= 0 {
+		add("jwt.access_token_ttl", "must be positive")
+	}
+	if c.JWT.RefreshTokenTTL <= c.JWT.AccessTokenTTL {
+		add("jwt.refresh_token_ttl", "must be longer than access_token_ttl (%s)", c.JWT.AccessTokenTTL)
+	}
+	if len(c.JWT.Keys) > 0 {
+		kids := make([]string, 0, len(c.JWT.Keys))
+		for i, key := range c.JWT.Keys {
+			field := fmt.Sprintf("jwt.keys[%d]", i)
+			if key.ID == "" {
+				add(field+".kid", "is required")
+			} else if slices.Contains(kids, key.ID) {
+				add(field+".kid", "duplicate kid %q", key.ID)
+			}
+			kids = append(kids, key.ID)
+			if key.Algorithm != "EdDSA" && key.Algorithm != "RS256" {
+				add(field+".algorithm", "must be EdDSA or RS256, got %q", key.Algorithm)
+			}
+			if key.PrivateKeyFile == "" && key.PublicKeyFile == "" {
+				add(field, "one of private_key_file or public_key_file is required")
+			}
+		}
+		if c.JWT.ActiveKey == "" {
+			add("jwt.active_key", "is required when jwt.keys is set")
+		} else if i := slices.Index(kids, c.JWT.ActiveKey); i < 0 {
+			add("jwt.active_key", "%q does not match any kid in jwt.keys", c.JWT.ActiveKey)
+		} else if c.JWT.Keys[i].PrivateKeyFile == "" {
+			add("jwt.active_key", "key %q has no private_key_file and cannot sign tokens", c.JWT.ActiveKey)
+		}
+	}
+
+	// cors
+	validateCORSPolicy("cors", c.CORS.CORSPolicy, add)
+	prefixes := make(map[string]bool)
+	for i, group := range c.CORS.Groups {
+		field := fmt.Sprintf("cors.groups[%d]", i)
+		switch {
+		case !strings.HasPrefix(group.PathPrefix, "/"):
+			add(field+".path_prefix", "%q must start with /", group.PathPrefix)
+		case prefixes[group.PathPrefix]:
+			add(field+".path_prefix", "%q is used by another group", group.PathPrefix)
+		}
+		prefixes[group.PathPrefix] = true
+		validateCORSPolicy(field, group.CORSPolicy, add)
+	}
+
+	// rate_limit
+	for i, proxy := range c.RateLimit.TrustedProxies {
+		if _, err := netip.ParsePrefix(proxy); err != nil {
+			if _, err := netip.ParseAddr(proxy); err != nil {
+				add(fmt.Sprintf("rate_limit.trusted_proxies[%d]", i), "%q must be an IP address or CIDR", proxy)
+			}
+		}
+	}
+	for pattern, rule := range c.RateLimit.Routes {
+		field := fmt.Sprintf("rate_limit.routes[%q]", pattern)
+		if rule.Limit < 0 {
+			add(field+".limit", "must not be negative")
+		}
+		// limit 为 0 的规则只用于关闭默认规则, 不需要其他字段
+		if rule.Limit <= 0 {
+			continue
+		}
+		if !slices.Contains([]string{"ip", "user", "api_key"}, rule.Key) {
+			add(field+".key", "must be one of ip, user or api_key, got %q", rule.Key)
+		}
+		if rule.Period <= 0 {
+			add(field+".period", "must be positive")
+		}
+	}
+
+	// lockout
+	if c.Lockout.MaxAttempts < 0 {
+		add("lockout.max_attempts", "must not be negative")
+	}
+	if c.Lockout.MaxAttempts > 0 {
+		if c.Lockout.Duration <= 0 {
+			add("lockout.duration", "must be positive")
+		}
+		if c.Lockout.MaxDuration < c.Lockout.Duration {
+			add("lockout.max_duration", "must be at least lockout.duration (%s)", c.Lockout.Duration)
+		}
+	}
+
+	// account
+	if u, err := url.Parse(c.Account.AppURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add("account.app_url", "%q must be an http(s) URL like https://skymates.example.com", c.Account.AppURL)
+	}
+	if c.Account.PasswordResetTTL <= 0 {
+		add("account.password_reset_ttl", "must be positive")
+	}
+	if c.Account.EmailVerificationTTL <= 0 {
+		add("account.email_verification_ttl", "must be positive")
+	}
+
+	// mail
+	if _, err := mail.ParseAddress(c.Mail.From); err != nil {
+		add("mail.from", "%q must be an email address like Skymates <no-reply@example.com>", c.Mail.From)
+	}
+	switch c.Mail.Driver {
+	case "memory":
+	case "file":
+		if c.Mail.Dir == "" {
+			add("mail.dir", "is required when mail.driver is file")
+		}
+	case "smtp":
+		if c.Mail.SMTP.Host == "" {
+			add("mail.smtp.host", "is required when mail.driver is smtp (env SMTP_HOST)")
+		}
+		if c.Mail.SMTP.Port < 1 || c.Mail.SMTP.Port > 65535 {
+			add("mail.smtp.port", "must be between 1 and 65535, got %d", c.Mail.SMTP.Port)
+		}
+	default:
+		add("mail.driver", "must be one of smtp, file or memory, got %q", c.Mail.Driver)
+	}
+
+	// log
+	if !slices.Contains([]string{"debug", "info", "warn", "error"}, c.Log.Level) {
+		add("log.level", "must be one of debug, info, warn or error, got %q", c.Log.Level)
+	}
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		add("log.format", "must be text or json, got %q", c.Log.Format)
+	}
+
+	// health
+	if c.Health.CheckTimeout <= 0 {
+		add("health.check_timeout", "must be positive")
+	}
+	if c.Health.PoolSaturation <= 0 || c.Health.PoolSaturation > 1 {
+		add("health.pool_saturation", "must be greater than 0 and at most 1, got %g", c.Health.PoolSaturation)
+	}
+
+	// tracing
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "file":
+		if c.Tracing.File == "" {
+			add("tracing.file", "is required when tracing.exporter is file")
+		}
+	case "otlp":
+		if u, err := url.Parse(c.Tracing.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("tracing.endpoint", "%q must be an http(s) URL like http://localhost:4318/v1/traces", c.Tracing.Endpoint)
+		}
+	default:
+		add("tracing.exporter", "must be one of none, stdout, file or otlp, got %q", c.Tracing.Exporter)
+	}
+	if c.Tracing.ServiceName == "" {
+		add("tracing.service_name", "must not be empty")
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		add("tracing.sample_ratio", "must be between 0 and 1, got %g", c.Tracing.SampleRatio)
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	// map 遍历顺序不固定, 排序后输出稳定的错误信息
+	slices.Sort(problems)
+	return errors.New("invalid configuration:\n  " + strings.Join(problems, "\n  "))
+}
+
+// validateCORSPolicy 检查一组跨域规则, field 为规则所在的 YAML 路径
+func validateCORSPolicy(field string, policy CORSPolicy, add func(field, format string, args ...interface{})) {
+	for i, origin := range policy.AllowOrigins {
+		if origin == "*" {
+			if len(policy.AllowOrigins) > 1 {
+				add(field+".allow_origins", "* must be the only origin")
+			}
+			if policy.AllowCredentials {
+				add(field+".allow_origins", "* cannot be used together with allow_credentials")
+			}
+			continue
+		}
+		if err := validateOrigin(origin); err != nil {
+			add(fmt.Sprintf("%s.allow_origins[%d]", field, i), "%v", err)
+		}
+	}
+	if policy.MaxAge < 0 {
+		add(field+".max_age", "must not be negative")
+	}
+}
+
+// validateOrigin 检查源的格式, 浏览器发送的 Origin 头不包含路径, 带路径的源永远不会匹配
+func validateOrigin(origin string) error {
+	// 通配符只能是域名的第一段, 替换成普通的域名后再检查格式
+	scheme, host, _ := strings.Cut(origin, "://")
+	if strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+		return fmt.Errorf("%q may only use * as the first label of the host, like https://*.example.com", origin)
+	}
+	u, err := url.Parse(scheme + "://" + strings.Replace(host, "*.", "wildcard.", 1))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q must look like https://example.com[:port]", origin)
+	}
+	if u.Path != "" || u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("%q must not contain a path, query or trailing slash", origin)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package errors
+
+import "errors"
+
+// 响应中 code 字段的取值, 客户端根据 code 判断错误类型, message 只用于展示
+// 已经发布的错误码不能修改, 新的错误类型只能追加新的错误码
+const (
+	CodeOK              = 0     // 成功
+	CodeInternal        = 10000 // 系统内部错误
+	CodeNotFound        = 10001 // 资源未找到
+	CodeAlreadyExists   = 10002 // 资源已存在
+	CodeValidation      = 10003 // 参数校验失败
+	CodeUnauthorized    = 10004 // 需要认证
+	CodeForbidden       = 10005 // 权限不足
+	CodeConflict        = 10006 // 冲突
+	CodeTooManyRequests = 10007 // 请求过于频繁
+)
+
+// Code 根据 ServerError.Kind 返回对应的错误码
+// 如果不是 *ServerError，就返回 CodeInternal
+func Code(err error) int {
+	var se *ServerError
+	if errors.As(err, &se) {
+		switch se.Kind {
+		case KindNotFound:
+			return CodeNotFound
+		case KindAlreadyExists:
+			return CodeAlreadyExists
+		case KindValidation:
+			return CodeValidation
+		case KindUnauthorized:
+			return CodeUnauthorized
+		case KindForbidden:
+			return CodeForbidden
+		case KindConflict:
+			return CodeConflict
+		case KindTooManyRequests:
+			return CodeTooManyRequests
+		}
+	}
+	return CodeInternal
+}
@@ -9,13 +9,14 @@ import (
 type ErrorKind int
 
 const (
-	KindInternal      ErrorKind = iota // 系统内部错误
-	KindNotFound                       // 资源未找到
-	KindAlreadyExists                  // 资源已存在
-	KindValidation                     // 参数校验失败
-	KindUnauthorized                   // 需要认证
-	KindForbidden                      // 权限不足
-	KindConflict                       // 冲突，比如悲观锁、版本号不一致等
+	KindInternal        ErrorKind = iota // 系统内部错误
+	KindNotFound                         // 资源未找到
+	KindAlreadyExists                    // 资源已存在
+	KindValidation                       // 参数校验失败
+	KindUnauthorized                     // 需要认证
+	KindForbidden                        // 权限不足
+	KindConflict                         // 冲突，比如悲观锁、版本号不一致等
+	KindTooManyRequests                  // 请求过于频繁, 被限流
 )
 
 // ServerError 是所有可预知业务错误的统一类型
@@ -76,3 +77,7 @@ func NewForbiddenError(msg string, err error) *ServerError {
 func NewConflictError(msg string, err error) *ServerError {
 	return &ServerError{Kind: KindConflict, Message: msg, Err: err}
 }
+
+func NewTooManyRequestsError(msg string, err error) *ServerError {
+	return &ServerError{Kind: KindTooManyRequests, Message: msg, Err: err}
+}
@@ -21,6 +21,8 @@ func HTTPStatus(err error) int {
 			return http.StatusUnauthorized // 401
 		case KindForbidden:
 			return http.StatusForbidden // 403
+		case KindTooManyRequests:
+			return http.StatusTooManyRequests // 429
 		case KindInternal:
 			fallthrough
 		default:
@@ -0,0 +1,141 @@
+package authz
+
+import (
+	"net/http"
+	servererrors "skymates-api/errors"
+	"skymates-api/internal/i18n"
+	"skymates-api/internal/model"
+	"skymates-api/pkg/auth"
+	"slices"
+)
+
+// Policy 描述一条授权规则, 在认证通过后针对当前用户和请求进行判断
+// 返回 nil 表示允许访问
+// 未登录时返回 UnauthorizedError, 已登录但权限不足时返回 ForbiddenError
+type Policy func(r *http.Request, principal *auth.Principal) error
+
+// OwnerResolver 从请求中解析出目标资源所属用户的 ID, 比如根据路径参数查询资源
+type OwnerResolver func(r *http.Request) (int64, error)
+
+// Authenticated 只要求用户已登录
+func Authenticated() Policy {
+	return func(r *http.Request, principal *auth.Principal) error {
+		return RequireAuthenticated(principal)
+	}
+}
+
+// RequireRole 要求用户拥有给定角色之一
+func RequireRole(roles ...string) Policy {
+	return func(r *http.Request, principal *auth.Principal) error {
+		if err := RequireAuthenticated(principal); err != nil {
+			return err
+		}
+		if !slices.Contains(roles, principal.Role) {
+			return servererrors.NewForbiddenError(i18n.MsgForbidden, nil)
+		}
+		return nil
+	}
+}
+
+// AdminOnly 只允许管理员访问
+func AdminOnly() Policy {
+	return RequireRole(model.RoleAdmin)
+}
+
+// VerifiedEmail 要求用户已验证邮箱, 根据访问令牌中的 email_verified 判断
+// 刚验证邮箱的用户需要刷新令牌后才能通过
+func VerifiedEmail() Policy {
+	return func(r *http.Request, principal *auth.Principal) error {
+		if err := RequireAuthenticated(principal); err != nil {
+			return err
+		}
+		if !principal.EmailVerified {
+			return servererrors.NewForbiddenError(i18n.MsgEmailNotVerified, nil)
+		}
+		return nil
+	}
+}
+
+// OwnerOrAdmin 允许资源所有者或管理员访问
+// resolve 返回的错误会原样返回, 比如资源不存在时返回 NotFoundError
+func OwnerOrAdmin(resolve OwnerResolver) Policy {
+	return func(r *http.Request, principal *auth.Principal) error {
+		if err := RequireAuthenticated(principal); err != nil {
+			return err
+		}
+		if isAdmin(principal) {
+			return nil
+		}
+		ownerID, err := resolve(r)
+		if err != nil {
+			return err
+		}
+		return RequireOwnerOrAdmin(principal, &ownerID)
+	}
+}
+
+// AllOf 要求所有规则都通过, 返回第一个不通过的错误
+func AllOf(policies ...Policy) Policy {
+	return func(r *http.Request, principal *auth.Principal) error {
+		for _, policy := range policies {
+			if err := policy(r, principal); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// AnyOf 只要有一条规则通过即可, 全部不通过时返回最后一个错误
+func AnyOf(policies ...Policy) Policy {
+	return func(r *http.Request, principal *auth.Principal) error {
+		var err error = servererrors.NewForbiddenError(i18n.MsgForbidden, nil)
+		for _, policy := range policies {
+			if err = policy(r, principal); err == nil {
+				return nil
+			}
+		}
+		return err
+	}
+}
+
+// --- 供服务层直接使用的检查函数 ---
+
+// RequireAuthenticated 要求 principal 不为空
+func RequireAuthenticated(principal *auth.Principal) error {
+	if principal == nil {
+		return servererrors.NewUnauthorizedError(i18n.MsgLoginRequired, nil)
+	}
+	return nil
+}
+
+// RequireOwnerOrAdmin 要求 principal 是资源所有者或管理员
+// ownerID 为 nil 表示资源没有所有者, 此时只有管理员可以操作
+func RequireOwnerOrAdmin(principal *auth.Principal, ownerID *int64) error {
+	if err := RequireAuthenticated(principal); err != nil {
+		return err
+	}
+	if isAdmin(principal) {
+		return nil
+	}
+	if ownerID == nil || *ownerID != principal.UserID {
+		return servererrors.NewForbiddenError(i18n.MsgForbidden, nil)
+	}
+	return nil
+}
+
+// RequireAdmin 要求 principal 是管理员
+func RequireAdmin(principal *auth.Principal) error {
+	if err := RequireAuthenticated(principal); err != nil {
+		return err
+	}
+	if !isAdmin(principal) {
+		return servererrors.NewForbiddenError(i18n.MsgForbidden, nil)
+	}
+	return nil
+}
+
+// isAdmin 判断当前用户是否为管理员
+func isAdmin(principal *auth.Principal) bool {
+	return principal != nil && principal.Role == model.RoleAdmin
+}
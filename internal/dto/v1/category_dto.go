@@ -0,0 +1,49 @@
+package v1
+
+import "time"
+
+// CategoryResponse 分类的响应 DTO
+type CategoryResponse struct {
+	ID          int64     `json:"id"`
+	ParentID    *int64    `json:"parent_id"`
+	Name        string    `json:"name"`
+	Slug        string    `json:"slug"`
+	Description string    `json:"description"`
+	SortOrder   int       `json:"sort_order"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ListCategoriesResponse 列出分类的响应 DTO
+type ListCategoriesResponse struct {
+	Categories []CategoryResponse `json:"categories"`
+}
+
+// CategoryNode 分类树节点 DTO
+type CategoryNode struct {
+	CategoryResponse
+	Children []CategoryNode `json:"children"`
+}
+
+// CategoryTreeResponse 分类树的响应 DTO, categories 为顶级分类
+type CategoryTreeResponse struct {
+	Categories []CategoryNode `json:"categories"`
+}
+
+// CreateCategoryRequest 创建分类的请求 DTO, parent_id 为空时创建顶级分类
+type CreateCategoryRequest struct {
+	ParentID    *int64 `json:"parent_id"`
+	Name        string `json:"name" validate:"required,max=100"`
+	Slug        string `json:"slug" validate:"required,max=100,slug"`
+	Description string `json:"description" validate:"max=1024"`
+	SortOrder   int    `json:"sort_order"`
+}
+
+// UpdateCategoryRequest 更新分类的请求 DTO, 所有字段都会被替换, parent_id 为空时移动为顶级分类
+type UpdateCategoryRequest struct {
+	ParentID    *int64 `json:"parent_id"`
+	Name        string `json:"name" validate:"required,max=100"`
+	Slug        string `json:"slug" validate:"required,max=100,slug"`
+	Description string `json:"description" validate:"max=1024"`
+	SortOrder   int    `json:"sort_order"`
+}
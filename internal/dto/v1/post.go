@@ -10,7 +10,7 @@ type CreatePostRequest struct {
 // PostResponse 帖子响应
 type PostResponse struct {
 	ID        uuid.UUID `json:"id"`
-	UserID    uuid.UUID `json:"user_id"`
+	UserID    int64     `json:"user_id"`  // 与 users.id 及 auth.Principal.UserID 一致, 用于归属判断
 	Username  string    `json:"username"` // 增加用户名称，方便前端展示
 	Content   string    `json:"content"`
 	CreatedAt string    `json:"created_at"` // 格式化的时间字符串
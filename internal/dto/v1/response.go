@@ -1,5 +1,7 @@
 package v1
 
+import "skymates-api/internal/validator"
+
 // Response 通用响应结构
 // 若使用 `json:"data,omitempty"`, 则表示
 // 当 data 为 nil 时序列化时会忽略这个字段, 得到的 json 无 data 字段
@@ -8,3 +10,8 @@ type Response struct {
 	Message string      `json:"message"`
 	Data    interface{} `json:"data"`
 }
+
+// ValidationErrorData 参数校验失败时响应的 data, 包含每个字段的错误
+type ValidationErrorData struct {
+	Errors []validator.FieldError `json:"errors"`
+}
@@ -4,7 +4,25 @@ import "time"
 
 // SearchTermsResponse 搜索术语的响应 DTO
 type SearchTermsResponse struct {
-	Terms []TermSummary `json:"terms"`
+	Terms      []TermSearchHit `json:"terms"`
+	Total      int             `json:"total"`
+	Facets     []CategoryFacet `json:"facets"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// TermSearchHit 搜索结果中的术语 DTO, highlight 和 snippet 已做 HTML 转义, 关键字用 <mark> 标出
+type TermSearchHit struct {
+	ID        int64    `json:"id"`
+	Name      string   `json:"name"`
+	Aliases   []string `json:"aliases"`
+	Highlight string   `json:"highlight"`
+	Snippet   string   `json:"snippet"`
+}
+
+// CategoryFacet 搜索结果按分类统计的术语数 DTO
+type CategoryFacet struct {
+	CategoryID int64 `json:"category_id"`
+	Count      int   `json:"count"`
 }
 
 // TermSummary 术语概要 DTO
@@ -13,13 +31,27 @@ type TermSummary struct {
 	Name string `json:"name"`
 }
 
+// SuggestTermsResponse 搜索建议的响应 DTO
+type SuggestTermsResponse struct {
+	Suggestions []TermSuggestion `json:"suggestions"`
+}
+
+// TermSuggestion 搜索建议 DTO, 通过别名命中时 alias 为命中的别名
+type TermSuggestion struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Alias string `json:"alias,omitempty"`
+}
+
 // TermDetailResponse 术语详情的响应 DTO
 type TermDetailResponse struct {
 	ID          int64     `json:"id"`
 	Name        string    `json:"name"`
+	Aliases     []string  `json:"aliases"`
 	Explanation string    `json:"explanation"`
 	SourceURL   string    `json:"source_url"`
 	CategoryIDs []int64   `json:"category_ids"`
+	CreatedBy   *int64    `json:"created_by,omitempty"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
@@ -32,16 +64,65 @@ type ListTermsByCategoryResponse struct {
 
 // CreateTermRequest 创建术语的请求 DTO
 type CreateTermRequest struct {
-	Name        string  `json:"name" validate:"required"`
-	Explanation string  `json:"explanation" validate:"required"`
-	SourceURL   string  `json:"source_url"`
-	CategoryIDs []int64 `json:"category_ids"`
+	Name        string   `json:"name" validate:"required"`
+	Aliases     []string `json:"aliases" validate:"max=10,dive,max=100"`
+	Explanation string   `json:"explanation" validate:"required"`
+	SourceURL   string   `json:"source_url" validate:"omitempty,https_url"`
+	CategoryIDs []int64  `json:"category_ids"`
+	Summary     string   `json:"summary" validate:"max=255"`
 }
 
 // UpdateTermRequest 更新术语的请求 DTO
 type UpdateTermRequest struct {
-	Name        string  `json:"name" validate:"required"`
-	Explanation string  `json:"explanation" validate:"required"`
-	SourceURL   string  `json:"source_url"`
-	CategoryIDs []int64 `json:"category_ids"`
+	Name        string   `json:"name" validate:"required"`
+	Aliases     []string `json:"aliases" validate:"max=10,dive,max=100"`
+	Explanation string   `json:"explanation" validate:"required"`
+	SourceURL   string   `json:"source_url" validate:"omitempty,https_url"`
+	CategoryIDs []int64  `json:"category_ids"`
+	Summary     string   `json:"summary" validate:"max=255"`
+}
+
+// TermRevisionSummary 修订版本概要 DTO, 回滚产生的版本 restored_revision 为被恢复的版本号
+type TermRevisionSummary struct {
+	Revision         int       `json:"revision"`
+	ChangedFields    []string  `json:"changed_fields"`
+	Summary          string    `json:"summary"`
+	RestoredRevision *int      `json:"restored_revision,omitempty"`
+	CreatedBy        *int64    `json:"created_by,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// ListTermRevisionsResponse 列出术语修订版本的响应 DTO
+type ListTermRevisionsResponse struct {
+	Revisions []TermRevisionSummary `json:"revisions"`
+}
+
+// TermRevisionResponse 修订版本详情的响应 DTO, 包含该版本的完整快照
+type TermRevisionResponse struct {
+	TermRevisionSummary
+	TermID      int64    `json:"term_id"`
+	Name        string   `json:"name"`
+	Aliases     []string `json:"aliases"`
+	Explanation string   `json:"explanation"`
+	SourceURL   string   `json:"source_url"`
+	CategoryIDs []int64  `json:"category_ids"`
+}
+
+// TextChange 差异中的一段文本, op 为 equal, insert 或 delete
+type TextChange struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// FieldDiff 一个字段的差异
+type FieldDiff struct {
+	Field   string       `json:"field"`
+	Changes []TextChange `json:"changes"`
+}
+
+// TermRevisionDiffResponse 比较两个修订版本的响应 DTO, 只包含值不同的字段
+type TermRevisionDiffResponse struct {
+	From   int         `json:"from"`
+	To     int         `json:"to"`
+	Fields []FieldDiff `json:"fields"`
 }
@@ -1,9 +1,11 @@
 package v1
 
+import "time"
+
 // RegisterDto 用户注册请求
 type RegisterDto struct {
-	Username string `json:"username" validate:"required,min=3"`
-	Password string `json:"password" validate:"required,min=6"`
+	Username string `json:"username" validate:"required,min=3,max=32,username"`
+	Password string `json:"password" validate:"required,password"`
 	Email    string `json:"email" validate:"required,email"`
 }
 
@@ -13,10 +15,54 @@ type LoginDto struct {
 	Password string `json:"password" validate:"required,min=6"`
 }
 
-// UserInfoDto 用户响应（可选，根据需要添加）
+// UserInfoDto 用户的公开资料, 不包含邮箱等私人信息
 type UserInfoDto struct {
-	ID        string `json:"id"`
-	Username  string `json:"username"`
-	Email     string `json:"email"`
-	AvatarURL string `json:"avatar_url"`
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	AvatarURL string    `json:"avatar_url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UpdateProfileDto 修改当前用户的资料, 没有出现的字段保持不变
+// 修改邮箱时需要提供当前密码, 修改后需要重新验证; avatar_url 为空字符串时删除头像
+type UpdateProfileDto struct {
+	Username        *string `json:"username" validate:"omitempty,min=3,max=32,username"`
+	Email           *string `json:"email" validate:"omitempty,email"`
+	AvatarURL       *string `json:"avatar_url" validate:"omitempty,max=1024,https_url"`
+	CurrentPassword string  `json:"current_password"`
+}
+
+// ChangePasswordDto 修改当前用户的密码
+type ChangePasswordDto struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,password"`
+}
+
+// RefreshTokenDto 刷新令牌请求
+type RefreshTokenDto struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// TokenDto 登录或刷新成功后返回的令牌
+type TokenDto struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"` // 固定为 Bearer
+	ExpiresIn    int64  `json:"expires_in"` // 访问令牌剩余有效期(秒)
+}
+
+// PasswordResetRequestDto 申请重置密码请求
+type PasswordResetRequestDto struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordDto 使用邮件中的令牌设置新密码
+type ResetPasswordDto struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,password"`
+}
+
+// VerifyEmailDto 使用邮件中的令牌验证邮箱
+type VerifyEmailDto struct {
+	Token string `json:"token" validate:"required"`
 }
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"net/http"
+	serverErrors "skymates-api/errors"
+	v1 "skymates-api/internal/dto/v1"
+	"skymates-api/internal/i18n"
+	"skymates-api/internal/service"
+	"skymates-api/pkg/auth"
+)
+
+// AccountHandler 找回密码和验证邮箱处理器
+type AccountHandler struct {
+	BaseHandler
+	accountService service.AccountService
+}
+
+// NewAccountHandler 创建找回密码和验证邮箱处理器
+func NewAccountHandler(accountService service.AccountService) *AccountHandler {
+	return &AccountHandler{accountService: accountService}
+}
+
+// RequestPasswordReset 向邮箱发送重置密码的链接, 无论邮箱是否注册都返回相同的响应
+func (h *AccountHandler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) error {
+	var requestDto v1.PasswordResetRequestDto
+	if err := h.DecodeJSON(r, &requestDto); err != nil {
+		return serverErrors.NewValidationError(i18n.MsgInvalidFormat, err)
+	}
+
+	if err := h.Validate(r, requestDto); err != nil {
+		return err
+	}
+
+	if err := h.accountService.RequestPasswordReset(r.Context(), requestDto.Email); err != nil {
+		return err
+	}
+
+	h.ResponseJSON(w, r, http.StatusAccepted, i18n.MsgPasswordResetRequested, nil)
+	return nil
+}
+
+// ResetPassword 使用邮件中的令牌设置新密码, 成功后用户的所有会话都需要重新登录
+func (h *AccountHandler) ResetPassword(w http.ResponseWriter, r *http.Request) error {
+	var resetDto v1.ResetPasswordDto
+	if err := h.DecodeJSON(r, &resetDto); err != nil {
+		return serverErrors.NewValidationError(i18n.MsgInvalidFormat, err)
+	}
+
+	if err := h.Validate(r, resetDto); err != nil {
+		return err
+	}
+
+	if err := h.accountService.ResetPassword(r.Context(), resetDto); err != nil {
+		return err
+	}
+
+	h.ResponseJSON(w, r, http.StatusOK, i18n.MsgPasswordReset, nil)
+	return nil
+}
+
+// SendEmailVerification 重新向当前用户的邮箱发送验证链接
+func (h *AccountHandler) SendEmailVerification(w http.ResponseWriter, r *http.Request) error {
+	principal, _ := auth.PrincipalFrom(r.Context())
+	if err := h.accountService.SendEmailVerification(r.Context(), principal.UserID); err != nil {
+		return err
+	}
+
+	h.ResponseJSON(w, r, http.StatusAccepted, i18n.MsgVerificationSent, nil)
+	return nil
+}
+
+// VerifyEmail 使用邮件中的令牌验证邮箱
+func (h *AccountHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) error {
+	var verifyDto v1.VerifyEmailDto
+	if err := h.DecodeJSON(r, &verifyDto); err != nil {
+		return serverErrors.NewValidationError(i18n.MsgInvalidFormat, err)
+	}
+
+	if err := h.Validate(r, verifyDto); err != nil {
+		return err
+	}
+
+	if err := h.accountService.VerifyEmail(r.Context(), verifyDto.Token); err != nil {
+		return err
+	}
+
+	h.ResponseJSON(w, r, http.StatusOK, i18n.MsgEmailVerified, nil)
+	return nil
+}
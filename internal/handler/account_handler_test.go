@@ -0,0 +1,251 @@
+package handler_test
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	netmail "net/mail"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"skymates-api/config"
+	servererrors "skymates-api/errors"
+	dto "skymates-api/internal/dto/v1"
+	"skymates-api/pkg/mail"
+)
+
+// linkToken 匹配邮件正文中链接的令牌
+var linkToken = regexp.MustCompile(`/(reset-password|verify-email)\?token=([A-Za-z0-9_-]+)`)
+
+// lastToken 返回发给 to 的最后一封邮件中 page 链接的令牌
+func (s *testServer) lastToken(t *testing.T, to, page string) string {
+	t.Helper()
+	messages := s.mailbox.Messages()
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].To != to {
+			continue
+		}
+		if match := linkToken.FindStringSubmatch(messages[i].Body); match != nil && match[1] == page {
+			return match[2]
+		}
+	}
+	t.Fatalf("no %s mail sent to %s in %+v", page, to, messages)
+	return ""
+}
+
+// waitForMail 等待邮箱中至少有 count 封邮件, 返回所有邮件
+func (s *testServer) waitForMail(t *testing.T, count int) []mail.Message {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		messages := s.mailbox.Messages()
+		if len(messages) >= count {
+			return messages
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d mails, want %d", len(messages), count)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPasswordResetFlow(t *testing.T) {
+	server := newTestServer(t)
+	tokens := server.registerAndLogin(t, "alice")
+
+	// 邮箱未注册时返回相同的响应, 但不发送邮件
+	sent := len(server.mailbox.Messages())
+	unknown := dto.PasswordResetRequestDto{Email: "nobody@example.com"}
+	if status := server.do(t, http.MethodPost, "/api/v1/users/password-reset/request", "", unknown, nil); status != http.StatusAccepted {
+		t.Fatalf("request for unknown email: status = %d, want %d", status, http.StatusAccepted)
+	}
+
+	// 只有最后一次申请的链接有效
+	request := dto.PasswordResetRequestDto{Email: "alice@example.com"}
+	for range 2 {
+		if status := server.do(t, http.MethodPost, "/api/v1/users/password-reset/request", "", request, nil); status != http.StatusAccepted {
+			t.Fatalf("request: status = %d, want %d", status, http.StatusAccepted)
+		}
+	}
+	// 申请按顺序处理, 两封邮件都发给 alice 说明未注册的邮箱没有收到邮件
+	messages := server.waitForMail(t, sent+2)
+	for _, msg := range messages[sent:] {
+		if msg.To != "alice@example.com" {
+			t.Fatalf("mail sent to %s, want only alice@example.com", msg.To)
+		}
+	}
+	stale := linkToken.FindStringSubmatch(messages[len(messages)-2].Body)[2]
+	token := server.lastToken(t, "alice@example.com", "reset-password")
+
+	status, response := server.request(t, http.MethodPost, "/api/v1/users/password-reset/confirm", "",
+		dto.ResetPasswordDto{Token: stale, Password: "newsecret456"}, nil)
+	expectError(t, "stale token", status, response, http.StatusBadRequest, servererrors.CodeValidation)
+
+	confirm := dto.ResetPasswordDto{Token: token, Password: "newsecret456"}
+	if status := server.do(t, http.MethodPost, "/api/v1/users/password-reset/confirm", "", confirm, nil); status != http.StatusOK {
+		t.Fatalf("confirm: status = %d, want %d", status, http.StatusOK)
+	}
+	status, response = server.request(t, http.MethodPost, "/api/v1/users/password-reset/confirm", "", confirm, nil)
+	expectError(t, "reused token", status, response, http.StatusBadRequest, servererrors.CodeValidation)
+
+	// 重置密码后原来的会话全部结束, 只能使用新密码登录
+	if status := server.do(t, http.MethodPost, "/api/v1/users/refresh", "", dto.RefreshTokenDto{RefreshToken: tokens.RefreshToken}, nil); status != http.StatusUnauthorized {
+		t.Fatalf("refresh after reset: status = %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status, _, _ := server.login(t, "alice@example.com", "secret123"); status != http.StatusUnauthorized {
+		t.Fatalf("login with old password: status = %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status, _, _ := server.login(t, "alice@example.com", "newsecret456"); status != http.StatusOK {
+		t.Fatalf("login with new password: status = %d, want %d", status, http.StatusOK)
+	}
+}
+
+func TestEmailVerificationGatesPosting(t *testing.T) {
+	server := newTestServer(t, func(cfg *config.Config) {
+		cfg.Account.RequireVerifiedEmail = true
+	})
+	tokens := server.registerAndLogin(t, "alice")
+
+	term := dto.CreateTermRequest{Name: "Jet Lag", Explanation: "时差反应"}
+	status, response := server.request(t, http.MethodPost, "/api/v1/terms", tokens.AccessToken, term, nil)
+	expectError(t, "unverified post", status, response, http.StatusForbidden, servererrors.CodeForbidden)
+
+	// 注册时已经发送了验证邮件, 重新申请后旧链接失效
+	first := server.lastToken(t, "alice@example.com", "verify-email")
+	if status := server.do(t, http.MethodPost, "/api/v1/users/verify-email/request", tokens.AccessToken, nil, nil); status != http.StatusAccepted {
+		t.Fatalf("resend: status = %d, want %d", status, http.StatusAccepted)
+	}
+	token := server.lastToken(t, "alice@example.com", "verify-email")
+	status, response = server.request(t, http.MethodPost, "/api/v1/users/verify-email/confirm", "", dto.VerifyEmailDto{Token: first}, nil)
+	expectError(t, "stale token", status, response, http.StatusBadRequest, servererrors.CodeValidation)
+
+	if status := server.do(t, http.MethodPost, "/api/v1/users/verify-email/confirm", "", dto.VerifyEmailDto{Token: token}, nil); status != http.StatusOK {
+		t.Fatalf("verify: status = %d, want %d", status, http.StatusOK)
+	}
+	status, response = server.request(t, http.MethodPost, "/api/v1/users/verify-email/request", tokens.AccessToken, nil, nil)
+	expectError(t, "already verified", status, response, http.StatusConflict, servererrors.CodeConflict)
+
+	// 验证状态记录在访问令牌中, 刷新令牌后才能发布
+	var refreshed dto.TokenDto
+	if status := server.do(t, http.MethodPost, "/api/v1/users/refresh", "", dto.RefreshTokenDto{RefreshToken: tokens.RefreshToken}, &refreshed); status != http.StatusOK {
+		t.Fatalf("refresh: status = %d, want %d", status, http.StatusOK)
+	}
+	if status := server.do(t, http.MethodPost, "/api/v1/terms", refreshed.AccessToken, term, nil); status != http.StatusCreated {
+		t.Fatalf("verified post: status = %d, want %d", status, http.StatusCreated)
+	}
+}
+
+// smtpStandIn 只接收邮件的 SMTP 服务, 把收到的每封邮件的原始内容发送到 received
+func smtpStandIn(t *testing.T) (string, int, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, received)
+		}
+	}()
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, received
+}
+
+func serveSMTP(conn net.Conn, received chan<- string) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 localhost ESMTP stand-in")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case command == "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			received <- data.String()
+			reply("250 queued")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func TestSMTPMailerDeliversResetLink(t *testing.T) {
+	host, port, received := smtpStandIn(t)
+	server := newTestServer(t, func(cfg *config.Config) {
+		cfg.Mail.Driver = "smtp"
+		cfg.Mail.SMTP = config.SMTPConfig{Host: host, Port: port}
+	})
+	register := dto.RegisterDto{Username: "alice", Password: "secret123", Email: "alice@example.com"}
+	if status := server.do(t, http.MethodPost, "/api/v1/users/register", "", register, nil); status != http.StatusCreated {
+		t.Fatalf("register: status = %d, want %d", status, http.StatusCreated)
+	}
+	<-received // 验证邮件
+
+	request := dto.PasswordResetRequestDto{Email: "alice@example.com"}
+	if status := server.do(t, http.MethodPost, "/api/v1/users/password-reset/request", "", request, nil); status != http.StatusAccepted {
+		t.Fatalf("request: status = %d, want %d", status, http.StatusAccepted)
+	}
+
+	var raw string
+	select {
+	case raw = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no mail delivered to the SMTP server")
+	}
+	msg, err := netmail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if to := msg.Header.Get("To"); to != "<alice@example.com>" {
+		t.Fatalf("To = %q, want <alice@example.com>", to)
+	}
+	if subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); err != nil || subject != "重置 Skymates 密码" {
+		t.Fatalf("Subject = %q (%v), want the zh-CN subject", subject, err)
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	match := linkToken.FindStringSubmatch(string(body))
+	if match == nil || match[1] != "reset-password" {
+		t.Fatalf("body has no reset link:\n%s", body)
+	}
+
+	confirm := dto.ResetPasswordDto{Token: match[2], Password: "newsecret456"}
+	if status := server.do(t, http.MethodPost, "/api/v1/users/password-reset/confirm", "", confirm, nil); status != http.StatusOK {
+		t.Fatalf("confirm: status = %d, want %d", status, http.StatusOK)
+	}
+}
@@ -2,24 +2,29 @@ package handler
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
+	servererrors "skymates-api/errors"
 	v1 "skymates-api/internal/dto/v1"
+	"skymates-api/internal/i18n"
+	"skymates-api/internal/validator"
 )
 
 // BaseHandler 基础处理器
 type BaseHandler struct{}
 
-// ResponseJSON 写入JSON响应
-func (h *BaseHandler) ResponseJSON(w http.ResponseWriter, code int, message string, data interface{}) {
+// ResponseJSON 写入成功的JSON响应, 错误响应由 WriteError 写入
+// message 为 i18n 中的消息 ID, 按请求的语言翻译
+func (h *BaseHandler) ResponseJSON(w http.ResponseWriter, r *http.Request, status int, message string, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
+	w.WriteHeader(status)
 
 	if err := json.NewEncoder(w).Encode(v1.Response{
-		Message: message,
+		Code:    servererrors.CodeOK,
+		Message: i18n.T(r.Context(), message),
 		Data:    data,
 	}); err != nil {
-		log.Printf("handler.SendJSON: failed to encode response: %v", err)
+		slog.Error("encode response failed", "error", err)
 	}
 }
 
@@ -31,6 +36,12 @@ func (h *BaseHandler) DecodeJSON(r *http.Request, v interface{}) error {
 	return decoder.Decode(v)
 }
 
+// Validate 校验请求 DTO, 错误描述使用 Locale 中间件选择的语言
+// 校验不通过时返回的错误中包含每个字段的错误, 由 WriteError 写入响应的 data.errors
+func (h *BaseHandler) Validate(r *http.Request, v interface{}) error {
+	return validator.ValidateRequest(v, i18n.LocaleFromContext(r.Context()).String())
+}
+
 // ValidateEmail 验证邮箱格式（从原代码中提取）
 func (h *BaseHandler) ValidateEmail(email string) bool {
 	// 实现邮箱验证逻辑
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"net/http"
+	serverErrors "skymates-api/errors"
+	v1 "skymates-api/internal/dto/v1"
+	"skymates-api/internal/i18n"
+	"skymates-api/internal/model"
+	"skymates-api/internal/service"
+	"strconv"
+)
+
+// CategoryHandler 分类处理器
+type CategoryHandler struct {
+	BaseHandler
+	categoryService service.CategoryService
+}
+
+// NewCategoryHandler 创建分类处理器
+func NewCategoryHandler(categoryService service.CategoryService) *CategoryHandler {
+	return &CategoryHandler{
+		categoryService: categoryService,
+	}
+}
+
+// ListCategories 处理列出全部分类请求
+func (h *CategoryHandler) ListCategories(w http.ResponseWriter, r *http.Request) error {
+	categories, err := h.categoryService.ListCategories(r.Context())
+	if err != nil {
+		return err
+	}
+
+	// 类型转换：model.Category -> v1.CategoryResponse
+	v1Categories := make([]v1.CategoryResponse, len(categories))
+	for i, category := range categories {
+		v1Categories[i] = categoryResponse(category)
+	}
+
+	h.ResponseJSON(w, r, http.StatusOK, i18n.MsgOK, v1.ListCategoriesResponse{Categories: v1Categories})
+	return nil
+}
+
+// GetCategoryTree 处理获取分类树请求
+func (h *CategoryHandler) GetCategoryTree(w http.ResponseWriter, r *http.Request) error {
+	nodes, err := h.categoryService.GetCategoryTree(r.Context())
+	if err != nil {
+		return err
+	}
+
+	h.ResponseJSON(w, r, http.StatusOK, i18n.MsgOK, v1.CategoryTreeResponse{Categories: categoryNodes(nodes)})
+	return nil
+}
+
+// GetCategoryByID 处理获取分类详情请求
+func (h *CategoryHandler) GetCategoryByID(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.ParseInt(r.PathValue("categoryID"), 10, 64)
+	if err != nil {
+		return serverErrors.NewValidationError(i18n.MsgInvalidCategoryID, err)
+	}
+
+	category, err := h.categoryService.GetCategoryByID(r.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	h.ResponseJSON(w, r, http.StatusOK, i18n.MsgOK, categoryResponse(*category))
+	return nil
+}
+
+// CreateCategory 处理创建分类请求
+func (h *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) error {
+	var req v1.CreateCategoryRequest
+	if err := h.DecodeJSON(r, &req); err != nil {
+		return serverErrors.NewValidationError(i18n.MsgInvalidFormat, err)
+	}
+
+	if err := h.Validate(r, req); err != nil {
+		return err
+	}
+
+	category := &model.Category{
+		ParentID:    req.ParentID,
+		Name:        req.Name,
+		Slug:        req.Slug,
+		Description: req.Description,
+		SortOrder:   req.SortOrder,
+	}
+	id, err := h.categoryService.CreateCategory(r.Context(), category)
+	if err != nil {
+		return err
+	}
+
+	h.ResponseJSON(w, r, http.StatusCreated, i18n.MsgCategoryCreated, map[string]int64{"id": id})
+	return nil
+}
+
+// UpdateCategory 处理更新分类请求
+func (h *CategoryHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.ParseInt(r.PathValue("categoryID"), 10, 64)
+	if err != nil {
+		return serverErrors.NewValidationError(i18n.MsgInvalidCategoryID, err)
+	}
+
+	var req v1.UpdateCategoryRequest
+	if err := h.DecodeJSON(r, &req); err != nil {
+		return serverErrors.NewValidationError(i18n.MsgInvalidFormat, err)
+	}
+
+	if err := h.Validate(r, req); err != nil {
+		return err
+	}
+
+	category := &model.Category{
+		ID:          id,
+		ParentID:    req.ParentID,
+		Name:        req.Name,
+		Slug:        req.Slug,
+		Description: req.Description,
+		SortOrder:   req.SortOrder,
+	}
+	if err := h.categoryService.UpdateCategory(r.Context(), category); err != nil {
+		return err
+	}
+
+	h.ResponseJSON(w, r, http.StatusOK, i18n.MsgCategoryUpdated, nil)
+	return nil
+}
+
+// DeleteCategory 处理删除分类请求
+func (h *CategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.ParseInt(r.PathValue("categoryID"), 10, 64)
+	if err != nil {
+		return serverErrors.NewValidationError(i18n.MsgInvalidCategoryID, err)
+	}
+
+	if err := h.categoryService.DeleteCategory(r.Context(), id); err != nil {
+		return err
+	}
+
+	h.ResponseJSON(w, r, http.StatusOK, i18n.MsgCategoryDeleted, nil)
+	return nil
+}
+
+func categoryResponse(category model.Category) v1.CategoryResponse {
+	return v1.CategoryResponse{
+		ID:          category.ID,
+		ParentID:    category.ParentID,
+		Name:        category.Name,
+		Slug:        category.Slug,
+		Description: category.Description,
+		SortOrder:   category.SortOrder,
+		CreatedAt:   category.CreatedAt,
+		UpdatedAt:   category.UpdatedAt,
+	}
+}
+
+func categoryNodes(nodes []model.CategoryNode) []v1.CategoryNode {
+	v1Nodes := make([]v1.CategoryNode, len(nodes))
+	for i, node := range nodes {
+		v1Nodes[i] = v1.CategoryNode{CategoryResponse: categoryResponse(node.Category), Children: categoryNodes(node.Children)}
+	}
+	return v1Nodes
+}
@@ -0,0 +1,191 @@
+package handler_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	servererrors "skymates-api/errors"
+	dto "skymates-api/internal/dto/v1"
+)
+
+func TestCategoryWritesRequireAdmin(t *testing.T) {
+	server := newTestServer(t)
+	tokens := server.registerAndLogin(t, "alice")
+
+	req := dto.CreateCategoryRequest{Name: "Aviation", Slug: "aviation"}
+	status, response := server.request(t, http.MethodPost, "/api/v1/categories", "", req, nil)
+	expectError(t, "anonymous", status, response, http.StatusUnauthorized, servererrors.CodeUnauthorized)
+	status, response = server.request(t, http.MethodPost, "/api/v1/categories", tokens.AccessToken, req, nil)
+	expectError(t, "user", status, response, http.StatusForbidden, servererrors.CodeForbidden)
+	status, response = server.request(t, http.MethodDelete, "/api/v1/categories/1", tokens.AccessToken, nil, nil)
+	expectError(t, "user delete", status, response, http.StatusForbidden, servererrors.CodeForbidden)
+}
+
+func TestCategoryTree(t *testing.T) {
+	server := newTestServer(t)
+	admin := server.registerAdmin(t, "admin")
+
+	create := func(req dto.CreateCategoryRequest) int64 {
+		t.Helper()
+		var created struct {
+			ID int64 `json:"id"`
+		}
+		if status := server.do(t, http.MethodPost, "/api/v1/categories", admin.AccessToken, req, &created); status != http.StatusCreated {
+			t.Fatalf("create %q: status = %d, want %d", req.Slug, status, http.StatusCreated)
+		}
+		return created.ID
+	}
+	aviation := create(dto.CreateCategoryRequest{Name: "Aviation", Slug: "aviation", Description: "航空"})
+	navigation := create(dto.CreateCategoryRequest{Name: "Navigation", Slug: "navigation", ParentID: &aviation})
+	charts := create(dto.CreateCategoryRequest{Name: "Charts", Slug: "charts", ParentID: &navigation})
+	// sort_order 较小的排在前面
+	weather := create(dto.CreateCategoryRequest{Name: "Weather", Slug: "weather", SortOrder: -1})
+
+	var category dto.CategoryResponse
+	if status := server.do(t, http.MethodGet, fmt.Sprintf("/api/v1/categories/%d", charts), "", nil, &category); status != http.StatusOK {
+		t.Fatalf("get: status = %d, want %d", status, http.StatusOK)
+	}
+	if category.Name != "Charts" || category.ParentID == nil || *category.ParentID != navigation {
+		t.Fatalf("category = %+v, want Charts under %d", category, navigation)
+	}
+
+	var tree dto.CategoryTreeResponse
+	if status := server.do(t, http.MethodGet, "/api/v1/categories/tree", "", nil, &tree); status != http.StatusOK {
+		t.Fatalf("tree: status = %d, want %d", status, http.StatusOK)
+	}
+	if len(tree.Categories) != 2 || tree.Categories[0].ID != weather || tree.Categories[1].ID != aviation {
+		t.Fatalf("tree = %+v, want weather then aviation", tree.Categories)
+	}
+	if children := tree.Categories[1].Children; len(children) != 1 || children[0].ID != navigation ||
+		len(children[0].Children) != 1 || children[0].Children[0].ID != charts {
+		t.Fatalf("aviation children = %+v, want navigation > charts", children)
+	}
+
+	var list dto.ListCategoriesResponse
+	if status := server.do(t, http.MethodGet, "/api/v1/categories", "", nil, &list); status != http.StatusOK {
+		t.Fatalf("list: status = %d, want %d", status, http.StatusOK)
+	}
+	if len(list.Categories) != 4 {
+		t.Fatalf("categories = %+v, want 4", list.Categories)
+	}
+
+	for _, c := range []struct {
+		name       string
+		req        dto.CreateCategoryRequest
+		wantStatus int
+		wantCode   int
+	}{
+		{"duplicate slug", dto.CreateCategoryRequest{Name: "Other", Slug: "charts"}, http.StatusConflict, servererrors.CodeAlreadyExists},
+		{"invalid slug", dto.CreateCategoryRequest{Name: "Other", Slug: "Bad Slug"}, http.StatusBadRequest, servererrors.CodeValidation},
+		{"missing name", dto.CreateCategoryRequest{Slug: "other"}, http.StatusBadRequest, servererrors.CodeValidation},
+		{"unknown parent", dto.CreateCategoryRequest{Name: "Other", Slug: "other", ParentID: new(int64)}, http.StatusBadRequest, servererrors.CodeValidation},
+	} {
+		status, response := server.request(t, http.MethodPost, "/api/v1/categories", admin.AccessToken, c.req, nil)
+		expectError(t, c.name, status, response, c.wantStatus, c.wantCode)
+	}
+
+	// 不能移动到自身或子孙分类下
+	for _, parent := range []int64{aviation, charts} {
+		update := dto.UpdateCategoryRequest{Name: "Aviation", Slug: "aviation", ParentID: &parent}
+		status, response := server.request(t, http.MethodPut, fmt.Sprintf("/api/v1/categories/%d", aviation), admin.AccessToken, update, nil)
+		expectError(t, "cycle", status, response, http.StatusBadRequest, servererrors.CodeValidation)
+	}
+
+	// 把 charts 移动到 weather 下, 保留原来的 slug
+	update := dto.UpdateCategoryRequest{Name: "Weather Charts", Slug: "charts", ParentID: &weather}
+	if status := server.do(t, http.MethodPut, fmt.Sprintf("/api/v1/categories/%d", charts), admin.AccessToken, update, nil); status != http.StatusOK {
+		t.Fatalf("update: status = %d, want %d", status, http.StatusOK)
+	}
+	if status := server.do(t, http.MethodGet, "/api/v1/categories/tree", "", nil, &tree); status != http.StatusOK {
+		t.Fatalf("tree: status = %d, want %d", status, http.StatusOK)
+	}
+	if children := tree.Categories[0].Children; len(children) != 1 || children[0].Name != "Weather Charts" {
+		t.Fatalf("weather children = %+v, want Weather Charts", children)
+	}
+	if children := tree.Categories[1].Children[0].Children; len(children) != 0 {
+		t.Fatalf("navigation children = %+v, want none", children)
+	}
+
+	status, response := server.request(t, http.MethodPut, "/api/v1/categories/999", admin.AccessToken, update, nil)
+	expectError(t, "update missing", status, response, http.StatusNotFound, servererrors.CodeNotFound)
+	status, response = server.request(t, http.MethodGet, "/api/v1/categories/999", "", nil, nil)
+	expectError(t, "get missing", status, response, http.StatusNotFound, servererrors.CodeNotFound)
+}
+
+func TestCategoryTermsAndDelete(t *testing.T) {
+	server := newTestServer(t)
+	admin := server.registerAdmin(t, "admin")
+	tokens := server.registerAndLogin(t, "alice")
+
+	create := func(req dto.CreateCategoryRequest) int64 {
+		t.Helper()
+		var created struct {
+			ID int64 `json:"id"`
+		}
+		if status := server.do(t, http.MethodPost, "/api/v1/categories", admin.AccessToken, req, &created); status != http.StatusCreated {
+			t.Fatalf("create %q: status = %d, want %d", req.Slug, status, http.StatusCreated)
+		}
+		return created.ID
+	}
+	aviation := create(dto.CreateCategoryRequest{Name: "Aviation", Slug: "aviation"})
+	navigation := create(dto.CreateCategoryRequest{Name: "Navigation", Slug: "navigation", ParentID: &aviation})
+	empty := create(dto.CreateCategoryRequest{Name: "Empty", Slug: "empty"})
+
+	createTerm := func(name string, categoryIDs []int64) {
+		t.Helper()
+		req := dto.CreateTermRequest{Name: name, Explanation: "explanation of " + name, CategoryIDs: categoryIDs}
+		if status := server.do(t, http.MethodPost, "/api/v1/terms", tokens.AccessToken, req, nil); status != http.StatusCreated {
+			t.Fatalf("create term %q: status = %d, want %d", name, status, http.StatusCreated)
+		}
+	}
+	createTerm("Altimeter", []int64{aviation})
+	createTerm("VOR", []int64{navigation})
+	createTerm("NDB", []int64{aviation, navigation})
+
+	// 不存在的分类不能关联到术语
+	req := dto.CreateTermRequest{Name: "Jet Lag", Explanation: "时差反应", CategoryIDs: []int64{aviation, 999}}
+	status, response := server.request(t, http.MethodPost, "/api/v1/terms", tokens.AccessToken, req, nil)
+	expectError(t, "unknown category", status, response, http.StatusBadRequest, servererrors.CodeValidation)
+
+	list := func(path string) dto.ListTermsByCategoryResponse {
+		t.Helper()
+		var result dto.ListTermsByCategoryResponse
+		if status := server.do(t, http.MethodGet, path, "", nil, &result); status != http.StatusOK {
+			t.Fatalf("list %s: status = %d, want %d", path, status, http.StatusOK)
+		}
+		return result
+	}
+	if direct := list(fmt.Sprintf("/api/v1/categories/%d/terms", aviation)); len(direct.Terms) != 2 {
+		t.Fatalf("direct terms = %+v, want Altimeter and NDB", direct.Terms)
+	}
+	// 同时属于两个分类的 NDB 只出现一次
+	all := list(fmt.Sprintf("/api/v1/categories/%d/terms?includeDescendants=true", aviation))
+	if len(all.Terms) != 3 || all.HasMore {
+		t.Fatalf("terms with descendants = %+v, want 3 terms", all)
+	}
+	page := list(fmt.Sprintf("/api/v1/categories/%d/terms?includeDescendants=true&limit=2", aviation))
+	if len(page.Terms) != 2 || !page.HasMore {
+		t.Fatalf("first page = %+v, want 2 terms and more", page)
+	}
+	page = list(fmt.Sprintf("/api/v1/categories/%d/terms?includeDescendants=true&limit=2&lastID=%d", aviation, page.Terms[1].ID))
+	if len(page.Terms) != 1 || page.HasMore || page.Terms[0].Name != "NDB" {
+		t.Fatalf("second page = %+v, want NDB only", page)
+	}
+	status, response = server.request(t, http.MethodGet, "/api/v1/categories/999/terms", "", nil, nil)
+	expectError(t, "list missing", status, response, http.StatusNotFound, servererrors.CodeNotFound)
+
+	status, response = server.request(t, http.MethodDelete, fmt.Sprintf("/api/v1/categories/%d", aviation), admin.AccessToken, nil, nil)
+	expectError(t, "delete with children", status, response, http.StatusConflict, servererrors.CodeConflict)
+	status, response = server.request(t, http.MethodDelete, fmt.Sprintf("/api/v1/categories/%d", navigation), admin.AccessToken, nil, nil)
+	expectError(t, "delete with terms", status, response, http.StatusConflict, servererrors.CodeConflict)
+
+	path := fmt.Sprintf("/api/v1/categories/%d", empty)
+	if status := server.do(t, http.MethodDelete, path, admin.AccessToken, nil, nil); status != http.StatusOK {
+		t.Fatalf("delete: status = %d, want %d", status, http.StatusOK)
+	}
+	status, response = server.request(t, http.MethodGet, path, "", nil, nil)
+	expectError(t, "get deleted", status, response, http.StatusNotFound, servererrors.CodeNotFound)
+	status, response = server.request(t, http.MethodDelete, path, admin.AccessToken, nil, nil)
+	expectError(t, "delete again", status, response, http.StatusNotFound, servererrors.CodeNotFound)
+}
@@ -0,0 +1,103 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	dto "skymates-api/internal/dto/v1"
+)
+
+// preflight 发送预检请求, 返回响应和被拒绝时解码后的错误
+func (s *testServer) preflight(t *testing.T, path, origin string) (*http.Response, dto.Response) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodOptions, s.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "content-type,authorization")
+	req.Header.Set("Accept-Language", "en")
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var envelope dto.Response
+	if resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp, envelope
+}
+
+func TestCORSPolicyFollowsOriginAndRouteGroup(t *testing.T) {
+	server := newTestServer(t)
+
+	for _, tc := range []struct {
+		path        string
+		origin      string
+		status      int
+		allowOrigin string
+		credentials string
+		maxAge      string
+	}{
+		{"/api/v1/terms", "http://localhost:3000", http.StatusNoContent, "http://localhost:3000", "true", "86400"},
+		{"/api/v1/terms", "https://pr-42.vercel.app", http.StatusNoContent, "https://pr-42.vercel.app", "true", "86400"},
+		{"/api/v1/terms", "https://a.b.vercel.app", http.StatusNoContent, "https://a.b.vercel.app", "true", "86400"},
+		// 通配符只匹配子域名, 协议和端口必须一致
+		{"/api/v1/terms", "https://vercel.app", http.StatusForbidden, "", "", ""},
+		{"/api/v1/terms", "http://pr-42.vercel.app", http.StatusForbidden, "", "", ""},
+		{"/api/v1/terms", "https://evilvercel.app", http.StatusForbidden, "", "", ""},
+		{"/api/v1/terms", "http://localhost:3001", http.StatusForbidden, "", "", ""},
+		// 路由组的规则覆盖默认规则
+		{"/.well-known/jwks.json", "https://example.com", http.StatusNoContent, "*", "", "3600"},
+	} {
+		resp, envelope := server.preflight(t, tc.path, tc.origin)
+		header := resp.Header
+		if resp.StatusCode != tc.status ||
+			header.Get("Access-Control-Allow-Origin") != tc.allowOrigin ||
+			header.Get("Access-Control-Allow-Credentials") != tc.credentials ||
+			header.Get("Access-Control-Max-Age") != tc.maxAge {
+			t.Errorf("preflight %s from %s: status = %d, headers = %v", tc.path, tc.origin, resp.StatusCode, header)
+		}
+		if vary := header.Values("Vary"); !contains(vary, "Origin") || !contains(vary, "Access-Control-Request-Method") {
+			t.Errorf("preflight %s from %s: Vary = %v", tc.path, tc.origin, vary)
+		}
+		// 拒绝预检请求的错误按请求的语言翻译
+		if tc.status == http.StatusForbidden && envelope.Message != "Cross-origin requests from this origin are not allowed" {
+			t.Errorf("preflight %s from %s: message = %q", tc.path, tc.origin, envelope.Message)
+		}
+	}
+
+	// 不允许的源的普通请求照常处理, 但没有 CORS 头, 浏览器不会把响应交给页面
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v1/terms/search?keyword=jet", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Origin", "https://evil.example")
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("GET from disallowed origin: status = %d, headers = %v", resp.StatusCode, resp.Header)
+	}
+	if !contains(resp.Header.Values("Vary"), "Origin") {
+		t.Fatalf("GET from disallowed origin: Vary = %v", resp.Header.Values("Vary"))
+	}
+
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	servererrors "skymates-api/errors"
+	v1 "skymates-api/internal/dto/v1"
+	"skymates-api/internal/i18n"
+	"skymates-api/internal/validator"
+	"skymates-api/pkg/logging"
+)
+
+// Func 是返回 error 的处理函数, 实现 http.Handler
+// 处理函数只负责成功的响应, 返回的错误统一由 WriteError 渲染
+type Func func(w http.ResponseWriter, r *http.Request) error
+
+// ServeHTTP 实现 http.Handler
+func (f Func) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := f(w, r); err != nil {
+		WriteError(w, r, err)
+	}
+}
+
+// WriteError 根据错误类型写入统一格式的错误响应
+// 状态码由 servererrors.HTTPStatus 决定, code 由 servererrors.Code 决定, message 按请求的语言翻译
+// 内部错误只记录到日志, 不向客户端暴露具体信息; 每个错误只在这里记录一次
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	status := servererrors.HTTPStatus(err)
+	message := i18n.MsgInternalError
+	var se *servererrors.ServerError
+	if errors.As(err, &se) && se.Kind != servererrors.KindInternal {
+		message = se.Message
+	}
+	if status >= http.StatusInternalServerError {
+		logging.FromContext(r.Context()).Error("request failed",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"error", err,
+		)
+	}
+
+	// 参数校验失败时返回每个字段的错误, 客户端可以对应到表单字段
+	var data interface{}
+	var fields validator.FieldErrors
+	if errors.As(err, &fields) {
+		data = v1.ValidationErrorData{Errors: fields}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v1.Response{
+		Code:    servererrors.Code(err),
+		Message: i18n.T(r.Context(), message),
+		Data:    data,
+	}); err != nil {
+		logging.FromContext(r.Context()).Error("encode error response failed", "error", err)
+	}
+}
@@ -0,0 +1,74 @@
+package handler_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	servererrors "skymates-api/errors"
+	dto "skymates-api/internal/dto/v1"
+)
+
+// expectError 检查错误响应的状态码和错误码
+func expectError(t *testing.T, name string, status int, response dto.Response, wantStatus, wantCode int) {
+	t.Helper()
+	if status != wantStatus || response.Code != wantCode {
+		t.Fatalf("%s: status = %d, code = %d, want %d and %d (message %q)", name, status, response.Code, wantStatus, wantCode, response.Message)
+	}
+	if response.Message == "" {
+		t.Fatalf("%s: message should not be empty", name)
+	}
+}
+
+func TestRegisterDuplicateReturnsConflict(t *testing.T) {
+	server := newTestServer(t)
+	server.registerAndLogin(t, "alice")
+
+	duplicateEmail := dto.RegisterDto{Username: "alice2", Password: "secret123", Email: "alice@example.com"}
+	status, response := server.request(t, http.MethodPost, "/api/v1/users/register", "", duplicateEmail, nil)
+	expectError(t, "duplicate email", status, response, http.StatusConflict, servererrors.CodeAlreadyExists)
+
+	duplicateUsername := dto.RegisterDto{Username: "alice", Password: "secret123", Email: "alice2@example.com"}
+	status, response = server.request(t, http.MethodPost, "/api/v1/users/register", "", duplicateUsername, nil)
+	expectError(t, "duplicate username", status, response, http.StatusConflict, servererrors.CodeAlreadyExists)
+
+	invalid := dto.RegisterDto{Username: "al", Password: "secret123", Email: "al@example.com"}
+	status, response = server.request(t, http.MethodPost, "/api/v1/users/register", "", invalid, nil)
+	expectError(t, "invalid username", status, response, http.StatusBadRequest, servererrors.CodeValidation)
+}
+
+func TestTermErrorsUseKindStatusAndCode(t *testing.T) {
+	server := newTestServer(t)
+	alice := server.registerAndLogin(t, "alice")
+	bob := server.registerAndLogin(t, "bob")
+
+	status, response := server.request(t, http.MethodGet, "/api/v1/terms/404", "", nil, nil)
+	expectError(t, "missing term", status, response, http.StatusNotFound, servererrors.CodeNotFound)
+
+	status, response = server.request(t, http.MethodGet, "/api/v1/terms/abc", "", nil, nil)
+	expectError(t, "invalid id", status, response, http.StatusBadRequest, servererrors.CodeValidation)
+
+	status, response = server.request(t, http.MethodGet, "/api/v1/terms/search", "", nil, nil)
+	expectError(t, "missing keyword", status, response, http.StatusBadRequest, servererrors.CodeValidation)
+
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	term := dto.CreateTermRequest{Name: "Jet Lag", Explanation: "时差反应"}
+	status, response = server.request(t, http.MethodPost, "/api/v1/terms", alice.AccessToken, term, &created)
+	if status != http.StatusCreated || response.Code != servererrors.CodeOK {
+		t.Fatalf("create: status = %d, code = %d, want %d and %d", status, response.Code, http.StatusCreated, servererrors.CodeOK)
+	}
+
+	update := dto.UpdateTermRequest{Name: "Jet Lag", Explanation: "被其他用户修改"}
+	path := fmt.Sprintf("/api/v1/terms/%d", created.ID)
+	status, response = server.request(t, http.MethodPut, path, bob.AccessToken, update, nil)
+	expectError(t, "update by other user", status, response, http.StatusForbidden, servererrors.CodeForbidden)
+
+	status, response = server.request(t, http.MethodPut, "/api/v1/terms/404", alice.AccessToken, update, nil)
+	expectError(t, "update missing term", status, response, http.StatusNotFound, servererrors.CodeNotFound)
+
+	// 中间件返回的错误使用相同的格式
+	status, response = server.request(t, http.MethodPost, "/api/v1/terms", "", term, nil)
+	expectError(t, "create without token", status, response, http.StatusUnauthorized, servererrors.CodeUnauthorized)
+}
@@ -0,0 +1,182 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"skymates-api/api"
+	v1 "skymates-api/api/v1"
+	"skymates-api/config"
+	dto "skymates-api/internal/dto/v1"
+	"skymates-api/internal/middleware"
+	"skymates-api/internal/model"
+	"skymates-api/internal/repository"
+	"skymates-api/internal/repository/repositorytest"
+	"skymates-api/internal/service"
+	"skymates-api/pkg/auth"
+	"skymates-api/pkg/mail"
+	"skymates-api/pkg/metrics"
+	"skymates-api/pkg/ratelimit"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// testServer 使用 SQLite 内存数据库和临时密钥启动完整的路由, 不依赖任何外部服务
+type testServer struct {
+	*httptest.Server
+	// mailbox 保存发送的邮件, 配置了其他邮件驱动时为 nil
+	mailbox  *mail.MemoryMailer
+	services *service.Services
+	// db 和 repos 用于准备测试数据, 例如创建分类和设置管理员
+	db    *sqlx.DB
+	repos *repository.Repositories
+}
+
+// newTestServer 启动测试服务, configure 可以在默认的测试配置上修改配置, 比如调小限流的额度
+func newTestServer(t *testing.T, configure ...func(cfg *config.Config)) *testServer {
+	t.Helper()
+	db, repos := repositorytest.OpenSQLite(t)
+
+	cfg := config.Default()
+	// 默认规则允许本地前端和 Vercel 的预览环境, /.well-known/ 允许任意源
+	cfg.CORS.AllowOrigins = append(cfg.CORS.AllowOrigins, "https://*.vercel.app")
+	cfg.CORS.Groups = []config.CORSGroup{
+		{PathPrefix: "/.well-known/", CORSPolicy: config.CORSPolicy{AllowOrigins: []string{"*"}, MaxAge: time.Hour}},
+	}
+	cfg.Mail.Driver = "memory"
+	for _, fn := range configure {
+		fn(cfg)
+	}
+	keys, err := auth.NewKeyManager(cfg.JWT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mailbox *mail.MemoryMailer
+	mailer, err := mail.New(cfg.Mail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if memory, ok := mailer.(*mail.MemoryMailer); ok {
+		mailbox = memory
+	}
+	registry := metrics.NewRegistry()
+	services := service.NewServices(repos.User, repos.Term, repos.Category, repos.Token, repos.UserToken, keys, mailer, cfg,
+		service.NewMetrics(registry))
+	if err := services.TermService.RebuildSuggestIndex(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重置密码的邮件由后台任务发送, 测试结束时等待任务退出后再关闭数据库
+	workerCtx, stopWorker := context.WithCancel(context.Background())
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		_ = services.AccountService.SendPasswordResetMails(workerCtx)
+	}()
+	t.Cleanup(func() {
+		stopWorker()
+		<-workerDone
+	})
+
+	mux := http.NewServeMux()
+	api.RegisterMetricsRoutes(mux, registry)
+	api.RegisterWellKnownRoutes(mux, keys)
+	v1.RegisterRoutes(mux, services, keys, middleware.RateLimit(cfg.RateLimit, ratelimit.NewMemoryStore()), cfg.Account.RequireVerifiedEmail)
+
+	server := httptest.NewServer(middleware.RequestID(middleware.Locale(middleware.CORS(cfg.CORS)(middleware.Tracing(middleware.Logger(middleware.Metrics(registry)(mux)))))))
+	t.Cleanup(server.Close)
+	return &testServer{Server: server, mailbox: mailbox, services: services, db: db, repos: repos}
+}
+
+// do 发送 JSON 请求, 将响应的 data 字段解码到 data (可以为 nil), 返回状态码
+func (s *testServer) do(t *testing.T, method, path, accessToken string, body, data interface{}) int {
+	t.Helper()
+	status, _ := s.request(t, method, path, accessToken, body, data)
+	return status
+}
+
+// request 和 do 相同, 同时返回解码后的响应, 用于检查 code 和 message
+func (s *testServer) request(t *testing.T, method, path, accessToken string, body, data interface{}) (int, dto.Response) {
+	t.Helper()
+	var reader bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reader).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req, err := http.NewRequest(method, s.URL+path, &reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	envelope := dto.Response{Data: data}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		t.Fatalf("%s %s: decode response: %v", method, path, err)
+	}
+	return resp.StatusCode, envelope
+}
+
+// registerAndLogin 注册一个新用户并登录, 返回登录后的令牌
+func (s *testServer) registerAndLogin(t *testing.T, username string) dto.TokenDto {
+	t.Helper()
+	register := dto.RegisterDto{Username: username, Password: "secret123", Email: username + "@example.com"}
+	if status := s.do(t, http.MethodPost, "/api/v1/users/register", "", register, nil); status != http.StatusCreated {
+		t.Fatalf("register: status = %d, want %d", status, http.StatusCreated)
+	}
+
+	var login struct {
+		Token dto.TokenDto `json:"token"`
+	}
+	credentials := dto.LoginDto{Email: register.Email, Password: register.Password}
+	if status := s.do(t, http.MethodPost, "/api/v1/users/login", "", credentials, &login); status != http.StatusOK {
+		t.Fatalf("login: status = %d, want %d", status, http.StatusOK)
+	}
+	return login.Token
+}
+
+// registerAdmin 注册一个新用户, 设置为管理员后登录, 返回登录后的令牌
+func (s *testServer) registerAdmin(t *testing.T, username string) dto.TokenDto {
+	t.Helper()
+	s.registerAndLogin(t, username)
+	if _, err := s.db.Exec(s.db.Rebind(`UPDATE users SET role = ? WHERE username = ?`), model.RoleAdmin, username); err != nil {
+		t.Fatal(err)
+	}
+
+	// 角色写在访问令牌中, 需要重新登录
+	var login struct {
+		Token dto.TokenDto `json:"token"`
+	}
+	credentials := dto.LoginDto{Email: username + "@example.com", Password: "secret123"}
+	if status := s.do(t, http.MethodPost, "/api/v1/users/login", "", credentials, &login); status != http.StatusOK {
+		t.Fatalf("login: status = %d, want %d", status, http.StatusOK)
+	}
+	return login.Token
+}
+
+// createCategories 创建 n 个顶级分类, 空数据库中分类的 ID 依次为 1 到 n
+func (s *testServer) createCategories(t *testing.T, n int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		category := &model.Category{Name: fmt.Sprintf("Category %d", i), Slug: fmt.Sprintf("category-%d", i)}
+		if _, err := s.repos.Category.CreateCategory(context.Background(), category); err != nil {
+			t.Fatalf("create category: %v", err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	dto "skymates-api/internal/dto/v1"
+)
+
+// getMessage 发送带 Accept-Language 的 GET 请求, 返回响应的 message 和 Content-Language
+func (s *testServer) getMessage(t *testing.T, path, acceptLanguage string) (string, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, s.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acceptLanguage != "" {
+		req.Header.Set("Accept-Language", acceptLanguage)
+	}
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var envelope dto.Response
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		t.Fatal(err)
+	}
+	return envelope.Message, resp.Header.Get("Content-Language")
+}
+
+func TestMessagesFollowAcceptLanguage(t *testing.T) {
+	server := newTestServer(t)
+
+	for _, tc := range []struct {
+		acceptLanguage string
+		path           string
+		message        string
+		locale         string
+	}{
+		{"", "/api/v1/terms/404", "术语不存在", "zh-CN"},
+		{"en-GB,en;q=0.9", "/api/v1/terms/404", "Term not found", "en"},
+		{"fr, en;q=0.5", "/api/v1/terms/404", "Term not found", "en"},
+		{"de", "/api/v1/terms/404", "术语不存在", "zh-CN"},
+		{"en", "/api/v1/terms/search?keyword=jet", "Success", "en"},
+		{"zh-TW", "/api/v1/terms/search?keyword=jet", "成功", "zh-CN"},
+	} {
+		message, locale := server.getMessage(t, tc.path, tc.acceptLanguage)
+		if message != tc.message || locale != tc.locale {
+			t.Errorf("Accept-Language %q, GET %s: message = %q (%s), want %q (%s)",
+				tc.acceptLanguage, tc.path, message, locale, tc.message, tc.locale)
+		}
+	}
+
+	// 中间件返回的错误同样翻译
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/api/v1/users/logout", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept-Language", "en")
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var envelope dto.Response
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		t.Fatal(err)
+	}
+	if envelope.Message != "Authorization header is required" {
+		t.Fatalf("logout without token: message = %q", envelope.Message)
+	}
+}
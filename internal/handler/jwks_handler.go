@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"skymates-api/pkg/auth"
+	"skymates-api/pkg/logging"
+)
+
+// JWKSHandler 公开 JWT 验证公钥的处理器
+type JWKSHandler struct {
+	keys *auth.KeyManager
+}
+
+// NewJWKSHandler 创建 JWKS 处理器
+func NewJWKSHandler(keys *auth.KeyManager) *JWKSHandler {
+	return &JWKSHandler{keys: keys}
+}
+
+// GetJWKS 返回 JWK Set
+// 按照 RFC 7517 直接返回 {"keys": [...]}, 不使用通用的 Response 包装, 以便标准的 JWT 库直接使用
+func (h *JWKSHandler) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	// 允许其他服务缓存一段时间, 轮换密钥时新密钥应提前加入配置
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if err := json.NewEncoder(w).Encode(h.keys.JWKS()); err != nil {
+		logging.FromContext(r.Context()).Error("encode jwks failed", "error", err)
+	}
+}
@@ -0,0 +1,100 @@
+package handler_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"testing"
+
+	"skymates-api/internal/middleware"
+	"skymates-api/pkg/tracing"
+)
+
+// logBuffer 是并发安全的日志输出, 服务端在其他 goroutine 中写日志
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// records 解析所有 JSON 日志
+func (b *logBuffer) records(t *testing.T) []map[string]interface{} {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var records []map[string]interface{}
+	scanner := bufio.NewScanner(bytes.NewReader(b.buf.Bytes()))
+	for scanner.Scan() {
+		var record map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("decode log %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestRequestIDIsEchoedAndAddedToLogs(t *testing.T) {
+	logs := &logBuffer{}
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	tracer := tracing.NewTracer(&recordingExporter{}, tracing.Options{SampleRatio: 1})
+	tracing.SetDefault(tracer)
+	t.Cleanup(func() { tracing.SetDefault(nil) })
+
+	server := newTestServer(t)
+
+	// 客户端传入的合法 ID 原样返回
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v1/terms/search?keyword=jet", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(middleware.RequestIDHeader, "client-req-42")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get(middleware.RequestIDHeader); got != "client-req-42" {
+		t.Fatalf("%s = %q, want client-req-42", middleware.RequestIDHeader, got)
+	}
+	traceparent, ok := tracing.ParseTraceparent(resp.Header.Get(tracing.TraceparentHeader))
+	if !ok {
+		t.Fatalf("response traceparent = %q", resp.Header.Get(tracing.TraceparentHeader))
+	}
+
+	// 访问日志带有 request_id 和 trace_id
+	var access map[string]interface{}
+	for _, record := range logs.records(t) {
+		if record["msg"] == "http request" && record["request_id"] == "client-req-42" {
+			access = record
+		}
+	}
+	if access == nil {
+		t.Fatalf("access log with request_id not found in %s", logs.buf.String())
+	}
+	if access["trace_id"] != traceparent.TraceID.String() || access["status"] != float64(http.StatusOK) {
+		t.Fatalf("access log = %v, want trace_id %s and status 200", access, traceparent.TraceID)
+	}
+
+	// 非法的 ID 被替换为新生成的 ID
+	req.Header.Set(middleware.RequestIDHeader, "has space")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get(middleware.RequestIDHeader); got == "" || got == "has space" {
+		t.Fatalf("%s = %q, want a generated ID", middleware.RequestIDHeader, got)
+	}
+}
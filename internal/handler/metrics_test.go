@@ -0,0 +1,54 @@
+package handler_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	dto "skymates-api/internal/dto/v1"
+)
+
+func TestMetricsExposesRoutesAndBusinessCounters(t *testing.T) {
+	server := newTestServer(t)
+	server.registerAndLogin(t, "alice")
+	credentials := dto.LoginDto{Email: "alice@example.com", Password: "wrong-password"}
+	server.do(t, http.MethodPost, "/api/v1/users/login", "", credentials, nil)
+	server.do(t, http.MethodGet, "/api/v1/categories/7/terms", "", nil, nil)
+	unmatched, err := http.Get(server.URL + "/no/such/path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unmatched.Body.Close()
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("Content-Type = %q", resp.Header.Get("Content-Type"))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`http_requests_total{method="POST",route="/api/v1/users/login",status="401"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/api/v1/categories/{categoryID}/terms"} 1`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`skymates_user_registrations_total 1`,
+		`skymates_user_logins_total{result="success"} 1`,
+		`skymates_user_logins_total{result="failure"} 1`,
+		`skymates_terms_created_total 0`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+	// 原始路径不能作为标签
+	if strings.Contains(string(body), "/no/such/path") {
+		t.Error("metrics must not contain the raw path of unmatched requests")
+	}
+}
@@ -0,0 +1,96 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"skymates-api/config"
+	servererrors "skymates-api/errors"
+	dto "skymates-api/internal/dto/v1"
+)
+
+// login 发送登录请求, 返回状态码, 解码后的响应和响应头
+func (s *testServer) login(t *testing.T, email, password string) (int, dto.Response, http.Header) {
+	t.Helper()
+	body := strings.NewReader(`{"email":` + strconv.Quote(email) + `,"password":` + strconv.Quote(password) + `}`)
+	resp, err := s.Client().Post(s.URL+"/api/v1/users/login", "application/json", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var envelope dto.Response
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, envelope, resp.Header
+}
+
+func TestLoginIsRateLimitedByIP(t *testing.T) {
+	server := newTestServer(t, func(cfg *config.Config) {
+		cfg.RateLimit.Routes["POST /api/v1/users/login"] = config.RateLimitRule{Key: "ip", Limit: 3, Period: time.Minute}
+	})
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		status, _, header := server.login(t, "nobody@example.com", "secret123")
+		if status != http.StatusUnauthorized {
+			t.Fatalf("login: status = %d, want %d", status, http.StatusUnauthorized)
+		}
+		if header.Get("RateLimit-Limit") != "3" || header.Get("RateLimit-Remaining") != strconv.Itoa(remaining) ||
+			header.Get("RateLimit-Policy") != "3;w=60" {
+			t.Fatalf("login: RateLimit headers = %v, want %d remaining", header, remaining)
+		}
+	}
+
+	status, response, header := server.login(t, "nobody@example.com", "secret123")
+	expectError(t, "login over limit", status, response, http.StatusTooManyRequests, servererrors.CodeTooManyRequests)
+	// 每 20 秒补充一个令牌
+	if retryAfter, err := strconv.Atoi(header.Get("Retry-After")); err != nil || retryAfter < 1 || retryAfter > 20 {
+		t.Fatalf("Retry-After = %q, want 1-20 seconds", header.Get("Retry-After"))
+	}
+
+	// 其他路由的额度互相独立
+	register := dto.RegisterDto{Username: "alice", Password: "secret123", Email: "alice@example.com"}
+	if status := server.do(t, http.MethodPost, "/api/v1/users/register", "", register, nil); status != http.StatusCreated {
+		t.Fatalf("register: status = %d, want %d", status, http.StatusCreated)
+	}
+}
+
+func TestLoginFailuresAreUniformAndLockTheAccount(t *testing.T) {
+	server := newTestServer(t, func(cfg *config.Config) {
+		cfg.RateLimit.Enabled = false
+		cfg.Lockout = config.LockoutConfig{MaxAttempts: 2, Duration: 300 * time.Millisecond, MaxDuration: time.Hour}
+	})
+	server.registerAndLogin(t, "alice")
+
+	// 邮箱未注册和密码错误的响应完全相同
+	status, unknown, _ := server.login(t, "nobody@example.com", "secret123")
+	expectError(t, "unknown email", status, unknown, http.StatusUnauthorized, servererrors.CodeUnauthorized)
+	status, wrong, _ := server.login(t, "alice@example.com", "wrong-password1")
+	expectError(t, "wrong password", status, wrong, http.StatusUnauthorized, servererrors.CodeUnauthorized)
+	if unknown.Message != wrong.Message {
+		t.Fatalf("messages differ: %q and %q", unknown.Message, wrong.Message)
+	}
+
+	// 第二次失败后锁定, 锁定期间正确的密码也返回同样的错误
+	server.login(t, "alice@example.com", "wrong-password2")
+	status, locked, _ := server.login(t, "alice@example.com", "secret123")
+	expectError(t, "locked account", status, locked, http.StatusUnauthorized, servererrors.CodeUnauthorized)
+	if locked.Message != wrong.Message {
+		t.Fatalf("locked account message = %q, want %q", locked.Message, wrong.Message)
+	}
+
+	// 锁定结束后可以登录, 登录成功清空失败次数
+	time.Sleep(350 * time.Millisecond)
+	if status, _, _ := server.login(t, "alice@example.com", "secret123"); status != http.StatusOK {
+		t.Fatalf("login after lockout: status = %d, want %d", status, http.StatusOK)
+	}
+	server.login(t, "alice@example.com", "wrong-password3")
+	if status, _, _ := server.login(t, "alice@example.com", "secret123"); status != http.StatusOK {
+		t.Fatalf("login after one failure: status = %d, want %d", status, http.StatusOK)
+	}
+}
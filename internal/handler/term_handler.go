@@ -1,15 +1,15 @@
 package handler
 
 import (
-	"errors"
-	"log"
 	"net/http"
 	serverErrors "skymates-api/errors"
 	v1 "skymates-api/internal/dto/v1"
+	"skymates-api/internal/i18n"
 	"skymates-api/internal/model"
 	"skymates-api/internal/service"
-	"skymates-api/internal/validator"
+	"skymates-api/pkg/auth"
 	"strconv"
+	"strings"
 )
 
 // TermHandler 术语处理器
@@ -26,75 +26,115 @@ func NewTermHandler(termService service.TermService) *TermHandler {
 }
 
 // SearchTerms 处理术语搜索请求
-func (h *TermHandler) SearchTerms(w http.ResponseWriter, r *http.Request) {
-	keyword := r.URL.Query().Get("keyword")
+func (h *TermHandler) SearchTerms(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+	keyword := strings.TrimSpace(query.Get("keyword"))
 	if keyword == "" {
-		h.ResponseJSON(w, http.StatusBadRequest, "缺少关键字", nil)
-		return
+		return serverErrors.NewValidationError(i18n.MsgMissingKeyword, nil)
 	}
 
-	terms, err := h.termService.SearchTerms(r.Context(), keyword)
+	var categoryID *int64
+	if categoryIDStr := query.Get("categoryID"); categoryIDStr != "" {
+		id, err := strconv.ParseInt(categoryIDStr, 10, 64)
+		if err != nil {
+			return serverErrors.NewValidationError(i18n.MsgInvalidCategoryID, err)
+		}
+		categoryID = &id
+	}
+
+	// limit 无效时由服务层使用默认分页大小
+	limit, _ := strconv.Atoi(query.Get("limit"))
+
+	// mode 为空时按 text 搜索, 取值由服务层校验
+	result, err := h.termService.SearchTerms(r.Context(), keyword, query.Get("mode"), categoryID, query.Get("cursor"), limit)
 	if err != nil {
-		var serverErr *serverErrors.ServerError
-		if errors.As(err, &serverErr) {
-			h.ResponseJSON(w, http.StatusInternalServerError, serverErr.Message, nil)
-			return
+		return err
+	}
+
+	// 类型转换：model.TermSearchHit -> v1.TermSearchHit
+	hits := make([]v1.TermSearchHit, len(result.Hits))
+	for i, hit := range result.Hits {
+		hits[i] = v1.TermSearchHit{
+			ID:        hit.ID,
+			Name:      hit.Name,
+			Aliases:   hit.Aliases,
+			Highlight: hit.Highlight,
+			Snippet:   hit.Snippet,
 		}
-		h.ResponseJSON(w, http.StatusInternalServerError, "服务器内部错误", nil)
-		log.Printf("TermHandler.SearchTerms: %v", err)
-		return
+	}
+	facets := make([]v1.CategoryFacet, len(result.Facets))
+	for i, facet := range result.Facets {
+		facets[i] = v1.CategoryFacet{CategoryID: facet.CategoryID, Count: facet.Count}
 	}
 
-	// 类型转换：model.TermSummary -> v1.TermSummary
-	v1Terms := make([]v1.TermSummary, len(terms))
-	for i, term := range terms {
-		v1Terms[i] = v1.TermSummary{ID: term.ID, Name: term.Name}
+	response := v1.SearchTermsResponse{
+		Terms:      hits,
+		Total:      result.Total,
+		Facets:     facets,
+		NextCursor: result.NextCursor,
+	}
+	h.ResponseJSON(w, r, http.StatusOK, i18n.MsgOK, response)
+	return nil
+}
+
+// SuggestTerms 处理输入时的搜索建议请求
+func (h *TermHandler) SuggestTerms(w http.ResponseWriter, r *http.Request) error {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		return serverErrors.NewValidationError(i18n.MsgMissingKeyword, nil)
+	}
+	// limit 无效时由服务层使用默认数量
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	suggestions, err := h.termService.SuggestTerms(r.Context(), q, limit)
+	if err != nil {
+		return err
+	}
+
+	// 类型转换：model.TermSuggestion -> v1.TermSuggestion
+	v1Suggestions := make([]v1.TermSuggestion, len(suggestions))
+	for i, suggestion := range suggestions {
+		v1Suggestions[i] = v1.TermSuggestion{ID: suggestion.ID, Name: suggestion.Name, Alias: suggestion.Alias}
 	}
 
-	response := v1.SearchTermsResponse{Terms: v1Terms}
-	h.ResponseJSON(w, http.StatusOK, "成功", response)
+	h.ResponseJSON(w, r, http.StatusOK, i18n.MsgOK, v1.SuggestTermsResponse{Suggestions: v1Suggestions})
+	return nil
 }
 
 // GetTermByID 处理获取术语详情请求
-func (h *TermHandler) GetTermByID(w http.ResponseWriter, r *http.Request) {
+func (h *TermHandler) GetTermByID(w http.ResponseWriter, r *http.Request) error {
 	idStr := r.PathValue("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		h.ResponseJSON(w, http.StatusBadRequest, "无效的术语 ID", nil)
-		return
+		return serverErrors.NewValidationError(i18n.MsgInvalidTermID, err)
 	}
 
 	term, err := h.termService.GetTermByID(r.Context(), id)
 	if err != nil {
-		var serverErr *serverErrors.ServerError
-		if errors.As(err, &serverErr) {
-			h.ResponseJSON(w, http.StatusInternalServerError, serverErr.Message, nil)
-			return
-		}
-		h.ResponseJSON(w, http.StatusInternalServerError, "服务器内部错误", nil)
-		log.Printf("TermHandler.GetTermByID: %v", err)
-		return
+		return err
 	}
 
 	response := v1.TermDetailResponse{
 		ID:          term.ID,
 		Name:        term.Name,
+		Aliases:     term.Aliases,
 		Explanation: term.Explanation,
 		SourceURL:   term.SourceURL,
 		CategoryIDs: term.CategoryIDs,
+		CreatedBy:   term.CreatedBy,
 		CreatedAt:   term.CreatedAt,
 		UpdatedAt:   term.UpdatedAt,
 	}
-	h.ResponseJSON(w, http.StatusOK, "成功", response)
+	h.ResponseJSON(w, r, http.StatusOK, i18n.MsgOK, response)
+	return nil
 }
 
 // ListTermsByCategory 处理列出分类下术语请求
-func (h *TermHandler) ListTermsByCategory(w http.ResponseWriter, r *http.Request) {
+func (h *TermHandler) ListTermsByCategory(w http.ResponseWriter, r *http.Request) error {
 	categoryIDStr := r.PathValue("categoryID")
 	categoryID, err := strconv.ParseInt(categoryIDStr, 10, 64)
 	if err != nil {
-		h.ResponseJSON(w, http.StatusBadRequest, "无效的分类 ID", nil)
-		return
+		return serverErrors.NewValidationError(i18n.MsgInvalidCategoryID, err)
 	}
 
 	lastIDStr := r.URL.Query().Get("lastID")
@@ -102,8 +142,7 @@ func (h *TermHandler) ListTermsByCategory(w http.ResponseWriter, r *http.Request
 	if lastIDStr != "" {
 		id, err := strconv.ParseInt(lastIDStr, 10, 64)
 		if err != nil {
-			h.ResponseJSON(w, http.StatusBadRequest, "无效的 lastID", nil)
-			return
+			return serverErrors.NewValidationError(i18n.MsgInvalidLastID, err)
 		}
 		lastID = &id
 	}
@@ -114,16 +153,12 @@ func (h *TermHandler) ListTermsByCategory(w http.ResponseWriter, r *http.Request
 		limit = 10 // 默认分页大小
 	}
 
-	terms, hasMore, err := h.termService.ListTermsByCategory(r.Context(), categoryID, lastID, limit)
+	// includeDescendants=true 时也列出子孙分类下的术语, 无效的值视为 false
+	includeDescendants, _ := strconv.ParseBool(r.URL.Query().Get("includeDescendants"))
+
+	terms, hasMore, err := h.termService.ListTermsByCategory(r.Context(), categoryID, includeDescendants, lastID, limit)
 	if err != nil {
-		var serverErr *serverErrors.ServerError
-		if errors.As(err, &serverErr) {
-			h.ResponseJSON(w, http.StatusInternalServerError, serverErr.Message, nil)
-			return
-		}
-		h.ResponseJSON(w, http.StatusInternalServerError, "服务器内部错误", nil)
-		log.Printf("TermHandler.ListTermsByCategory: %v", err)
-		return
+		return err
 	}
 
 	// 类型转换：model.TermSummary -> v1.TermSummary
@@ -136,83 +171,197 @@ func (h *TermHandler) ListTermsByCategory(w http.ResponseWriter, r *http.Request
 		Terms:   v1Terms,
 		HasMore: hasMore,
 	}
-	h.ResponseJSON(w, http.StatusOK, "成功", response)
+	h.ResponseJSON(w, r, http.StatusOK, i18n.MsgOK, response)
+	return nil
 }
 
 // CreateTerm 处理创建术语请求
-func (h *TermHandler) CreateTerm(w http.ResponseWriter, r *http.Request) {
+func (h *TermHandler) CreateTerm(w http.ResponseWriter, r *http.Request) error {
 	var req v1.CreateTermRequest
 	if err := h.DecodeJSON(r, &req); err != nil {
-		h.ResponseJSON(w, http.StatusBadRequest, "请求格式无效", nil)
-		return
+		return serverErrors.NewValidationError(i18n.MsgInvalidFormat, err)
 	}
 
-	msg, err := validator.ValidateRequest(req)
-	if err != nil {
-		h.ResponseJSON(w, http.StatusBadRequest, msg, nil)
-		return
+	if err := h.Validate(r, req); err != nil {
+		return err
 	}
 
 	term := &model.Term{
 		Name:        req.Name,
+		Aliases:     req.Aliases,
 		Explanation: req.Explanation,
 		SourceURL:   req.SourceURL,
 	}
 
-	id, err := h.termService.CreateTerm(r.Context(), term, req.CategoryIDs)
+	principal, _ := auth.PrincipalFrom(r.Context())
+	id, err := h.termService.CreateTerm(r.Context(), principal, term, req.CategoryIDs, req.Summary)
 	if err != nil {
-		var serverErr *serverErrors.ServerError
-		if errors.As(err, &serverErr) {
-			h.ResponseJSON(w, http.StatusInternalServerError, serverErr.Message, nil)
-			return
-		}
-		h.ResponseJSON(w, http.StatusInternalServerError, "服务器内部错误", nil)
-		log.Printf("TermHandler.CreateTerm: %v", err)
-		return
+		return err
 	}
 
-	h.ResponseJSON(w, http.StatusCreated, "术语创建成功", map[string]int64{"id": id})
+	h.ResponseJSON(w, r, http.StatusCreated, i18n.MsgTermCreated, map[string]int64{"id": id})
+	return nil
 }
 
 // UpdateTerm 处理更新术语请求
-func (h *TermHandler) UpdateTerm(w http.ResponseWriter, r *http.Request) {
+func (h *TermHandler) UpdateTerm(w http.ResponseWriter, r *http.Request) error {
 	idStr := r.PathValue("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		h.ResponseJSON(w, http.StatusBadRequest, "无效的术语 ID", nil)
-		return
+		return serverErrors.NewValidationError(i18n.MsgInvalidTermID, err)
 	}
 
 	var req v1.UpdateTermRequest
 	if err := h.DecodeJSON(r, &req); err != nil {
-		h.ResponseJSON(w, http.StatusBadRequest, "请求格式无效", nil)
-		return
+		return serverErrors.NewValidationError(i18n.MsgInvalidFormat, err)
 	}
 
-	msg, err := validator.ValidateRequest(req)
-	if err != nil {
-		h.ResponseJSON(w, http.StatusBadRequest, msg, nil)
-		return
+	if err := h.Validate(r, req); err != nil {
+		return err
 	}
 
 	term := &model.Term{
 		ID:          id,
 		Name:        req.Name,
+		Aliases:     req.Aliases,
 		Explanation: req.Explanation,
 		SourceURL:   req.SourceURL,
 	}
 
-	err = h.termService.UpdateTerm(r.Context(), term, req.CategoryIDs)
+	principal, _ := auth.PrincipalFrom(r.Context())
+	if err := h.termService.UpdateTerm(r.Context(), principal, term, req.CategoryIDs, req.Summary); err != nil {
+		return err
+	}
+
+	h.ResponseJSON(w, r, http.StatusOK, i18n.MsgTermUpdated, nil)
+	return nil
+}
+
+// ListTermRevisions 处理列出术语修订版本请求
+func (h *TermHandler) ListTermRevisions(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return serverErrors.NewValidationError(i18n.MsgInvalidTermID, err)
+	}
+
+	revisions, err := h.termService.ListTermRevisions(r.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	// 类型转换：model.TermRevision -> v1.TermRevisionSummary
+	v1Revisions := make([]v1.TermRevisionSummary, len(revisions))
+	for i, revision := range revisions {
+		v1Revisions[i] = revisionSummary(revision)
+	}
+
+	h.ResponseJSON(w, r, http.StatusOK, i18n.MsgOK, v1.ListTermRevisionsResponse{Revisions: v1Revisions})
+	return nil
+}
+
+// GetTermRevision 处理获取术语修订版本请求
+func (h *TermHandler) GetTermRevision(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return serverErrors.NewValidationError(i18n.MsgInvalidTermID, err)
+	}
+	revision, err := parseRevision(r.PathValue("revision"))
+	if err != nil {
+		return err
+	}
+
+	result, err := h.termService.GetTermRevision(r.Context(), id, revision)
+	if err != nil {
+		return err
+	}
+
+	response := v1.TermRevisionResponse{
+		TermRevisionSummary: revisionSummary(*result),
+		TermID:              result.TermID,
+		Name:                result.Name,
+		Aliases:             result.Aliases,
+		Explanation:         result.Explanation,
+		SourceURL:           result.SourceURL,
+		CategoryIDs:         result.CategoryIDs,
+	}
+	h.ResponseJSON(w, r, http.StatusOK, i18n.MsgOK, response)
+	return nil
+}
+
+// DiffTermRevisions 处理比较术语两个修订版本请求, from 和 to 都是必填的版本号
+func (h *TermHandler) DiffTermRevisions(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return serverErrors.NewValidationError(i18n.MsgInvalidTermID, err)
+	}
+	from, err := parseRevision(r.URL.Query().Get("from"))
+	if err != nil {
+		return err
+	}
+	to, err := parseRevision(r.URL.Query().Get("to"))
+	if err != nil {
+		return err
+	}
+
+	result, err := h.termService.DiffTermRevisions(r.Context(), id, from, to)
 	if err != nil {
-		var serverErr *serverErrors.ServerError
-		if errors.As(err, &serverErr) {
-			h.ResponseJSON(w, http.StatusInternalServerError, serverErr.Message, nil)
-			return
+		return err
+	}
+
+	// 类型转换：model.FieldDiff -> v1.FieldDiff
+	fields := make([]v1.FieldDiff, len(result.Fields))
+	for i, field := range result.Fields {
+		changes := make([]v1.TextChange, len(field.Changes))
+		for j, change := range field.Changes {
+			changes[j] = v1.TextChange{Op: change.Op, Text: change.Text}
 		}
-		h.ResponseJSON(w, http.StatusInternalServerError, "服务器内部错误", nil)
-		log.Printf("TermHandler.UpdateTerm: %v", err)
-		return
+		fields[i] = v1.FieldDiff{Field: field.Field, Changes: changes}
+	}
+
+	h.ResponseJSON(w, r, http.StatusOK, i18n.MsgOK, v1.TermRevisionDiffResponse{From: result.From, To: result.To, Fields: fields})
+	return nil
+}
+
+// RollbackTerm 处理把术语回滚到一个修订版本的请求
+func (h *TermHandler) RollbackTerm(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return serverErrors.NewValidationError(i18n.MsgInvalidTermID, err)
+	}
+	revision, err := parseRevision(r.PathValue("revision"))
+	if err != nil {
+		return err
 	}
 
-	h.ResponseJSON(w, http.StatusOK, "术语更新成功", nil)
+	principal, _ := auth.PrincipalFrom(r.Context())
+	if err := h.termService.RollbackTerm(r.Context(), principal, id, revision); err != nil {
+		return err
+	}
+
+	h.ResponseJSON(w, r, http.StatusOK, i18n.MsgTermRolledBack, nil)
+	return nil
+}
+
+// parseRevision 解析版本号, 版本号从 1 开始
+func parseRevision(s string) (int, error) {
+	revision, err := strconv.Atoi(s)
+	if err != nil || revision <= 0 {
+		return 0, serverErrors.NewValidationError(i18n.MsgInvalidRevision, err)
+	}
+	return revision, nil
+}
+
+func revisionSummary(revision model.TermRevision) v1.TermRevisionSummary {
+	changedFields := revision.ChangedFields
+	if changedFields == nil {
+		changedFields = []string{}
+	}
+	return v1.TermRevisionSummary{
+		Revision:         revision.Revision,
+		ChangedFields:    changedFields,
+		Summary:          revision.Summary,
+		RestoredRevision: revision.RestoredRevision,
+		CreatedBy:        revision.CreatedBy,
+		CreatedAt:        revision.CreatedAt,
+	}
 }
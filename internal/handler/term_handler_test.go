@@ -0,0 +1,331 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+
+	servererrors "skymates-api/errors"
+	dto "skymates-api/internal/dto/v1"
+)
+
+func TestCreateTermRequiresAuthentication(t *testing.T) {
+	server := newTestServer(t)
+
+	req := dto.CreateTermRequest{Name: "Jet Lag", Explanation: "时差反应"}
+	if status := server.do(t, http.MethodPost, "/api/v1/terms", "", req, nil); status != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status := server.do(t, http.MethodPost, "/api/v1/terms", "not-a-token", req, nil); status != http.StatusUnauthorized {
+		t.Fatalf("invalid token: status = %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestCreateAndGetTerm(t *testing.T) {
+	server := newTestServer(t)
+	server.createCategories(t, 3)
+	tokens := server.registerAndLogin(t, "alice")
+
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	// 重复的分类 ID 只关联一次
+	req := dto.CreateTermRequest{Name: "Jet Lag", Explanation: "时差反应", CategoryIDs: []int64{3, 1, 3}}
+	if status := server.do(t, http.MethodPost, "/api/v1/terms", tokens.AccessToken, req, &created); status != http.StatusCreated {
+		t.Fatalf("create: status = %d, want %d", status, http.StatusCreated)
+	}
+
+	var term dto.TermDetailResponse
+	if status := server.do(t, http.MethodGet, fmt.Sprintf("/api/v1/terms/%d", created.ID), "", nil, &term); status != http.StatusOK {
+		t.Fatalf("get: status = %d, want %d", status, http.StatusOK)
+	}
+	if term.Name != req.Name || term.Explanation != req.Explanation {
+		t.Fatalf("term = %+v, want name %q and explanation %q", term, req.Name, req.Explanation)
+	}
+	if !slices.Equal(term.CategoryIDs, []int64{1, 3}) {
+		t.Fatalf("category_ids = %v, want [1 3]", term.CategoryIDs)
+	}
+	if term.CreatedBy == nil {
+		t.Fatal("created_by should be set to the creator")
+	}
+
+	var search dto.SearchTermsResponse
+	if status := server.do(t, http.MethodGet, "/api/v1/terms/search?keyword=jet", "", nil, &search); status != http.StatusOK {
+		t.Fatalf("search: status = %d, want %d", status, http.StatusOK)
+	}
+	if len(search.Terms) != 1 || search.Terms[0].ID != created.ID {
+		t.Fatalf("search terms = %+v, want term %d", search.Terms, created.ID)
+	}
+
+	var list dto.ListTermsByCategoryResponse
+	if status := server.do(t, http.MethodGet, "/api/v1/categories/3/terms", "", nil, &list); status != http.StatusOK {
+		t.Fatalf("list: status = %d, want %d", status, http.StatusOK)
+	}
+	if len(list.Terms) != 1 || list.HasMore {
+		t.Fatalf("list = %+v, want one term without more pages", list)
+	}
+}
+
+func TestUpdateTermByOwner(t *testing.T) {
+	server := newTestServer(t)
+	server.createCategories(t, 2)
+	tokens := server.registerAndLogin(t, "alice")
+
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	req := dto.CreateTermRequest{Name: "Jet Lag", Explanation: "时差反应"}
+	if status := server.do(t, http.MethodPost, "/api/v1/terms", tokens.AccessToken, req, &created); status != http.StatusCreated {
+		t.Fatalf("create: status = %d, want %d", status, http.StatusCreated)
+	}
+
+	path := fmt.Sprintf("/api/v1/terms/%d", created.ID)
+	update := dto.UpdateTermRequest{Name: "Jet Lag", Explanation: "跨时区飞行后的生物钟紊乱", CategoryIDs: []int64{2}}
+	if status := server.do(t, http.MethodPut, path, tokens.AccessToken, update, nil); status != http.StatusOK {
+		t.Fatalf("update: status = %d, want %d", status, http.StatusOK)
+	}
+
+	var term dto.TermDetailResponse
+	if status := server.do(t, http.MethodGet, path, "", nil, &term); status != http.StatusOK {
+		t.Fatalf("get: status = %d, want %d", status, http.StatusOK)
+	}
+	if term.Explanation != update.Explanation || !slices.Equal(term.CategoryIDs, []int64{2}) {
+		t.Fatalf("term = %+v, want updated explanation and categories", term)
+	}
+
+	update.CategoryIDs = []int64{2, 3}
+	status, response := server.request(t, http.MethodPut, path, tokens.AccessToken, update, nil)
+	expectError(t, "unknown category", status, response, http.StatusBadRequest, servererrors.CodeValidation)
+}
+
+func TestSearchTerms(t *testing.T) {
+	server := newTestServer(t)
+	server.createCategories(t, 2)
+	tokens := server.registerAndLogin(t, "alice")
+
+	terms := []dto.CreateTermRequest{
+		{Name: "Visual Flight Rules", Aliases: []string{"VFR", " 目视  飞行规则 ", "vfr"}, Explanation: "在目视气象条件下飞行的规则", CategoryIDs: []int64{1}},
+		{Name: "VFR Chart", Explanation: "供目视飞行使用的航图", CategoryIDs: []int64{1, 2}},
+		{Name: "Sectional", Explanation: "美国常用的 VFR <航图>, 比例尺为 1:500,000", CategoryIDs: []int64{2}},
+	}
+	for _, term := range terms {
+		if status := server.do(t, http.MethodPost, "/api/v1/terms", tokens.AccessToken, term, nil); status != http.StatusCreated {
+			t.Fatalf("create %q: status = %d, want %d", term.Name, status, http.StatusCreated)
+		}
+	}
+
+	// 与别名完全相同的术语排在名称以关键字开头的术语之前, 只在解释中出现的排在最后
+	var result dto.SearchTermsResponse
+	if status := server.do(t, http.MethodGet, "/api/v1/terms/search?keyword=vfr", "", nil, &result); status != http.StatusOK {
+		t.Fatalf("search: status = %d, want %d", status, http.StatusOK)
+	}
+	var names []string
+	for _, hit := range result.Terms {
+		names = append(names, hit.Name)
+	}
+	if !slices.Equal(names, []string{"Visual Flight Rules", "VFR Chart", "Sectional"}) || result.Total != 3 || result.NextCursor != "" {
+		t.Fatalf("search = %+v, want three ranked terms on one page", result)
+	}
+	if !slices.Equal(result.Terms[0].Aliases, []string{"VFR", "目视 飞行规则"}) {
+		t.Fatalf("aliases = %q, want normalized aliases without duplicates", result.Terms[0].Aliases)
+	}
+	if hit := result.Terms[1]; hit.Highlight != "<mark>VFR</mark> Chart" {
+		t.Fatalf("highlight = %q", hit.Highlight)
+	}
+	if hit := result.Terms[2]; hit.Snippet != "美国常用的 <mark>VFR</mark> &lt;航图&gt;, 比例尺为 1:500,000" {
+		t.Fatalf("snippet = %q, want the escaped explanation with the keyword marked", hit.Snippet)
+	}
+	if !slices.Equal(result.Facets, []dto.CategoryFacet{{CategoryID: 1, Count: 2}, {CategoryID: 2, Count: 2}}) {
+		t.Fatalf("facets = %+v", result.Facets)
+	}
+
+	// 按游标翻页, 每个术语只出现一次
+	var pages []string
+	cursor := ""
+	for range 3 {
+		var page dto.SearchTermsResponse
+		path := "/api/v1/terms/search?keyword=vfr&limit=2&cursor=" + cursor
+		if status := server.do(t, http.MethodGet, path, "", nil, &page); status != http.StatusOK {
+			t.Fatalf("page: status = %d, want %d", status, http.StatusOK)
+		}
+		for _, hit := range page.Terms {
+			pages = append(pages, hit.Name)
+		}
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+	if !slices.Equal(pages, names) {
+		t.Fatalf("paged results = %v, want %v", pages, names)
+	}
+
+	var filtered dto.SearchTermsResponse
+	if status := server.do(t, http.MethodGet, "/api/v1/terms/search?keyword=vfr&categoryID=2", "", nil, &filtered); status != http.StatusOK {
+		t.Fatalf("filtered search: status = %d, want %d", status, http.StatusOK)
+	}
+	if filtered.Total != 2 || len(filtered.Terms) != 2 || filtered.Terms[0].Name != "VFR Chart" {
+		t.Fatalf("filtered search = %+v, want the two terms in category 2", filtered)
+	}
+
+	// 游标不能超过结果上限
+	beyondCap := base64.RawURLEncoding.EncodeToString([]byte("200"))
+	for _, path := range []string{
+		"/api/v1/terms/search?keyword=vfr&cursor=not-a-cursor",
+		"/api/v1/terms/search?keyword=vfr&cursor=" + beyondCap,
+		"/api/v1/terms/search?keyword=vfr&categoryID=x",
+		"/api/v1/terms/search?keyword=%20",
+		"/api/v1/terms/search?keyword=vfr&mode=fuzzy",
+	} {
+		status, response := server.request(t, http.MethodGet, path, "", nil, nil)
+		expectError(t, path, status, response, http.StatusBadRequest, servererrors.CodeValidation)
+	}
+}
+
+func TestSearchTermsInChinese(t *testing.T) {
+	server := newTestServer(t)
+	tokens := server.registerAndLogin(t, "alice")
+
+	for _, term := range []dto.CreateTermRequest{
+		{Name: "飞行", Explanation: "航空器在空中的运动"},
+		{Name: "飛行計劃", Explanation: "向空中交通管制部门提交的飞行计划"},
+		{Name: "Visual Flight Rules", Aliases: []string{"目视飞行规则"}, Explanation: "目视气象条件下的规则"},
+		{Name: "侧风", Explanation: "与跑道方向垂直的风分量, feixing 时需要修正"},
+	} {
+		if status := server.do(t, http.MethodPost, "/api/v1/terms", tokens.AccessToken, term, nil); status != http.StatusCreated {
+			t.Fatalf("create %q: status = %d, want %d", term.Name, status, http.StatusCreated)
+		}
+	}
+
+	search := func(t *testing.T, query string) dto.SearchTermsResponse {
+		t.Helper()
+		var result dto.SearchTermsResponse
+		if status := server.do(t, http.MethodGet, "/api/v1/terms/search?"+query, "", nil, &result); status != http.StatusOK {
+			t.Fatalf("search %s: status = %d, want %d", query, status, http.StatusOK)
+		}
+		return result
+	}
+
+	for _, tc := range []struct {
+		name      string
+		query     string
+		want      []string
+		highlight string // 第一条结果的高亮
+	}{
+		// 繁体关键字找到简体名称, 简体关键字也找到繁体名称, 高亮标出原文
+		{"Traditional", "keyword=" + url.QueryEscape("飛行"), []string{"飞行", "飛行計劃", "Visual Flight Rules"}, "<mark>飞行</mark>"},
+		{"Simplified", "keyword=" + url.QueryEscape("飞行计划"), []string{"飛行計劃"}, "<mark>飛行計劃</mark>"},
+		// 拼音只匹配名称和别名, 解释中的 "feixing" 不算
+		{"FullPinyin", "mode=pinyin&keyword=feixing", []string{"飞行", "飛行計劃", "Visual Flight Rules"}, "<mark>飞行</mark>"},
+		{"SeparatedPinyin", "mode=pinyin&keyword=" + url.QueryEscape("fei'xing"), []string{"飞行", "飛行計劃", "Visual Flight Rules"}, "<mark>飞行</mark>"},
+		{"Initials", "mode=pinyin&keyword=FX", []string{"飞行", "飛行計劃", "Visual Flight Rules"}, "<mark>飞行</mark>"},
+		{"PartialSyllable", "mode=pinyin&keyword=cef", []string{"侧风"}, "<mark>侧风</mark>"},
+		{"ChineseInPinyinMode", "mode=pinyin&keyword=" + url.QueryEscape("飛行計"), []string{"飛行計劃"}, "<mark>飛行計</mark>劃"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			result := search(t, tc.query)
+			var names []string
+			for _, hit := range result.Terms {
+				names = append(names, hit.Name)
+			}
+			if !slices.Equal(names, tc.want) || result.Total != len(tc.want) {
+				t.Fatalf("search %s = %v (total %d), want %v", tc.query, names, result.Total, tc.want)
+			}
+			if got := result.Terms[0].Highlight; got != tc.highlight {
+				t.Fatalf("highlight = %q, want %q", got, tc.highlight)
+			}
+		})
+	}
+
+	// 没有可以转换为拼音的字符时不匹配任何术语
+	if result := search(t, "mode=pinyin&keyword=%21%21"); result.Total != 0 || len(result.Terms) != 0 {
+		t.Fatalf("search for punctuation = %+v, want no terms", result)
+	}
+}
+
+func TestSuggestTerms(t *testing.T) {
+	server := newTestServer(t)
+	tokens := server.registerAndLogin(t, "alice")
+
+	ids := map[string]int64{}
+	for _, term := range []dto.CreateTermRequest{
+		{Name: "Visual Flight Rules", Aliases: []string{"目视飞行规则"}, Explanation: "目视飞行规则"},
+		{Name: "VFR Chart", Explanation: "目视飞行航图"},
+		{Name: "Flight Level", Explanation: "飞行高度层"},
+		{Name: "Föhn", Explanation: "焚风"},
+		{Name: "Crosswind", Explanation: "侧风"},
+	} {
+		var created struct {
+			ID int64 `json:"id"`
+		}
+		if status := server.do(t, http.MethodPost, "/api/v1/terms", tokens.AccessToken, term, &created); status != http.StatusCreated {
+			t.Fatalf("create %q: status = %d, want %d", term.Name, status, http.StatusCreated)
+		}
+		ids[term.Name] = created.ID
+	}
+
+	suggest := func(t *testing.T, query string) []dto.TermSuggestion {
+		t.Helper()
+		var result dto.SuggestTermsResponse
+		if status := server.do(t, http.MethodGet, "/api/v1/terms/suggest?"+query, "", nil, &result); status != http.StatusOK {
+			t.Fatalf("suggest %s: status = %d, want %d", query, status, http.StatusOK)
+		}
+		return result.Suggestions
+	}
+	names := func(suggestions []dto.TermSuggestion) []string {
+		result := []string{}
+		for _, suggestion := range suggestions {
+			result = append(result, suggestion.Name)
+		}
+		return result
+	}
+
+	for _, tc := range []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"abbreviation before name prefix", "q=vfr", []string{"Visual Flight Rules", "VFR Chart"}},
+		{"name prefix before later word", "q=FLIGHT", []string{"Flight Level", "Visual Flight Rules"}},
+		{"accent folding", "q=fohn", []string{"Föhn"}},
+		{"accented query", "q=F%C3%96H", []string{"Föhn"}},
+		{"typo", "q=fligt", []string{"Flight Level", "Visual Flight Rules"}},
+		{"two typos in a long query", "q=crosswnid", []string{"Crosswind"}},
+		{"limit", "q=vfr&limit=1", []string{"Visual Flight Rules"}},
+		{"short queries are not fuzzy", "q=vfx", []string{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := names(suggest(t, tc.query)); !slices.Equal(got, tc.want) {
+				t.Fatalf("suggest %s = %v, want %v", tc.query, got, tc.want)
+			}
+		})
+	}
+
+	alias := suggest(t, "q=%E7%9B%AE%E8%A7%86")
+	if len(alias) != 1 || alias[0].ID != ids["Visual Flight Rules"] || alias[0].Alias != "目视飞行规则" {
+		t.Fatalf("suggest by alias = %+v, want Visual Flight Rules with the matched alias", alias)
+	}
+
+	// 更新术语后索引立即更新, 重建索引后结果不变
+	update := dto.UpdateTermRequest{Name: "Headwind", Explanation: "逆风"}
+	if status := server.do(t, http.MethodPut, fmt.Sprintf("/api/v1/terms/%d", ids["Crosswind"]), tokens.AccessToken, update, nil); status != http.StatusOK {
+		t.Fatalf("update: status = %d, want %d", status, http.StatusOK)
+	}
+	for range 2 {
+		if got := names(suggest(t, "q=cross")); len(got) != 0 {
+			t.Fatalf("old name still suggested: %v", got)
+		}
+		if got := names(suggest(t, "q=head")); !slices.Equal(got, []string{"Headwind"}) {
+			t.Fatalf("suggest head = %v, want [Headwind]", got)
+		}
+		if err := server.services.TermService.RebuildSuggestIndex(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	status, response := server.request(t, http.MethodGet, "/api/v1/terms/suggest?q=%20", "", nil, nil)
+	expectError(t, "blank query", status, response, http.StatusBadRequest, servererrors.CodeValidation)
+}
@@ -0,0 +1,163 @@
+package handler_test
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+
+	servererrors "skymates-api/errors"
+	dto "skymates-api/internal/dto/v1"
+)
+
+func TestTermRevisions(t *testing.T) {
+	server := newTestServer(t)
+	server.createCategories(t, 2)
+	tokens := server.registerAndLogin(t, "alice")
+
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	create := dto.CreateTermRequest{Name: "Flap", Aliases: []string{"襟翼"}, Explanation: "A hinged surface on the wing", CategoryIDs: []int64{1}, Summary: "initial"}
+	if status := server.do(t, http.MethodPost, "/api/v1/terms", tokens.AccessToken, create, &created); status != http.StatusCreated {
+		t.Fatalf("create: status = %d, want %d", status, http.StatusCreated)
+	}
+	base := fmt.Sprintf("/api/v1/terms/%d", created.ID)
+
+	update := dto.UpdateTermRequest{Name: "Flap", Aliases: []string{"襟翼"}, Explanation: "A hinged surface on the trailing edge of the wing", CategoryIDs: []int64{1, 2, 1}, Summary: "more detail"}
+	if status := server.do(t, http.MethodPut, base, tokens.AccessToken, update, nil); status != http.StatusOK {
+		t.Fatalf("update: status = %d, want %d", status, http.StatusOK)
+	}
+	// 内容没有变化的更新成功返回, 但不追加版本
+	if status := server.do(t, http.MethodPut, base, tokens.AccessToken, update, nil); status != http.StatusOK {
+		t.Fatalf("unchanged update: status = %d, want %d", status, http.StatusOK)
+	}
+
+	var list dto.ListTermRevisionsResponse
+	if status := server.do(t, http.MethodGet, base+"/revisions", "", nil, &list); status != http.StatusOK {
+		t.Fatalf("list: status = %d, want %d", status, http.StatusOK)
+	}
+	if len(list.Revisions) != 2 || list.Revisions[0].Revision != 2 || list.Revisions[0].Summary != "more detail" {
+		t.Fatalf("revisions = %+v, want 2 then 1", list.Revisions)
+	}
+	if fields := list.Revisions[0].ChangedFields; !slices.Equal(fields, []string{"explanation", "category_ids"}) {
+		t.Fatalf("changed fields = %v, want explanation and category_ids", fields)
+	}
+	if list.Revisions[1].CreatedBy == nil || len(list.Revisions[1].ChangedFields) != 5 {
+		t.Fatalf("first revision = %+v, want an author and all fields", list.Revisions[1])
+	}
+
+	var latest dto.TermRevisionResponse
+	if status := server.do(t, http.MethodGet, base+"/revisions/2", "", nil, &latest); status != http.StatusOK {
+		t.Fatalf("get latest: status = %d, want %d", status, http.StatusOK)
+	}
+	if !slices.Equal(latest.CategoryIDs, []int64{1, 2}) {
+		t.Fatalf("revision 2 category_ids = %v, want [1 2]", latest.CategoryIDs)
+	}
+
+	var revision dto.TermRevisionResponse
+	if status := server.do(t, http.MethodGet, base+"/revisions/1", "", nil, &revision); status != http.StatusOK {
+		t.Fatalf("get: status = %d, want %d", status, http.StatusOK)
+	}
+	if revision.Explanation != create.Explanation || !slices.Equal(revision.CategoryIDs, []int64{1}) || revision.Summary != "initial" {
+		t.Fatalf("revision 1 = %+v, want the created snapshot", revision)
+	}
+
+	var diff dto.TermRevisionDiffResponse
+	if status := server.do(t, http.MethodGet, base+"/revisions/diff?from=1&to=2", "", nil, &diff); status != http.StatusOK {
+		t.Fatalf("diff: status = %d, want %d", status, http.StatusOK)
+	}
+	if len(diff.Fields) != 2 || diff.Fields[0].Field != "explanation" || diff.Fields[1].Field != "category_ids" {
+		t.Fatalf("diff fields = %+v, want explanation and category_ids", diff.Fields)
+	}
+	want := []dto.TextChange{
+		{Op: "equal", Text: "A hinged surface on the "},
+		{Op: "insert", Text: "trailing edge of the "},
+		{Op: "equal", Text: "wing"},
+	}
+	if !slices.Equal(diff.Fields[0].Changes, want) {
+		t.Fatalf("explanation changes = %+v, want %+v", diff.Fields[0].Changes, want)
+	}
+
+	for _, c := range []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantCode   int
+	}{
+		{"missing revision", base + "/revisions/3", http.StatusNotFound, servererrors.CodeNotFound},
+		{"invalid revision", base + "/revisions/0", http.StatusBadRequest, servererrors.CodeValidation},
+		{"diff without to", base + "/revisions/diff?from=1", http.StatusBadRequest, servererrors.CodeValidation},
+		{"diff missing revision", base + "/revisions/diff?from=1&to=9", http.StatusNotFound, servererrors.CodeNotFound},
+		{"missing term", "/api/v1/terms/999/revisions", http.StatusNotFound, servererrors.CodeNotFound},
+	} {
+		status, response := server.request(t, http.MethodGet, c.path, "", nil, nil)
+		expectError(t, c.name, status, response, c.wantStatus, c.wantCode)
+	}
+}
+
+func TestRollbackTerm(t *testing.T) {
+	server := newTestServer(t)
+	server.createCategories(t, 2)
+	admin := server.registerAdmin(t, "admin")
+	tokens := server.registerAndLogin(t, "alice")
+
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	create := dto.CreateTermRequest{Name: "Flap", Explanation: "original", CategoryIDs: []int64{1}}
+	if status := server.do(t, http.MethodPost, "/api/v1/terms", tokens.AccessToken, create, &created); status != http.StatusCreated {
+		t.Fatalf("create: status = %d, want %d", status, http.StatusCreated)
+	}
+	base := fmt.Sprintf("/api/v1/terms/%d", created.ID)
+	update := dto.UpdateTermRequest{Name: "Flaps", Aliases: []string{"襟翼"}, Explanation: "vandalized", CategoryIDs: []int64{2}}
+	if status := server.do(t, http.MethodPut, base, tokens.AccessToken, update, nil); status != http.StatusOK {
+		t.Fatalf("update: status = %d, want %d", status, http.StatusOK)
+	}
+
+	status, response := server.request(t, http.MethodPost, base+"/revisions/1/rollback", "", nil, nil)
+	expectError(t, "anonymous", status, response, http.StatusUnauthorized, servererrors.CodeUnauthorized)
+	status, response = server.request(t, http.MethodPost, base+"/revisions/1/rollback", tokens.AccessToken, nil, nil)
+	expectError(t, "owner", status, response, http.StatusForbidden, servererrors.CodeForbidden)
+	status, response = server.request(t, http.MethodPost, base+"/revisions/5/rollback", admin.AccessToken, nil, nil)
+	expectError(t, "missing revision", status, response, http.StatusNotFound, servererrors.CodeNotFound)
+
+	if status := server.do(t, http.MethodPost, base+"/revisions/1/rollback", admin.AccessToken, nil, nil); status != http.StatusOK {
+		t.Fatalf("rollback: status = %d, want %d", status, http.StatusOK)
+	}
+
+	var term dto.TermDetailResponse
+	if status := server.do(t, http.MethodGet, base, "", nil, &term); status != http.StatusOK {
+		t.Fatalf("get: status = %d, want %d", status, http.StatusOK)
+	}
+	if term.Name != "Flap" || term.Explanation != "original" || len(term.Aliases) != 0 || !slices.Equal(term.CategoryIDs, []int64{1}) {
+		t.Fatalf("term = %+v, want the content of revision 1", term)
+	}
+
+	// 回滚追加新的版本, 不删除被撤销的版本
+	var list dto.ListTermRevisionsResponse
+	if status := server.do(t, http.MethodGet, base+"/revisions", "", nil, &list); status != http.StatusOK {
+		t.Fatalf("list: status = %d, want %d", status, http.StatusOK)
+	}
+	if len(list.Revisions) != 3 {
+		t.Fatalf("revisions = %+v, want 3", list.Revisions)
+	}
+	latest := list.Revisions[0]
+	if latest.Revision != 3 || latest.RestoredRevision == nil || *latest.RestoredRevision != 1 || latest.CreatedBy == nil {
+		t.Fatalf("latest revision = %+v, want revision 3 restoring 1", latest)
+	}
+	if !slices.Equal(latest.ChangedFields, []string{"name", "aliases", "explanation", "category_ids"}) {
+		t.Fatalf("changed fields = %v", latest.ChangedFields)
+	}
+
+	// 恢复到与当前内容相同的版本时不追加版本
+	if status := server.do(t, http.MethodPost, base+"/revisions/3/rollback", admin.AccessToken, nil, nil); status != http.StatusOK {
+		t.Fatalf("rollback to the current revision: status = %d, want %d", status, http.StatusOK)
+	}
+	if status := server.do(t, http.MethodGet, base+"/revisions", "", nil, &list); status != http.StatusOK {
+		t.Fatalf("list: status = %d, want %d", status, http.StatusOK)
+	}
+	if len(list.Revisions) != 3 {
+		t.Fatalf("revisions = %+v, want 3", list.Revisions)
+	}
+}
@@ -0,0 +1,111 @@
+package handler_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	dto "skymates-api/internal/dto/v1"
+	"skymates-api/pkg/tracing"
+)
+
+// recordingExporter 在内存中保存导出的 span
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []tracing.SpanData
+}
+
+func (e *recordingExporter) Export(ctx context.Context, spans []tracing.SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *recordingExporter) Shutdown(ctx context.Context) error { return nil }
+
+// find 返回指定名称的 span, 不存在时测试失败
+func (e *recordingExporter) find(t *testing.T, name string) tracing.SpanData {
+	t.Helper()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, span := range e.spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	var names []string
+	for _, span := range e.spans {
+		names = append(names, span.Name)
+	}
+	t.Fatalf("span %q not found, got %v", name, names)
+	return tracing.SpanData{}
+}
+
+func TestTracingPropagatesThroughServiceAndRepository(t *testing.T) {
+	exporter := &recordingExporter{}
+	tracer := tracing.NewTracer(exporter, tracing.Options{SampleRatio: 1})
+	tracing.SetDefault(tracer)
+	t.Cleanup(func() { tracing.SetDefault(nil) })
+
+	server := newTestServer(t)
+	server.createCategories(t, 1)
+	tokens := server.registerAndLogin(t, "alice")
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	term := dto.CreateTermRequest{Name: "Jet Lag", Explanation: "时差反应", CategoryIDs: []int64{1}}
+	if status := server.do(t, http.MethodPost, "/api/v1/terms", tokens.AccessToken, term, &created); status != http.StatusCreated {
+		t.Fatalf("create: status = %d, want %d", status, http.StatusCreated)
+	}
+
+	const upstream = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/api/v1/terms/%d", server.URL, created.ID), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(tracing.TraceparentHeader, upstream)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// 响应头返回同一条调用链中本服务的 span
+	returned, ok := tracing.ParseTraceparent(resp.Header.Get(tracing.TraceparentHeader))
+	if !ok || returned.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || returned.SpanID.String() == "00f067aa0ba902b7" {
+		t.Fatalf("response traceparent = %q, want a child of %q", resp.Header.Get(tracing.TraceparentHeader), upstream)
+	}
+
+	if err := tracer.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	request := exporter.find(t, "GET /api/v1/terms/{id}")
+	if request.ParentSpanID.String() != "00f067aa0ba902b7" || request.SpanID != returned.SpanID {
+		t.Fatalf("request span parent = %s, want the upstream span", request.ParentSpanID)
+	}
+	service := exporter.find(t, "TermService.GetTermByID")
+	if service.ParentSpanID != request.SpanID {
+		t.Fatalf("service span parent = %s, want request span %s", service.ParentSpanID, request.SpanID)
+	}
+	for _, statement := range []string{"terms.get_by_id", "term_category_relations.list_by_term"} {
+		span := exporter.find(t, statement)
+		if span.ParentSpanID != service.SpanID || span.TraceID != service.TraceID {
+			t.Fatalf("%s span parent = %s, want service span %s", statement, span.ParentSpanID, service.SpanID)
+		}
+		if !hasAttribute(span, "db.system", "sqlite") || !hasAttribute(span, "db.statement.name", statement) {
+			t.Fatalf("%s span attributes = %v", statement, span.Attributes)
+		}
+	}
+}
+
+func hasAttribute(span tracing.SpanData, key string, value interface{}) bool {
+	for _, attr := range span.Attributes {
+		if attr.Key == key && attr.Value == value {
+			return true
+		}
+	}
+	return false
+}
@@ -1,102 +1,189 @@
 package handler
 
 import (
-	"errors"
-	"log"
 	"net/http"
 	serverErrors "skymates-api/errors"
 	v1 "skymates-api/internal/dto/v1"
+	"skymates-api/internal/i18n"
+	"skymates-api/internal/model"
 	"skymates-api/internal/service"
-	"skymates-api/internal/validator"
+	"skymates-api/pkg/auth"
+	"time"
 )
 
 // UserHandler 用户处理器
 type UserHandler struct {
 	BaseHandler
-	userService service.UserService
+	userService  service.UserService
+	tokenService service.TokenService
 }
 
 // NewUserHandler 创建用户处理器
-func NewUserHandler(userService service.UserService) *UserHandler {
+func NewUserHandler(userService service.UserService, tokenService service.TokenService) *UserHandler {
 	return &UserHandler{
-		userService: userService,
+		userService:  userService,
+		tokenService: tokenService,
 	}
 }
 
 // Register 处理用户注册
-func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
+func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) error {
 	var registerDto v1.RegisterDto
 	if err := h.DecodeJSON(r, &registerDto); err != nil {
-		h.ResponseJSON(w, http.StatusBadRequest, "invalid request format", nil)
-		return
+		return serverErrors.NewValidationError(i18n.MsgInvalidFormat, err)
 	}
 
 	// 基本验证
-	msg, err := validator.ValidateRequest(registerDto)
-	if err != nil {
-		h.ResponseJSON(w, http.StatusBadRequest, msg, nil)
-		return
+	if err := h.Validate(r, registerDto); err != nil {
+		return err
 	}
 
-	// 调用服务层注册用户
-	user, err := h.userService.Register(registerDto)
+	// 调用服务层注册用户, 用户名或邮箱已存在时返回 409
+	user, err := h.userService.Register(r.Context(), registerDto)
 	if err != nil {
-		var serverErr *serverErrors.ServerError
-		if errors.As(err, &serverErr) {
-			switch serverErr.Kind {
-			case serverErrors.KindValidation:
-				h.ResponseJSON(w, http.StatusConflict, serverErr.Message, nil)
-			default:
-				h.ResponseJSON(w, http.StatusInternalServerError, "internal server error", nil)
-				log.Printf("UserHandler.Register: %v", err)
-			}
-			return
-		}
-
-		h.ResponseJSON(w, http.StatusInternalServerError, "internal server error", nil)
-		log.Printf("UserHandler.Register: %v", err)
-		return
-	}
-
-	h.ResponseJSON(w, http.StatusCreated, "user created successfully", user)
+		return err
+	}
+
+	h.ResponseJSON(w, r, http.StatusCreated, i18n.MsgUserCreated, user)
+	return nil
 }
 
 // Login 处理用户登录
-func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
+func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) error {
 	var loginDto v1.LoginDto
 	if err := h.DecodeJSON(r, &loginDto); err != nil {
-		h.ResponseJSON(w, http.StatusBadRequest, "invalid request format", nil)
-		return
+		return serverErrors.NewValidationError(i18n.MsgInvalidFormat, err)
 	}
 
 	// 基本验证
-	msg, err := validator.ValidateRequest(loginDto)
+	if err := h.Validate(r, loginDto); err != nil {
+		return err
+	}
+
+	user, tokens, err := h.userService.Login(r.Context(), loginDto)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{"token": newTokenDto(tokens), "user": user}
+	h.ResponseJSON(w, r, http.StatusOK, i18n.MsgLoginSucceeded, data)
+	return nil
+}
+
+// Refresh 使用刷新令牌换取新的访问令牌和刷新令牌
+func (h *UserHandler) Refresh(w http.ResponseWriter, r *http.Request) error {
+	var refreshDto v1.RefreshTokenDto
+	if err := h.DecodeJSON(r, &refreshDto); err != nil {
+		return serverErrors.NewValidationError(i18n.MsgInvalidFormat, err)
+	}
+
+	if err := h.Validate(r, refreshDto); err != nil {
+		return err
+	}
+
+	tokens, err := h.tokenService.Refresh(r.Context(), refreshDto.RefreshToken)
 	if err != nil {
-		h.ResponseJSON(w, http.StatusBadRequest, msg, nil)
-		return
+		return err
 	}
 
-	user, token, err := h.userService.Login(loginDto)
+	h.ResponseJSON(w, r, http.StatusOK, i18n.MsgTokenRefreshed, newTokenDto(tokens))
+	return nil
+}
+
+// Logout 吊销当前会话, 客户端之后需要重新登录
+func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) error {
+	principal, _ := auth.PrincipalFrom(r.Context())
+	if err := h.tokenService.Logout(r.Context(), principal); err != nil {
+		return err
+	}
+
+	h.ResponseJSON(w, r, http.StatusOK, i18n.MsgLogoutSucceeded, nil)
+	return nil
+}
+
+// GetMe 返回当前用户的完整资料
+func (h *UserHandler) GetMe(w http.ResponseWriter, r *http.Request) error {
+	principal, _ := auth.PrincipalFrom(r.Context())
+	user, err := h.userService.GetUserById(r.Context(), principal.UserID)
 	if err != nil {
-		var serverErr *serverErrors.ServerError
-		if errors.As(err, &serverErr) {
-			switch serverErr.Kind {
-			case serverErrors.KindNotFound:
-				h.ResponseJSON(w, http.StatusNotFound, "user not found", nil)
-			case serverErrors.KindUnauthorized:
-				h.ResponseJSON(w, http.StatusUnauthorized, "invalid credentials", nil)
-			default:
-				h.ResponseJSON(w, http.StatusInternalServerError, "internal server error", nil)
-				log.Printf("UserHandler.Login: %v", err)
-			}
-			return
-		}
-
-		h.ResponseJSON(w, http.StatusInternalServerError, "internal server error", nil)
-		log.Printf("UserHandler.Login: %v", err)
-		return
-	}
-
-	data := map[string]interface{}{"token": token, "user": user}
-	h.ResponseJSON(w, http.StatusOK, "login successful", data)
+		return err
+	}
+
+	h.ResponseJSON(w, r, http.StatusOK, i18n.MsgOK, user)
+	return nil
+}
+
+// UpdateMe 修改当前用户的用户名, 邮箱或头像
+func (h *UserHandler) UpdateMe(w http.ResponseWriter, r *http.Request) error {
+	var profileDto v1.UpdateProfileDto
+	if err := h.DecodeJSON(r, &profileDto); err != nil {
+		return serverErrors.NewValidationError(i18n.MsgInvalidFormat, err)
+	}
+
+	if err := h.Validate(r, profileDto); err != nil {
+		return err
+	}
+
+	principal, _ := auth.PrincipalFrom(r.Context())
+	user, err := h.userService.UpdateProfile(r.Context(), principal.UserID, profileDto)
+	if err != nil {
+		return err
+	}
+
+	h.ResponseJSON(w, r, http.StatusOK, i18n.MsgProfileUpdated, user)
+	return nil
+}
+
+// ChangePassword 修改当前用户的密码, 其他设备上的会话需要重新登录
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) error {
+	var passwordDto v1.ChangePasswordDto
+	if err := h.DecodeJSON(r, &passwordDto); err != nil {
+		return serverErrors.NewValidationError(i18n.MsgInvalidFormat, err)
+	}
+
+	if err := h.Validate(r, passwordDto); err != nil {
+		return err
+	}
+
+	principal, _ := auth.PrincipalFrom(r.Context())
+	if err := h.userService.ChangePassword(r.Context(), principal, passwordDto); err != nil {
+		return err
+	}
+
+	h.ResponseJSON(w, r, http.StatusOK, i18n.MsgPasswordChanged, nil)
+	return nil
+}
+
+// GetUserByUsername 返回用户的公开资料
+func (h *UserHandler) GetUserByUsername(w http.ResponseWriter, r *http.Request) error {
+	user, err := h.userService.GetUserByUsername(r.Context(), r.PathValue("username"))
+	if err != nil {
+		return err
+	}
+
+	h.ResponseJSON(w, r, http.StatusOK, i18n.MsgOK, newUserInfoDto(user))
+	return nil
+}
+
+// newUserInfoDto 将 model.User 转换为公开资料
+func newUserInfoDto(user *model.User) v1.UserInfoDto {
+	info := v1.UserInfoDto{
+		ID:        user.ID,
+		Username:  user.Username,
+		CreatedAt: user.CreatedAt,
+	}
+	if user.AvatarURL != nil {
+		info.AvatarURL = *user.AvatarURL
+	}
+	return info
+}
+
+// newTokenDto 将 model.TokenPair 转换为响应 DTO
+func newTokenDto(tokens *model.TokenPair) v1.TokenDto {
+	return v1.TokenDto{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(time.Until(tokens.AccessTokenExpiresAt).Seconds()),
+	}
 }
@@ -0,0 +1,187 @@
+package handler_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	servererrors "skymates-api/errors"
+	dto "skymates-api/internal/dto/v1"
+)
+
+func TestLoginRejectsWrongPassword(t *testing.T) {
+	server := newTestServer(t)
+	server.registerAndLogin(t, "alice")
+
+	credentials := dto.LoginDto{Email: "alice@example.com", Password: "wrong-password"}
+	if status := server.do(t, http.MethodPost, "/api/v1/users/login", "", credentials, nil); status != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestRefreshRotatesAndDetectsReuse(t *testing.T) {
+	server := newTestServer(t)
+	tokens := server.registerAndLogin(t, "alice")
+
+	var rotated dto.TokenDto
+	status := server.do(t, http.MethodPost, "/api/v1/users/refresh", "", dto.RefreshTokenDto{RefreshToken: tokens.RefreshToken}, &rotated)
+	if status != http.StatusOK {
+		t.Fatalf("refresh: status = %d, want %d", status, http.StatusOK)
+	}
+	if rotated.RefreshToken == "" || rotated.RefreshToken == tokens.RefreshToken {
+		t.Fatal("refresh should return a new refresh token")
+	}
+
+	// 重复使用已轮换的刷新令牌会吊销整个会话, 新令牌也随之失效
+	status = server.do(t, http.MethodPost, "/api/v1/users/refresh", "", dto.RefreshTokenDto{RefreshToken: tokens.RefreshToken}, nil)
+	if status != http.StatusUnauthorized {
+		t.Fatalf("reuse: status = %d, want %d", status, http.StatusUnauthorized)
+	}
+	status = server.do(t, http.MethodPost, "/api/v1/users/refresh", "", dto.RefreshTokenDto{RefreshToken: rotated.RefreshToken}, nil)
+	if status != http.StatusUnauthorized {
+		t.Fatalf("refresh after reuse: status = %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestLogoutRevokesTokens(t *testing.T) {
+	server := newTestServer(t)
+	tokens := server.registerAndLogin(t, "alice")
+
+	if status := server.do(t, http.MethodPost, "/api/v1/users/logout", "", nil, nil); status != http.StatusUnauthorized {
+		t.Fatalf("logout without token: status = %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status := server.do(t, http.MethodPost, "/api/v1/users/logout", tokens.AccessToken, nil, nil); status != http.StatusOK {
+		t.Fatalf("logout: status = %d, want %d", status, http.StatusOK)
+	}
+
+	// 访问令牌和刷新令牌都已失效
+	if status := server.do(t, http.MethodPost, "/api/v1/users/logout", tokens.AccessToken, nil, nil); status != http.StatusUnauthorized {
+		t.Fatalf("reuse access token: status = %d, want %d", status, http.StatusUnauthorized)
+	}
+	status := server.do(t, http.MethodPost, "/api/v1/users/refresh", "", dto.RefreshTokenDto{RefreshToken: tokens.RefreshToken}, nil)
+	if status != http.StatusUnauthorized {
+		t.Fatalf("refresh after logout: status = %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestProfileEndpoints(t *testing.T) {
+	server := newTestServer(t)
+	tokens := server.registerAndLogin(t, "alice")
+	server.registerAndLogin(t, "bob")
+
+	var me map[string]interface{}
+	if status := server.do(t, http.MethodGet, "/api/v1/users/me", tokens.AccessToken, nil, &me); status != http.StatusOK {
+		t.Fatalf("get me: status = %d, want %d", status, http.StatusOK)
+	}
+	if me["username"] != "alice" || me["email"] != "alice@example.com" {
+		t.Fatalf("me = %v, want alice with her email", me)
+	}
+	if status := server.do(t, http.MethodGet, "/api/v1/users/me", "", nil, nil); status != http.StatusUnauthorized {
+		t.Fatalf("get me without token: status = %d, want %d", status, http.StatusUnauthorized)
+	}
+
+	taken := "bob"
+	status, response := server.request(t, http.MethodPatch, "/api/v1/users/me", tokens.AccessToken, dto.UpdateProfileDto{Username: &taken}, nil)
+	expectError(t, "taken username", status, response, http.StatusConflict, servererrors.CodeAlreadyExists)
+	insecure := "http://example.com/alice.png"
+	status, response = server.request(t, http.MethodPatch, "/api/v1/users/me", tokens.AccessToken, dto.UpdateProfileDto{AvatarURL: &insecure}, nil)
+	expectError(t, "insecure avatar", status, response, http.StatusBadRequest, servererrors.CodeValidation)
+
+	// 修改邮箱需要当前密码, 修改失败时其他字段也不变
+	username, email, avatar := "alice2", "alice2@example.com", "https://example.com/alice.png"
+	update := dto.UpdateProfileDto{Username: &username, Email: &email, AvatarURL: &avatar}
+	status, response = server.request(t, http.MethodPatch, "/api/v1/users/me", tokens.AccessToken, update, nil)
+	expectError(t, "email without password", status, response, http.StatusBadRequest, servererrors.CodeValidation)
+	update.CurrentPassword = "wrong-password"
+	status, response = server.request(t, http.MethodPatch, "/api/v1/users/me", tokens.AccessToken, update, nil)
+	expectError(t, "email with wrong password", status, response, http.StatusBadRequest, servererrors.CodeValidation)
+	if status := server.do(t, http.MethodGet, "/api/v1/users/alice", "", nil, nil); status != http.StatusOK {
+		t.Fatalf("username after rejected update: status = %d, want %d", status, http.StatusOK)
+	}
+
+	// 只修改出现的字段, 修改邮箱后向新邮箱发送验证邮件
+	var updated map[string]interface{}
+	update.CurrentPassword = "secret123"
+	if status := server.do(t, http.MethodPatch, "/api/v1/users/me", tokens.AccessToken, update, &updated); status != http.StatusOK {
+		t.Fatalf("update: status = %d, want %d", status, http.StatusOK)
+	}
+	if updated["username"] != username || updated["email"] != email || updated["avatar_url"] != avatar {
+		t.Fatalf("updated = %v, want %s, %s and %s", updated, username, email, avatar)
+	}
+	server.lastToken(t, email, "verify-email")
+
+	var profile map[string]interface{}
+	if status := server.do(t, http.MethodGet, "/api/v1/users/alice2", "", nil, &profile); status != http.StatusOK {
+		t.Fatalf("public profile: status = %d, want %d", status, http.StatusOK)
+	}
+	if _, ok := profile["email"]; ok || profile["username"] != username || profile["avatar_url"] != avatar {
+		t.Fatalf("public profile = %v, want username and avatar without email", profile)
+	}
+	if status := server.do(t, http.MethodGet, "/api/v1/users/alice", "", nil, nil); status != http.StatusNotFound {
+		t.Fatalf("old username: status = %d, want %d", status, http.StatusNotFound)
+	}
+
+	// 空字符串删除头像
+	empty := ""
+	var cleared map[string]interface{}
+	if status := server.do(t, http.MethodPatch, "/api/v1/users/me", tokens.AccessToken, dto.UpdateProfileDto{AvatarURL: &empty}, &cleared); status != http.StatusOK {
+		t.Fatalf("clear avatar: status = %d, want %d", status, http.StatusOK)
+	}
+	if _, ok := cleared["avatar_url"]; ok || cleared["username"] != username {
+		t.Fatalf("after clearing avatar = %v", cleared)
+	}
+
+	// 访问令牌中不保存用户名, 改名之后已签发的令牌不会带着旧用户名
+	payload, err := base64.RawURLEncoding.DecodeString(strings.Split(tokens.AccessToken, ".")[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := claims["username"]; ok {
+		t.Fatalf("access token claims = %v, want no username", claims)
+	}
+}
+
+func TestChangePasswordRevokesOtherSessions(t *testing.T) {
+	server := newTestServer(t)
+	current := server.registerAndLogin(t, "alice")
+	var other struct {
+		Token dto.TokenDto `json:"token"`
+	}
+	if status := server.do(t, http.MethodPost, "/api/v1/users/login", "", dto.LoginDto{Email: "alice@example.com", Password: "secret123"}, &other); status != http.StatusOK {
+		t.Fatalf("second login: status = %d, want %d", status, http.StatusOK)
+	}
+
+	wrong := dto.ChangePasswordDto{CurrentPassword: "wrong-password1", NewPassword: "newsecret456"}
+	status, response := server.request(t, http.MethodPut, "/api/v1/users/me/password", current.AccessToken, wrong, nil)
+	expectError(t, "wrong current password", status, response, http.StatusBadRequest, servererrors.CodeValidation)
+
+	change := dto.ChangePasswordDto{CurrentPassword: "secret123", NewPassword: "newsecret456"}
+	if status := server.do(t, http.MethodPut, "/api/v1/users/me/password", current.AccessToken, change, nil); status != http.StatusOK {
+		t.Fatalf("change password: status = %d, want %d", status, http.StatusOK)
+	}
+
+	// 其他会话的访问令牌立即失效, 当前会话不受影响
+	if status := server.do(t, http.MethodGet, "/api/v1/users/me", other.Token.AccessToken, nil, nil); status != http.StatusUnauthorized {
+		t.Fatalf("access token of other session: status = %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status := server.do(t, http.MethodGet, "/api/v1/users/me", current.AccessToken, nil, nil); status != http.StatusOK {
+		t.Fatalf("access token of current session: status = %d, want %d", status, http.StatusOK)
+	}
+
+	// 其他会话被吊销, 当前会话仍然可以刷新
+	if status := server.do(t, http.MethodPost, "/api/v1/users/refresh", "", dto.RefreshTokenDto{RefreshToken: other.Token.RefreshToken}, nil); status != http.StatusUnauthorized {
+		t.Fatalf("refresh other session: status = %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status := server.do(t, http.MethodPost, "/api/v1/users/refresh", "", dto.RefreshTokenDto{RefreshToken: current.RefreshToken}, nil); status != http.StatusOK {
+		t.Fatalf("refresh current session: status = %d, want %d", status, http.StatusOK)
+	}
+	if status, _, _ := server.login(t, "alice@example.com", "newsecret456"); status != http.StatusOK {
+		t.Fatalf("login with new password: status = %d, want %d", status, http.StatusOK)
+	}
+}
@@ -0,0 +1,78 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	servererrors "skymates-api/errors"
+	dto "skymates-api/internal/dto/v1"
+	"skymates-api/internal/validator"
+)
+
+// postValidation 发送带 Accept-Language 的请求, 检查返回的是参数校验错误, 返回以字段路径为 key 的字段错误
+func (s *testServer) postValidation(t *testing.T, path, accessToken, acceptLanguage string, body interface{}) map[string]validator.FieldError {
+	t.Helper()
+	payload, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, s.URL+path, bytes.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", acceptLanguage)
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var data dto.ValidationErrorData
+	envelope := dto.Response{Data: &data}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusBadRequest || envelope.Code != servererrors.CodeValidation {
+		t.Fatalf("status = %d, code = %d, want %d and %d", resp.StatusCode, envelope.Code, http.StatusBadRequest, servererrors.CodeValidation)
+	}
+	fields := make(map[string]validator.FieldError)
+	for _, field := range data.Errors {
+		fields[field.Field] = field
+	}
+	return fields
+}
+
+func TestValidationErrorsListFieldsInRequestLanguage(t *testing.T) {
+	server := newTestServer(t)
+	register := dto.RegisterDto{Username: "a b", Password: "short", Email: "not-an-email"}
+
+	fields := server.postValidation(t, "/api/v1/users/register", "", "en-US,en;q=0.9,zh;q=0.5", register)
+	for field, rule := range map[string]string{"username": "username", "password": "password", "email": "email"} {
+		if fields[field].Rule != rule {
+			t.Fatalf("errors = %+v, want %s to fail %q", fields, field, rule)
+		}
+	}
+	if !strings.Contains(fields["password"].Message, "letters and digits") {
+		t.Fatalf("password message = %q, want English", fields["password"].Message)
+	}
+
+	// 优先使用 q 值更高的语言, 不支持的语言被跳过
+	fields = server.postValidation(t, "/api/v1/users/register", "", "fr;q=1, zh-CN;q=0.8, en;q=0.1", register)
+	if !strings.Contains(fields["email"].Message, "邮箱") {
+		t.Fatalf("email message = %q, want Chinese", fields["email"].Message)
+	}
+
+	tokens := server.registerAndLogin(t, "alice")
+	term := dto.CreateTermRequest{Name: "Jet Lag", Explanation: "时差反应", SourceURL: "http://example.com"}
+	fields = server.postValidation(t, "/api/v1/terms", tokens.AccessToken, "en", term)
+	if field := fields["source_url"]; field.Rule != "https_url" || len(fields) != 1 {
+		t.Fatalf("errors = %+v, want only source_url to fail https_url", fields)
+	}
+}
@@ -0,0 +1,109 @@
+// Package i18n 根据请求的语言翻译返回给客户端的消息
+//
+// 消息文本按语言保存在 locales/<语言>.json 中, 以消息 ID 为 key, 编译时嵌入二进制文件
+// Locale 中间件根据 Accept-Language 选择语言并放入 context, 写入响应时使用 T 翻译消息 ID
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// 支持的语言, 第一个为默认语言
+var (
+	ZhCN = language.MustParse("zh-CN")
+	En   = language.English
+
+	supported = []language.Tag{ZhCN, En}
+	matcher   = language.NewMatcher(supported)
+)
+
+//go:embed locales/*.json
+var locales embed.FS
+
+// bundles 每种语言的消息, key 为语言标签
+var bundles = loadBundles()
+
+// loadBundles 加载所有语言的消息, 语言文件缺失或消息 ID 与默认语言不一致时 panic
+func loadBundles() map[language.Tag]map[string]string {
+	result := make(map[language.Tag]map[string]string, len(supported))
+	for _, tag := range supported {
+		data, err := locales.ReadFile(path.Join("locales", tag.String()+".json"))
+		if err != nil {
+			panic(fmt.Sprintf("i18n: %v", err))
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("i18n: parse %s: %v", tag, err))
+		}
+		result[tag] = messages
+	}
+
+	// 所有语言必须翻译相同的消息, 避免某种语言的客户端看到消息 ID
+	defaults := result[supported[0]]
+	for _, tag := range supported[1:] {
+		if missing := diff(defaults, result[tag]); len(missing) > 0 {
+			panic(fmt.Sprintf("i18n: %s is missing messages %s", tag, strings.Join(missing, ", ")))
+		}
+		if extra := diff(result[tag], defaults); len(extra) > 0 {
+			panic(fmt.Sprintf("i18n: %s has messages not in %s: %s", tag, supported[0], strings.Join(extra, ", ")))
+		}
+	}
+	return result
+}
+
+// diff 返回在 a 中但不在 b 中的消息 ID
+func diff(a, b map[string]string) []string {
+	var ids []string
+	for id := range a {
+		if _, ok := b[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Negotiate 根据 Accept-Language 选择最合适的语言, 都不支持时返回默认语言
+func Negotiate(acceptLanguage string) language.Tag {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return supported[0]
+	}
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return supported[0]
+	}
+	return supported[index]
+}
+
+type localeKey struct{}
+
+// WithLocale 返回携带语言的 context
+func WithLocale(ctx context.Context, locale language.Tag) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// LocaleFromContext 返回 ctx 中的语言, 没有时返回默认语言
+func LocaleFromContext(ctx context.Context) language.Tag {
+	if locale, ok := ctx.Value(localeKey{}).(language.Tag); ok {
+		return locale
+	}
+	return supported[0]
+}
+
+// T 按 ctx 中的语言翻译消息 ID
+// 不是消息 ID 的文本原样返回, 比如已经按请求语言生成的参数校验错误
+func T(ctx context.Context, id string) string {
+	if message, ok := bundles[LocaleFromContext(ctx)][id]; ok {
+		return message
+	}
+	return id
+}
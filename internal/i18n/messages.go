@@ -0,0 +1,75 @@
+package i18n
+
+// 消息 ID, 每个 ID 在 locales 下的所有语言文件中都必须有对应的文本
+// 可以用作 ServerError.Message 和 ResponseJSON 的 message, 写入响应时按请求的语言翻译
+const (
+	MsgOK              = "ok"
+	MsgInternalError   = "error.internal"
+	MsgInvalidFormat   = "request.invalid_format"
+	MsgTooManyRequests = "request.too_many"
+
+	MsgOriginNotAllowed = "cors.origin_not_allowed"
+
+	MsgLoginRequired     = "auth.login_required"
+	MsgForbidden         = "auth.forbidden"
+	MsgMissingAuthHeader = "auth.missing_header"
+	MsgInvalidAuthFormat = "auth.invalid_format"
+	MsgInvalidSignature  = "auth.invalid_signature"
+	MsgTokenExpired      = "auth.token_expired"
+	MsgUnauthorized      = "auth.unauthorized"
+	MsgTokenRevoked      = "auth.token_revoked"
+	MsgEmailNotVerified  = "auth.email_not_verified"
+
+	MsgUserCreated        = "user.created"
+	MsgLoginSucceeded     = "user.login_succeeded"
+	MsgLogoutSucceeded    = "user.logout_succeeded"
+	MsgUsernameExists     = "user.username_exists"
+	MsgEmailExists        = "user.email_exists"
+	MsgUserNotFound       = "user.not_found"
+	MsgInvalidCredentials = "user.invalid_credentials"
+	MsgProfileUpdated     = "user.profile_updated"
+	MsgPasswordChanged    = "user.password_changed"
+	MsgWrongPassword      = "user.wrong_password"
+
+	MsgTokenRefreshed      = "token.refreshed"
+	MsgInvalidRefreshToken = "token.refresh_invalid"
+	MsgRefreshTokenExpired = "token.refresh_expired"
+
+	MsgPasswordResetRequested   = "account.password_reset_requested"
+	MsgPasswordReset            = "account.password_reset"
+	MsgInvalidResetToken        = "account.invalid_reset_token"
+	MsgVerificationSent         = "account.verification_sent"
+	MsgEmailVerified            = "account.email_verified"
+	MsgEmailAlreadyVerified     = "account.email_already_verified"
+	MsgInvalidVerificationToken = "account.invalid_verification_token"
+
+	// 邮件模板, 正文中的 {username} 和 {link} 在发送时替换
+	MailPasswordResetSubject     = "mail.password_reset.subject"
+	MailPasswordResetBody        = "mail.password_reset.body"
+	MailEmailVerificationSubject = "mail.email_verification.subject"
+	MailEmailVerificationBody    = "mail.email_verification.body"
+
+	MsgMissingKeyword    = "term.missing_keyword"
+	MsgInvalidTermID     = "term.invalid_id"
+	MsgInvalidCategoryID = "term.invalid_category_id"
+	MsgInvalidLastID     = "term.invalid_last_id"
+	MsgInvalidCursor     = "term.invalid_cursor"
+	MsgInvalidSearchMode = "term.invalid_search_mode"
+	MsgTermNotFound      = "term.not_found"
+	MsgTermCreated       = "term.created"
+	MsgTermUpdated       = "term.updated"
+	MsgUnknownCategory   = "term.unknown_category"
+	MsgInvalidRevision   = "term.invalid_revision"
+	MsgRevisionNotFound  = "term.revision_not_found"
+	MsgTermRolledBack    = "term.rolled_back"
+
+	MsgCategoryNotFound    = "category.not_found"
+	MsgCategorySlugExists  = "category.slug_exists"
+	MsgInvalidParent       = "category.invalid_parent"
+	MsgCategoryCycle       = "category.cycle"
+	MsgCategoryHasChildren = "category.has_children"
+	MsgCategoryHasTerms    = "category.has_terms"
+	MsgCategoryCreated     = "category.created"
+	MsgCategoryUpdated     = "category.updated"
+	MsgCategoryDeleted     = "category.deleted"
+)
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	servererrors "skymates-api/errors"
+	"skymates-api/internal/i18n"
+	"skymates-api/pkg/auth"
+	"skymates-api/pkg/logging"
+	"strings"
+)
+
+// Auth 返回身份验证中间件
+// 使用 keys 验证 Bearer 令牌的签名和有效期后, 再通过 revocations 检查令牌是否已被吊销 (比如用户已登出)
+func Auth(keys *auth.KeyManager, revocations auth.RevocationChecker) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeaderValue := r.Header.Get("Authorization")
+			if authHeaderValue == "" {
+				writeError(w, r, servererrors.NewUnauthorizedError(i18n.MsgMissingAuthHeader, nil))
+				return
+			}
+
+			bearerToken := strings.Split(authHeaderValue, " ")
+			if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
+				writeError(w, r, servererrors.NewUnauthorizedError(i18n.MsgInvalidAuthFormat, nil))
+				return
+			}
+
+			claims, err := keys.ValidateJwtToken(bearerToken[1])
+			if err != nil {
+				switch {
+				case strings.Contains(err.Error(), "signature"):
+					writeError(w, r, servererrors.NewUnauthorizedError(i18n.MsgInvalidSignature, err))
+				case strings.Contains(err.Error(), "expired"):
+					writeError(w, r, servererrors.NewUnauthorizedError(i18n.MsgTokenExpired, err))
+				default:
+					writeError(w, r, servererrors.NewUnauthorizedError(i18n.MsgUnauthorized, nil))
+				}
+				return
+			}
+
+			if claims.ID != "" || claims.SessionID != "" {
+				revoked, err := revocations.IsTokenRevoked(r.Context(), claims.ID, claims.SessionID)
+				if err != nil {
+					writeError(w, r, err)
+					return
+				}
+				if revoked {
+					writeError(w, r, servererrors.NewUnauthorizedError(i18n.MsgTokenRevoked, nil))
+					return
+				}
+			}
+
+			ctx := auth.WithPrincipal(r.Context(), claims.Principal())
+			ctx = logging.With(ctx, "user_id", claims.UserID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+	"skymates-api/internal/authz"
+	"skymates-api/pkg/auth"
+)
+
+// Authorize 返回一个授权中间件, 必须放在 Auth 中间件之后
+// 从 context 中取出 Auth 写入的 Principal, 再交给 policy 判断是否允许访问
+func Authorize(policy authz.Policy) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, _ := auth.PrincipalFrom(r.Context())
+			if err := policy(r, principal); err != nil {
+				writeError(w, r, err)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
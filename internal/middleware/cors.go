@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"skymates-api/config"
+	servererrors "skymates-api/errors"
+	"skymates-api/internal/i18n"
+	"strconv"
+	"strings"
+)
+
+var (
+	corsAllowMethods = strings.Join([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"}, ",")
+	// 不要使用 Access-Control-Allow-Headers: *
+	// 当 credentials: 'include' 或 withCredentials: true 时，通配符 * 可能不会被正确解析
+	corsAllowHeaders = strings.Join([]string{
+		"Content-Type",
+		"Authorization",
+		"X-Requested-With",
+		"Accept",
+		"Accept-Language",
+		APIKeyHeader,
+		RequestIDHeader,
+		"traceparent",
+	}, ",")
+	// 客户端可以读取请求 ID 和 traceparent, 报告问题时附上, 以及限流的剩余额度和重试时间
+	corsExposeHeaders = strings.Join([]string{
+		RequestIDHeader,
+		"traceparent",
+		"Retry-After",
+		"RateLimit-Policy",
+		"RateLimit-Limit",
+		"RateLimit-Remaining",
+		"RateLimit-Reset",
+	}, ",")
+)
+
+// corsPolicy 解析后的跨域规则
+type corsPolicy struct {
+	anyOrigin        bool
+	origins          map[string]bool // 精确匹配的源
+	patterns         []originPattern // 带通配符的源
+	allowCredentials bool
+	maxAge           string
+}
+
+// originPattern 形如 https://*.example.com 的源, 匹配 example.com 的任意子域名, 不匹配 example.com 本身
+type originPattern struct {
+	scheme string
+	suffix string // .example.com
+	port   string
+}
+
+func newCORSPolicy(cfg config.CORSPolicy) *corsPolicy {
+	policy := &corsPolicy{
+		origins:          make(map[string]bool),
+		allowCredentials: cfg.AllowCredentials,
+		maxAge:           strconv.Itoa(int(cfg.MaxAge.Seconds())),
+	}
+	for _, origin := range cfg.AllowOrigins {
+		scheme, host, _ := strings.Cut(origin, "://")
+		switch {
+		case origin == "*":
+			policy.anyOrigin = true
+		case strings.HasPrefix(host, "*."):
+			// 格式已经由配置校验保证
+			u, _ := url.Parse(scheme + "://" + host[len("*."):])
+			policy.patterns = append(policy.patterns, originPattern{scheme: scheme, suffix: "." + u.Hostname(), port: u.Port()})
+		default:
+			policy.origins[origin] = true
+		}
+	}
+	return policy
+}
+
+// allows 判断是否允许源 origin
+func (p *corsPolicy) allows(origin string) bool {
+	if p.anyOrigin || p.origins[origin] {
+		return true
+	}
+	if len(p.patterns) == 0 {
+		return false
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Path != "" {
+		return false
+	}
+	for _, pattern := range p.patterns {
+		if u.Scheme == pattern.scheme && u.Port() == pattern.port && strings.HasSuffix(u.Hostname(), pattern.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// corsGroup 路由组的跨域规则
+type corsGroup struct {
+	pathPrefix string
+	policy     *corsPolicy
+}
+
+// CORS 返回一个处理跨域资源共享(CORS)的中间件, 按请求路径选择匹配前缀最长的路由组的规则, 没有匹配时使用默认规则
+// 不允许的源发送的预检请求返回 403, 普通请求照常处理但不返回 CORS 头, 由浏览器拦截响应
+func CORS(cfg config.CORSConfig) Middleware {
+	defaultPolicy := newCORSPolicy(cfg.CORSPolicy)
+	groups := make([]corsGroup, len(cfg.Groups))
+	for i, group := range cfg.Groups {
+		groups[i] = corsGroup{pathPrefix: group.PathPrefix, policy: newCORSPolicy(group.CORSPolicy)}
+	}
+	policyFor := func(path string) *corsPolicy {
+		policy, longest := defaultPolicy, -1
+		for _, group := range groups {
+			if strings.HasPrefix(path, group.pathPrefix) && len(group.pathPrefix) > longest {
+				policy, longest = group.policy, len(group.pathPrefix)
+			}
+		}
+		return policy
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			// 响应随 Origin 变化, 缓存时需要区分, 没有 Origin 的响应也要加上, 否则缓存的响应会被其他源复用
+			w.Header().Add("Vary", "Origin")
+			if preflight {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			policy := policyFor(r.URL.Path)
+			if !policy.allows(origin) {
+				if preflight {
+					writeError(w, r, servererrors.NewForbiddenError(i18n.MsgOriginNotAllowed, nil))
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// 允许任意源且不携带凭证时返回 *, 其余情况必须返回确切的源
+			if policy.anyOrigin && !policy.allowCredentials {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if policy.allowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+			w.Header().Set("Access-Control-Expose-Headers", corsExposeHeaders)
+
+			if preflight {
+				w.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+				w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+				w.Header().Set("Access-Control-Max-Age", policy.maxAge)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
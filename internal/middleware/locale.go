@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"net/http"
+	"skymates-api/internal/i18n"
+)
+
+// Locale 根据 Accept-Language 选择响应消息的语言并放入 context
+// 响应头 Content-Language 返回选择的语言, 同一个 URL 的响应随 Accept-Language 变化, 缓存时需要区分
+func Locale(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locale := i18n.Negotiate(r.Header.Get("Accept-Language"))
+		w.Header().Set("Content-Language", locale.String())
+		w.Header().Add("Vary", "Accept-Language")
+		next.ServeHTTP(w, r.WithContext(i18n.WithLocale(r.Context(), locale)))
+	})
+}
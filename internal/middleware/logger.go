@@ -1,12 +1,15 @@
 package middleware
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
+	"skymates-api/pkg/logging"
 	"time"
 )
 
-// Logger 是一个记录HTTP请求的中间件
+// Logger 是一个记录HTTP请求的中间件, 每个请求结束后记录一条访问日志
+// 使用 context 中的 logger, 所以要放在 RequestID 和 Tracing 内层, 日志才会带有 request_id 和 trace_id
+// 处理失败的原因由 handler 记录, 这里只记录状态码
 func Logger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -20,17 +23,12 @@ func Logger(next http.Handler) http.Handler {
 		// 调用下一个处理器
 		next.ServeHTTP(rw, r)
 
-		// 计算请求处理时间
-		duration := time.Since(start)
-
-		// 记录请求信息
-		log.Printf(
-			"[%s] %s %s %d %s",
-			r.Method,
-			r.RequestURI,
-			r.RemoteAddr,
-			rw.statusCode,
-			duration,
+		logging.FromContext(r.Context()).LogAttrs(r.Context(), slog.LevelInfo, "http request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rw.statusCode),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("remote_addr", r.RemoteAddr),
 		)
 	})
 }
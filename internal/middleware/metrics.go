@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"skymates-api/pkg/metrics"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unmatchedRoute 没有匹配到任何路由的请求 (404/405) 使用的 route 标签,
+// 避免扫描器请求的随机路径撑爆标签数量
+const unmatchedRoute = "unmatched"
+
+// Metrics 记录每个路由的请求数和耗时
+// route 标签取 ServeMux 匹配到的模式 (例如 /api/v1/terms/{id}), 而不是原始路径,
+// 所以必须包在 ServeMux 外层, 在请求处理完成后读取 r.Pattern
+func Metrics(registry *metrics.Registry) Middleware {
+	requests := registry.NewCounterVec("http_requests_total",
+		"Total number of HTTP requests by route, method and status code.", "method", "route", "status")
+	durations := registry.NewHistogramVec("http_request_duration_seconds",
+		"HTTP request latency by route and method.", metrics.DefBuckets, "method", "route")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+			next.ServeHTTP(rw, r)
+
+			route := routeLabel(r.Pattern)
+			requests.With(r.Method, route, strconv.Itoa(rw.statusCode)).Inc()
+			durations.With(r.Method, route).Observe(time.Since(start).Seconds())
+		})
+	}
+}
+
+// routeLabel 去掉模式中的方法前缀, 方法单独作为标签
+func routeLabel(pattern string) string {
+	if pattern == "" {
+		return unmatchedRoute
+	}
+	if i := strings.IndexByte(pattern, ' '); i >= 0 {
+		pattern = strings.TrimLeft(pattern[i+1:], " ")
+	}
+	return pattern
+}
@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"skymates-api/config"
+	servererrors "skymates-api/errors"
+	"skymates-api/internal/i18n"
+	"skymates-api/pkg/auth"
+	"skymates-api/pkg/logging"
+	"skymates-api/pkg/ratelimit"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIKeyHeader 按 api_key 限流时读取的请求头
+const APIKeyHeader = "X-API-Key"
+
+// rateLimitRoute 单个路由解析后的限流规则
+type rateLimitRoute struct {
+	key  string
+	rule ratelimit.Rule
+}
+
+// RateLimit 返回按路由限流的中间件, 根据 r.Pattern 查找 cfg.Routes 中的规则, 没有规则的路由不限流
+// r.Pattern 只有在路由匹配之后才有值, 所以中间件要加在注册到 mux 的处理器上;
+// 按 user 限流的路由要放在认证中间件之后, 才能取到当前用户
+// 响应头返回 RateLimit-* 描述剩余的额度, 超过限制时返回 429 和 Retry-After
+// store 出错时不限流, 限流不可用不应该让整个服务不可用
+func RateLimit(cfg config.RateLimitConfig, store ratelimit.Store) Middleware {
+	if !cfg.Enabled {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	routes := make(map[string]rateLimitRoute, len(cfg.Routes))
+	for pattern, rule := range cfg.Routes {
+		if rule.Limit > 0 {
+			routes[pattern] = rateLimitRoute{key: rule.Key, rule: ratelimit.Rule{Limit: rule.Limit, Period: rule.Period}}
+		}
+	}
+	// 格式已经由配置校验保证
+	proxies := make([]netip.Prefix, 0, len(cfg.TrustedProxies))
+	for _, proxy := range cfg.TrustedProxies {
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			addr := netip.MustParseAddr(proxy)
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		proxies = append(proxies, prefix)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route, ok := routes[r.Pattern]
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// 不同路由的桶互相独立; 有多个桶时依次取令牌, 任何一个桶空了就拒绝, 响应头描述剩余最少的桶
+			var result ratelimit.Result
+			now := time.Now()
+			for i, key := range rateLimitKeys(r, route.key, proxies) {
+				taken, err := store.Take(r.Context(), r.Pattern+"|"+key, route.rule, now)
+				if err != nil {
+					logging.FromContext(r.Context()).Error("rate limit store failed", "error", err)
+					next.ServeHTTP(w, r)
+					return
+				}
+				if i == 0 || !taken.Allowed || taken.Remaining < result.Remaining {
+					result = taken
+				}
+				if !taken.Allowed {
+					break
+				}
+			}
+
+			header := w.Header()
+			header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", route.rule.Limit, seconds(route.rule.Period)))
+			header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			header.Set("RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+			if !result.Allowed {
+				header.Set("Retry-After", strconv.Itoa(seconds(result.RetryAfter)))
+				writeError(w, r, servererrors.NewTooManyRequestsError(i18n.MsgTooManyRequests, nil))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitKeys 返回请求在 kind 维度上要取令牌的桶, 取不到用户或 API Key 时退回到按 IP 限流
+// API Key 没有经过校验, 只按 API Key 限流时客户端每次换一个值就能得到一个满桶, 所以同时按 IP 限流
+func rateLimitKeys(r *http.Request, kind string, proxies []netip.Prefix) []string {
+	ip := "ip:" + clientIP(r, proxies)
+	switch kind {
+	case "user":
+		if principal, ok := auth.PrincipalFrom(r.Context()); ok {
+			return []string{"user:" + strconv.FormatInt(principal.UserID, 10)}
+		}
+	case "api_key":
+		// 只保存摘要, 存储中不出现 API Key 明文
+		if apiKey := r.Header.Get(APIKeyHeader); apiKey != "" {
+			sum := sha256.Sum256([]byte(apiKey))
+			return []string{ip, "api_key:" + hex.EncodeToString(sum[:])}
+		}
+	}
+	return []string{ip}
+}
+
+// clientIP 返回客户端的 IP, IPv4 映射的 IPv6 地址 (::ffff:a.b.c.d) 转为 IPv4, 与直接的 IPv4 连接共用一个桶
+// 连接来自可信代理时, 从右向左跳过 X-Forwarded-For 中的可信代理, 取第一个不可信的地址;
+// 左侧的地址可以由客户端任意伪造, 不能直接使用
+func clientIP(r *http.Request, proxies []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	addr = addr.Unmap()
+	if !trusted(addr, proxies) {
+		return addr.String()
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			break
+		}
+		hop = hop.Unmap()
+		if !trusted(hop, proxies) {
+			return hop.String()
+		}
+		addr = hop
+	}
+	// 所有地址都是可信代理时, 使用最左侧的可信地址
+	return addr.String()
+}
+
+func trusted(addr netip.Addr, proxies []netip.Prefix) bool {
+	for _, prefix := range proxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// seconds 将时长向上取整为秒
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
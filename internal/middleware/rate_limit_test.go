@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"skymates-api/config"
+	"skymates-api/pkg/ratelimit"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.168.1.1/32"),
+		netip.MustParsePrefix("fd00::/8"),
+	}
+
+	for _, c := range []struct {
+		name      string
+		remote    string
+		forwarded []string
+		proxies   []netip.Prefix
+		want      string
+	}{
+		{"direct connection", "203.0.113.7:1234", nil, proxies, "203.0.113.7"},
+		{"untrusted remote ignores forwarded", "203.0.113.7:1234", []string{"198.51.100.1"}, proxies, "203.0.113.7"},
+		{"no trusted proxies ignores forwarded", "10.0.0.1:1234", []string{"198.51.100.1"}, nil, "10.0.0.1"},
+		{"trusted remote uses forwarded", "10.0.0.1:1234", []string{"198.51.100.1"}, proxies, "198.51.100.1"},
+		{"spoofed leftmost entries", "10.0.0.1:1234", []string{"1.1.1.1, 2.2.2.2, 198.51.100.1"}, proxies, "198.51.100.1"},
+		{"skips trusted hops", "10.0.0.1:1234", []string{"1.1.1.1, 198.51.100.1, 10.0.0.2, 192.168.1.1"}, proxies, "198.51.100.1"},
+		{"multiple headers", "10.0.0.1:1234", []string{"1.1.1.1, 198.51.100.1", "10.0.0.2"}, proxies, "198.51.100.1"},
+		{"single address proxy", "192.168.1.1:1234", []string{"198.51.100.1"}, proxies, "198.51.100.1"},
+		{"address next to single address proxy", "192.168.1.2:1234", []string{"198.51.100.1"}, proxies, "192.168.1.2"},
+		{"all hops trusted", "10.0.0.1:1234", []string{"10.0.0.3, 10.0.0.2"}, proxies, "10.0.0.3"},
+		{"invalid hop stops at last trusted", "10.0.0.1:1234", []string{"1.1.1.1, unknown, 10.0.0.2"}, proxies, "10.0.0.2"},
+		{"no forwarded header", "10.0.0.1:1234", nil, proxies, "10.0.0.1"},
+		{"ipv4 mapped remote", "[::ffff:203.0.113.7]:1234", nil, proxies, "203.0.113.7"},
+		{"ipv4 mapped trusted remote", "[::ffff:10.0.0.1]:1234", []string{"198.51.100.1"}, proxies, "198.51.100.1"},
+		{"ipv4 mapped hop", "10.0.0.1:1234", []string{"::ffff:198.51.100.1"}, proxies, "198.51.100.1"},
+		{"ipv4 mapped trusted hop", "10.0.0.1:1234", []string{"198.51.100.1, ::ffff:10.0.0.2"}, proxies, "198.51.100.1"},
+		{"ipv6", "[fd00::1]:1234", []string{"2001:db8::1"}, proxies, "2001:db8::1"},
+		{"remote without port", "203.0.113.7", nil, proxies, "203.0.113.7"},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = c.remote
+		for _, value := range c.forwarded {
+			r.Header.Add("X-Forwarded-For", value)
+		}
+		if got := clientIP(r, c.proxies); got != c.want {
+			t.Errorf("%s: clientIP = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func newRateLimitedMux(key string, limit int) http.Handler {
+	cfg := config.RateLimitConfig{
+		Enabled: true,
+		Routes: map[string]config.RateLimitRule{
+			"GET /limited": {Key: key, Limit: limit, Period: time.Hour},
+		},
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /limited", RateLimit(cfg, ratelimit.NewMemoryStore())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	return mux
+}
+
+func serve(handler http.Handler, remote, apiKey string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/limited", nil)
+	r.RemoteAddr = remote
+	if apiKey != "" {
+		r.Header.Set(APIKeyHeader, apiKey)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	handler := newRateLimitedMux("ip", 2)
+
+	w := serve(handler, "203.0.113.7:1234", "")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+	for header, want := range map[string]string{
+		"RateLimit-Policy":    "2;w=3600",
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "1",
+		"RateLimit-Reset":     "1800",
+	} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+
+	serve(handler, "203.0.113.7:1234", "")
+	w = serve(handler, "203.0.113.7:1234", "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("Retry-After"); got != "1800" {
+		t.Errorf("Retry-After = %q, want 1800", got)
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("RateLimit-Remaining = %q, want 0", got)
+	}
+
+	// IPv4 映射的地址与直接的 IPv4 连接共用一个桶
+	if w := serve(handler, "[::ffff:203.0.113.7]:1234", ""); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("ipv4 mapped status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if w := serve(handler, "203.0.113.8:1234", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("other ip status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+}
+
+func TestRateLimitAPIKey(t *testing.T) {
+	// 每次换一个 API Key 也会被按 IP 限流
+	handler := newRateLimitedMux("api_key", 2)
+	for i, key := range []string{"a", "b"} {
+		if w := serve(handler, "203.0.113.7:1234", key); w.Code != http.StatusNoContent {
+			t.Fatalf("request %d status = %d, want %d", i, w.Code, http.StatusNoContent)
+		}
+	}
+	if w := serve(handler, "203.0.113.7:1234", "c"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("rotated key status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+
+	// 同一个 API Key 从不同的 IP 请求也会被按 API Key 限流
+	handler = newRateLimitedMux("api_key", 2)
+	serve(handler, "198.51.100.1:1234", "shared")
+	w := serve(handler, "198.51.100.2:1234", "shared")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("second ip status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+	// 响应头描述剩余最少的桶
+	if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("RateLimit-Remaining = %q, want 0", got)
+	}
+	if w := serve(handler, "198.51.100.3:1234", "shared"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("shared key status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	// 没有 API Key 时只按 IP 限流
+	if w := serve(handler, "198.51.100.3:1234", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("no key status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+}
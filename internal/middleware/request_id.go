@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"skymates-api/pkg/logging"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader 请求 ID 请求头, 网关或客户端传入时沿用, 否则生成新的 ID
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength 传入的请求 ID 的最大长度, 过长或包含非法字符时重新生成, 避免污染日志
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// RequestIDFromContext 返回当前请求的 ID, 没有时返回空字符串
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID 为每个请求分配 ID 并在响应头中返回
+// ID 会附加到 context 中的 logger 上, 同一请求的所有日志都带有 request_id
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		ctx = logging.With(ctx, "request_id", id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// validRequestID 只接受可打印的 ASCII 字符, 不含空格
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+	"skymates-api/pkg/logging"
+	"skymates-api/pkg/tracing"
+	"strconv"
+)
+
+// Tracing 为每个请求创建服务端 span, 请求头中有 traceparent 时作为上游 span 的子 span
+// 响应头返回本次请求的 traceparent, 客户端可以用它在追踪系统中查找请求
+// 和 Metrics 一样需要在请求处理完成后读取 r.Pattern, 必须包在 Metrics 或 ServeMux 外层
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.Extract(r.Context(), r.Header)
+		ctx, span := tracing.Start(ctx, r.Method, tracing.WithKind(tracing.SpanKindServer), tracing.WithAttributes(
+			tracing.String("http.request.method", r.Method),
+			tracing.String("url.path", r.URL.Path),
+		))
+		defer span.End()
+
+		if sc := span.SpanContext(); sc.IsValid() {
+			w.Header().Set(tracing.TraceparentHeader, sc.Traceparent())
+			// 日志带上 trace_id, 可以从日志跳转到对应的调用链
+			ctx = logging.With(ctx, "trace_id", sc.TraceID.String())
+		}
+
+		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		r = r.WithContext(ctx)
+		next.ServeHTTP(rw, r)
+
+		// r 是传给下游的同一个请求, ServeMux 已经在上面设置了匹配的路由模式
+		route := routeLabel(r.Pattern)
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(
+			tracing.String("http.route", route),
+			tracing.Int("http.response.status_code", rw.statusCode),
+		)
+		if rw.statusCode >= http.StatusInternalServerError {
+			span.SetStatus(tracing.StatusError, strconv.Itoa(rw.statusCode)+" "+http.StatusText(rw.statusCode))
+		}
+	})
+}
@@ -0,0 +1,167 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// lockName 是迁移使用的数据库锁名称, 所有实例共用, 保证同一时间只有一个实例执行迁移
+const lockName = "skymates_schema_migrations"
+
+// lockTimeoutSeconds 等待迁移锁的最长时间
+const lockTimeoutSeconds = 60
+
+// Dialect 封装不同数据库在迁移时的差异
+type Dialect interface {
+	// Name 返回方言名称, 同时也是内嵌迁移文件的目录名
+	Name() string
+	// CreateVersionTableSQL 返回创建 schema_migrations 表的语句
+	CreateVersionTableSQL() string
+	// VersionTableExistsSQL 返回查询 schema_migrations 表是否存在的语句, 结果为表的数量 0 或 1
+	VersionTableExistsSQL() string
+	// Placeholder 返回第 n 个 (从 1 开始) 绑定参数的占位符
+	Placeholder(n int) string
+	// TransactionalDDL 表示 DDL 语句是否可以在事务中执行并回滚
+	TransactionalDDL() bool
+	// Lock 获取迁移锁, 同一个连接上调用 Unlock 释放
+	Lock(ctx context.Context, conn *sql.Conn) error
+	// Unlock 释放迁移锁
+	Unlock(ctx context.Context, conn *sql.Conn) error
+}
+
+// 各数据库的迁移方言
+var (
+	MySQL    Dialect = mysqlDialect{}
+	Postgres Dialect = postgresDialect{}
+	SQLite   Dialect = sqliteDialect{}
+)
+
+// DialectFor 根据数据库驱动名称返回对应的迁移方言
+func DialectFor(driver string) (Dialect, error) {
+	switch driver {
+	case "mysql":
+		return MySQL, nil
+	case "postgres":
+		return Postgres, nil
+	case "sqlite":
+		return SQLite, nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string { return "mysql" }
+
+func (mysqlDialect) CreateVersionTableSQL() string {
+	return `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    BIGINT       NOT NULL,
+		name       VARCHAR(255) NOT NULL,
+		applied_at DATETIME(3)  NOT NULL,
+		PRIMARY KEY (version)
+	) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`
+}
+
+func (mysqlDialect) VersionTableExistsSQL() string {
+	return `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = 'schema_migrations'`
+}
+
+func (mysqlDialect) Placeholder(int) string { return "?" }
+
+// MySQL 的 DDL 会隐式提交事务, 无法回滚
+func (mysqlDialect) TransactionalDDL() bool { return false }
+
+// Lock 使用 GET_LOCK 获取命名锁, 锁与连接绑定, 连接断开时自动释放
+func (mysqlDialect) Lock(ctx context.Context, conn *sql.Conn) error {
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, lockName, lockTimeoutSeconds).Scan(&acquired); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		return fmt.Errorf("acquire migration lock: timed out after %ds", lockTimeoutSeconds)
+	}
+	return nil
+}
+
+func (mysqlDialect) Unlock(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `SELECT RELEASE_LOCK(?)`, lockName)
+	return err
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string { return "postgres" }
+
+func (postgresDialect) CreateVersionTableSQL() string {
+	return `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    BIGINT       NOT NULL PRIMARY KEY,
+		name       VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMPTZ  NOT NULL
+	)`
+}
+
+func (postgresDialect) VersionTableExistsSQL() string {
+	return `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'schema_migrations'`
+}
+
+func (postgresDialect) Placeholder(n int) string { return fmt.Sprintf("$%d", n) }
+
+func (postgresDialect) TransactionalDDL() bool { return true }
+
+// Lock 使用会话级 advisory lock, 锁与连接绑定, 连接断开时自动释放
+// pg_advisory_lock 会无限等待, 所以轮询 pg_try_advisory_lock 以便超时退出
+func (postgresDialect) Lock(ctx context.Context, conn *sql.Conn) error {
+	deadline := time.Now().Add(lockTimeoutSeconds * time.Second)
+	for {
+		var acquired bool
+		if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, lockName).Scan(&acquired); err != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+		if acquired {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("acquire migration lock: timed out after %ds", lockTimeoutSeconds)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+func (postgresDialect) Unlock(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock(hashtext($1))`, lockName)
+	return err
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string { return "sqlite" }
+
+func (sqliteDialect) CreateVersionTableSQL() string {
+	return `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER  NOT NULL PRIMARY KEY,
+		name       TEXT     NOT NULL,
+		applied_at DATETIME NOT NULL
+	)`
+}
+
+func (sqliteDialect) VersionTableExistsSQL() string {
+	return `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`
+}
+
+func (sqliteDialect) Placeholder(int) string { return "?" }
+
+func (sqliteDialect) TransactionalDDL() bool { return true }
+
+// Lock 不做任何事: SQLite 是嵌入式数据库, 只有当前进程会执行迁移,
+// 并发写入由数据库文件锁保证
+func (sqliteDialect) Lock(context.Context, *sql.Conn) error { return nil }
+
+func (sqliteDialect) Unlock(context.Context, *sql.Conn) error { return nil }
@@ -0,0 +1,303 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestParseFileName(t *testing.T) {
+	for _, c := range []struct {
+		file      string
+		version   int64
+		name      string
+		direction string
+		wantErr   string
+	}{
+		{file: "0001_create_users.up.sql", version: 1, name: "create_users", direction: "up"},
+		{file: "0012_add_index_to_terms.down.sql", version: 12, name: "add_index_to_terms", direction: "down"},
+		{file: "20240101_seed.up.sql", version: 20240101, name: "seed", direction: "up"},
+		{file: "0001_create_users.sql", wantErr: "must end with .up.sql or .down.sql"},
+		{file: "0001.up.sql", wantErr: "must be named"},
+		{file: "0001_.up.sql", wantErr: "must be named"},
+		{file: "v1_create_users.up.sql", wantErr: "invalid version"},
+		{file: "0000_create_users.up.sql", wantErr: "invalid version"},
+		{file: "-1_create_users.up.sql", wantErr: "invalid version"},
+	} {
+		version, name, direction, err := parseFileName(c.file)
+		if c.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Errorf("%s: err = %v, want it to contain %q", c.file, err, c.wantErr)
+			}
+			continue
+		}
+		if err != nil || version != c.version || name != c.name || direction != c.direction {
+			t.Errorf("%s: got %d, %q, %q, %v; want %d, %q, %q", c.file, version, name, direction, err, c.version, c.name, c.direction)
+		}
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"db/0010_c.up.sql":   {Data: []byte("up c")},
+		"db/0010_c.down.sql": {Data: []byte("down c")},
+		"db/0002_b.up.sql":   {Data: []byte("up b")},
+		"db/0002_b.down.sql": {Data: []byte("down b")},
+		"db/0001_a.up.sql":   {Data: []byte("up a")},
+		"db/0001_a.down.sql": {Data: []byte("down a")},
+		"db/README.md":       {Data: []byte("不是迁移文件")},
+	}
+	migrations, err := loadMigrations(fsys, "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 按版本号的数值排序, 不是按文件名
+	var versions []int64
+	for _, m := range migrations {
+		versions = append(versions, m.Version)
+	}
+	if !slices.Equal(versions, []int64{1, 2, 10}) {
+		t.Fatalf("versions = %v, want [1 2 10]", versions)
+	}
+	if m := migrations[2]; m.Name != "c" || m.Up != "up c" || m.Down != "down c" {
+		t.Fatalf("migration 10 = %+v", m)
+	}
+
+	for _, c := range []struct {
+		name    string
+		files   fstest.MapFS
+		wantErr string
+	}{
+		{"missing down", fstest.MapFS{"db/0001_a.up.sql": {Data: []byte("up")}}, "must have both up and down"},
+		{"empty down", fstest.MapFS{"db/0001_a.up.sql": {Data: []byte("up")}, "db/0001_a.down.sql": {}}, "must have both up and down"},
+		{"conflicting names", fstest.MapFS{"db/0001_a.up.sql": {Data: []byte("up")}, "db/0001_b.down.sql": {Data: []byte("down")}}, "conflicting names"},
+		{"invalid file name", fstest.MapFS{"db/create_users.up.sql": {Data: []byte("up")}}, "invalid version"},
+		{"missing dir", fstest.MapFS{}, "read migrations dir"},
+	} {
+		if _, err := loadMigrations(c.files, "db"); err == nil || !strings.Contains(err.Error(), c.wantErr) {
+			t.Errorf("%s: err = %v, want it to contain %q", c.name, err, c.wantErr)
+		}
+	}
+}
+
+func TestEmbeddedMigrationsMatchAcrossDialects(t *testing.T) {
+	var names [][]string
+	for _, dialect := range []Dialect{MySQL, Postgres, SQLite} {
+		migrations, err := loadMigrations(migrationFiles, dialect.Name())
+		if err != nil {
+			t.Fatalf("%s: %v", dialect.Name(), err)
+		}
+		var dialectNames []string
+		for _, m := range migrations {
+			dialectNames = append(dialectNames, m.Name)
+		}
+		names = append(names, dialectNames)
+	}
+	if !slices.Equal(names[0], names[1]) || !slices.Equal(names[0], names[2]) {
+		t.Fatalf("dialects have different migrations: %v", names)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- 创建表
+CREATE TABLE a (
+    id INTEGER -- 行尾注释不会结束语句
+);
+
+INSERT INTO a VALUES (1);
+INSERT INTO a VALUES (2)`
+	want := []string{
+		"CREATE TABLE a (\n    id INTEGER -- 行尾注释不会结束语句\n);",
+		"INSERT INTO a VALUES (1);",
+		"INSERT INTO a VALUES (2)",
+	}
+	if got := splitStatements(script); !slices.Equal(got, want) {
+		t.Fatalf("statements = %q, want %q", got, want)
+	}
+	if got := splitStatements("\n-- 只有注释\n\n"); len(got) != 0 {
+		t.Fatalf("statements = %q, want none", got)
+	}
+}
+
+// newTestRunner 在 SQLite 内存数据库上创建只包含给定迁移的执行器
+func newTestRunner(t *testing.T, migrations ...Migration) (*Runner, *sql.DB) {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 每个连接都是独立的内存数据库, 只使用一个连接
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	return &Runner{db: db, dialect: SQLite, migrations: migrations}, db
+}
+
+// orderedMigrations 每个迁移在 log 表中记录自己的执行, 后一个迁移依赖前一个迁移创建的表
+func orderedMigrations() []Migration {
+	return []Migration{
+		{Version: 1, Name: "a", Up: "CREATE TABLE log (entry TEXT NOT NULL);\nINSERT INTO log VALUES ('up 1');", Down: "DROP TABLE log;"},
+		{Version: 2, Name: "b", Up: "CREATE TABLE b (id INTEGER);\nINSERT INTO log VALUES ('up 2');", Down: "DROP TABLE b;\nINSERT INTO log VALUES ('down 2');"},
+		{Version: 3, Name: "c", Up: "CREATE TABLE c (id INTEGER REFERENCES b (id));\nINSERT INTO log VALUES ('up 3');", Down: "DROP TABLE c;\nINSERT INTO log VALUES ('down 3');"},
+	}
+}
+
+func executedVersions(migrations []Migration) []int64 {
+	versions := []int64{}
+	for _, m := range migrations {
+		versions = append(versions, m.Version)
+	}
+	return versions
+}
+
+func logEntries(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	rows, err := db.Query(`SELECT entry FROM log`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var entries []string
+	for rows.Next() {
+		var entry string
+		if err := rows.Scan(&entry); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestRunnerAppliesUpInOrderAndDownInReverse(t *testing.T) {
+	ctx := context.Background()
+	runner, db := newTestRunner(t, orderedMigrations()...)
+
+	if version, err := runner.CurrentVersion(ctx); err != nil || version != 0 {
+		t.Fatalf("version = %d, %v; want 0", version, err)
+	}
+	executed, err := runner.To(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := executedVersions(executed); !slices.Equal(got, []int64{1, 2}) {
+		t.Fatalf("executed %v, want [1 2]", got)
+	}
+	executed, err = runner.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := executedVersions(executed); !slices.Equal(got, []int64{3}) {
+		t.Fatalf("executed %v, want [3]", got)
+	}
+	if err := runner.CheckVersion(ctx); err != nil {
+		t.Fatalf("check version: %v", err)
+	}
+
+	// 已经是最新版本时不再执行
+	executed, err = runner.Up(ctx)
+	if err != nil || len(executed) != 0 {
+		t.Fatalf("executed %v, %v; want nothing", executedVersions(executed), err)
+	}
+
+	executed, err = runner.To(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := executedVersions(executed); !slices.Equal(got, []int64{3, 2}) {
+		t.Fatalf("rolled back %v, want [3 2]", got)
+	}
+	if got := logEntries(t, db); !slices.Equal(got, []string{"up 1", "up 2", "up 3", "down 3", "down 2"}) {
+		t.Fatalf("log = %v", got)
+	}
+	if err := runner.CheckVersion(ctx); err == nil || !strings.Contains(err.Error(), "version 1, expected 3") {
+		t.Fatalf("check version: err = %v, want version 1, expected 3", err)
+	}
+
+	statuses, err := runner.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 3 || !statuses[0].Applied || statuses[0].AppliedAt == nil || statuses[1].Applied || statuses[2].Applied {
+		t.Fatalf("statuses = %+v, want only 1 applied", statuses)
+	}
+
+	rolledBack, err := runner.Down(ctx)
+	if err != nil || rolledBack == nil || rolledBack.Version != 1 {
+		t.Fatalf("down = %+v, %v; want 1", rolledBack, err)
+	}
+	if rolledBack, err := runner.Down(ctx); err != nil || rolledBack != nil {
+		t.Fatalf("down = %+v, %v; want nothing to roll back", rolledBack, err)
+	}
+	if _, err := runner.To(ctx, 4); err == nil || !strings.Contains(err.Error(), "unknown migration version 4") {
+		t.Fatalf("to unknown version: err = %v", err)
+	}
+}
+
+func TestRunnerRollsBackFailedMigration(t *testing.T) {
+	ctx := context.Background()
+	migrations := orderedMigrations()
+	migrations[2].Up = "CREATE TABLE c (id INTEGER);\nINSERT INTO missing VALUES (1);"
+	runner, db := newTestRunner(t, migrations...)
+
+	executed, err := runner.Up(ctx)
+	if err == nil || !strings.Contains(err.Error(), "migration 3_c up failed") {
+		t.Fatalf("err = %v, want migration 3 to fail", err)
+	}
+	if got := executedVersions(executed); !slices.Equal(got, []int64{1, 2}) {
+		t.Fatalf("executed %v, want [1 2]", got)
+	}
+	// SQLite 支持事务性 DDL, 失败的迁移不留下任何修改
+	if version, err := runner.CurrentVersion(ctx); err != nil || version != 2 {
+		t.Fatalf("version = %d, %v; want 2", version, err)
+	}
+	var tables int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'c'`).Scan(&tables); err != nil || tables != 0 {
+		t.Fatalf("table c exists = %d, %v; want it rolled back", tables, err)
+	}
+}
+
+func TestRunnerDownRequiresEmbeddedMigration(t *testing.T) {
+	ctx := context.Background()
+	runner, db := newTestRunner(t, orderedMigrations()...)
+	if _, err := runner.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// 用旧版本程序回滚新版本程序执行的迁移
+	old := &Runner{db: db, dialect: SQLite, migrations: orderedMigrations()[:2]}
+	if _, err := old.Down(ctx); err == nil || !strings.Contains(err.Error(), "not embedded") {
+		t.Fatalf("err = %v, want migration 3 not embedded", err)
+	}
+	if err := old.CheckVersion(ctx); err == nil {
+		t.Fatal("expected an old binary to report a newer schema")
+	}
+}
+
+func TestCheckVersionIsReadOnly(t *testing.T) {
+	ctx := context.Background()
+	runner, db := newTestRunner(t, orderedMigrations()...)
+
+	// 从未执行过迁移时版本为 0, 检查不创建 schema_migrations 表
+	if err := runner.CheckVersion(ctx); err == nil || !strings.Contains(err.Error(), "version 0, expected 3") {
+		t.Fatalf("err = %v, want version 0, expected 3", err)
+	}
+	var tables int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'schema_migrations'`).Scan(&tables); err != nil || tables != 0 {
+		t.Fatalf("schema_migrations exists = %d, %v; want the check to create nothing", tables, err)
+	}
+
+	// 回滚全部迁移后表仍然存在但为空
+	if _, err := runner.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.To(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := runner.CurrentVersion(ctx); err != nil || version != 0 {
+		t.Fatalf("version = %d, %v; want 0", version, err)
+	}
+}
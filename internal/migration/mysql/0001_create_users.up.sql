@@ -0,0 +1,13 @@
+CREATE TABLE users (
+    id              BIGINT       NOT NULL AUTO_INCREMENT,
+    username        VARCHAR(64)  NOT NULL,
+    hashed_password VARCHAR(255) NOT NULL,
+    email           VARCHAR(255) NOT NULL,
+    avatar_url      VARCHAR(1024) NULL,
+    role            ENUM('user', 'admin') NOT NULL DEFAULT 'user',
+    created_at      DATETIME(3)  NOT NULL,
+    updated_at      DATETIME(3)  NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_users_username (username),
+    UNIQUE KEY uk_users_email (email)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
@@ -0,0 +1,23 @@
+CREATE TABLE terms (
+    id            BIGINT        NOT NULL AUTO_INCREMENT,
+    name          VARCHAR(255)  NOT NULL,
+    explanation   TEXT          NOT NULL,
+    source_url    VARCHAR(1024) NOT NULL DEFAULT '',
+    -- 冗余保存分类 ID 列表 (JSON 数组), 与 term_category_relations 保持一致
+    category_list JSON          NULL,
+    created_by    BIGINT        NULL,
+    created_at    DATETIME(3)   NOT NULL,
+    updated_at    DATETIME(3)   NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_terms_name (name),
+    CONSTRAINT fk_terms_created_by FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE SET NULL
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
+
+CREATE TABLE term_category_relations (
+    term_id     BIGINT NOT NULL,
+    category_id BIGINT NOT NULL,
+    PRIMARY KEY (term_id, category_id),
+    -- ListTermsByCategory 按分类查询并按 term_id 分页
+    KEY idx_term_category_relations_category (category_id, term_id),
+    CONSTRAINT fk_term_category_relations_term FOREIGN KEY (term_id) REFERENCES terms (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
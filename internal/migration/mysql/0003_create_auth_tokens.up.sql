@@ -0,0 +1,22 @@
+CREATE TABLE refresh_tokens (
+    id         BIGINT      NOT NULL AUTO_INCREMENT,
+    user_id    BIGINT      NOT NULL,
+    family_id  CHAR(36)    NOT NULL,
+    token_hash CHAR(64)    NOT NULL,
+    expires_at DATETIME(3) NOT NULL,
+    used_at    DATETIME(3) NULL,
+    revoked_at DATETIME(3) NULL,
+    created_at DATETIME(3) NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_refresh_tokens_token_hash (token_hash),
+    KEY idx_refresh_tokens_family (family_id),
+    KEY idx_refresh_tokens_user (user_id),
+    CONSTRAINT fk_refresh_tokens_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
+
+CREATE TABLE revoked_access_tokens (
+    jti        CHAR(36)    NOT NULL,
+    expires_at DATETIME(3) NOT NULL,
+    PRIMARY KEY (jti),
+    KEY idx_revoked_access_tokens_expires_at (expires_at)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
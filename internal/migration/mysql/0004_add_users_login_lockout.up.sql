@@ -0,0 +1,3 @@
+ALTER TABLE users
+    ADD COLUMN failed_login_attempts INT         NOT NULL DEFAULT 0 AFTER role,
+    ADD COLUMN locked_until          DATETIME(3) NULL AFTER failed_login_attempts;
@@ -0,0 +1,14 @@
+CREATE TABLE user_tokens (
+    id         BIGINT      NOT NULL AUTO_INCREMENT,
+    user_id    BIGINT      NOT NULL,
+    purpose    VARCHAR(32) NOT NULL,
+    token_hash CHAR(64)    NOT NULL,
+    expires_at DATETIME(3) NOT NULL,
+    used_at    DATETIME(3) NULL,
+    created_at DATETIME(3) NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_user_tokens_token_hash (token_hash),
+    KEY idx_user_tokens_user_purpose (user_id, purpose),
+    KEY idx_user_tokens_expires_at (expires_at),
+    CONSTRAINT fk_user_tokens_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
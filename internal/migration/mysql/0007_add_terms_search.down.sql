@@ -0,0 +1,2 @@
+ALTER TABLE terms DROP INDEX ft_terms_search;
+ALTER TABLE terms DROP COLUMN aliases;
@@ -0,0 +1,5 @@
+-- 别名以换行分隔保存, 首尾各有一个换行, 便于用 LIKE 匹配整个别名或别名前缀
+ALTER TABLE terms ADD COLUMN aliases VARCHAR(2048) NOT NULL DEFAULT '' AFTER name;
+
+-- ngram 分词器同时支持英文和不以空格分词的中文
+ALTER TABLE terms ADD FULLTEXT INDEX ft_terms_search (name, aliases, explanation) WITH PARSER ngram;
@@ -0,0 +1,4 @@
+ALTER TABLE terms DROP INDEX idx_terms_search_version;
+ALTER TABLE terms DROP COLUMN search_version;
+ALTER TABLE terms DROP COLUMN search_pinyin;
+ALTER TABLE terms DROP COLUMN search_names;
@@ -0,0 +1,8 @@
+-- 名称和别名的检索形式, 由应用在写入时计算, 格式与 aliases 相同 (换行分隔, 首尾各有一个换行):
+-- search_names 保存转为小写和简体后的名称和别名, search_pinyin 保存它们的全拼和拼音首字母
+ALTER TABLE terms ADD COLUMN search_names TEXT NOT NULL AFTER aliases;
+ALTER TABLE terms ADD COLUMN search_pinyin TEXT NOT NULL AFTER search_names;
+
+-- 计算方式变化时应用提高版本号, 启动时重新计算版本较旧的行; 已有的行版本为 0, 首次启动时计算
+ALTER TABLE terms ADD COLUMN search_version INT NOT NULL DEFAULT 0 AFTER search_pinyin;
+ALTER TABLE terms ADD INDEX idx_terms_search_version (search_version);
@@ -0,0 +1,22 @@
+CREATE TABLE categories (
+    id          BIGINT        NOT NULL AUTO_INCREMENT,
+    -- 顶级分类为 NULL; 有子分类的分类不能删除
+    parent_id   BIGINT        NULL,
+    name        VARCHAR(100)  NOT NULL,
+    slug        VARCHAR(100)  NOT NULL,
+    description VARCHAR(1024) NOT NULL DEFAULT '',
+    -- 同一父分类下按 sort_order 升序排列, 相同时按 ID
+    sort_order  INT           NOT NULL DEFAULT 0,
+    created_at  DATETIME(3)   NOT NULL,
+    updated_at  DATETIME(3)   NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_categories_slug (slug),
+    KEY idx_categories_parent (parent_id),
+    CONSTRAINT fk_categories_parent FOREIGN KEY (parent_id) REFERENCES categories (id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
+
+-- 之前客户端直接使用分类 ID, 为已经关联术语的 ID 补建分类, 由管理员之后修改名称
+-- term_category_relations.category_id 不加外键, 由服务层校验分类是否存在
+INSERT INTO categories (id, name, slug, created_at, updated_at)
+SELECT DISTINCT category_id, CONCAT('Category ', category_id), CONCAT('category-', category_id), NOW(3), NOW(3)
+FROM term_category_relations;
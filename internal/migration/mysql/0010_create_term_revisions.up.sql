@@ -0,0 +1,30 @@
+-- 术语每次创建和修改都追加一个修订版本, 保存修改后的完整快照, 已有的行不再修改
+CREATE TABLE term_revisions (
+    id                BIGINT        NOT NULL AUTO_INCREMENT,
+    term_id           BIGINT        NOT NULL,
+    -- 同一术语的版本号从 1 开始连续递增
+    revision          INT           NOT NULL,
+    name              VARCHAR(255)  NOT NULL,
+    aliases           VARCHAR(2048) NOT NULL DEFAULT '',
+    explanation       TEXT          NOT NULL,
+    source_url        VARCHAR(1024) NOT NULL DEFAULT '',
+    -- 分类 ID 列表 (JSON 数组)
+    category_ids      VARCHAR(1024) NOT NULL DEFAULT '[]',
+    -- 与上一个版本相比修改了的字段, 与 aliases 一样以换行分隔
+    changed_fields    VARCHAR(255)  NOT NULL DEFAULT '',
+    summary           VARCHAR(255)  NOT NULL DEFAULT '',
+    -- 回滚产生的版本记录恢复的是哪个版本
+    restored_revision INT           NULL,
+    created_by        BIGINT        NULL,
+    created_at        DATETIME(3)   NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_term_revisions_term_revision (term_id, revision),
+    CONSTRAINT fk_term_revisions_term FOREIGN KEY (term_id) REFERENCES terms (id) ON DELETE CASCADE,
+    CONSTRAINT fk_term_revisions_created_by FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE SET NULL
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
+
+-- 已有术语以当前内容作为第 1 个版本, 之前的修改没有记录, 作者记为术语的创建者
+INSERT INTO term_revisions (term_id, revision, name, aliases, explanation, source_url, category_ids, changed_fields, created_by, created_at)
+SELECT id, 1, name, aliases, explanation, source_url, COALESCE(CAST(category_list AS CHAR), '[]'),
+    '\nname\naliases\nexplanation\nsource_url\ncategory_ids\n', created_by, updated_at
+FROM terms;
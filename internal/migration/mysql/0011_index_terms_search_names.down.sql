@@ -0,0 +1,2 @@
+ALTER TABLE terms DROP INDEX ft_terms_search;
+ALTER TABLE terms ADD FULLTEXT INDEX ft_terms_search (name, aliases, explanation) WITH PARSER ngram;
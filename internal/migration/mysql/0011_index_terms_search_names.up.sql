@@ -0,0 +1,8 @@
+-- 全文索引改建在 search_names 和 explanation 上: 名称和别名使用转为小写和简体后的形式,
+-- 搜索只用全文索引, 不再用 LIKE 扫描全表
+ALTER TABLE terms DROP INDEX ft_terms_search;
+-- ngram 分出的词包含停用词时不会进入索引, 建索引时关闭停用词, 结束后恢复当前连接原来的设置
+SET @old_ft_enable_stopword = @@SESSION.innodb_ft_enable_stopword;
+SET SESSION innodb_ft_enable_stopword = OFF;
+ALTER TABLE terms ADD FULLTEXT INDEX ft_terms_search (search_names, explanation) WITH PARSER ngram;
+SET SESSION innodb_ft_enable_stopword = @old_ft_enable_stopword;
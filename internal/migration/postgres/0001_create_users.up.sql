@@ -0,0 +1,12 @@
+CREATE TABLE users (
+    id              BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    username        VARCHAR(64)   NOT NULL,
+    hashed_password VARCHAR(255)  NOT NULL,
+    email           VARCHAR(255)  NOT NULL,
+    avatar_url      VARCHAR(1024) NULL,
+    role            VARCHAR(16)   NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin')),
+    created_at      TIMESTAMPTZ   NOT NULL,
+    updated_at      TIMESTAMPTZ   NOT NULL,
+    CONSTRAINT uk_users_username UNIQUE (username),
+    CONSTRAINT uk_users_email UNIQUE (email)
+);
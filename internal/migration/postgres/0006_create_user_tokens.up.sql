@@ -0,0 +1,13 @@
+CREATE TABLE user_tokens (
+    id         BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id    BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    purpose    VARCHAR(32) NOT NULL,
+    token_hash CHAR(64)    NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at    TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT uk_user_tokens_token_hash UNIQUE (token_hash)
+);
+
+CREATE INDEX idx_user_tokens_user_purpose ON user_tokens (user_id, purpose);
+CREATE INDEX idx_user_tokens_expires_at ON user_tokens (expires_at);
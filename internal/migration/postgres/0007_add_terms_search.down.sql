@@ -0,0 +1,3 @@
+DROP INDEX idx_terms_search_vector;
+ALTER TABLE terms DROP COLUMN search_vector;
+ALTER TABLE terms DROP COLUMN aliases;
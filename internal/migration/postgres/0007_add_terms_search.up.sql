@@ -0,0 +1,11 @@
+-- 别名以换行分隔保存, 首尾各有一个换行, 便于用 LIKE 匹配整个别名或别名前缀
+ALTER TABLE terms ADD COLUMN aliases VARCHAR(2048) NOT NULL DEFAULT '';
+
+-- 名称, 别名和解释的权重依次为 A, B, C, ts_rank 据此计算相关度
+ALTER TABLE terms ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', name), 'A') ||
+    setweight(to_tsvector('simple', aliases), 'B') ||
+    setweight(to_tsvector('simple', explanation), 'C')
+) STORED;
+
+CREATE INDEX idx_terms_search_vector ON terms USING GIN (search_vector);
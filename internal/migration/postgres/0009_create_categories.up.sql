@@ -0,0 +1,24 @@
+CREATE TABLE categories (
+    id          BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    -- 顶级分类为 NULL; 有子分类的分类不能删除
+    parent_id   BIGINT        NULL REFERENCES categories (id),
+    name        VARCHAR(100)  NOT NULL,
+    slug        VARCHAR(100)  NOT NULL,
+    description VARCHAR(1024) NOT NULL DEFAULT '',
+    -- 同一父分类下按 sort_order 升序排列, 相同时按 ID
+    sort_order  INTEGER       NOT NULL DEFAULT 0,
+    created_at  TIMESTAMPTZ   NOT NULL,
+    updated_at  TIMESTAMPTZ   NOT NULL,
+    CONSTRAINT uk_categories_slug UNIQUE (slug)
+);
+
+CREATE INDEX idx_categories_parent ON categories (parent_id);
+
+-- 之前客户端直接使用分类 ID, 为已经关联术语的 ID 补建分类, 由管理员之后修改名称
+-- term_category_relations.category_id 不加外键, 由服务层校验分类是否存在
+INSERT INTO categories (id, name, slug, created_at, updated_at)
+SELECT DISTINCT category_id, 'Category ' || category_id, 'category-' || category_id, NOW(), NOW()
+FROM term_category_relations;
+
+-- 显式插入 ID 不会推进自增序列, 之后创建的分类从最大 ID 之后开始
+SELECT setval(pg_get_serial_sequence('categories', 'id'), COALESCE((SELECT MAX(id) FROM categories), 0) + 1, false);
@@ -0,0 +1,27 @@
+-- 术语每次创建和修改都追加一个修订版本, 保存修改后的完整快照, 已有的行不再修改
+CREATE TABLE term_revisions (
+    id                BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    term_id           BIGINT        NOT NULL REFERENCES terms (id) ON DELETE CASCADE,
+    -- 同一术语的版本号从 1 开始连续递增
+    revision          INTEGER       NOT NULL,
+    name              VARCHAR(255)  NOT NULL,
+    aliases           VARCHAR(2048) NOT NULL DEFAULT '',
+    explanation       TEXT          NOT NULL,
+    source_url        VARCHAR(1024) NOT NULL DEFAULT '',
+    -- 分类 ID 列表 (JSON 数组)
+    category_ids      VARCHAR(1024) NOT NULL DEFAULT '[]',
+    -- 与上一个版本相比修改了的字段, 与 aliases 一样以换行分隔
+    changed_fields    VARCHAR(255)  NOT NULL DEFAULT '',
+    summary           VARCHAR(255)  NOT NULL DEFAULT '',
+    -- 回滚产生的版本记录恢复的是哪个版本
+    restored_revision INTEGER       NULL,
+    created_by        BIGINT        NULL REFERENCES users (id) ON DELETE SET NULL,
+    created_at        TIMESTAMPTZ   NOT NULL,
+    CONSTRAINT uk_term_revisions_term_revision UNIQUE (term_id, revision)
+);
+
+-- 已有术语以当前内容作为第 1 个版本, 之前的修改没有记录, 作者记为术语的创建者
+INSERT INTO term_revisions (term_id, revision, name, aliases, explanation, source_url, category_ids, changed_fields, created_by, created_at)
+SELECT id, 1, name, aliases, explanation, source_url, COALESCE(category_list::text, '[]'),
+    E'\nname\naliases\nexplanation\nsource_url\ncategory_ids\n', created_by, updated_at
+FROM terms;
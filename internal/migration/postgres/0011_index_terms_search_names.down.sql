@@ -0,0 +1,2 @@
+-- pg_trgm 扩展可能被其他对象使用, 不删除
+DROP INDEX idx_terms_search_names_trgm;
@@ -0,0 +1,3 @@
+-- search_names 上的三元组索引, LIKE '%关键字%' 不需要扫描全表; 关键字少于三个字符时索引不能缩小范围
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX idx_terms_search_names_trgm ON terms USING GIN (search_names gin_trgm_ops);
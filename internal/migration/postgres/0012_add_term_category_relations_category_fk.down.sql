@@ -0,0 +1 @@
+ALTER TABLE term_category_relations DROP CONSTRAINT fk_term_category_relations_category;
@@ -0,0 +1,4 @@
+-- 0009 已为关联中的每个分类 ID 补建了分类, 之后由外键保证关联的分类存在
+-- 删除分类时仍由 DeleteCategory 检查分类下是否有术语
+ALTER TABLE term_category_relations
+    ADD CONSTRAINT fk_term_category_relations_category FOREIGN KEY (category_id) REFERENCES categories (id);
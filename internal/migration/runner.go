@@ -0,0 +1,296 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Status 表示单个迁移的执行状态
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// Runner 负责执行内嵌的数据库迁移, 并在 schema_migrations 表中记录已执行的版本
+type Runner struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+// NewRunner 创建迁移执行器, 加载 dialect 对应目录下的内嵌迁移文件
+func NewRunner(db *sql.DB, dialect Dialect) (*Runner, error) {
+	migrations, err := loadMigrations(migrationFiles, dialect.Name())
+	if err != nil {
+		return nil, err
+	}
+	return &Runner{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+// LatestVersion 返回内嵌迁移中的最新版本号, 没有迁移时返回 0
+func (r *Runner) LatestVersion() int64 {
+	if len(r.migrations) == 0 {
+		return 0
+	}
+	return r.migrations[len(r.migrations)-1].Version
+}
+
+// CurrentVersion 返回数据库当前已执行到的版本号, 从未执行过迁移 (schema_migrations 表不存在) 时返回 0
+// 只执行查询, 不创建 schema_migrations 表, 也不占用专用连接
+func (r *Runner) CurrentVersion(ctx context.Context) (int64, error) {
+	var exists int
+	if err := r.db.QueryRowContext(ctx, r.dialect.VersionTableExistsSQL()).Scan(&exists); err != nil {
+		return 0, fmt.Errorf("check schema_migrations table: %w", err)
+	}
+	if exists == 0 {
+		return 0, nil
+	}
+	var version sql.NullInt64
+	if err := r.db.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("query schema_migrations: %w", err)
+	}
+	return version.Int64, nil
+}
+
+// CheckVersion 检查数据库版本是否与当前程序内嵌的最新迁移一致, 用于就绪检查, 只读取数据库
+// 数据库版本落后说明还没有执行迁移, 版本超前说明运行的是旧版本程序, 两种情况都不应该接收请求
+func (r *Runner) CheckVersion(ctx context.Context) error {
+	current, err := r.CurrentVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if latest := r.LatestVersion(); current != latest {
+		return fmt.Errorf("database schema is at version %d, expected %d", current, latest)
+	}
+	return nil
+}
+
+// Status 返回所有内嵌迁移的执行状态
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := r.ensureVersionTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	applied, err := r.appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(r.migrations))
+	for i, m := range r.migrations {
+		statuses[i] = Status{Version: m.Version, Name: m.Name}
+		if appliedAt, ok := applied[m.Version]; ok {
+			statuses[i].Applied = true
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// Up 执行所有尚未执行的迁移, 返回本次执行的迁移
+func (r *Runner) Up(ctx context.Context) ([]Migration, error) {
+	return r.To(ctx, r.LatestVersion())
+}
+
+// Down 回滚最近执行的一个迁移, 没有可回滚的迁移时返回 nil
+func (r *Runner) Down(ctx context.Context) (*Migration, error) {
+	var rolledBack *Migration
+	err := r.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := r.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		current := maxVersion(applied)
+		if current == 0 {
+			return nil
+		}
+
+		m, ok := r.find(current)
+		if !ok {
+			return fmt.Errorf("applied migration %d is not embedded in this binary", current)
+		}
+		if err := r.apply(ctx, conn, m, false); err != nil {
+			return err
+		}
+		rolledBack = &m
+		return nil
+	})
+	return rolledBack, err
+}
+
+// To 将数据库迁移到指定版本
+// 目标版本高于当前版本时按顺序执行 up, 低于当前版本时按倒序执行 down, 返回本次执行的迁移
+func (r *Runner) To(ctx context.Context, version int64) ([]Migration, error) {
+	if version != 0 {
+		if _, ok := r.find(version); !ok {
+			return nil, fmt.Errorf("unknown migration version %d", version)
+		}
+	}
+
+	var executed []Migration
+	err := r.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := r.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		// 升级: 执行所有不超过目标版本且尚未执行的迁移
+		for _, m := range r.migrations {
+			if m.Version > version {
+				break
+			}
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if err := r.apply(ctx, conn, m, true); err != nil {
+				return err
+			}
+			executed = append(executed, m)
+		}
+
+		// 回滚: 倒序回滚所有高于目标版本且已执行的迁移
+		for i := len(r.migrations) - 1; i >= 0; i-- {
+			m := r.migrations[i]
+			if m.Version <= version {
+				break
+			}
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if err := r.apply(ctx, conn, m, false); err != nil {
+				return err
+			}
+			executed = append(executed, m)
+		}
+		return nil
+	})
+	return executed, err
+}
+
+// withLock 在持有迁移锁的专用连接上执行 fn
+// 多个实例同时启动时, 只有拿到锁的实例执行迁移, 其他实例等待后发现已经是最新版本
+func (r *Runner) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := r.dialect.Lock(ctx, conn); err != nil {
+		return err
+	}
+	defer func() {
+		// 使用独立的 context, 避免 ctx 已取消时无法释放锁
+		if err := r.dialect.Unlock(context.Background(), conn); err != nil {
+			slog.Error("release migration lock failed", "error", err)
+		}
+	}()
+
+	if err := r.ensureVersionTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// apply 执行单个迁移并更新 schema_migrations
+// 支持事务性 DDL 的数据库在同一事务中执行, 否则逐条执行, 失败时需要人工检查数据库状态
+func (r *Runner) apply(ctx context.Context, conn *sql.Conn, m Migration, up bool) error {
+	script, direction := m.Up, "up"
+	bookkeeping := fmt.Sprintf(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (%s, %s, %s)`,
+		r.dialect.Placeholder(1), r.dialect.Placeholder(2), r.dialect.Placeholder(3))
+	args := []interface{}{m.Version, m.Name, time.Now().UTC()}
+	if !up {
+		script, direction = m.Down, "down"
+		bookkeeping = fmt.Sprintf(`DELETE FROM schema_migrations WHERE version = %s`, r.dialect.Placeholder(1))
+		args = []interface{}{m.Version}
+	}
+
+	slog.Info("apply migration", "direction", direction, "version", m.Version, "name", m.Name)
+
+	var execer interface {
+		ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	} = conn
+	var tx *sql.Tx
+	if r.dialect.TransactionalDDL() {
+		var err error
+		tx, err = conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = tx.Rollback() }()
+		execer = tx
+	}
+
+	for _, statement := range splitStatements(script) {
+		if _, err := execer.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("migration %d_%s %s failed: %w", m.Version, m.Name, direction, err)
+		}
+	}
+	if _, err := execer.ExecContext(ctx, bookkeeping, args...); err != nil {
+		return fmt.Errorf("record migration %d_%s: %w", m.Version, m.Name, err)
+	}
+
+	if tx != nil {
+		return tx.Commit()
+	}
+	return nil
+}
+
+// ensureVersionTable 确保 schema_migrations 表存在
+func (r *Runner) ensureVersionTable(ctx context.Context, conn *sql.Conn) error {
+	if _, err := conn.ExecContext(ctx, r.dialect.CreateVersionTableSQL()); err != nil {
+		return fmt.Errorf("create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// appliedVersions 返回已执行的版本及其执行时间
+func (r *Runner) appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// find 根据版本号查找内嵌的迁移
+func (r *Runner) find(version int64) (Migration, bool) {
+	for _, m := range r.migrations {
+		if m.Version == version {
+			return m, true
+		}
+	}
+	return Migration{}, false
+}
+
+// maxVersion 返回已执行版本中的最大值
+func maxVersion(applied map[int64]time.Time) int64 {
+	var current int64
+	for version := range applied {
+		if version > current {
+			current = version
+		}
+	}
+	return current
+}
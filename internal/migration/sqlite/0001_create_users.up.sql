@@ -0,0 +1,12 @@
+CREATE TABLE users (
+    id              INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+    username        TEXT     NOT NULL,
+    hashed_password TEXT     NOT NULL,
+    email           TEXT     NOT NULL,
+    avatar_url      TEXT     NULL,
+    role            TEXT     NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin')),
+    created_at      DATETIME NOT NULL,
+    updated_at      DATETIME NOT NULL,
+    CONSTRAINT uk_users_username UNIQUE (username),
+    CONSTRAINT uk_users_email UNIQUE (email)
+);
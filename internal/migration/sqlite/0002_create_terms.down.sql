@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS term_category_relations;
+DROP TABLE IF EXISTS terms;
@@ -0,0 +1,22 @@
+CREATE TABLE terms (
+    id            INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+    name          TEXT     NOT NULL,
+    explanation   TEXT     NOT NULL,
+    source_url    TEXT     NOT NULL DEFAULT '',
+    -- 冗余保存分类 ID 列表 (JSON 数组), 与 term_category_relations 保持一致
+    category_list TEXT     NULL,
+    created_by    INTEGER  NULL REFERENCES users (id) ON DELETE SET NULL,
+    created_at    DATETIME NOT NULL,
+    updated_at    DATETIME NOT NULL
+);
+
+CREATE INDEX idx_terms_name ON terms (name);
+
+CREATE TABLE term_category_relations (
+    term_id     INTEGER NOT NULL REFERENCES terms (id) ON DELETE CASCADE,
+    category_id INTEGER NOT NULL,
+    PRIMARY KEY (term_id, category_id)
+);
+
+-- ListTermsByCategory 按分类查询并按 term_id 分页
+CREATE INDEX idx_term_category_relations_category ON term_category_relations (category_id, term_id);
@@ -0,0 +1,21 @@
+CREATE TABLE refresh_tokens (
+    id         INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+    user_id    INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    family_id  TEXT     NOT NULL,
+    token_hash TEXT     NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at    DATETIME NULL,
+    revoked_at DATETIME NULL,
+    created_at DATETIME NOT NULL,
+    CONSTRAINT uk_refresh_tokens_token_hash UNIQUE (token_hash)
+);
+
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens (family_id);
+CREATE INDEX idx_refresh_tokens_user ON refresh_tokens (user_id);
+
+CREATE TABLE revoked_access_tokens (
+    jti        TEXT     NOT NULL PRIMARY KEY,
+    expires_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_revoked_access_tokens_expires_at ON revoked_access_tokens (expires_at);
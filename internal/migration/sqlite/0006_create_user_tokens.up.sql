@@ -0,0 +1,13 @@
+CREATE TABLE user_tokens (
+    id         INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+    user_id    INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    purpose    TEXT     NOT NULL,
+    token_hash TEXT     NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at    DATETIME NULL,
+    created_at DATETIME NOT NULL,
+    CONSTRAINT uk_user_tokens_token_hash UNIQUE (token_hash)
+);
+
+CREATE INDEX idx_user_tokens_user_purpose ON user_tokens (user_id, purpose);
+CREATE INDEX idx_user_tokens_expires_at ON user_tokens (expires_at);
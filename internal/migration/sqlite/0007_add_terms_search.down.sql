@@ -0,0 +1 @@
+ALTER TABLE terms DROP COLUMN aliases;
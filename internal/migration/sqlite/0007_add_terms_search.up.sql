@@ -0,0 +1,3 @@
+-- 别名以换行分隔保存, 首尾各有一个换行, 便于用 LIKE 匹配整个别名或别名前缀
+-- SQLite 不建全文索引, 搜索退化为 LIKE
+ALTER TABLE terms ADD COLUMN aliases TEXT NOT NULL DEFAULT '';
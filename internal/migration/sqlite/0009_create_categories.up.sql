@@ -0,0 +1,21 @@
+CREATE TABLE categories (
+    id          INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+    -- 顶级分类为 NULL; 有子分类的分类不能删除
+    parent_id   INTEGER  NULL REFERENCES categories (id),
+    name        TEXT     NOT NULL,
+    slug        TEXT     NOT NULL,
+    description TEXT     NOT NULL DEFAULT '',
+    -- 同一父分类下按 sort_order 升序排列, 相同时按 ID
+    sort_order  INTEGER  NOT NULL DEFAULT 0,
+    created_at  DATETIME NOT NULL,
+    updated_at  DATETIME NOT NULL,
+    CONSTRAINT uk_categories_slug UNIQUE (slug)
+);
+
+CREATE INDEX idx_categories_parent ON categories (parent_id);
+
+-- 之前客户端直接使用分类 ID, 为已经关联术语的 ID 补建分类, 由管理员之后修改名称
+-- term_category_relations.category_id 不加外键, 由服务层校验分类是否存在
+INSERT INTO categories (id, name, slug, created_at, updated_at)
+SELECT DISTINCT category_id, 'Category ' || category_id, 'category-' || category_id, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+FROM term_category_relations;
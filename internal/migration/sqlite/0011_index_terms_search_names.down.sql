@@ -0,0 +1 @@
+-- SQLite 没有全文索引, 没有需要撤销的修改
@@ -0,0 +1 @@
+-- SQLite 没有全文索引, 搜索仍然用 LIKE 匹配子串; 保留这个版本使各数据库的迁移版本一致
@@ -0,0 +1,14 @@
+CREATE TABLE term_category_relations_old (
+    term_id     INTEGER NOT NULL REFERENCES terms (id) ON DELETE CASCADE,
+    category_id INTEGER NOT NULL,
+    PRIMARY KEY (term_id, category_id)
+);
+
+INSERT INTO term_category_relations_old (term_id, category_id)
+SELECT term_id, category_id FROM term_category_relations;
+
+DROP TABLE term_category_relations;
+
+ALTER TABLE term_category_relations_old RENAME TO term_category_relations;
+
+CREATE INDEX idx_term_category_relations_category ON term_category_relations (category_id, term_id);
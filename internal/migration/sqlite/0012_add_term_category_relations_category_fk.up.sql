@@ -0,0 +1,17 @@
+-- 0009 已为关联中的每个分类 ID 补建了分类, 之后由外键保证关联的分类存在
+-- SQLite 不能给已有的表添加外键, 需要重建表
+CREATE TABLE term_category_relations_new (
+    term_id     INTEGER NOT NULL REFERENCES terms (id) ON DELETE CASCADE,
+    category_id INTEGER NOT NULL CONSTRAINT fk_term_category_relations_category REFERENCES categories (id),
+    PRIMARY KEY (term_id, category_id)
+);
+
+INSERT INTO term_category_relations_new (term_id, category_id)
+SELECT term_id, category_id FROM term_category_relations;
+
+DROP TABLE term_category_relations;
+
+ALTER TABLE term_category_relations_new RENAME TO term_category_relations;
+
+-- ListTermsByCategory 按分类查询并按 term_id 分页
+CREATE INDEX idx_term_category_relations_category ON term_category_relations (category_id, term_id);
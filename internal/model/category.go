@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// Category 术语分类，对应 categories 表, 分类可以通过 ParentID 组成树
+type Category struct {
+	ID          int64     `json:"id" db:"id"`
+	ParentID    *int64    `json:"parent_id" db:"parent_id"` // 顶级分类为 nil
+	Name        string    `json:"name" db:"name"`
+	Slug        string    `json:"slug" db:"slug"` // 唯一的 URL 友好名称, 例如 "flight-rules"
+	Description string    `json:"description" db:"description"`
+	SortOrder   int       `json:"sort_order" db:"sort_order"` // 同一父分类下按 SortOrder 升序排列, 相同时按 ID
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// CategoryNode 分类树的节点
+type CategoryNode struct {
+	Category
+	Children []CategoryNode `json:"children"`
+}
@@ -4,27 +4,156 @@ import "time"
 
 // TermSummary 术语概要模型，仅包含 ID 和名称
 type TermSummary struct {
-	ID   int64  `json:"id"`
-	Name string `json:"name"`
+	ID   int64  `json:"id" db:"id"`
+	Name string `json:"name" db:"name"`
 }
 
 // Term 术语模型，对应 terms 表
 type Term struct {
-	ID          int64     `json:"id"`
-	Name        string    `json:"name"`
-	Explanation string    `json:"explanation"`
-	SourceURL   string    `json:"source_url"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int64     `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Aliases     []string  `json:"aliases" db:"-"` // 别名, 例如缩写和其他译名, 可以通过别名搜索
+	Explanation string    `json:"explanation" db:"explanation"`
+	SourceURL   string    `json:"source_url" db:"source_url"`
+	CreatedBy   *int64    `json:"created_by" db:"created_by"` // 创建者用户 ID, 可为 NULL
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
 }
 
 // TermDetail 术语详情模型，包含分类 ID 列表
 type TermDetail struct {
-	ID          int64     `json:"id"`
-	Name        string    `json:"name"`
-	Explanation string    `json:"explanation"`
-	SourceURL   string    `json:"source_url"`
-	CategoryIDs []int64   `json:"category_ids"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int64     `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Aliases     []string  `json:"aliases" db:"-"`
+	Explanation string    `json:"explanation" db:"explanation"`
+	SourceURL   string    `json:"source_url" db:"source_url"`
+	CategoryIDs []int64   `json:"category_ids" db:"-"`
+	CreatedBy   *int64    `json:"created_by" db:"created_by"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// TermNames 术语的名称和别名, 用于构建搜索建议的索引
+type TermNames struct {
+	ID      int64    `json:"id" db:"id"`
+	Name    string   `json:"name" db:"name"`
+	Aliases []string `json:"aliases" db:"-"`
+}
+
+// TermSuggestion 输入时的搜索建议
+type TermSuggestion struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Alias string `json:"alias,omitempty"` // 通过别名命中时为命中的别名
+}
+
+// 搜索命中的等级, 等级高的排在前面, 同一等级内再按全文索引的相关度排序
+const (
+	MatchFullText = 0 // 只有解释或全文索引命中
+	MatchContains = 1 // 名称或别名包含关键字
+	MatchPrefix   = 2 // 名称或别名以关键字开头
+	MatchExact    = 3 // 名称或别名与关键字相同
+)
+
+// 搜索模式, 两种模式下名称和别名的比较都不区分大小写和繁简
+const (
+	SearchModeText   = "text"   // 在名称, 别名和解释中搜索关键字
+	SearchModePinyin = "pinyin" // 按全拼或拼音首字母搜索名称和别名, 关键字中的汉字也转换为拼音
+)
+
+// TermSearchQuery 全文搜索术语的条件
+type TermSearchQuery struct {
+	Keyword    string
+	Mode       string // SearchModeText 或 SearchModePinyin
+	CategoryID *int64 // 只返回该分类下的术语, 为 nil 时不过滤; 分类统计不受影响
+	Offset     int
+	Limit      int
+}
+
+// TermSearchHit 搜索命中的术语
+type TermSearchHit struct {
+	ID          int64    `json:"id" db:"id"`
+	Name        string   `json:"name" db:"name"`
+	Aliases     []string `json:"aliases" db:"-"`
+	Explanation string   `json:"explanation" db:"explanation"`
+	MatchRank   int      `json:"match_rank" db:"match_rank"`
+	Score       float64  `json:"score" db:"score"` // 数据库给出的全文相关度, 不同数据库之间不可比较
+	Highlight   string   `json:"highlight" db:"-"` // HTML 转义后的名称, 关键字用 <mark> 标出
+	Snippet     string   `json:"snippet" db:"-"`   // HTML 转义后的解释片段, 关键字用 <mark> 标出
+}
+
+// CategoryFacet 搜索结果在一个分类下的术语数
+type CategoryFacet struct {
+	CategoryID int64 `json:"category_id" db:"category_id"`
+	Count      int   `json:"count" db:"term_count"`
+}
+
+// TermSearchResult 一页搜索结果
+type TermSearchResult struct {
+	Hits       []TermSearchHit
+	Total      int             // 命中的术语总数, 不受分页和结果上限影响
+	Facets     []CategoryFacet // 按术语数从多到少排列
+	NextCursor string          // 下一页的游标, 没有下一页时为空
+}
+
+// 术语修订版本中记录修改了哪些字段时使用的字段名, 与 JSON 字段名相同
+const (
+	TermFieldName        = "name"
+	TermFieldAliases     = "aliases"
+	TermFieldExplanation = "explanation"
+	TermFieldSourceURL   = "source_url"
+	TermFieldCategoryIDs = "category_ids"
+)
+
+// TermFields 全部记录修订版本的字段, 按在响应中出现的顺序
+var TermFields = []string{TermFieldName, TermFieldAliases, TermFieldExplanation, TermFieldSourceURL, TermFieldCategoryIDs}
+
+// TermChange 创建或修改术语时写入修订版本的信息, 修改了的字段由存储库在事务中与上一个版本比较得出
+type TermChange struct {
+	AuthorID         *int64
+	Summary          string // 修改者填写的说明, 可以为空
+	RestoredRevision *int   // 回滚时为恢复的版本号
+}
+
+// TermRevision 术语的一个修订版本, 对应 term_revisions 表, 保存修改后的完整快照
+type TermRevision struct {
+	ID               int64     `json:"id" db:"id"`
+	TermID           int64     `json:"term_id" db:"term_id"`
+	Revision         int       `json:"revision" db:"revision"` // 从 1 开始连续递增
+	Name             string    `json:"name" db:"name"`
+	Aliases          []string  `json:"aliases" db:"-"`
+	Explanation      string    `json:"explanation" db:"explanation"`
+	SourceURL        string    `json:"source_url" db:"source_url"`
+	CategoryIDs      []int64   `json:"category_ids" db:"-"`
+	ChangedFields    []string  `json:"changed_fields" db:"-"`
+	Summary          string    `json:"summary" db:"summary"`
+	RestoredRevision *int      `json:"restored_revision" db:"restored_revision"`
+	CreatedBy        *int64    `json:"created_by" db:"created_by"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+}
+
+// 修订版本差异中一段文本的类型
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// TextChange 差异中的一段文本
+type TextChange struct {
+	Op   string `json:"op"` // DiffEqual, DiffInsert 或 DiffDelete
+	Text string `json:"text"`
+}
+
+// FieldDiff 一个字段在两个修订版本之间按词的差异
+type FieldDiff struct {
+	Field   string       `json:"field"`
+	Changes []TextChange `json:"changes"`
+}
+
+// TermRevisionDiff 两个修订版本之间的差异, 只包含值不同的字段
+type TermRevisionDiff struct {
+	From   int         `json:"from"`
+	To     int         `json:"to"`
+	Fields []FieldDiff `json:"fields"`
 }
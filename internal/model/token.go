@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// RefreshToken 刷新令牌模型，对应 refresh_tokens 表
+// 数据库只保存令牌的 SHA-256 哈希，原始令牌只在签发时返回给客户端一次
+// 同一次登录产生的刷新令牌属于同一个 family，每次刷新都会轮换出新的令牌
+type RefreshToken struct {
+	ID        int64      `json:"id" db:"id"`
+	UserID    int64      `json:"user_id" db:"user_id"`
+	FamilyID  string     `json:"family_id" db:"family_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`       // 已被轮换时设置，可为 NULL
+	RevokedAt *time.Time `json:"revoked_at,omitempty" db:"revoked_at"` // 已被吊销时设置，可为 NULL
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// TokenPair 登录或刷新后签发的一组令牌
+type TokenPair struct {
+	AccessToken          string
+	AccessTokenExpiresAt time.Time
+	RefreshToken         string
+}
@@ -4,14 +4,42 @@ import (
 	"time"
 )
 
+// 用户角色, 对应 users.role 字段的 ENUM('user', 'admin')
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 // User 用户模型
 type User struct {
-	ID        int64     `json:"id"`
-	Username  string    `json:"username"`
-	Password  string    `json:"-"` // 对应 password 字段，隐藏字段
-	Email     string    `json:"email"`
-	AvatarURL *string   `json:"avatar_url,omitempty"` // 可为 NULL
-	Role      string    `json:"role"`                 // 对应 ENUM('user', 'admin')
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        int64     `json:"id" db:"id"`
+	Username  string    `json:"username" db:"username"`
+	Password  string    `json:"-" db:"hashed_password"` // 对应 hashed_password 字段，隐藏字段
+	Email     string    `json:"email" db:"email"`
+	AvatarURL *string   `json:"avatar_url,omitempty" db:"avatar_url"` // 可为 NULL
+	Role      string    `json:"role" db:"role"`                       // 对应 ENUM('user', 'admin')
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+
+	// EmailVerifiedAt 用户通过邮件中的链接确认邮箱的时间, 未确认时为 NULL
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty" db:"email_verified_at"`
+
+	// 连续登录失败的次数和账号锁定的截止时间, 登录成功后清空
+	FailedLoginAttempts int        `json:"-" db:"failed_login_attempts"`
+	LockedUntil         *time.Time `json:"-" db:"locked_until"`
+}
+
+// IsLocked 判断账号在 now 时是否因为登录失败次数过多而被锁定
+func (u *User) IsLocked(now time.Time) bool {
+	return u.LockedUntil != nil && now.Before(*u.LockedUntil)
+}
+
+// IsEmailVerified 判断用户是否已经确认邮箱
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// IsAdmin 判断用户是否为管理员
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
 }
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// 一次性令牌的用途, 对应 user_tokens.purpose 字段
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+)
+
+// UserToken 通过邮件发送给用户的一次性令牌，对应 user_tokens 表
+// 和刷新令牌一样，数据库只保存令牌的 SHA-256 哈希，原始令牌只出现在邮件中
+type UserToken struct {
+	ID        int64      `json:"id" db:"id"`
+	UserID    int64      `json:"user_id" db:"user_id"`
+	Purpose   string     `json:"purpose" db:"purpose"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"` // 已使用或已作废时设置，可为 NULL
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
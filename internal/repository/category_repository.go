@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	servererrors "skymates-api/errors"
+	"skymates-api/internal/model"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// CategoryRepository 定义分类存储库接口
+type CategoryRepository interface {
+	ListCategories(ctx context.Context) ([]model.Category, error)
+	GetCategoryByID(ctx context.Context, id int64) (*model.Category, error)
+	CreateCategory(ctx context.Context, category *model.Category) (int64, error)
+	UpdateCategory(ctx context.Context, category *model.Category) error
+	DeleteCategory(ctx context.Context, id int64) (bool, error)
+}
+
+// categoryWriteError 转换创建或更新分类时的约束冲突, 服务层检查之后其他请求可能抢先修改了分类:
+// slug 重复时返回 KindAlreadyExists 错误, 父分类已被删除时返回 KindValidation 错误
+func categoryWriteError(err error) error {
+	switch {
+	case isDuplicateKey(err):
+		return servererrors.NewAlreadyExistsError("分类 slug 已存在", err)
+	case isForeignKeyViolation(err):
+		return servererrors.NewValidationError("父分类不存在", err)
+	}
+	return err
+}
+
+// categoryDeleteError 转换删除分类时的外键冲突: 服务层检查之后其他请求添加了子分类或关联了术语, 返回 KindConflict 错误
+func categoryDeleteError(err error) error {
+	if isForeignKeyViolation(err) {
+		return servererrors.NewConflictError("分类仍被引用", err)
+	}
+	return err
+}
+
+// CategoryRepositoryImpl 实现 CategoryRepository 接口
+type CategoryRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+// NewCategoryRepository 创建 CategoryRepository 实例
+func NewCategoryRepository(db *sqlx.DB) CategoryRepository {
+	return &CategoryRepositoryImpl{db: db}
+}
+
+// ListCategories 列出全部分类, 按 sort_order 和 ID 排序
+// 分类数量很少, 由服务层在内存中组成树
+func (r *CategoryRepositoryImpl) ListCategories(ctx context.Context) ([]model.Category, error) {
+	query := `SELECT id, parent_id, name, slug, description, sort_order, created_at, updated_at
+		FROM categories ORDER BY sort_order ASC, id ASC`
+	categories := []model.Category{}
+	if err := selectContext(ctx, r.db, "categories.list", &categories, query); err != nil {
+		return nil, fmt.Errorf("CategoryRepositoryImpl.ListCategories: %w", err)
+	}
+	return categories, nil
+}
+
+// GetCategoryByID 根据 ID 获取分类, 不存在时返回 nil
+func (r *CategoryRepositoryImpl) GetCategoryByID(ctx context.Context, id int64) (*model.Category, error) {
+	query := `SELECT id, parent_id, name, slug, description, sort_order, created_at, updated_at FROM categories WHERE id = ?`
+	var category model.Category
+	if err := getContext(ctx, r.db, "categories.get_by_id", &category, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("CategoryRepositoryImpl.GetCategoryByID: %w", err)
+	}
+	return &category, nil
+}
+
+// CreateCategory 创建分类, 返回分类 ID; 约束冲突时的错误见 categoryWriteError
+func (r *CategoryRepositoryImpl) CreateCategory(ctx context.Context, category *model.Category) (int64, error) {
+	now := time.Now()
+	query := `INSERT INTO categories (parent_id, name, slug, description, sort_order, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+	result, err := execContext(ctx, r.db, "categories.insert", query,
+		category.ParentID, category.Name, category.Slug, category.Description, category.SortOrder, now, now,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("CategoryRepositoryImpl.CreateCategory: %w", categoryWriteError(err))
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("CategoryRepositoryImpl.CreateCategory: %w", err)
+	}
+	return id, nil
+}
+
+// UpdateCategory 更新分类的父分类, 名称, slug, 描述和排序; 约束冲突时的错误见 categoryWriteError
+func (r *CategoryRepositoryImpl) UpdateCategory(ctx context.Context, category *model.Category) error {
+	query := `UPDATE categories SET parent_id = ?, name = ?, slug = ?, description = ?, sort_order = ?, updated_at = ? WHERE id = ?`
+	_, err := execContext(ctx, r.db, "categories.update", query,
+		category.ParentID, category.Name, category.Slug, category.Description, category.SortOrder, time.Now(), category.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("CategoryRepositoryImpl.UpdateCategory: %w", categoryWriteError(err))
+	}
+	return nil
+}
+
+// DeleteCategory 删除没有关联术语的分类, 返回是否删除了分类
+// 分类下有术语或分类不存在时返回 false; 外键约束使删除失败时的错误见 categoryDeleteError
+func (r *CategoryRepositoryImpl) DeleteCategory(ctx context.Context, id int64) (bool, error) {
+	query := `DELETE FROM categories WHERE id = ?
+		AND NOT EXISTS (SELECT 1 FROM term_category_relations WHERE category_id = ?)`
+	result, err := execContext(ctx, r.db, "categories.delete", query, id, id)
+	if err != nil {
+		return false, fmt.Errorf("CategoryRepositoryImpl.DeleteCategory: %w", categoryDeleteError(err))
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("CategoryRepositoryImpl.DeleteCategory: %w", err)
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"skymates-api/internal/model"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SQLiteCategoryRepository 实现了 CategoryRepository 接口, 使用 SQLite 数据库
+type SQLiteCategoryRepository struct {
+	db *sqlx.DB
+}
+
+// NewSQLiteCategoryRepository 返回一个基于 SQLite 的分类存储库
+func NewSQLiteCategoryRepository(db *sqlx.DB) CategoryRepository {
+	return &SQLiteCategoryRepository{db: db}
+}
+
+// ListCategories 列出全部分类, 按 sort_order 和 ID 排序
+// 分类数量很少, 由服务层在内存中组成树
+func (r *SQLiteCategoryRepository) ListCategories(ctx context.Context) ([]model.Category, error) {
+	query := `SELECT id, parent_id, name, slug, description, sort_order, created_at, updated_at
+		FROM categories ORDER BY sort_order ASC, id ASC`
+	categories := []model.Category{}
+	if err := selectContext(ctx, r.db, "categories.list", &categories, query); err != nil {
+		return nil, fmt.Errorf("SQLiteCategoryRepository.ListCategories: %w", err)
+	}
+	return categories, nil
+}
+
+// GetCategoryByID 根据 ID 获取分类, 不存在时返回 nil
+func (r *SQLiteCategoryRepository) GetCategoryByID(ctx context.Context, id int64) (*model.Category, error) {
+	query := `SELECT id, parent_id, name, slug, description, sort_order, created_at, updated_at FROM categories WHERE id = ?`
+	var category model.Category
+	if err := getContext(ctx, r.db, "categories.get_by_id", &category, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("SQLiteCategoryRepository.GetCategoryByID: %w", err)
+	}
+	return &category, nil
+}
+
+// CreateCategory 创建分类, 返回分类 ID; 约束冲突时的错误见 categoryWriteError
+func (r *SQLiteCategoryRepository) CreateCategory(ctx context.Context, category *model.Category) (int64, error) {
+	now := time.Now()
+	query := `INSERT INTO categories (parent_id, name, slug, description, sort_order, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`
+	var id int64
+	err := getContext(ctx, r.db, "categories.insert", &id, query,
+		category.ParentID, category.Name, category.Slug, category.Description, category.SortOrder, now, now,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("SQLiteCategoryRepository.CreateCategory: %w", categoryWriteError(err))
+	}
+	return id, nil
+}
+
+// UpdateCategory 更新分类的父分类, 名称, slug, 描述和排序; 约束冲突时的错误见 categoryWriteError
+func (r *SQLiteCategoryRepository) UpdateCategory(ctx context.Context, category *model.Category) error {
+	query := `UPDATE categories SET parent_id = ?, name = ?, slug = ?, description = ?, sort_order = ?, updated_at = ? WHERE id = ?`
+	_, err := execContext(ctx, r.db, "categories.update", query,
+		category.ParentID, category.Name, category.Slug, category.Description, category.SortOrder, time.Now(), category.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("SQLiteCategoryRepository.UpdateCategory: %w", categoryWriteError(err))
+	}
+	return nil
+}
+
+// DeleteCategory 删除没有关联术语的分类, 返回是否删除了分类
+// 分类下有术语或分类不存在时返回 false; 外键约束使删除失败时的错误见 categoryDeleteError
+func (r *SQLiteCategoryRepository) DeleteCategory(ctx context.Context, id int64) (bool, error) {
+	query := `DELETE FROM categories WHERE id = ?
+		AND NOT EXISTS (SELECT 1 FROM term_category_relations WHERE category_id = ?)`
+	result, err := execContext(ctx, r.db, "categories.delete", query, id, id)
+	if err != nil {
+		return false, fmt.Errorf("SQLiteCategoryRepository.DeleteCategory: %w", categoryDeleteError(err))
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("SQLiteCategoryRepository.DeleteCategory: %w", err)
+	}
+	return n > 0, nil
+}
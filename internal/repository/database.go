@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"skymates-api/config"
+	"time"
+)
+
+// 支持的数据库驱动, 对应配置中的 database.driver
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite" // 嵌入式数据库, 用于本地开发和测试, 不需要外部服务
+)
+
+// sqlDriverNames 将配置中的驱动名映射为 database/sql 注册的驱动名
+// sqlx 也根据驱动名决定命名参数的绑定方式 (? 或 $n)
+var sqlDriverNames = map[string]string{
+	DriverMySQL:    "mysql",
+	DriverPostgres: "pgx",
+	DriverSQLite:   "sqlite",
+}
+
+// isDuplicateKey 判断错误是否由唯一索引冲突引起, 不区分驱动
+func isDuplicateKey(err error) bool {
+	return isMySQLDuplicateKey(err) || isPostgresDuplicateKey(err) || isSQLiteDuplicateKey(err)
+}
+
+// isForeignKeyViolation 判断错误是否由外键约束冲突引起, 不区分驱动
+func isForeignKeyViolation(err error) bool {
+	return isMySQLForeignKeyViolation(err) || isPostgresForeignKeyViolation(err) || isSQLiteForeignKeyViolation(err)
+}
+
+// NewDatabase 根据数据库配置初始化连接池
+func NewDatabase(cfg config.DatabaseConfig) (*sqlx.DB, error) {
+	// 1. 构造 DSN (Data Source Name)
+	var dsn string
+	switch cfg.Driver {
+	case DriverMySQL:
+		dsn = mysqlDSN(cfg)
+	case DriverPostgres:
+		dsn = postgresDSN(cfg)
+	case DriverSQLite:
+		dsn = sqliteDSN(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
+
+	// 2. 打开连接池并按配置设置连接池参数
+	db, err := Open(cfg.Driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Driver != DriverSQLite {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+	return db, nil
+}
+
+// Open 打开数据库连接池, 使用默认的连接池参数并测试连通性
+func Open(driver, dsn string) (*sqlx.DB, error) {
+	sqlDriverName, ok := sqlDriverNames[driver]
+	if !ok {
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+
+	// 1. 打开数据库连接（底层会创建和管理连接池）
+	db, err := sqlx.Open(sqlDriverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %w", driver, err)
+	}
+
+	// 2. 配置连接池参数, NewDatabase 会再用配置中的值覆盖
+	if driver == DriverSQLite {
+		// SQLite 同一时间只允许一个写入者, 并且每个连接都会打开一个独立的内存数据库,
+		// 所以只使用一个连接, 连接也不能过期, 否则内存数据库的数据会丢失
+		db.SetMaxOpenConns(1)
+		db.SetMaxIdleConns(1)
+		db.SetConnMaxLifetime(0)
+	} else {
+		db.SetMaxOpenConns(25)                 // 最多保持 25 个打开连接
+		db.SetMaxIdleConns(25)                 // 最多保持 25 个空闲连接
+		db.SetConnMaxLifetime(5 * time.Minute) // 连接最大复用时间
+	}
+
+	// 3. 测试连通性
+	if err := db.PingContext(context.Background()); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("error pinging %s: %w", driver, err)
+	}
+
+	return db, nil
+}
+
+// Repositories 包含某一种数据库驱动下的所有仓库实例
+type Repositories struct {
+	User      UserRepository
+	Term      TermRepository
+	Category  CategoryRepository
+	Token     TokenRepository
+	UserToken UserTokenRepository
+}
+
+// NewRepositories 根据数据库驱动创建对应实现的仓库
+func NewRepositories(driver string, db *sqlx.DB) (*Repositories, error) {
+	switch driver {
+	case DriverMySQL:
+		return &Repositories{
+			User:      NewUserRepository(db),
+			Term:      NewTermRepository(db),
+			Category:  NewCategoryRepository(db),
+			Token:     NewTokenRepository(db),
+			UserToken: NewUserTokenRepository(db),
+		}, nil
+	case DriverPostgres:
+		return &Repositories{
+			User:      NewPostgresUserRepository(db),
+			Term:      NewPostgresTermRepository(db),
+			Category:  NewPostgresCategoryRepository(db),
+			Token:     NewPostgresTokenRepository(db),
+			UserToken: NewPostgresUserTokenRepository(db),
+		}, nil
+	case DriverSQLite:
+		return &Repositories{
+			User:      NewSQLiteUserRepository(db),
+			Term:      NewSQLiteTermRepository(db),
+			Category:  NewSQLiteCategoryRepository(db),
+			Token:     NewSQLiteTokenRepository(db),
+			UserToken: NewSQLiteUserTokenRepository(db),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+}
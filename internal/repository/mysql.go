@@ -1,47 +1,41 @@
 package repository
 
 import (
-	"context"
-	"database/sql"
+	"errors"
 	"fmt"
-	_ "github.com/go-sql-driver/mysql"
-	"github.com/joho/godotenv"
-	"os"
-	"time"
+	"github.com/go-sql-driver/mysql"
+	"skymates-api/config"
 )
 
-// NewMySQLDatabase 加载环境变量并初始化一个 *sql.DB 连接池
-func NewMySQLDatabase() (*sql.DB, error) {
-	// 1. 加载 .env 文件
-	if err := godotenv.Load(); err != nil {
-		return nil, fmt.Errorf("error loading .env file: %v", err)
-	}
+// mysqlDuplicateEntry 违反唯一索引时 MySQL 返回的错误码 (ER_DUP_ENTRY)
+const mysqlDuplicateEntry = 1062
 
-	// 2. 构造 DSN (Data Source Name)
-	dsn := fmt.Sprintf(
-		"%s:%s@tcp(%s:%s)/%s?parseTime=true&charset=utf8mb4",
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_PORT"),
-		os.Getenv("DB_NAME"),
-	)
-
-	// 3. 打开数据库连接（底层会创建和管理连接池）
-	db, err := sql.Open("mysql", dsn)
-	if err != nil {
-		return nil, fmt.Errorf("error opening mysql: %w", err)
-	}
+// 违反外键约束时 MySQL 返回的错误码: 删除或修改仍被引用的行 (ER_ROW_IS_REFERENCED_2), 引用的行不存在 (ER_NO_REFERENCED_ROW_2)
+const (
+	mysqlRowIsReferenced = 1451
+	mysqlNoReferencedRow = 1452
+)
 
-	// 4. 配置连接池参数（可根据业务负载调整）
-	db.SetMaxOpenConns(25)                 // 最多保持 25 个打开连接
-	db.SetMaxIdleConns(25)                 // 最多保持 25 个空闲连接
-	db.SetConnMaxLifetime(5 * time.Minute) // 连接最大复用时间
+// mysqlDSN 根据数据库配置构造 MySQL 的 DSN
+func mysqlDSN(cfg config.DatabaseConfig) string {
+	return fmt.Sprintf(
+		"%s:%s@tcp(%s:%d)/%s?parseTime=true&charset=utf8mb4",
+		cfg.User,
+		cfg.Password,
+		cfg.Host,
+		cfg.Port,
+		cfg.DbName,
+	)
+}
 
-	// 5. 测试连通性
-	if err := db.PingContext(context.Background()); err != nil {
-		return nil, fmt.Errorf("error pinging mysql: %w", err)
-	}
+// isMySQLDuplicateKey 判断错误是否为 MySQL 的唯一索引冲突
+func isMySQLDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry
+}
 
-	return db, nil
+// isMySQLForeignKeyViolation 判断错误是否为 MySQL 的外键约束冲突
+func isMySQLForeignKeyViolation(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && (mysqlErr.Number == mysqlRowIsReferenced || mysqlErr.Number == mysqlNoReferencedRow)
 }
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"net/url"
+	"skymates-api/config"
+)
+
+// postgresUniqueViolation 违反唯一约束时 PostgreSQL 返回的 SQLSTATE
+const postgresUniqueViolation = "23505"
+
+// postgresForeignKeyViolation 违反外键约束时 PostgreSQL 返回的 SQLSTATE
+const postgresForeignKeyViolation = "23503"
+
+// postgresDSN 根据数据库配置构造 PostgreSQL 的连接 URL
+// sslmode 默认为 disable, 生产环境应设置为 require 或 verify-full
+func postgresDSN(cfg config.DatabaseConfig) string {
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		Path:     "/" + cfg.DbName,
+		RawQuery: url.Values{"sslmode": {cfg.SSLMode}}.Encode(),
+	}
+	return dsn.String()
+}
+
+// isPostgresDuplicateKey 判断错误是否为 PostgreSQL 的唯一约束冲突
+func isPostgresDuplicateKey(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == postgresUniqueViolation
+}
+
+// isPostgresForeignKeyViolation 判断错误是否为 PostgreSQL 的外键约束冲突
+func isPostgresForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == postgresForeignKeyViolation
+}
@@ -0,0 +1,36 @@
+package repositorytest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+
+	"skymates-api/internal/migration"
+	"skymates-api/internal/repository"
+)
+
+// OpenSQLite 打开一个执行了全部迁移的 SQLite 内存数据库, 测试结束时自动关闭
+// 不依赖任何外部服务, 可以在仓库测试之外的端到端测试中使用
+func OpenSQLite(t *testing.T) (*sqlx.DB, *repository.Repositories) {
+	t.Helper()
+	db, err := repository.Open(repository.DriverSQLite, repository.SQLiteDSN(repository.SQLiteMemory))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	runner, err := migration.NewRunner(db.DB, migration.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.Up(context.Background()); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+
+	repos, err := repository.NewRepositories(repository.DriverSQLite, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, repos
+}
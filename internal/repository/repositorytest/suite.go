@@ -0,0 +1,1367 @@
+// Package repositorytest 提供所有数据库实现都必须通过的仓库一致性测试
+// 每种数据库在自己的 _test.go 中调用 Run, 传入创建空数据库的函数
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	servererrors "skymates-api/errors"
+	"skymates-api/internal/model"
+	"skymates-api/internal/repository"
+)
+
+// Factory 为每个子测试创建一组连接到已执行全部迁移的空数据库的仓库
+type Factory func(t *testing.T) *repository.Repositories
+
+// Run 运行所有仓库一致性测试
+func Run(t *testing.T, newRepositories Factory) {
+	t.Run("User", func(t *testing.T) { runUserTests(t, newRepositories) })
+	t.Run("Term", func(t *testing.T) { runTermTests(t, newRepositories) })
+	t.Run("Category", func(t *testing.T) { runCategoryTests(t, newRepositories) })
+	t.Run("Token", func(t *testing.T) { runTokenTests(t, newRepositories) })
+	t.Run("UserToken", func(t *testing.T) { runUserTokenTests(t, newRepositories) })
+}
+
+var sequence atomic.Int64
+
+// unique 返回测试内唯一的字符串, 避免不同子测试的数据互相冲突
+func unique(prefix string) string {
+	return fmt.Sprintf("%s%d", prefix, sequence.Add(1))
+}
+
+// CreateUser 创建一个测试用户
+func CreateUser(t *testing.T, repos *repository.Repositories, role string) *model.User {
+	t.Helper()
+	name := unique("user")
+	user := &model.User{
+		Username: name,
+		Password: "hashed-" + name,
+		Email:    name + "@example.com",
+		Role:     role,
+	}
+	if err := repos.User.Create(context.Background(), user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// createCategories 在空数据库中创建 n 个顶级分类, ID 依次为 1 到 n
+// term_category_relations.category_id 有外键约束, 术语只能关联已经存在的分类
+func createCategories(t *testing.T, repos *repository.Repositories, n int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		category := &model.Category{Name: fmt.Sprintf("Category %d", i), Slug: unique("category")}
+		id, err := repos.Category.CreateCategory(context.Background(), category)
+		if err != nil {
+			t.Fatalf("create category: %v", err)
+		}
+		if id != int64(i) {
+			t.Fatalf("category id = %d, want %d", id, i)
+		}
+	}
+}
+
+func isKind(err error, kind servererrors.ErrorKind) bool {
+	return errors.Is(err, &servererrors.ServerError{Kind: kind})
+}
+
+func runUserTests(t *testing.T, newRepositories Factory) {
+	ctx := context.Background()
+
+	t.Run("CreateAssignsIDAndDefaultRole", func(t *testing.T) {
+		repos := newRepositories(t)
+		user := CreateUser(t, repos, "")
+		if user.ID == 0 {
+			t.Fatal("expected user ID to be assigned")
+		}
+		if user.Role != model.RoleUser {
+			t.Fatalf("expected default role %q, got %q", model.RoleUser, user.Role)
+		}
+	})
+
+	t.Run("GetUserBy", func(t *testing.T) {
+		repos := newRepositories(t)
+		created := CreateUser(t, repos, model.RoleAdmin)
+
+		lookups := map[string]struct {
+			queryType repository.QueryType
+			value     string
+		}{
+			"username": {repository.QueryByUsername, created.Username},
+			"email":    {repository.QueryByEmail, created.Email},
+			"id":       {repository.QueryByID, strconv.FormatInt(created.ID, 10)},
+		}
+		for name, lookup := range lookups {
+			user, err := repos.User.GetUserBy(ctx, lookup.queryType, lookup.value)
+			if err != nil {
+				t.Fatalf("get user by %s: %v", name, err)
+			}
+			if user.ID != created.ID || user.Username != created.Username || user.Email != created.Email {
+				t.Fatalf("get user by %s: got %+v, want %+v", name, user, created)
+			}
+			if user.Password != created.Password {
+				t.Fatalf("get user by %s: password hash not loaded", name)
+			}
+			if user.Role != model.RoleAdmin {
+				t.Fatalf("get user by %s: expected role admin, got %q", name, user.Role)
+			}
+		}
+	})
+
+	t.Run("GetUserByMissingReturnsNotFound", func(t *testing.T) {
+		repos := newRepositories(t)
+		_, err := repos.User.GetUserBy(ctx, repository.QueryByUsername, "missing-user")
+		if !isKind(err, servererrors.KindNotFound) {
+			t.Fatalf("expected NotFound error, got %v", err)
+		}
+		_, err = repos.User.GetUserBy(ctx, repository.QueryByID, "999999")
+		if !isKind(err, servererrors.KindNotFound) {
+			t.Fatalf("expected NotFound error, got %v", err)
+		}
+	})
+
+	t.Run("CheckExists", func(t *testing.T) {
+		repos := newRepositories(t)
+		user := CreateUser(t, repos, model.RoleUser)
+
+		for _, c := range []struct {
+			queryType repository.QueryType
+			value     string
+			want      bool
+		}{
+			{repository.QueryByUsername, user.Username, true},
+			{repository.QueryByEmail, user.Email, true},
+			{repository.QueryByUsername, "nobody", false},
+			{repository.QueryByEmail, "nobody@example.com", false},
+		} {
+			exists, err := repos.User.CheckExists(ctx, c.queryType, c.value)
+			if err != nil {
+				t.Fatalf("check exists %q: %v", c.value, err)
+			}
+			if exists != c.want {
+				t.Fatalf("check exists %q: got %v, want %v", c.value, exists, c.want)
+			}
+		}
+	})
+
+	t.Run("CreateDuplicateFails", func(t *testing.T) {
+		repos := newRepositories(t)
+		user := CreateUser(t, repos, model.RoleUser)
+		alreadyExists := &servererrors.ServerError{Kind: servererrors.KindAlreadyExists}
+		duplicate := &model.User{Username: user.Username, Password: "x", Email: unique("other") + "@example.com"}
+		if err := repos.User.Create(ctx, duplicate); !errors.Is(err, alreadyExists) {
+			t.Fatalf("duplicate username: err = %v, want AlreadyExists", err)
+		}
+		duplicate = &model.User{Username: unique("other"), Password: "x", Email: user.Email}
+		if err := repos.User.Create(ctx, duplicate); !errors.Is(err, alreadyExists) {
+			t.Fatalf("duplicate email: err = %v, want AlreadyExists", err)
+		}
+	})
+
+	t.Run("LoginFailuresAndLock", func(t *testing.T) {
+		repos := newRepositories(t)
+		user := CreateUser(t, repos, model.RoleUser)
+		id := strconv.FormatInt(user.ID, 10)
+
+		for want := 1; want <= 3; want++ {
+			attempts, err := repos.User.RecordLoginFailure(ctx, user.ID)
+			if err != nil {
+				t.Fatalf("record login failure: %v", err)
+			}
+			if attempts != want {
+				t.Fatalf("record login failure: got %d attempts, want %d", attempts, want)
+			}
+		}
+		until := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+		if err := repos.User.LockUser(ctx, user.ID, until); err != nil {
+			t.Fatalf("lock user: %v", err)
+		}
+		locked, err := repos.User.GetUserBy(ctx, repository.QueryByID, id)
+		if err != nil {
+			t.Fatalf("get locked user: %v", err)
+		}
+		if locked.FailedLoginAttempts != 3 || locked.LockedUntil == nil || !locked.LockedUntil.Equal(until) {
+			t.Fatalf("locked user: attempts = %d, locked until %v, want 3 and %v", locked.FailedLoginAttempts, locked.LockedUntil, until)
+		}
+		if !locked.IsLocked(time.Now()) || locked.IsLocked(until.Add(time.Second)) {
+			t.Fatalf("IsLocked does not follow locked until %v", until)
+		}
+
+		if err := repos.User.ResetLoginFailures(ctx, user.ID); err != nil {
+			t.Fatalf("reset login failures: %v", err)
+		}
+		reset, err := repos.User.GetUserBy(ctx, repository.QueryByID, id)
+		if err != nil {
+			t.Fatalf("get reset user: %v", err)
+		}
+		if reset.FailedLoginAttempts != 0 || reset.LockedUntil != nil {
+			t.Fatalf("reset user: attempts = %d, locked until %v", reset.FailedLoginAttempts, reset.LockedUntil)
+		}
+
+		if _, err := repos.User.RecordLoginFailure(ctx, 999999); !isKind(err, servererrors.KindNotFound) {
+			t.Fatalf("record login failure for missing user: expected NotFound, got %v", err)
+		}
+	})
+
+	t.Run("ChangePasswordAndVerifyEmail", func(t *testing.T) {
+		repos := newRepositories(t)
+		user := CreateUser(t, repos, model.RoleUser)
+		id := strconv.FormatInt(user.ID, 10)
+		if user.IsEmailVerified() {
+			t.Fatal("expected new user to have an unverified email")
+		}
+
+		if err := repos.User.ChangePassword(ctx, user.ID, "hashed-new", ""); err != nil {
+			t.Fatalf("change password: %v", err)
+		}
+		verifiedAt := time.Now().Truncate(time.Millisecond)
+		if err := repos.User.MarkEmailVerified(ctx, user.ID, verifiedAt); err != nil {
+			t.Fatalf("mark email verified: %v", err)
+		}
+		// 再次确认保留第一次确认的时间
+		if err := repos.User.MarkEmailVerified(ctx, user.ID, verifiedAt.Add(time.Hour)); err != nil {
+			t.Fatalf("mark email verified again: %v", err)
+		}
+
+		got, err := repos.User.GetUserBy(ctx, repository.QueryByID, id)
+		if err != nil {
+			t.Fatalf("get user: %v", err)
+		}
+		if got.Password != "hashed-new" {
+			t.Fatalf("password hash = %q, want hashed-new", got.Password)
+		}
+		if got.EmailVerifiedAt == nil || !got.EmailVerifiedAt.Equal(verifiedAt) {
+			t.Fatalf("email verified at = %v, want %v", got.EmailVerifiedAt, verifiedAt)
+		}
+	})
+
+	t.Run("UpdateProfile", func(t *testing.T) {
+		repos := newRepositories(t)
+		user := CreateUser(t, repos, model.RoleUser)
+		verifiedAt := time.Now().Truncate(time.Millisecond)
+		if err := repos.User.MarkEmailVerified(ctx, user.ID, verifiedAt); err != nil {
+			t.Fatalf("mark email verified: %v", err)
+		}
+
+		avatar := "https://example.com/avatar.png"
+		user.Username = unique("renamed")
+		user.Email = user.Username + "@example.com"
+		user.EmailVerifiedAt = nil
+		user.AvatarURL = &avatar
+		if err := repos.User.UpdateProfile(ctx, user); err != nil {
+			t.Fatalf("update profile: %v", err)
+		}
+
+		got, err := repos.User.GetUserBy(ctx, repository.QueryByUsername, user.Username)
+		if err != nil {
+			t.Fatalf("get renamed user: %v", err)
+		}
+		if got.ID != user.ID || got.Email != user.Email || got.EmailVerifiedAt != nil {
+			t.Fatalf("renamed user = %+v, want id %d, email %s and unverified", got, user.ID, user.Email)
+		}
+		if got.AvatarURL == nil || *got.AvatarURL != avatar {
+			t.Fatalf("avatar url = %v, want %s", got.AvatarURL, avatar)
+		}
+		if got.Password != user.Password || got.Role != model.RoleUser {
+			t.Fatalf("update profile changed password or role: %+v", got)
+		}
+
+		// 唯一索引冲突返回 AlreadyExists, 不修改任何字段
+		other := CreateUser(t, repos, model.RoleUser)
+		for _, field := range []string{"username", "email"} {
+			duplicate := *other
+			if field == "username" {
+				duplicate.Username = user.Username
+			} else {
+				duplicate.Email = user.Email
+			}
+			if err := repos.User.UpdateProfile(ctx, &duplicate); !isKind(err, servererrors.KindAlreadyExists) {
+				t.Fatalf("duplicate %s: expected AlreadyExists, got %v", field, err)
+			}
+		}
+		unchanged, err := repos.User.GetUserBy(ctx, repository.QueryByID, strconv.FormatInt(other.ID, 10))
+		if err != nil {
+			t.Fatalf("get other user: %v", err)
+		}
+		if unchanged.Username != other.Username || unchanged.Email != other.Email {
+			t.Fatalf("other user = %+v, want it unchanged", unchanged)
+		}
+	})
+}
+
+func runTermTests(t *testing.T, newRepositories Factory) {
+	ctx := context.Background()
+
+	createTerm := func(t *testing.T, repos *repository.Repositories, name string, createdBy *int64, categoryIDs []int64) int64 {
+		t.Helper()
+		term := &model.Term{Name: name, Explanation: "explanation of " + name, SourceURL: "https://example.com/" + name, CreatedBy: createdBy}
+		id, err := repos.Term.CreateTerm(ctx, term, categoryIDs, model.TermChange{})
+		if err != nil {
+			t.Fatalf("create term %q: %v", name, err)
+		}
+		if id == 0 {
+			t.Fatalf("create term %q: expected ID to be assigned", name)
+		}
+		return id
+	}
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		repos := newRepositories(t)
+		createCategories(t, repos, 3)
+		user := CreateUser(t, repos, model.RoleUser)
+		id := createTerm(t, repos, "Altimeter", &user.ID, []int64{3, 1})
+
+		term, err := repos.Term.GetTermByID(ctx, id)
+		if err != nil {
+			t.Fatalf("get term: %v", err)
+		}
+		if term == nil {
+			t.Fatal("expected term, got nil")
+		}
+		if term.Name != "Altimeter" || term.Explanation != "explanation of Altimeter" || term.SourceURL != "https://example.com/Altimeter" {
+			t.Fatalf("unexpected term: %+v", term)
+		}
+		if term.CreatedBy == nil || *term.CreatedBy != user.ID {
+			t.Fatalf("expected created_by %d, got %v", user.ID, term.CreatedBy)
+		}
+		if term.Aliases == nil || len(term.Aliases) != 0 {
+			t.Fatalf("expected empty aliases, got %#v", term.Aliases)
+		}
+		if !slices.Equal(term.CategoryIDs, []int64{1, 3}) {
+			t.Fatalf("expected category IDs [1 3], got %v", term.CategoryIDs)
+		}
+		if term.CreatedAt.IsZero() || term.UpdatedAt.IsZero() {
+			t.Fatal("expected timestamps to be set")
+		}
+	})
+
+	t.Run("GetMissingReturnsNil", func(t *testing.T) {
+		repos := newRepositories(t)
+		term, err := repos.Term.GetTermByID(ctx, 999999)
+		if err != nil {
+			t.Fatalf("get missing term: %v", err)
+		}
+		if term != nil {
+			t.Fatalf("expected nil term, got %+v", term)
+		}
+	})
+
+	search := func(t *testing.T, repos *repository.Repositories, query model.TermSearchQuery) *model.TermSearchResult {
+		t.Helper()
+		if query.Limit == 0 {
+			query.Limit = 20
+		}
+		result, err := repos.Term.SearchTerms(ctx, query)
+		if err != nil {
+			t.Fatalf("search terms %q: %v", query.Keyword, err)
+		}
+		return result
+	}
+	hitNames := func(result *model.TermSearchResult) []string {
+		names := []string{}
+		for _, hit := range result.Hits {
+			names = append(names, hit.Name)
+		}
+		return names
+	}
+
+	t.Run("SearchIsCaseInsensitiveSubstring", func(t *testing.T) {
+		repos := newRepositories(t)
+		createTerm(t, repos, "Visual Flight Rules", nil, nil)
+		createTerm(t, repos, "Instrument Flight Rules", nil, nil)
+		createTerm(t, repos, "Crosswind", nil, nil)
+
+		names := hitNames(search(t, repos, model.TermSearchQuery{Keyword: "flight"}))
+		slices.Sort(names)
+		if !slices.Equal(names, []string{"Instrument Flight Rules", "Visual Flight Rules"}) {
+			t.Fatalf("unexpected search result: %v", names)
+		}
+		// 子串不必是完整的词
+		if names := hitNames(search(t, repos, model.TermSearchQuery{Keyword: "ROSSWI"})); !slices.Equal(names, []string{"Crosswind"}) {
+			t.Fatalf("unexpected substring search result: %v", names)
+		}
+	})
+
+	t.Run("SearchRanksExactPrefixSubstringThenExplanation", func(t *testing.T) {
+		repos := newRepositories(t)
+		for _, term := range []*model.Term{
+			{Name: "Spoiler", Explanation: "Panel that dumps lift, deployed together with the flap on landing"},
+			{Name: "Wing Flap", Explanation: "Hinged surface on the trailing edge"},
+			{Name: "Flaperon", Explanation: "Aileron that also acts as a high-lift device"},
+			{Name: "Flap", Explanation: "High-lift device"},
+		} {
+			if _, err := repos.Term.CreateTerm(ctx, term, nil, model.TermChange{}); err != nil {
+				t.Fatalf("create term %q: %v", term.Name, err)
+			}
+		}
+
+		result := search(t, repos, model.TermSearchQuery{Keyword: "flap"})
+		if names := hitNames(result); !slices.Equal(names, []string{"Flap", "Flaperon", "Wing Flap", "Spoiler"}) {
+			t.Fatalf("unexpected ranking: %v", names)
+		}
+		ranks := []int{model.MatchExact, model.MatchPrefix, model.MatchContains, model.MatchFullText}
+		for i, hit := range result.Hits {
+			if hit.MatchRank != ranks[i] {
+				t.Fatalf("hit %q: match rank = %d, want %d", hit.Name, hit.MatchRank, ranks[i])
+			}
+		}
+		if result.Hits[3].Explanation == "" {
+			t.Fatal("expected hits to carry the explanation for snippets")
+		}
+	})
+
+	t.Run("SearchMatchesAliases", func(t *testing.T) {
+		repos := newRepositories(t)
+		vfr := &model.Term{Name: "Visual Flight Rules", Aliases: []string{"VFR", "目视飞行规则"}, Explanation: "Flying by looking outside"}
+		if _, err := repos.Term.CreateTerm(ctx, vfr, nil, model.TermChange{}); err != nil {
+			t.Fatalf("create term: %v", err)
+		}
+		createTerm(t, repos, "VFR Chart", nil, nil)
+
+		result := search(t, repos, model.TermSearchQuery{Keyword: "vfr"})
+		if names := hitNames(result); !slices.Equal(names, []string{"Visual Flight Rules", "VFR Chart"}) {
+			t.Fatalf("expected the exact alias before the name prefix, got %v", names)
+		}
+		if result.Hits[0].MatchRank != model.MatchExact || !slices.Equal(result.Hits[0].Aliases, []string{"VFR", "目视飞行规则"}) {
+			t.Fatalf("unexpected alias hit: %+v", result.Hits[0])
+		}
+		if names := hitNames(search(t, repos, model.TermSearchQuery{Keyword: "飞行"})); !slices.Equal(names, []string{"Visual Flight Rules"}) {
+			t.Fatalf("unexpected search result for a Chinese alias: %v", names)
+		}
+	})
+
+	t.Run("SearchEscapesWildcards", func(t *testing.T) {
+		repos := newRepositories(t)
+		createTerm(t, repos, "100% Power", nil, nil)
+		createTerm(t, repos, "Flap", nil, nil)
+		createTerm(t, repos, "Flight_Level", nil, nil)
+
+		if names := hitNames(search(t, repos, model.TermSearchQuery{Keyword: "%"})); !slices.Equal(names, []string{"100% Power"}) {
+			t.Fatalf("unexpected search result for %%: %v", names)
+		}
+		if names := hitNames(search(t, repos, model.TermSearchQuery{Keyword: "_"})); !slices.Equal(names, []string{"Flight_Level"}) {
+			t.Fatalf("unexpected search result for _: %v", names)
+		}
+	})
+
+	t.Run("SearchFoldsTraditionalAndSimplifiedChinese", func(t *testing.T) {
+		repos := newRepositories(t)
+		createTerm(t, repos, "飞行", nil, nil)
+		createTerm(t, repos, "飛行計劃", nil, nil)
+
+		result := search(t, repos, model.TermSearchQuery{Keyword: "飛行"})
+		if names := hitNames(result); !slices.Equal(names, []string{"飞行", "飛行計劃"}) {
+			t.Fatalf("unexpected search result for a traditional keyword: %v", names)
+		}
+		if result.Hits[0].MatchRank != model.MatchExact || result.Hits[1].MatchRank != model.MatchPrefix {
+			t.Fatalf("unexpected match ranks: %+v", result.Hits)
+		}
+		if names := hitNames(search(t, repos, model.TermSearchQuery{Keyword: "飞行计划"})); !slices.Equal(names, []string{"飛行計劃"}) {
+			t.Fatalf("unexpected search result for a simplified keyword: %v", names)
+		}
+	})
+
+	t.Run("SearchByPinyin", func(t *testing.T) {
+		repos := newRepositories(t)
+		createCategories(t, repos, 2)
+		createTerm(t, repos, "非线性", nil, []int64{1})
+		createTerm(t, repos, "飞行规则", nil, []int64{1})
+		createTerm(t, repos, "飞行", nil, []int64{2})
+		vfr := &model.Term{Name: "Visual Flight Rules", Aliases: []string{"目视飞行规则"}, Explanation: "Flying by looking outside"}
+		if _, err := repos.Term.CreateTerm(ctx, vfr, []int64{2}, model.TermChange{}); err != nil {
+			t.Fatalf("create term: %v", err)
+		}
+		// 解释不参与拼音搜索
+		if _, err := repos.Term.CreateTerm(ctx, &model.Term{Name: "Crosswind", Explanation: "feixing"}, nil, model.TermChange{}); err != nil {
+			t.Fatalf("create term: %v", err)
+		}
+
+		pinyin := func(keyword string) *model.TermSearchResult {
+			return search(t, repos, model.TermSearchQuery{Keyword: keyword, Mode: model.SearchModePinyin})
+		}
+		result := pinyin("feixing")
+		if names := hitNames(result); !slices.Equal(names, []string{"飞行", "飞行规则", "Visual Flight Rules"}) {
+			t.Fatalf("unexpected search result for full pinyin: %v", names)
+		}
+		ranks := []int{model.MatchExact, model.MatchPrefix, model.MatchContains}
+		for i, hit := range result.Hits {
+			if hit.MatchRank != ranks[i] {
+				t.Fatalf("hit %q: match rank = %d, want %d", hit.Name, hit.MatchRank, ranks[i])
+			}
+		}
+		if want := []model.CategoryFacet{{CategoryID: 2, Count: 2}, {CategoryID: 1, Count: 1}}; !slices.Equal(result.Facets, want) {
+			t.Fatalf("unexpected facets: %+v", result.Facets)
+		}
+
+		// 首字母 "fx" 与 "飞行" 完全相同, 是 "飞行规则" (fxgz) 和 "非线性" (fxx) 的前缀
+		if names := hitNames(pinyin("fx")); !slices.Equal(names, []string{"飞行", "非线性", "飞行规则", "Visual Flight Rules"}) {
+			t.Fatalf("unexpected search result for initials: %v", names)
+		}
+		categoryID := int64(1)
+		filtered := search(t, repos, model.TermSearchQuery{Keyword: "fx", Mode: model.SearchModePinyin, CategoryID: &categoryID, Limit: 1})
+		if filtered.Total != 2 || !slices.Equal(hitNames(filtered), []string{"非线性"}) {
+			t.Fatalf("unexpected filtered result: total %d, hits %v", filtered.Total, hitNames(filtered))
+		}
+		if names := hitNames(pinyin("飛行")); !slices.Equal(names, []string{"飞行", "飞行规则", "Visual Flight Rules"}) {
+			t.Fatalf("unexpected search result for Chinese characters in pinyin mode: %v", names)
+		}
+		if result := pinyin("%"); result.Total != 0 || len(result.Hits) != 0 {
+			t.Fatalf("expected no terms for a keyword without pinyin, got %v", hitNames(result))
+		}
+	})
+
+	t.Run("RefreshSearchColumnsSkipsCurrentTerms", func(t *testing.T) {
+		repos := newRepositories(t)
+		createTerm(t, repos, "飞行", nil, nil)
+		// 新写入的术语已经是当前版本, 不需要重新计算
+		n, err := repos.Term.RefreshSearchColumns(ctx, 100)
+		if err != nil {
+			t.Fatalf("refresh search columns: %v", err)
+		}
+		if n != 0 {
+			t.Fatalf("refreshed %d terms, want 0", n)
+		}
+	})
+
+	t.Run("SearchPaginatesAndCountsFacets", func(t *testing.T) {
+		repos := newRepositories(t)
+		createCategories(t, repos, 3)
+		var ids []int64
+		for i := 0; i < 5; i++ {
+			categories := []int64{1}
+			if i%2 == 0 {
+				categories = []int64{1, 2}
+			}
+			ids = append(ids, createTerm(t, repos, fmt.Sprintf("Runway %d", i), nil, categories))
+		}
+		createTerm(t, repos, "Taxiway", nil, []int64{3})
+
+		first := search(t, repos, model.TermSearchQuery{Keyword: "runway", Limit: 3})
+		second := search(t, repos, model.TermSearchQuery{Keyword: "runway", Offset: 3, Limit: 3})
+		if first.Total != 5 || second.Total != 5 {
+			t.Fatalf("expected total 5, got %d and %d", first.Total, second.Total)
+		}
+		var got []int64
+		for _, hit := range append(first.Hits, second.Hits...) {
+			got = append(got, hit.ID)
+		}
+		if !slices.Equal(got, ids) {
+			t.Fatalf("expected all terms once ordered by ID within the same rank, got %v want %v", got, ids)
+		}
+
+		want := []model.CategoryFacet{{CategoryID: 1, Count: 5}, {CategoryID: 2, Count: 3}}
+		if !slices.Equal(first.Facets, want) {
+			t.Fatalf("unexpected facets: %+v", first.Facets)
+		}
+
+		// 按分类过滤只影响结果和总数, 不影响分类统计
+		categoryID := int64(2)
+		filtered := search(t, repos, model.TermSearchQuery{Keyword: "runway", CategoryID: &categoryID})
+		if filtered.Total != 3 || len(filtered.Hits) != 3 || !slices.Equal(filtered.Facets, want) {
+			t.Fatalf("unexpected filtered result: total %d, hits %v, facets %+v", filtered.Total, hitNames(filtered), filtered.Facets)
+		}
+	})
+
+	t.Run("ListByCategoryPaginates", func(t *testing.T) {
+		repos := newRepositories(t)
+		createCategories(t, repos, 8)
+		var ids []int64
+		for i := 0; i < 5; i++ {
+			ids = append(ids, createTerm(t, repos, unique("term"), nil, []int64{7}))
+		}
+		createTerm(t, repos, unique("other"), nil, []int64{8})
+
+		page, hasMore, err := repos.Term.ListTermsByCategory(ctx, []int64{7}, nil, 3)
+		if err != nil {
+			t.Fatalf("list first page: %v", err)
+		}
+		if len(page) != 3 || !hasMore {
+			t.Fatalf("expected 3 terms and more pages, got %d terms, hasMore=%v", len(page), hasMore)
+		}
+		if page[0].ID != ids[0] || page[2].ID != ids[2] {
+			t.Fatalf("expected terms ordered by ID, got %+v", page)
+		}
+
+		lastID := page[len(page)-1].ID
+		page, hasMore, err = repos.Term.ListTermsByCategory(ctx, []int64{7}, &lastID, 3)
+		if err != nil {
+			t.Fatalf("list second page: %v", err)
+		}
+		if len(page) != 2 || hasMore {
+			t.Fatalf("expected 2 terms and no more pages, got %d terms, hasMore=%v", len(page), hasMore)
+		}
+	})
+
+	t.Run("ListByCategoriesReturnsEachTermOnce", func(t *testing.T) {
+		repos := newRepositories(t)
+		createCategories(t, repos, 9)
+		first := createTerm(t, repos, unique("term"), nil, []int64{7, 8})
+		second := createTerm(t, repos, unique("term"), nil, []int64{8})
+		createTerm(t, repos, unique("other"), nil, []int64{9})
+
+		page, hasMore, err := repos.Term.ListTermsByCategory(ctx, []int64{7, 8}, nil, 10)
+		if err != nil {
+			t.Fatalf("list terms: %v", err)
+		}
+		if len(page) != 2 || hasMore || page[0].ID != first || page[1].ID != second {
+			t.Fatalf("expected terms %d and %d, got %+v (hasMore=%v)", first, second, page, hasMore)
+		}
+
+		page, _, err = repos.Term.ListTermsByCategory(ctx, []int64{}, nil, 10)
+		if err != nil || len(page) != 0 {
+			t.Fatalf("expected no terms for no categories, got %+v, %v", page, err)
+		}
+	})
+
+	t.Run("ListTermNamesInBatches", func(t *testing.T) {
+		repos := newRepositories(t)
+		vfr := &model.Term{Name: "Visual Flight Rules", Aliases: []string{"VFR"}, Explanation: "explanation"}
+		first, err := repos.Term.CreateTerm(ctx, vfr, nil, model.TermChange{})
+		if err != nil {
+			t.Fatalf("create term: %v", err)
+		}
+		second := createTerm(t, repos, "Crosswind", nil, nil)
+		third := createTerm(t, repos, "Headwind", nil, nil)
+
+		page, err := repos.Term.ListTermNames(ctx, 0, 2)
+		if err != nil {
+			t.Fatalf("list first batch: %v", err)
+		}
+		if len(page) != 2 || page[0].ID != first || page[1].ID != second {
+			t.Fatalf("unexpected first batch: %+v", page)
+		}
+		if page[0].Name != "Visual Flight Rules" || !slices.Equal(page[0].Aliases, []string{"VFR"}) || len(page[1].Aliases) != 0 {
+			t.Fatalf("unexpected names: %+v", page)
+		}
+
+		page, err = repos.Term.ListTermNames(ctx, second, 2)
+		if err != nil {
+			t.Fatalf("list second batch: %v", err)
+		}
+		if len(page) != 1 || page[0].ID != third {
+			t.Fatalf("unexpected second batch: %+v", page)
+		}
+	})
+
+	t.Run("UpdateReplacesFieldsAndCategories", func(t *testing.T) {
+		repos := newRepositories(t)
+		createCategories(t, repos, 5)
+		id := createTerm(t, repos, "Flap", nil, []int64{1, 2})
+		before, err := repos.Term.GetTermByID(ctx, id)
+		if err != nil {
+			t.Fatalf("get term: %v", err)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		update := &model.Term{ID: id, Name: "Flaps", Aliases: []string{"Wing flaps", "襟翼"}, Explanation: "updated", SourceURL: ""}
+		if err := repos.Term.UpdateTerm(ctx, update, []int64{2, 5}, model.TermChange{}); err != nil {
+			t.Fatalf("update term: %v", err)
+		}
+
+		term, err := repos.Term.GetTermByID(ctx, id)
+		if err != nil {
+			t.Fatalf("get updated term: %v", err)
+		}
+		if term.Name != "Flaps" || term.Explanation != "updated" || term.SourceURL != "" {
+			t.Fatalf("unexpected updated term: %+v", term)
+		}
+		if !slices.Equal(term.Aliases, []string{"Wing flaps", "襟翼"}) {
+			t.Fatalf("expected aliases [Wing flaps 襟翼], got %v", term.Aliases)
+		}
+		if !slices.Equal(term.CategoryIDs, []int64{2, 5}) {
+			t.Fatalf("expected category IDs [2 5], got %v", term.CategoryIDs)
+		}
+		if !term.UpdatedAt.After(before.UpdatedAt) {
+			t.Fatalf("expected updated_at to advance, before %v after %v", before.UpdatedAt, term.UpdatedAt)
+		}
+		// 检索列随名称和别名一起更新
+		if names := hitNames(search(t, repos, model.TermSearchQuery{Keyword: "jinyi", Mode: model.SearchModePinyin})); !slices.Equal(names, []string{"Flaps"}) {
+			t.Fatalf("expected the new alias to be searchable by pinyin, got %v", names)
+		}
+	})
+
+	t.Run("UnknownCategoryFails", func(t *testing.T) {
+		repos := newRepositories(t)
+		createCategories(t, repos, 1)
+		term := &model.Term{Name: unique("term"), Explanation: "explanation"}
+		if _, err := repos.Term.CreateTerm(ctx, term, []int64{1, 2}, model.TermChange{}); !isKind(err, servererrors.KindValidation) {
+			t.Fatalf("create term with unknown category: expected Validation, got %v", err)
+		}
+
+		id := createTerm(t, repos, unique("term"), nil, []int64{1})
+		update := &model.Term{ID: id, Name: unique("term"), Explanation: "updated"}
+		if err := repos.Term.UpdateTerm(ctx, update, []int64{2}, model.TermChange{}); !isKind(err, servererrors.KindValidation) {
+			t.Fatalf("update term with unknown category: expected Validation, got %v", err)
+		}
+		// 失败的更新整体回滚
+		got, err := repos.Term.GetTermByID(ctx, id)
+		if err != nil {
+			t.Fatalf("get term: %v", err)
+		}
+		if !slices.Equal(got.CategoryIDs, []int64{1}) || got.Explanation != "explanation of "+got.Name {
+			t.Fatalf("expected failed update to be rolled back, got %+v", got)
+		}
+	})
+
+	t.Run("RevisionsRecordEachWrite", func(t *testing.T) {
+		repos := newRepositories(t)
+		createCategories(t, repos, 3)
+		author := CreateUser(t, repos, model.RoleUser)
+		term := &model.Term{Name: "Flap", Aliases: []string{"襟翼"}, Explanation: "explanation"}
+		create := model.TermChange{AuthorID: &author.ID, Summary: "first"}
+		id, err := repos.Term.CreateTerm(ctx, term, []int64{3, 1}, create)
+		if err != nil {
+			t.Fatalf("create term: %v", err)
+		}
+		update := &model.Term{ID: id, Name: "Flaps", Explanation: "explanation"}
+		restored := 1
+		change := model.TermChange{RestoredRevision: &restored}
+		if err := repos.Term.UpdateTerm(ctx, update, nil, change); err != nil {
+			t.Fatalf("update term: %v", err)
+		}
+
+		revisions, err := repos.Term.ListTermRevisions(ctx, id)
+		if err != nil {
+			t.Fatalf("list revisions: %v", err)
+		}
+		if len(revisions) != 2 || revisions[0].Revision != 2 || revisions[1].Revision != 1 {
+			t.Fatalf("expected revisions 2 and 1, got %+v", revisions)
+		}
+		if revisions[0].RestoredRevision == nil || *revisions[0].RestoredRevision != 1 || revisions[0].CreatedBy != nil {
+			t.Fatalf("unexpected second revision: %+v", revisions[0])
+		}
+		// 修改了的字段与上一个版本比较得出, 第 1 个版本记为全部字段
+		if !slices.Equal(revisions[0].ChangedFields, []string{model.TermFieldName, model.TermFieldAliases, model.TermFieldCategoryIDs}) {
+			t.Fatalf("unexpected changed fields: %v", revisions[0].ChangedFields)
+		}
+		if !slices.Equal(revisions[1].ChangedFields, model.TermFields) {
+			t.Fatalf("unexpected changed fields of the first revision: %v", revisions[1].ChangedFields)
+		}
+
+		first, err := repos.Term.GetTermRevision(ctx, id, 1)
+		if err != nil {
+			t.Fatalf("get revision: %v", err)
+		}
+		if first == nil || first.Name != "Flap" || first.Summary != "first" || first.CreatedBy == nil || *first.CreatedBy != author.ID {
+			t.Fatalf("unexpected first revision: %+v", first)
+		}
+		if !slices.Equal(first.Aliases, []string{"襟翼"}) || !slices.Equal(first.CategoryIDs, []int64{1, 3}) {
+			t.Fatalf("unexpected first snapshot: %+v", first)
+		}
+		second, err := repos.Term.GetTermRevision(ctx, id, 2)
+		if err != nil {
+			t.Fatalf("get revision: %v", err)
+		}
+		if second.Name != "Flaps" || len(second.Aliases) != 0 || len(second.CategoryIDs) != 0 {
+			t.Fatalf("unexpected second snapshot: %+v", second)
+		}
+
+		missing, err := repos.Term.GetTermRevision(ctx, id, 3)
+		if err != nil || missing != nil {
+			t.Fatalf("expected nil for a missing revision, got %+v, %v", missing, err)
+		}
+		revisions, err = repos.Term.ListTermRevisions(ctx, id+1)
+		if err != nil || len(revisions) != 0 {
+			t.Fatalf("expected no revisions for a missing term, got %+v, %v", revisions, err)
+		}
+	})
+
+	t.Run("UnchangedUpdateIsSkipped", func(t *testing.T) {
+		repos := newRepositories(t)
+		createCategories(t, repos, 2)
+		id := createTerm(t, repos, "Flap", nil, []int64{2, 1})
+		before, err := repos.Term.GetTermByID(ctx, id)
+		if err != nil {
+			t.Fatalf("get term: %v", err)
+		}
+
+		// 分类的顺序和重复不影响比较
+		time.Sleep(10 * time.Millisecond)
+		same := &model.Term{ID: id, Name: before.Name, Aliases: before.Aliases, Explanation: before.Explanation, SourceURL: before.SourceURL}
+		if err := repos.Term.UpdateTerm(ctx, same, []int64{1, 2, 2}, model.TermChange{Summary: "nothing"}); err != nil {
+			t.Fatalf("update term: %v", err)
+		}
+		revisions, err := repos.Term.ListTermRevisions(ctx, id)
+		if err != nil {
+			t.Fatalf("list revisions: %v", err)
+		}
+		if len(revisions) != 1 {
+			t.Fatalf("expected no revision for an unchanged update, got %+v", revisions)
+		}
+		after, err := repos.Term.GetTermByID(ctx, id)
+		if err != nil {
+			t.Fatalf("get term: %v", err)
+		}
+		if !after.UpdatedAt.Equal(before.UpdatedAt) {
+			t.Fatalf("expected updated_at to stay %v, got %v", before.UpdatedAt, after.UpdatedAt)
+		}
+	})
+}
+
+func runCategoryTests(t *testing.T, newRepositories Factory) {
+	ctx := context.Background()
+
+	createCategory := func(t *testing.T, repos *repository.Repositories, parentID *int64, sortOrder int) *model.Category {
+		t.Helper()
+		slug := unique("category-")
+		category := &model.Category{ParentID: parentID, Name: "Name of " + slug, Slug: slug, Description: "description of " + slug, SortOrder: sortOrder}
+		id, err := repos.Category.CreateCategory(ctx, category)
+		if err != nil {
+			t.Fatalf("create category %q: %v", slug, err)
+		}
+		if id == 0 {
+			t.Fatalf("create category %q: expected ID to be assigned", slug)
+		}
+		category.ID = id
+		return category
+	}
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		repos := newRepositories(t)
+		parent := createCategory(t, repos, nil, 0)
+		child := createCategory(t, repos, &parent.ID, 5)
+
+		category, err := repos.Category.GetCategoryByID(ctx, child.ID)
+		if err != nil {
+			t.Fatalf("get category: %v", err)
+		}
+		if category == nil {
+			t.Fatal("expected category, got nil")
+		}
+		if category.Name != child.Name || category.Slug != child.Slug || category.Description != child.Description || category.SortOrder != 5 {
+			t.Fatalf("unexpected category: %+v", category)
+		}
+		if category.ParentID == nil || *category.ParentID != parent.ID {
+			t.Fatalf("expected parent %d, got %v", parent.ID, category.ParentID)
+		}
+		if category.CreatedAt.IsZero() || category.UpdatedAt.IsZero() {
+			t.Fatal("expected timestamps to be set")
+		}
+
+		missing, err := repos.Category.GetCategoryByID(ctx, 999999)
+		if err != nil || missing != nil {
+			t.Fatalf("expected nil category for missing ID, got %+v, %v", missing, err)
+		}
+	})
+
+	t.Run("ListOrdersBySortOrderThenID", func(t *testing.T) {
+		repos := newRepositories(t)
+		first := createCategory(t, repos, nil, 2)
+		second := createCategory(t, repos, nil, 1)
+		third := createCategory(t, repos, nil, 1)
+
+		categories, err := repos.Category.ListCategories(ctx)
+		if err != nil {
+			t.Fatalf("list categories: %v", err)
+		}
+		var ids []int64
+		for _, category := range categories {
+			ids = append(ids, category.ID)
+		}
+		if !slices.Equal(ids, []int64{second.ID, third.ID, first.ID}) {
+			t.Fatalf("expected categories %v, got %v", []int64{second.ID, third.ID, first.ID}, ids)
+		}
+	})
+
+	t.Run("CreateDuplicateSlugFails", func(t *testing.T) {
+		repos := newRepositories(t)
+		existing := createCategory(t, repos, nil, 0)
+		_, err := repos.Category.CreateCategory(ctx, &model.Category{Name: "Other", Slug: existing.Slug})
+		if !isKind(err, servererrors.KindAlreadyExists) {
+			t.Fatalf("create duplicate slug: expected AlreadyExists, got %v", err)
+		}
+
+		other := createCategory(t, repos, nil, 0)
+		other.Slug = existing.Slug
+		if err := repos.Category.UpdateCategory(ctx, other); !isKind(err, servererrors.KindAlreadyExists) {
+			t.Fatalf("update to duplicate slug: expected AlreadyExists, got %v", err)
+		}
+	})
+
+	t.Run("MissingParentFails", func(t *testing.T) {
+		repos := newRepositories(t)
+		missing := int64(999999)
+		_, err := repos.Category.CreateCategory(ctx, &model.Category{ParentID: &missing, Name: "Orphan", Slug: unique("orphan-")})
+		if !isKind(err, servererrors.KindValidation) {
+			t.Fatalf("create with missing parent: expected Validation, got %v", err)
+		}
+
+		category := createCategory(t, repos, nil, 0)
+		category.ParentID = &missing
+		if err := repos.Category.UpdateCategory(ctx, category); !isKind(err, servererrors.KindValidation) {
+			t.Fatalf("move under missing parent: expected Validation, got %v", err)
+		}
+	})
+
+	t.Run("UpdateMovesCategory", func(t *testing.T) {
+		repos := newRepositories(t)
+		parent := createCategory(t, repos, nil, 0)
+		child := createCategory(t, repos, &parent.ID, 0)
+
+		child.ParentID = nil
+		child.Name = "Renamed"
+		child.Slug = unique("renamed-")
+		child.SortOrder = 3
+		if err := repos.Category.UpdateCategory(ctx, child); err != nil {
+			t.Fatalf("update category: %v", err)
+		}
+		category, err := repos.Category.GetCategoryByID(ctx, child.ID)
+		if err != nil {
+			t.Fatalf("get category: %v", err)
+		}
+		if category.ParentID != nil || category.Name != "Renamed" || category.Slug != child.Slug || category.SortOrder != 3 {
+			t.Fatalf("unexpected category after update: %+v", category)
+		}
+	})
+
+	t.Run("DeleteOnlyCategoriesWithoutTerms", func(t *testing.T) {
+		repos := newRepositories(t)
+		used := createCategory(t, repos, nil, 0)
+		unused := createCategory(t, repos, nil, 0)
+		if _, err := repos.Term.CreateTerm(ctx, &model.Term{Name: unique("term"), Explanation: "explanation"}, []int64{used.ID}, model.TermChange{}); err != nil {
+			t.Fatalf("create term: %v", err)
+		}
+
+		deleted, err := repos.Category.DeleteCategory(ctx, used.ID)
+		if err != nil || deleted {
+			t.Fatalf("expected category with terms to be kept, got deleted=%v, %v", deleted, err)
+		}
+		deleted, err = repos.Category.DeleteCategory(ctx, unused.ID)
+		if err != nil || !deleted {
+			t.Fatalf("expected category without terms to be deleted, got deleted=%v, %v", deleted, err)
+		}
+		if category, _ := repos.Category.GetCategoryByID(ctx, unused.ID); category != nil {
+			t.Fatalf("expected deleted category to be gone, got %+v", category)
+		}
+		deleted, err = repos.Category.DeleteCategory(ctx, unused.ID)
+		if err != nil || deleted {
+			t.Fatalf("expected deleting a missing category to report false, got deleted=%v, %v", deleted, err)
+		}
+	})
+
+	t.Run("DeleteParentFails", func(t *testing.T) {
+		repos := newRepositories(t)
+		parent := createCategory(t, repos, nil, 0)
+		createCategory(t, repos, &parent.ID, 0)
+		if _, err := repos.Category.DeleteCategory(ctx, parent.ID); !isKind(err, servererrors.KindConflict) {
+			t.Fatalf("delete category with children: expected Conflict, got %v", err)
+		}
+	})
+}
+
+func runTokenTests(t *testing.T, newRepositories Factory) {
+	ctx := context.Background()
+
+	newToken := func(userID int64, familyID string) *model.RefreshToken {
+		return &model.RefreshToken{
+			UserID:    userID,
+			FamilyID:  familyID,
+			TokenHash: fmt.Sprintf("%064s", unique("hash")),
+			ExpiresAt: time.Now().Add(time.Hour),
+		}
+	}
+
+	t.Run("CreateAndGetRefreshToken", func(t *testing.T) {
+		repos := newRepositories(t)
+		user := CreateUser(t, repos, model.RoleUser)
+		token := newToken(user.ID, unique("family"))
+		if err := repos.Token.CreateRefreshToken(ctx, token); err != nil {
+			t.Fatalf("create refresh token: %v", err)
+		}
+		if token.ID == 0 {
+			t.Fatal("expected refresh token ID to be assigned")
+		}
+
+		got, err := repos.Token.GetRefreshTokenByHash(ctx, token.TokenHash)
+		if err != nil {
+			t.Fatalf("get refresh token: %v", err)
+		}
+		if got.ID != token.ID || got.UserID != user.ID || got.FamilyID != token.FamilyID {
+			t.Fatalf("unexpected refresh token: %+v", got)
+		}
+		if got.UsedAt != nil || got.RevokedAt != nil {
+			t.Fatalf("expected fresh token, got %+v", got)
+		}
+
+		_, err = repos.Token.GetRefreshTokenByHash(ctx, "missing")
+		if !isKind(err, servererrors.KindNotFound) {
+			t.Fatalf("expected NotFound error, got %v", err)
+		}
+	})
+
+	t.Run("RotateRefreshTokenOnlyOnce", func(t *testing.T) {
+		repos := newRepositories(t)
+		user := CreateUser(t, repos, model.RoleUser)
+		familyID := unique("family")
+		first := newToken(user.ID, familyID)
+		if err := repos.Token.CreateRefreshToken(ctx, first); err != nil {
+			t.Fatalf("create refresh token: %v", err)
+		}
+
+		second := newToken(user.ID, familyID)
+		rotated, err := repos.Token.RotateRefreshToken(ctx, first.ID, second)
+		if err != nil || !rotated {
+			t.Fatalf("first rotation: rotated=%v err=%v", rotated, err)
+		}
+		if second.ID == 0 {
+			t.Fatal("expected rotated token ID to be assigned")
+		}
+
+		third := newToken(user.ID, familyID)
+		rotated, err = repos.Token.RotateRefreshToken(ctx, first.ID, third)
+		if err != nil {
+			t.Fatalf("second rotation: %v", err)
+		}
+		if rotated {
+			t.Fatal("expected rotating a used token to fail")
+		}
+		if _, err := repos.Token.GetRefreshTokenByHash(ctx, third.TokenHash); !isKind(err, servererrors.KindNotFound) {
+			t.Fatalf("expected rejected token not to be saved, got %v", err)
+		}
+
+		used, err := repos.Token.GetRefreshTokenByHash(ctx, first.TokenHash)
+		if err != nil {
+			t.Fatalf("get used token: %v", err)
+		}
+		if used.UsedAt == nil {
+			t.Fatal("expected used_at to be set")
+		}
+	})
+
+	t.Run("RevokeFamily", func(t *testing.T) {
+		repos := newRepositories(t)
+		user := CreateUser(t, repos, model.RoleUser)
+		familyID := unique("family")
+		revoked := []*model.RefreshToken{newToken(user.ID, familyID), newToken(user.ID, familyID)}
+		other := newToken(user.ID, unique("family"))
+		for _, token := range append(revoked, other) {
+			if err := repos.Token.CreateRefreshToken(ctx, token); err != nil {
+				t.Fatalf("create refresh token: %v", err)
+			}
+		}
+
+		if err := repos.Token.RevokeRefreshTokenFamily(ctx, familyID); err != nil {
+			t.Fatalf("revoke family: %v", err)
+		}
+		for _, token := range revoked {
+			got, err := repos.Token.GetRefreshTokenByHash(ctx, token.TokenHash)
+			if err != nil {
+				t.Fatalf("get refresh token: %v", err)
+			}
+			if got.RevokedAt == nil {
+				t.Fatal("expected token in family to be revoked")
+			}
+		}
+		got, err := repos.Token.GetRefreshTokenByHash(ctx, other.TokenHash)
+		if err != nil {
+			t.Fatalf("get refresh token: %v", err)
+		}
+		if got.RevokedAt != nil {
+			t.Fatal("expected token in other family to stay valid")
+		}
+
+		rotated, err := repos.Token.RotateRefreshToken(ctx, revoked[0].ID, newToken(user.ID, familyID))
+		if err != nil || rotated {
+			t.Fatalf("expected rotating a revoked token to fail, rotated=%v err=%v", rotated, err)
+		}
+
+		// 会话按家族判断是否被吊销
+		for family, want := range map[string]bool{familyID: true, other.FamilyID: false, unique("family"): false} {
+			sessionRevoked, err := repos.Token.IsSessionRevoked(ctx, family)
+			if err != nil || sessionRevoked != want {
+				t.Fatalf("session %s revoked = %v (err %v), want %v", family, sessionRevoked, err, want)
+			}
+		}
+	})
+
+	t.Run("ChangePasswordRevokesOtherSessions", func(t *testing.T) {
+		repos := newRepositories(t)
+		user := CreateUser(t, repos, model.RoleUser)
+		current := newToken(user.ID, unique("family"))
+		other := newToken(user.ID, unique("family"))
+		for _, token := range []*model.RefreshToken{current, other} {
+			if err := repos.Token.CreateRefreshToken(ctx, token); err != nil {
+				t.Fatalf("create refresh token: %v", err)
+			}
+		}
+
+		if err := repos.User.ChangePassword(ctx, user.ID, "hashed-new", current.FamilyID); err != nil {
+			t.Fatalf("change password: %v", err)
+		}
+		got, err := repos.User.GetUserBy(ctx, repository.QueryByID, strconv.FormatInt(user.ID, 10))
+		if err != nil {
+			t.Fatalf("get user: %v", err)
+		}
+		if got.Password != "hashed-new" {
+			t.Fatalf("password hash = %q, want hashed-new", got.Password)
+		}
+		for _, token := range []*model.RefreshToken{current, other} {
+			revoked, err := repos.Token.IsSessionRevoked(ctx, token.FamilyID)
+			if err != nil {
+				t.Fatalf("check session: %v", err)
+			}
+			if want := token == other; revoked != want {
+				t.Fatalf("session %s revoked = %v, want %v", token.FamilyID, revoked, want)
+			}
+		}
+	})
+
+	t.Run("RevokeUserRefreshTokens", func(t *testing.T) {
+		repos := newRepositories(t)
+		user := CreateUser(t, repos, model.RoleUser)
+		other := CreateUser(t, repos, model.RoleUser)
+		current := newToken(user.ID, unique("family"))
+		revoked := []*model.RefreshToken{newToken(user.ID, unique("family")), newToken(user.ID, unique("family"))}
+		kept := []*model.RefreshToken{current, newToken(other.ID, unique("family"))}
+		for _, token := range append(revoked, kept...) {
+			if err := repos.Token.CreateRefreshToken(ctx, token); err != nil {
+				t.Fatalf("create refresh token: %v", err)
+			}
+		}
+
+		// 保留当前会话, 吊销用户的其他会话
+		if err := repos.Token.RevokeUserRefreshTokens(ctx, user.ID, current.FamilyID); err != nil {
+			t.Fatalf("revoke user refresh tokens: %v", err)
+		}
+		for _, token := range append(revoked, kept...) {
+			got, err := repos.Token.GetRefreshTokenByHash(ctx, token.TokenHash)
+			if err != nil {
+				t.Fatalf("get refresh token: %v", err)
+			}
+			if wantRevoked := token.UserID == user.ID && token != current; (got.RevokedAt != nil) != wantRevoked {
+				t.Fatalf("token of user %d: revoked at %v, want revoked = %v", token.UserID, got.RevokedAt, wantRevoked)
+			}
+		}
+
+		// 不保留任何会话
+		if err := repos.Token.RevokeUserRefreshTokens(ctx, user.ID, ""); err != nil {
+			t.Fatalf("revoke all user refresh tokens: %v", err)
+		}
+		got, err := repos.Token.GetRefreshTokenByHash(ctx, current.TokenHash)
+		if err != nil {
+			t.Fatalf("get refresh token: %v", err)
+		}
+		if got.RevokedAt == nil {
+			t.Fatal("current session should be revoked when no family is kept")
+		}
+	})
+
+	t.Run("RevokeAccessToken", func(t *testing.T) {
+		repos := newRepositories(t)
+		jti := fmt.Sprintf("%036s", unique("jti"))
+
+		revoked, err := repos.Token.IsAccessTokenRevoked(ctx, jti)
+		if err != nil || revoked {
+			t.Fatalf("expected token not revoked, revoked=%v err=%v", revoked, err)
+		}
+		for i := 0; i < 2; i++ {
+			if err := repos.Token.RevokeAccessToken(ctx, jti, time.Now().Add(time.Minute)); err != nil {
+				t.Fatalf("revoke access token (attempt %d): %v", i+1, err)
+			}
+		}
+		revoked, err = repos.Token.IsAccessTokenRevoked(ctx, jti)
+		if err != nil || !revoked {
+			t.Fatalf("expected token revoked, revoked=%v err=%v", revoked, err)
+		}
+	})
+
+	t.Run("DeleteExpiredTokens", func(t *testing.T) {
+		repos := newRepositories(t)
+		user := CreateUser(t, repos, model.RoleUser)
+
+		// 使用不同时区保存的时间也要按时间先后比较
+		expired := newToken(user.ID, unique("family"))
+		expired.ExpiresAt = time.Now().Add(-time.Hour).In(time.FixedZone("UTC+8", 8*60*60))
+		valid := newToken(user.ID, unique("family"))
+		for _, token := range []*model.RefreshToken{expired, valid} {
+			if err := repos.Token.CreateRefreshToken(ctx, token); err != nil {
+				t.Fatalf("create refresh token: %v", err)
+			}
+		}
+		expiredJTI, validJTI := fmt.Sprintf("%036s", unique("jti")), fmt.Sprintf("%036s", unique("jti"))
+		if err := repos.Token.RevokeAccessToken(ctx, expiredJTI, time.Now().Add(-time.Minute)); err != nil {
+			t.Fatalf("revoke access token: %v", err)
+		}
+		if err := repos.Token.RevokeAccessToken(ctx, validJTI, time.Now().Add(time.Minute).In(time.FixedZone("UTC-8", -8*60*60))); err != nil {
+			t.Fatalf("revoke access token: %v", err)
+		}
+
+		deleted, err := repos.Token.DeleteExpiredTokens(ctx, time.Now())
+		if err != nil {
+			t.Fatalf("delete expired tokens: %v", err)
+		}
+		if deleted != 2 {
+			t.Fatalf("deleted = %d, want 2", deleted)
+		}
+		if _, err := repos.Token.GetRefreshTokenByHash(ctx, expired.TokenHash); !isKind(err, servererrors.KindNotFound) {
+			t.Fatalf("expected expired refresh token to be deleted, got %v", err)
+		}
+		if _, err := repos.Token.GetRefreshTokenByHash(ctx, valid.TokenHash); err != nil {
+			t.Fatalf("expected valid refresh token to be kept, got %v", err)
+		}
+		if revoked, err := repos.Token.IsAccessTokenRevoked(ctx, expiredJTI); err != nil || revoked {
+			t.Fatalf("expected expired revocation to be deleted, revoked=%v err=%v", revoked, err)
+		}
+		if revoked, err := repos.Token.IsAccessTokenRevoked(ctx, validJTI); err != nil || !revoked {
+			t.Fatalf("expected valid revocation to be kept, revoked=%v err=%v", revoked, err)
+		}
+	})
+}
+
+func runUserTokenTests(t *testing.T, newRepositories Factory) {
+	ctx := context.Background()
+
+	newToken := func(t *testing.T, repos *repository.Repositories, userID int64, purpose string, ttl time.Duration) *model.UserToken {
+		t.Helper()
+		token := &model.UserToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: fmt.Sprintf("%064s", unique("hash")),
+			ExpiresAt: time.Now().Add(ttl),
+		}
+		if err := repos.UserToken.Create(ctx, token); err != nil {
+			t.Fatalf("create user token: %v", err)
+		}
+		if token.ID == 0 {
+			t.Fatal("expected user token ID to be assigned")
+		}
+		return token
+	}
+
+	t.Run("ConsumeOnlyOnce", func(t *testing.T) {
+		repos := newRepositories(t)
+		user := CreateUser(t, repos, model.RoleUser)
+		token := newToken(t, repos, user.ID, model.TokenPurposePasswordReset, time.Hour)
+
+		// 用途不同的令牌不能使用
+		if _, err := repos.UserToken.Consume(ctx, model.TokenPurposeEmailVerification, token.TokenHash, time.Now()); !isKind(err, servererrors.KindNotFound) {
+			t.Fatalf("consume with another purpose: expected NotFound, got %v", err)
+		}
+		got, err := repos.UserToken.Consume(ctx, model.TokenPurposePasswordReset, token.TokenHash, time.Now())
+		if err != nil {
+			t.Fatalf("consume: %v", err)
+		}
+		if got.ID != token.ID || got.UserID != user.ID || got.UsedAt == nil {
+			t.Fatalf("unexpected consumed token: %+v", got)
+		}
+		if _, err := repos.UserToken.Consume(ctx, model.TokenPurposePasswordReset, token.TokenHash, time.Now()); !isKind(err, servererrors.KindNotFound) {
+			t.Fatalf("consume twice: expected NotFound, got %v", err)
+		}
+	})
+
+	t.Run("ConsumeExpiredFails", func(t *testing.T) {
+		repos := newRepositories(t)
+		user := CreateUser(t, repos, model.RoleUser)
+		token := newToken(t, repos, user.ID, model.TokenPurposeEmailVerification, time.Minute)
+
+		if _, err := repos.UserToken.Consume(ctx, model.TokenPurposeEmailVerification, token.TokenHash, time.Now().Add(2*time.Minute)); !isKind(err, servererrors.KindNotFound) {
+			t.Fatalf("consume expired: expected NotFound, got %v", err)
+		}
+	})
+
+	t.Run("InvalidateOnlyMatchingTokens", func(t *testing.T) {
+		repos := newRepositories(t)
+		user := CreateUser(t, repos, model.RoleUser)
+		invalidated := newToken(t, repos, user.ID, model.TokenPurposePasswordReset, time.Hour)
+		otherPurpose := newToken(t, repos, user.ID, model.TokenPurposeEmailVerification, time.Hour)
+
+		if err := repos.UserToken.Invalidate(ctx, user.ID, model.TokenPurposePasswordReset, time.Now()); err != nil {
+			t.Fatalf("invalidate: %v", err)
+		}
+		if _, err := repos.UserToken.Consume(ctx, model.TokenPurposePasswordReset, invalidated.TokenHash, time.Now()); !isKind(err, servererrors.KindNotFound) {
+			t.Fatalf("consume invalidated: expected NotFound, got %v", err)
+		}
+		if _, err := repos.UserToken.Consume(ctx, model.TokenPurposeEmailVerification, otherPurpose.TokenHash, time.Now()); err != nil {
+			t.Fatalf("consume token with other purpose: %v", err)
+		}
+	})
+
+	t.Run("ResetPassword", func(t *testing.T) {
+		repos := newRepositories(t)
+		user := CreateUser(t, repos, model.RoleUser)
+		token := newToken(t, repos, user.ID, model.TokenPurposePasswordReset, time.Hour)
+		other := newToken(t, repos, user.ID, model.TokenPurposePasswordReset, time.Hour)
+		verification := newToken(t, repos, user.ID, model.TokenPurposeEmailVerification, time.Hour)
+		if _, err := repos.User.RecordLoginFailure(ctx, user.ID); err != nil {
+			t.Fatalf("record login failure: %v", err)
+		}
+		if err := repos.User.LockUser(ctx, user.ID, time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("lock user: %v", err)
+		}
+		refresh := &model.RefreshToken{UserID: user.ID, FamilyID: unique("family"), TokenHash: fmt.Sprintf("%064s", unique("hash")), ExpiresAt: time.Now().Add(time.Hour)}
+		if err := repos.Token.CreateRefreshToken(ctx, refresh); err != nil {
+			t.Fatalf("create refresh token: %v", err)
+		}
+
+		// 令牌无效时不做任何修改
+		if _, err := repos.UserToken.ResetPassword(ctx, verification.TokenHash, "hashed-new", time.Now()); !isKind(err, servererrors.KindNotFound) {
+			t.Fatalf("reset with another purpose: expected NotFound, got %v", err)
+		}
+		id := strconv.FormatInt(user.ID, 10)
+		unchanged, err := repos.User.GetUserBy(ctx, repository.QueryByID, id)
+		if err != nil {
+			t.Fatalf("get user: %v", err)
+		}
+		if unchanged.Password != user.Password || unchanged.FailedLoginAttempts != 1 {
+			t.Fatalf("user changed by an invalid token: %+v", unchanged)
+		}
+
+		got, err := repos.UserToken.ResetPassword(ctx, token.TokenHash, "hashed-new", time.Now())
+		if err != nil {
+			t.Fatalf("reset password: %v", err)
+		}
+		if got.ID != token.ID || got.UserID != user.ID {
+			t.Fatalf("unexpected consumed token: %+v", got)
+		}
+		updated, err := repos.User.GetUserBy(ctx, repository.QueryByID, id)
+		if err != nil {
+			t.Fatalf("get user: %v", err)
+		}
+		if updated.Password != "hashed-new" || updated.FailedLoginAttempts != 0 || updated.LockedUntil != nil || !updated.IsEmailVerified() {
+			t.Fatalf("unexpected user after reset: %+v", updated)
+		}
+		revoked, err := repos.Token.GetRefreshTokenByHash(ctx, refresh.TokenHash)
+		if err != nil {
+			t.Fatalf("get refresh token: %v", err)
+		}
+		if revoked.RevokedAt == nil {
+			t.Fatal("expected refresh tokens to be revoked")
+		}
+
+		// 令牌只能使用一次, 其他重置密码的令牌被作废, 其他用途的令牌不受影响
+		if _, err := repos.UserToken.ResetPassword(ctx, token.TokenHash, "hashed-again", time.Now()); !isKind(err, servererrors.KindNotFound) {
+			t.Fatalf("reset twice: expected NotFound, got %v", err)
+		}
+		if _, err := repos.UserToken.Consume(ctx, model.TokenPurposePasswordReset, other.TokenHash, time.Now()); !isKind(err, servererrors.KindNotFound) {
+			t.Fatalf("consume other reset token: expected NotFound, got %v", err)
+		}
+		if _, err := repos.UserToken.Consume(ctx, model.TokenPurposeEmailVerification, verification.TokenHash, time.Now()); err != nil {
+			t.Fatalf("consume verification token: %v", err)
+		}
+	})
+
+	t.Run("DeleteExpired", func(t *testing.T) {
+		repos := newRepositories(t)
+		user := CreateUser(t, repos, model.RoleUser)
+		expired := newToken(t, repos, user.ID, model.TokenPurposePasswordReset, -time.Minute)
+		valid := newToken(t, repos, user.ID, model.TokenPurposePasswordReset, time.Hour)
+
+		deleted, err := repos.UserToken.DeleteExpired(ctx, time.Now())
+		if err != nil {
+			t.Fatalf("delete expired: %v", err)
+		}
+		if deleted != 1 {
+			t.Fatalf("deleted %d tokens, want 1 (%s)", deleted, expired.TokenHash)
+		}
+		if _, err := repos.UserToken.Consume(ctx, model.TokenPurposePasswordReset, valid.TokenHash, time.Now()); err != nil {
+			t.Fatalf("consume valid token: %v", err)
+		}
+	})
+}
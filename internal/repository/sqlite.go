@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+	"net/url"
+	"skymates-api/config"
+)
+
+func init() {
+	// modernc.org/sqlite 注册的驱动名是 sqlite, sqlx 默认只认识 sqlite3, 需要告诉 sqlx 使用 ? 占位符
+	sqlx.BindDriver("sqlite", sqlx.QUESTION)
+}
+
+// SQLiteMemory 表示使用内存数据库, 进程退出后数据丢失, 适合测试
+const SQLiteMemory = ":memory:"
+
+// sqliteDSN 根据数据库配置构造 SQLite 的 DSN
+func sqliteDSN(cfg config.DatabaseConfig) string {
+	return SQLiteDSN(cfg.Path)
+}
+
+// SQLiteDSN 为数据库文件路径 (或 SQLiteMemory) 构造 DSN
+// 开启外键约束, 设置忙等待时间, 并以 SQLite 可排序的格式保存时间
+func SQLiteDSN(path string) string {
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Set("_time_format", "sqlite")
+	return "file:" + path + "?" + params.Encode()
+}
+
+// isSQLiteDuplicateKey 判断错误是否为 SQLite 的唯一索引或主键冲突
+func isSQLiteDuplicateKey(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+}
+
+// isSQLiteForeignKeyViolation 判断错误是否为 SQLite 的外键约束冲突
+func isSQLiteForeignKeyViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY
+}
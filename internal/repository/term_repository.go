@@ -5,20 +5,222 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
+	servererrors "skymates-api/errors"
 	"skymates-api/internal/model"
+	"skymates-api/pkg/search"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/jmoiron/sqlx"
 )
 
 // TermRepository 定义术语存储库接口
 type TermRepository interface {
-	SearchTerms(ctx context.Context, keyword string) ([]model.Term, error)
+	SearchTerms(ctx context.Context, query model.TermSearchQuery) (*model.TermSearchResult, error)
 	GetTermByID(ctx context.Context, id int64) (*model.TermDetail, error)
-	ListTermsByCategory(ctx context.Context, categoryID int64, lastID *int64, limit int) ([]model.Term, bool, error)
-	CreateTerm(ctx context.Context, term *model.Term, categoryIDs []int64) (int64, error)
-	UpdateTerm(ctx context.Context, term *model.Term, categoryIDs []int64) error
+	ListTermNames(ctx context.Context, afterID int64, limit int) ([]model.TermNames, error)
+	ListTermsByCategory(ctx context.Context, categoryIDs []int64, lastID *int64, limit int) ([]model.Term, bool, error)
+	CreateTerm(ctx context.Context, term *model.Term, categoryIDs []int64, change model.TermChange) (int64, error)
+	UpdateTerm(ctx context.Context, term *model.Term, categoryIDs []int64, change model.TermChange) error
+	RefreshSearchColumns(ctx context.Context, limit int) (int, error)
+	ListTermRevisions(ctx context.Context, termID int64) ([]model.TermRevision, error)
+	GetTermRevision(ctx context.Context, termID int64, revision int) (*model.TermRevision, error)
+}
+
+// termDetailRow 术语详情的查询结果, aliases 列解码后放入 TermDetail.Aliases
+type termDetailRow struct {
+	model.TermDetail
+	AliasList string `db:"aliases"`
+}
+
+// termSearchRow 搜索结果的一行, aliases 列解码后放入 TermSearchHit.Aliases
+type termSearchRow struct {
+	model.TermSearchHit
+	AliasList string `db:"aliases"`
+}
+
+// termNamesRow 术语名称的查询结果, aliases 列解码后放入 TermNames.Aliases
+type termNamesRow struct {
+	model.TermNames
+	AliasList string `db:"aliases"`
+}
+
+// encodeList 把别名等列表编码为一列的值: 以换行分隔, 首尾各有一个换行, 列表为空时为空字符串
+// 这样 LIKE '%\n值\n%' 匹配整个元素, LIKE '%\n前缀%' 匹配元素的前缀
+func encodeList(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return "\n" + strings.Join(values, "\n") + "\n"
+}
+
+// decodeList 解码 encodeList 编码的列, 列表为空时返回空切片
+func decodeList(value string) []string {
+	values := []string{}
+	for _, v := range strings.Split(value, "\n") {
+		if v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// termSearchVersion 检索列计算方式 (见 searchColumns) 的版本, 繁简对照表或拼音规则变化时加一,
+// 启动时由 RefreshSearchColumns 重新计算版本较旧的行
+const termSearchVersion = 1
+
+// searchColumns 计算 search_names 和 search_pinyin 列: 前者是转为小写和简体的名称和别名,
+// 后者是它们的全拼和拼音首字母, 都用 encodeList 编码
+func searchColumns(name string, aliases []string) (names, pinyin string) {
+	var folded, spelled []string
+	for _, value := range append([]string{name}, aliases...) {
+		folded = append(folded, search.Simplify(strings.ToLower(value)))
+		full, initials := search.Pinyin(value)
+		if full != "" {
+			spelled = append(spelled, full)
+		}
+		if initials != full {
+			spelled = append(spelled, initials)
+		}
+	}
+	return encodeList(folded), encodeList(spelled)
+}
+
+// searchHits 把查询结果转换为 TermSearchHit
+func searchHits(rows []termSearchRow) []model.TermSearchHit {
+	hits := make([]model.TermSearchHit, len(rows))
+	for i, row := range rows {
+		hits[i] = row.TermSearchHit
+		hits[i].Aliases = decodeList(row.AliasList)
+	}
+	return hits
+}
+
+// likeEscaper 转义 LIKE 模式中的通配符, SQL 中需要加上 ESCAPE '!'
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// searchPatterns 搜索关键字对应的 LIKE 模式, 关键字统一转为小写
+type searchPatterns struct {
+	exact    string // search_names (或 search_pinyin) 中某一项与关键字相同
+	prefix   string // 某一项以关键字开头
+	names    string // 某一项包含关键字
+	contains string // 解释包含关键字, 只用于 SearchModeText
+}
+
+// newSearchPatterns 返回 SearchModeText 的模式, 与 search_names 比较时关键字也转为简体
+func newSearchPatterns(keyword string) searchPatterns {
+	lowered := strings.ToLower(keyword)
+	p := listPatterns(search.Simplify(lowered))
+	p.contains = "%" + likeEscaper.Replace(lowered) + "%"
+	return p
+}
+
+// listPatterns 返回匹配 encodeList 编码的列中某一项的模式
+func listPatterns(value string) searchPatterns {
+	escaped := likeEscaper.Replace(value)
+	return searchPatterns{
+		exact:  "%\n" + escaped + "\n%",
+		prefix: "%\n" + escaped + "%",
+		names:  "%" + escaped + "%",
+	}
+}
+
+// searchTermsByPinyin 以 SearchModePinyin 搜索术语, 只比较 search_pinyin 列, 结果按等级和 ID 排序
+// 三种数据库的 SQL 相同, 占位符由 db.Rebind 转换
+func searchTermsByPinyin(ctx context.Context, db *sqlx.DB, query model.TermSearchQuery) (*model.TermSearchResult, error) {
+	result := model.TermSearchResult{Hits: []model.TermSearchHit{}, Facets: []model.CategoryFacet{}}
+	target, _ := search.Pinyin(query.Keyword)
+	if target == "" {
+		// 关键字中没有汉字, 字母或数字, 不匹配任何术语
+		return &result, nil
+	}
+	p := listPatterns(target)
+
+	match := `t.search_pinyin LIKE ? ESCAPE '!'`
+	where, whereArgs := match, []interface{}{p.names}
+	if query.CategoryID != nil {
+		where += ` AND EXISTS (SELECT 1 FROM term_category_relations r WHERE r.term_id = t.id AND r.category_id = ?)`
+		whereArgs = append(whereArgs, *query.CategoryID)
+	}
+
+	err := getContext(ctx, db, "terms.search_pinyin_count", &result.Total, db.Rebind(`SELECT COUNT(*) FROM terms t WHERE `+where), whereArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	// 分类统计不受分类过滤的影响, 便于切换分类
+	facetsQuery := `SELECT r.category_id, COUNT(*) AS term_count FROM terms t
+		JOIN term_category_relations r ON r.term_id = t.id
+		WHERE ` + match + ` GROUP BY r.category_id ORDER BY term_count DESC, r.category_id ASC`
+	if err := selectContext(ctx, db, "terms.search_pinyin_facets", &result.Facets, db.Rebind(facetsQuery), p.names); err != nil {
+		return nil, err
+	}
+
+	// match_rank 的取值见 model.MatchExact 等常量, 拼音不参与全文索引, 最低为 MatchContains
+	hitsQuery := `SELECT t.id, t.name, t.aliases, t.explanation,
+			CASE WHEN t.search_pinyin LIKE ? ESCAPE '!' THEN 3
+				WHEN t.search_pinyin LIKE ? ESCAPE '!' THEN 2
+				ELSE 1 END AS match_rank
+		FROM terms t WHERE ` + where + `
+		ORDER BY match_rank DESC, t.id ASC LIMIT ? OFFSET ?`
+	args := append([]interface{}{p.exact, p.prefix}, whereArgs...)
+	args = append(args, query.Limit, query.Offset)
+	var rows []termSearchRow
+	if err := selectContext(ctx, db, "terms.search_pinyin", &rows, db.Rebind(hitsQuery), args...); err != nil {
+		return nil, err
+	}
+	result.Hits = searchHits(rows)
+	return &result, nil
+}
+
+// refreshSearchColumns 实现 RefreshSearchColumns, 三种数据库的 SQL 相同, 占位符由 db.Rebind 转换
+// 更新时再次检查版本, 期间被 UpdateTerm 改写过的行不会被旧的名称覆盖
+func refreshSearchColumns(ctx context.Context, db *sqlx.DB, limit int) (int, error) {
+	query := `SELECT id, name, aliases FROM terms WHERE search_version < ? ORDER BY id ASC LIMIT ?`
+	var rows []termNamesRow
+	if err := selectContext(ctx, db, "terms.list_stale_search", &rows, db.Rebind(query), termSearchVersion, limit); err != nil {
+		return 0, err
+	}
+	update := db.Rebind(`UPDATE terms SET search_names = ?, search_pinyin = ?, search_version = ? WHERE id = ? AND search_version < ?`)
+	for _, row := range rows {
+		names, pinyin := searchColumns(row.Name, decodeList(row.AliasList))
+		if _, err := execContext(ctx, db, "terms.update_search", update, names, pinyin, termSearchVersion, row.ID, termSearchVersion); err != nil {
+			return 0, err
+		}
+	}
+	return len(rows), nil
+}
+
+// listTermsByCategories 实现 ListTermsByCategory, 三种数据库的 SQL 相同, 占位符由 db.Rebind 转换
+// 属于多个分类的术语只返回一次
+func listTermsByCategories(ctx context.Context, db *sqlx.DB, categoryIDs []int64, lastID *int64, limit int) ([]model.Term, bool, error) {
+	if len(categoryIDs) == 0 {
+		return []model.Term{}, false, nil
+	}
+	afterID := int64(0)
+	if lastID != nil {
+		afterID = *lastID
+	}
+	query, args, err := sqlx.In(`SELECT DISTINCT t.id, t.name FROM terms t
+		JOIN term_category_relations r ON t.id = r.term_id
+		WHERE r.category_id IN (?) AND t.id > ? ORDER BY t.id ASC LIMIT ?`, categoryIDs, afterID, limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var terms []model.Term
+	if err := selectContext(ctx, db, "terms.list_by_category", &terms, db.Rebind(query), args...); err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(terms) > limit
+	if hasMore {
+		terms = terms[:limit]
+	}
+	return terms, hasMore, nil
 }
 
 // TermRepositoryImpl 实现 TermRepository 接口
@@ -31,173 +233,286 @@ func NewTermRepository(db *sqlx.DB) TermRepository {
 	return &TermRepositoryImpl{db: db}
 }
 
-// SearchTerms 根据关键字搜索术语
-func (r *TermRepositoryImpl) SearchTerms(ctx context.Context, keyword string) ([]model.Term, error) {
-	query := `SELECT id, name FROM terms WHERE name LIKE ?`
-	var terms []model.Term
-	err := r.db.SelectContext(ctx, &terms, query, "%"+keyword+"%")
+// SearchTerms 在名称, 别名和解释中搜索术语
+// 全文索引 ft_terms_search 建在 search_names 和 explanation 上, 搜索条件只用全文索引;
+// 关键字中有比 ngram 分词短的词 (单个字符) 时索引中找不到, 只在 search_names 中匹配子串
+func (r *TermRepositoryImpl) SearchTerms(ctx context.Context, query model.TermSearchQuery) (*model.TermSearchResult, error) {
+	if query.Mode == model.SearchModePinyin {
+		result, err := searchTermsByPinyin(ctx, r.db, query)
+		if err != nil {
+			return nil, fmt.Errorf("TermRepositoryImpl.SearchTerms: %w", err)
+		}
+		return result, nil
+	}
+
+	p := newSearchPatterns(query.Keyword)
+	// search_names 已经转为小写和简体, 关键字也要同样转换
+	folded := search.Simplify(strings.ToLower(query.Keyword))
+	against := mysqlBooleanQuery(folded)
+
+	match := `MATCH (t.search_names, t.explanation) AGAINST (? IN BOOLEAN MODE)`
+	matchArgs := []interface{}{against}
+	if !mysqlFullTextSearchable(folded) {
+		match = `t.search_names LIKE ? ESCAPE '!'`
+		matchArgs = []interface{}{p.names}
+	}
+	where, whereArgs := match, matchArgs
+	if query.CategoryID != nil {
+		where += ` AND EXISTS (SELECT 1 FROM term_category_relations r WHERE r.term_id = t.id AND r.category_id = ?)`
+		whereArgs = append(slices.Clone(matchArgs), *query.CategoryID)
+	}
+
+	var result model.TermSearchResult
+	err := getContext(ctx, r.db, "terms.search_count", &result.Total, `SELECT COUNT(*) FROM terms t WHERE `+where, whereArgs...)
 	if err != nil {
-		log.Printf("TermRepositoryImpl.SearchTerms: %v", err)
-		return nil, err
+		return nil, fmt.Errorf("TermRepositoryImpl.SearchTerms: %w", err)
+	}
+
+	// 分类统计不受分类过滤的影响, 便于切换分类
+	facetsQuery := `SELECT r.category_id, COUNT(*) AS term_count FROM terms t
+		JOIN term_category_relations r ON r.term_id = t.id
+		WHERE ` + match + ` GROUP BY r.category_id ORDER BY term_count DESC, r.category_id ASC`
+	err = selectContext(ctx, r.db, "terms.search_facets", &result.Facets, facetsQuery, matchArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("TermRepositoryImpl.SearchTerms: %w", err)
+	}
+
+	// match_rank 的取值见 model.MatchExact 等常量
+	hitsQuery := `SELECT t.id, t.name, t.aliases, t.explanation,
+			CASE WHEN t.search_names LIKE ? ESCAPE '!' THEN 3
+				WHEN t.search_names LIKE ? ESCAPE '!' THEN 2
+				WHEN t.search_names LIKE ? ESCAPE '!' THEN 1
+				ELSE 0 END AS match_rank,
+			MATCH (t.search_names, t.explanation) AGAINST (? IN BOOLEAN MODE) AS score
+		FROM terms t WHERE ` + where + `
+		ORDER BY match_rank DESC, score DESC, t.id ASC LIMIT ? OFFSET ?`
+	args := []interface{}{p.exact, p.prefix, p.names, against}
+	args = append(append(args, whereArgs...), query.Limit, query.Offset)
+	var rows []termSearchRow
+	if err := selectContext(ctx, r.db, "terms.search", &rows, hitsQuery, args...); err != nil {
+		return nil, fmt.Errorf("TermRepositoryImpl.SearchTerms: %w", err)
+	}
+	result.Hits = searchHits(rows)
+	return &result, nil
+}
+
+// mysqlNgramTokenSize ngram 分词器的词长 (ngram_token_size 的默认值), 更短的词不在全文索引中
+const mysqlNgramTokenSize = 2
+
+// mysqlBooleanQuery 把关键字转换为 BOOLEAN MODE 的查询: 每个词都必须出现,
+// 词用双引号括起来, 关键字中的运算符不起作用
+func mysqlBooleanQuery(keyword string) string {
+	var words []string
+	for _, word := range mysqlQueryWords(keyword) {
+		words = append(words, `+"`+word+`"`)
 	}
-	return terms, nil
+	return strings.Join(words, " ")
+}
+
+// mysqlFullTextSearchable 判断关键字能否只用全文索引查找: 至少有一个词, 且每个词都不短于 ngram 的词长
+func mysqlFullTextSearchable(keyword string) bool {
+	words := mysqlQueryWords(keyword)
+	for _, word := range words {
+		if utf8.RuneCountInString(word) < mysqlNgramTokenSize {
+			return false
+		}
+	}
+	return len(words) > 0
+}
+
+// mysqlQueryWords 按空白把关键字分为全文查询的词, 双引号当作空白
+func mysqlQueryWords(keyword string) []string {
+	return strings.Fields(strings.ReplaceAll(keyword, `"`, " "))
 }
 
 // GetTermByID 根据 ID 获取术语详情
 func (r *TermRepositoryImpl) GetTermByID(ctx context.Context, id int64) (*model.TermDetail, error) {
-	query := `SELECT id, name, explanation, source_url, created_at, updated_at FROM terms WHERE id = ?`
-	var term model.TermDetail
-	err := r.db.GetContext(ctx, &term, query, id)
+	query := `SELECT id, name, aliases, explanation, source_url, created_by, created_at, updated_at FROM terms WHERE id = ?`
+	var row termDetailRow
+	err := getContext(ctx, r.db, "terms.get_by_id", &row, query, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
-		log.Printf("TermRepositoryImpl.GetTermByID: %v", err)
-		return nil, err
+		return nil, fmt.Errorf("TermRepositoryImpl.GetTermByID: %w", err)
 	}
 
 	// 获取关联的分类 ID
-	categoryQuery := `SELECT category_id FROM term_category_relations WHERE term_id = ?`
+	categoryQuery := `SELECT category_id FROM term_category_relations WHERE term_id = ? ORDER BY category_id`
 	var categoryIDs []int64
-	err = r.db.SelectContext(ctx, &categoryIDs, categoryQuery, id)
+	err = selectContext(ctx, r.db, "term_category_relations.list_by_term", &categoryIDs, categoryQuery, id)
 	if err != nil {
-		log.Printf("TermRepositoryImpl.GetTermByID: %v", err)
-		return nil, err
+		return nil, fmt.Errorf("TermRepositoryImpl.GetTermByID: %w", err)
 	}
+	term := row.TermDetail
+	term.Aliases = decodeList(row.AliasList)
 	term.CategoryIDs = categoryIDs
 	return &term, nil
 }
 
-// ListTermsByCategory 列出指定分类下的术语
-func (r *TermRepositoryImpl) ListTermsByCategory(ctx context.Context, categoryID int64, lastID *int64, limit int) ([]model.Term, bool, error) {
-	var query string
-	var args []interface{}
-	if lastID == nil {
-		query = `SELECT t.id, t.name FROM terms t
-                 JOIN term_category_relations r ON t.id = r.term_id
-                 WHERE r.category_id = ? ORDER BY t.id ASC LIMIT ?`
-		args = []interface{}{categoryID, limit + 1}
-	} else {
-		query = `SELECT t.id, t.name FROM terms t
-                 JOIN term_category_relations r ON t.id = r.term_id
-                 WHERE r.category_id = ? AND t.id > ? ORDER BY t.id ASC LIMIT ?`
-		args = []interface{}{categoryID, *lastID, limit + 1}
+// ListTermNames 按 ID 顺序分批列出 ID 大于 afterID 的术语的名称和别名
+func (r *TermRepositoryImpl) ListTermNames(ctx context.Context, afterID int64, limit int) ([]model.TermNames, error) {
+	query := `SELECT id, name, aliases FROM terms WHERE id > ? ORDER BY id ASC LIMIT ?`
+	var rows []termNamesRow
+	if err := selectContext(ctx, r.db, "terms.list_names", &rows, query, afterID, limit); err != nil {
+		return nil, fmt.Errorf("TermRepositoryImpl.ListTermNames: %w", err)
 	}
-
-	var terms []model.Term
-	err := r.db.SelectContext(ctx, &terms, query, args...)
-	if err != nil {
-		log.Printf("TermRepositoryImpl.ListTermsByCategory: %v", err)
-		return nil, false, err
+	names := make([]model.TermNames, len(rows))
+	for i, row := range rows {
+		names[i] = row.TermNames
+		names[i].Aliases = decodeList(row.AliasList)
 	}
+	return names, nil
+}
 
-	hasMore := len(terms) > limit
-	if hasMore {
-		terms = terms[:limit]
+// ListTermsByCategory 列出属于任一指定分类的术语, 按 ID 排序
+func (r *TermRepositoryImpl) ListTermsByCategory(ctx context.Context, categoryIDs []int64, lastID *int64, limit int) ([]model.Term, bool, error) {
+	terms, hasMore, err := listTermsByCategories(ctx, r.db, categoryIDs, lastID, limit)
+	if err != nil {
+		return nil, false, fmt.Errorf("TermRepositoryImpl.ListTermsByCategory: %w", err)
 	}
 	return terms, hasMore, nil
 }
 
-// CreateTerm 创建新术语并关联分类
-func (r *TermRepositoryImpl) CreateTerm(ctx context.Context, term *model.Term, categoryIDs []int64) (int64, error) {
+// CreateTerm 创建新术语并关联分类, 同时写入第 1 个修订版本
+func (r *TermRepositoryImpl) CreateTerm(ctx context.Context, term *model.Term, categoryIDs []int64, change model.TermChange) (int64, error) {
 	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
-		log.Printf("TermRepositoryImpl.CreateTerm: %v", err)
-		return 0, err
+		return 0, fmt.Errorf("TermRepositoryImpl.CreateTerm: %w", err)
 	}
 	defer func(tx *sqlx.Tx) {
-		err := tx.Rollback()
-		if err != nil {
-			log.Printf("TermRepositoryImpl.CreateTerm: %v", err)
-		}
+		_ = tx.Rollback()
 	}(tx)
 
 	// 插入 terms 表
-	query := `INSERT INTO terms (name, explanation, source_url, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`
-	result, err := tx.ExecContext(ctx, query, term.Name, term.Explanation, term.SourceURL, time.Now(), time.Now())
+	searchNames, searchPinyin := searchColumns(term.Name, term.Aliases)
+	query := `INSERT INTO terms (name, aliases, search_names, search_pinyin, search_version, explanation, source_url, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := execContext(ctx, tx, "terms.insert", query,
+		term.Name, encodeList(term.Aliases), searchNames, searchPinyin, termSearchVersion, term.Explanation, term.SourceURL, term.CreatedBy, time.Now(), time.Now(),
+	)
 	if err != nil {
-		log.Printf("TermRepositoryImpl.CreateTerm: %v", err)
-		return 0, err
+		return 0, fmt.Errorf("TermRepositoryImpl.CreateTerm: %w", err)
 	}
 	id, err := result.LastInsertId()
 	if err != nil {
-		log.Printf("TermRepositoryImpl.CreateTerm: %v", err)
-		return 0, err
+		return 0, fmt.Errorf("TermRepositoryImpl.CreateTerm: %w", err)
 	}
 
 	// 插入 term_category_relations 表
 	for _, categoryID := range categoryIDs {
-		_, err := tx.ExecContext(ctx, `INSERT INTO term_category_relations (term_id, category_id) VALUES (?, ?)`, id, categoryID)
+		_, err := execContext(ctx, tx, "term_category_relations.insert", `INSERT INTO term_category_relations (term_id, category_id) VALUES (?, ?)`, id, categoryID)
 		if err != nil {
-			log.Printf("TermRepositoryImpl.CreateTerm: %v", err)
-			return 0, err
+			return 0, fmt.Errorf("TermRepositoryImpl.CreateTerm: %w", categoryRelationError(err))
 		}
 	}
 
 	// 更新 terms 表的 category_list 字段
 	categoryList, _ := json.Marshal(categoryIDs)
-	_, err = tx.ExecContext(ctx, `UPDATE terms SET category_list = ? WHERE id = ?`, categoryList, id)
+	_, err = execContext(ctx, tx, "terms.update_category_list", `UPDATE terms SET category_list = ? WHERE id = ?`, categoryList, id)
 	if err != nil {
-		log.Printf("TermRepositoryImpl.CreateTerm: %v", err)
-		return 0, err
+		return 0, fmt.Errorf("TermRepositoryImpl.CreateTerm: %w", err)
+	}
+
+	// 插入第 1 个修订版本
+	if _, err := insertTermRevision(ctx, tx, id, term, categoryIDs, change); err != nil {
+		return 0, fmt.Errorf("TermRepositoryImpl.CreateTerm: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		log.Printf("TermRepositoryImpl.CreateTerm: %v", err)
-		return 0, err
+		return 0, fmt.Errorf("TermRepositoryImpl.CreateTerm: %w", err)
 	}
 	return id, nil
 }
 
-// UpdateTerm 更新术语并更新关联分类
-func (r *TermRepositoryImpl) UpdateTerm(ctx context.Context, term *model.Term, categoryIDs []int64) error {
+// UpdateTerm 更新术语并更新关联分类, 同时追加一个修订版本; 内容与最新的版本相同时不做任何修改
+func (r *TermRepositoryImpl) UpdateTerm(ctx context.Context, term *model.Term, categoryIDs []int64, change model.TermChange) error {
 	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
-		log.Printf("TermRepositoryImpl.UpdateTerm: %v", err)
-		return err
+		return fmt.Errorf("TermRepositoryImpl.UpdateTerm: %w", err)
 	}
 	defer func(tx *sqlx.Tx) {
-		err := tx.Rollback()
-		if err != nil {
-			log.Printf("TermRepositoryImpl.UpdateTerm: %v", err)
-		}
+		_ = tx.Rollback()
 	}(tx)
 
 	// 更新 terms 表
-	query := `UPDATE terms SET name = ?, explanation = ?, source_url = ?, updated_at = ? WHERE id = ?`
-	_, err = tx.ExecContext(ctx, query, term.Name, term.Explanation, term.SourceURL, time.Now(), term.ID)
+	searchNames, searchPinyin := searchColumns(term.Name, term.Aliases)
+	query := `UPDATE terms SET name = ?, aliases = ?, search_names = ?, search_pinyin = ?, search_version = ?,
+		explanation = ?, source_url = ?, updated_at = ? WHERE id = ?`
+	_, err = execContext(ctx, tx, "terms.update", query,
+		term.Name, encodeList(term.Aliases), searchNames, searchPinyin, termSearchVersion, term.Explanation, term.SourceURL, time.Now(), term.ID,
+	)
 	if err != nil {
-		log.Printf("TermRepositoryImpl.UpdateTerm: %v", err)
-		return err
+		return fmt.Errorf("TermRepositoryImpl.UpdateTerm: %w", err)
 	}
 
 	// 删除旧的关联
-	_, err = tx.ExecContext(ctx, `DELETE FROM term_category_relations WHERE term_id = ?`, term.ID)
+	_, err = execContext(ctx, tx, "term_category_relations.delete_by_term", `DELETE FROM term_category_relations WHERE term_id = ?`, term.ID)
 	if err != nil {
-		log.Printf("TermRepositoryImpl.UpdateTerm: %v", err)
-		return err
+		return fmt.Errorf("TermRepositoryImpl.UpdateTerm: %w", err)
 	}
 
 	// 插入新的关联
 	for _, categoryID := range categoryIDs {
-		_, err := tx.ExecContext(ctx, `INSERT INTO term_category_relations (term_id, category_id) VALUES (?, ?)`, term.ID, categoryID)
+		_, err := execContext(ctx, tx, "term_category_relations.insert", `INSERT INTO term_category_relations (term_id, category_id) VALUES (?, ?)`, term.ID, categoryID)
 		if err != nil {
-			log.Printf("TermRepositoryImpl.UpdateTerm: %v", err)
-			return err
+			return fmt.Errorf("TermRepositoryImpl.UpdateTerm: %w", categoryRelationError(err))
 		}
 	}
 
 	// 更新 terms 表的 category_list 字段
 	categoryList, _ := json.Marshal(categoryIDs)
-	_, err = tx.ExecContext(ctx, `UPDATE terms SET category_list = ? WHERE id = ?`, categoryList, term.ID)
+	_, err = execContext(ctx, tx, "terms.update_category_list", `UPDATE terms SET category_list = ? WHERE id = ?`, categoryList, term.ID)
+	if err != nil {
+		return fmt.Errorf("TermRepositoryImpl.UpdateTerm: %w", err)
+	}
+
+	// 追加修订版本, 内容没有变化时放弃整个事务
+	inserted, err := insertTermRevision(ctx, tx, term.ID, term, categoryIDs, change)
 	if err != nil {
-		log.Printf("TermRepositoryImpl.UpdateTerm: %v", err)
-		return err
+		return fmt.Errorf("TermRepositoryImpl.UpdateTerm: %w", err)
+	}
+	if !inserted {
+		return nil
 	}
 
 	if err := tx.Commit(); err != nil {
-		log.Printf("TermRepositoryImpl.UpdateTerm: %v", err)
-		return err
+		return fmt.Errorf("TermRepositoryImpl.UpdateTerm: %w", err)
 	}
 	return nil
 }
+
+// categoryRelationError 将插入关联时的外键冲突 (分类不存在, 例如检查之后被并发删除) 转换为 KindValidation 错误
+func categoryRelationError(err error) error {
+	if isForeignKeyViolation(err) {
+		return servererrors.NewValidationError("分类不存在", err)
+	}
+	return err
+}
+
+// RefreshSearchColumns 重新计算最多 limit 个检索列版本较旧的术语, 返回处理的术语数
+func (r *TermRepositoryImpl) RefreshSearchColumns(ctx context.Context, limit int) (int, error) {
+	n, err := refreshSearchColumns(ctx, r.db, limit)
+	if err != nil {
+		return 0, fmt.Errorf("TermRepositoryImpl.RefreshSearchColumns: %w", err)
+	}
+	return n, nil
+}
+
+// ListTermRevisions 列出术语的全部修订版本, 按版本号从新到旧排序, 不包含快照的内容
+func (r *TermRepositoryImpl) ListTermRevisions(ctx context.Context, termID int64) ([]model.TermRevision, error) {
+	revisions, err := listTermRevisions(ctx, r.db, termID)
+	if err != nil {
+		return nil, fmt.Errorf("TermRepositoryImpl.ListTermRevisions: %w", err)
+	}
+	return revisions, nil
+}
+
+// GetTermRevision 获取术语的一个修订版本, 不存在时返回 nil
+func (r *TermRepositoryImpl) GetTermRevision(ctx context.Context, termID int64, revision int) (*model.TermRevision, error) {
+	result, err := getTermRevision(ctx, r.db, termID, revision)
+	if err != nil {
+		return nil, fmt.Errorf("TermRepositoryImpl.GetTermRevision: %w", err)
+	}
+	return result, nil
+}
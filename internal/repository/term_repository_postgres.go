@@ -0,0 +1,281 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"skymates-api/internal/model"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// PostgresTermRepository 实现了 TermRepository 接口, 使用 PostgreSQL 数据库
+type PostgresTermRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresTermRepository 返回一个基于 PostgreSQL 的术语存储库
+func NewPostgresTermRepository(db *sqlx.DB) TermRepository {
+	return &PostgresTermRepository{db: db}
+}
+
+// SearchTerms 在名称, 别名和解释中搜索术语
+// search_vector 的 GIN 索引按词前缀匹配名称, 别名和解释并给出相关度; 'simple' 配置不切分中文,
+// 名称和别名的任意子串由 search_names 上的 pg_trgm 索引匹配, 解释只按词前缀匹配
+func (r *PostgresTermRepository) SearchTerms(ctx context.Context, query model.TermSearchQuery) (*model.TermSearchResult, error) {
+	if query.Mode == model.SearchModePinyin {
+		result, err := searchTermsByPinyin(ctx, r.db, query)
+		if err != nil {
+			return nil, fmt.Errorf("PostgresTermRepository.SearchTerms: %w", err)
+		}
+		return result, nil
+	}
+
+	p := newSearchPatterns(query.Keyword)
+	tsquery := postgresPrefixQuery(query.Keyword)
+
+	var result model.TermSearchResult
+	countQuery := `SELECT COUNT(*) FROM terms t WHERE ` + postgresSearchMatch("$1", "$2")
+	countArgs := []interface{}{p.names, tsquery}
+	if query.CategoryID != nil {
+		countQuery += ` AND EXISTS (SELECT 1 FROM term_category_relations r WHERE r.term_id = t.id AND r.category_id = $3)`
+		countArgs = append(countArgs, *query.CategoryID)
+	}
+	if err := getContext(ctx, r.db, "terms.search_count", &result.Total, countQuery, countArgs...); err != nil {
+		return nil, fmt.Errorf("PostgresTermRepository.SearchTerms: %w", err)
+	}
+
+	// 分类统计不受分类过滤的影响, 便于切换分类
+	facetsQuery := `SELECT r.category_id, COUNT(*) AS term_count FROM terms t
+		JOIN term_category_relations r ON r.term_id = t.id
+		WHERE ` + postgresSearchMatch("$1", "$2") + ` GROUP BY r.category_id ORDER BY term_count DESC, r.category_id ASC`
+	err := selectContext(ctx, r.db, "terms.search_facets", &result.Facets, facetsQuery, p.names, tsquery)
+	if err != nil {
+		return nil, fmt.Errorf("PostgresTermRepository.SearchTerms: %w", err)
+	}
+
+	// match_rank 的取值见 model.MatchExact 等常量
+	hitsQuery := `SELECT t.id, t.name, t.aliases, t.explanation,
+			CASE WHEN t.search_names LIKE $1 ESCAPE '!' THEN 3
+				WHEN t.search_names LIKE $2 ESCAPE '!' THEN 2
+				WHEN t.search_names LIKE $3 ESCAPE '!' THEN 1
+				ELSE 0 END AS match_rank,
+			ts_rank(t.search_vector, to_tsquery('simple', $4)) AS score
+		FROM terms t WHERE ` + postgresSearchMatch("$3", "$4")
+	args := []interface{}{p.exact, p.prefix, p.names, tsquery}
+	if query.CategoryID != nil {
+		hitsQuery += ` AND EXISTS (SELECT 1 FROM term_category_relations r WHERE r.term_id = t.id AND r.category_id = $5)`
+		args = append(args, *query.CategoryID)
+	}
+	hitsQuery += fmt.Sprintf(` ORDER BY match_rank DESC, score DESC, t.id ASC LIMIT $%d OFFSET $%d`, len(args)+1, len(args)+2)
+	args = append(args, query.Limit, query.Offset)
+	var rows []termSearchRow
+	if err := selectContext(ctx, r.db, "terms.search", &rows, hitsQuery, args...); err != nil {
+		return nil, fmt.Errorf("PostgresTermRepository.SearchTerms: %w", err)
+	}
+	result.Hits = searchHits(rows)
+	return &result, nil
+}
+
+// postgresSearchMatch 返回搜索条件, names 和 tsquery 是对应参数的占位符
+// 两个条件分别使用 search_vector 和 search_names 上的 GIN 索引; search_names 已经转为小写, 用 LIKE 比较即可
+func postgresSearchMatch(names, tsquery string) string {
+	return fmt.Sprintf(`(t.search_vector @@ to_tsquery('simple', %[2]s) OR t.search_names LIKE %[1]s ESCAPE '!')`,
+		names, tsquery)
+}
+
+// postgresPrefixQuery 把关键字转换为 to_tsquery 的输入: 按字母和数字以外的字符分词, 每个词按前缀匹配且都必须出现
+// 词中只有字母和数字, 不会被当作 tsquery 的运算符; 没有词时返回空字符串, 不匹配任何术语
+func postgresPrefixQuery(keyword string) string {
+	words := strings.FieldsFunc(strings.ToLower(keyword), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	})
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+// GetTermByID 根据 ID 获取术语详情
+func (r *PostgresTermRepository) GetTermByID(ctx context.Context, id int64) (*model.TermDetail, error) {
+	query := `SELECT id, name, aliases, explanation, source_url, created_by, created_at, updated_at FROM terms WHERE id = $1`
+	var row termDetailRow
+	err := getContext(ctx, r.db, "terms.get_by_id", &row, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("PostgresTermRepository.GetTermByID: %w", err)
+	}
+
+	// 获取关联的分类 ID
+	categoryQuery := `SELECT category_id FROM term_category_relations WHERE term_id = $1 ORDER BY category_id`
+	var categoryIDs []int64
+	err = selectContext(ctx, r.db, "term_category_relations.list_by_term", &categoryIDs, categoryQuery, id)
+	if err != nil {
+		return nil, fmt.Errorf("PostgresTermRepository.GetTermByID: %w", err)
+	}
+	term := row.TermDetail
+	term.Aliases = decodeList(row.AliasList)
+	term.CategoryIDs = categoryIDs
+	return &term, nil
+}
+
+// ListTermNames 按 ID 顺序分批列出 ID 大于 afterID 的术语的名称和别名
+func (r *PostgresTermRepository) ListTermNames(ctx context.Context, afterID int64, limit int) ([]model.TermNames, error) {
+	query := `SELECT id, name, aliases FROM terms WHERE id > $1 ORDER BY id ASC LIMIT $2`
+	var rows []termNamesRow
+	if err := selectContext(ctx, r.db, "terms.list_names", &rows, query, afterID, limit); err != nil {
+		return nil, fmt.Errorf("PostgresTermRepository.ListTermNames: %w", err)
+	}
+	names := make([]model.TermNames, len(rows))
+	for i, row := range rows {
+		names[i] = row.TermNames
+		names[i].Aliases = decodeList(row.AliasList)
+	}
+	return names, nil
+}
+
+// ListTermsByCategory 列出属于任一指定分类的术语, 按 ID 排序
+func (r *PostgresTermRepository) ListTermsByCategory(ctx context.Context, categoryIDs []int64, lastID *int64, limit int) ([]model.Term, bool, error) {
+	terms, hasMore, err := listTermsByCategories(ctx, r.db, categoryIDs, lastID, limit)
+	if err != nil {
+		return nil, false, fmt.Errorf("PostgresTermRepository.ListTermsByCategory: %w", err)
+	}
+	return terms, hasMore, nil
+}
+
+// CreateTerm 创建新术语并关联分类, 同时写入第 1 个修订版本
+func (r *PostgresTermRepository) CreateTerm(ctx context.Context, term *model.Term, categoryIDs []int64, change model.TermChange) (int64, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("PostgresTermRepository.CreateTerm: %w", err)
+	}
+	defer func(tx *sqlx.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	// 插入 terms 表, 分类 ID 列表以 jsonb 冗余保存在 category_list 字段
+	categoryList, _ := json.Marshal(categoryIDs)
+	now := time.Now()
+	searchNames, searchPinyin := searchColumns(term.Name, term.Aliases)
+	query := `INSERT INTO terms (name, aliases, search_names, search_pinyin, search_version, explanation, source_url, category_list, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8::jsonb, $9, $10, $11) RETURNING id`
+	var id int64
+	err = getContext(ctx, tx, "terms.insert", &id, query,
+		term.Name, encodeList(term.Aliases), searchNames, searchPinyin, termSearchVersion,
+		term.Explanation, term.SourceURL, string(categoryList), term.CreatedBy, now, now,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("PostgresTermRepository.CreateTerm: %w", err)
+	}
+
+	// 插入 term_category_relations 表
+	if err := r.insertCategoryRelations(ctx, tx, id, categoryIDs); err != nil {
+		return 0, fmt.Errorf("PostgresTermRepository.CreateTerm: %w", err)
+	}
+
+	// 插入第 1 个修订版本
+	if _, err := insertTermRevision(ctx, tx, id, term, categoryIDs, change); err != nil {
+		return 0, fmt.Errorf("PostgresTermRepository.CreateTerm: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("PostgresTermRepository.CreateTerm: %w", err)
+	}
+	return id, nil
+}
+
+// UpdateTerm 更新术语并更新关联分类, 同时追加一个修订版本; 内容与最新的版本相同时不做任何修改
+func (r *PostgresTermRepository) UpdateTerm(ctx context.Context, term *model.Term, categoryIDs []int64, change model.TermChange) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("PostgresTermRepository.UpdateTerm: %w", err)
+	}
+	defer func(tx *sqlx.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	// 更新 terms 表
+	categoryList, _ := json.Marshal(categoryIDs)
+	searchNames, searchPinyin := searchColumns(term.Name, term.Aliases)
+	query := `UPDATE terms SET name = $1, aliases = $2, search_names = $3, search_pinyin = $4, search_version = $5,
+		explanation = $6, source_url = $7, category_list = $8::jsonb, updated_at = $9 WHERE id = $10`
+	_, err = execContext(ctx, tx, "terms.update", query,
+		term.Name, encodeList(term.Aliases), searchNames, searchPinyin, termSearchVersion,
+		term.Explanation, term.SourceURL, string(categoryList), time.Now(), term.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("PostgresTermRepository.UpdateTerm: %w", err)
+	}
+
+	// 删除旧的关联
+	_, err = execContext(ctx, tx, "term_category_relations.delete_by_term", `DELETE FROM term_category_relations WHERE term_id = $1`, term.ID)
+	if err != nil {
+		return fmt.Errorf("PostgresTermRepository.UpdateTerm: %w", err)
+	}
+
+	// 插入新的关联
+	if err := r.insertCategoryRelations(ctx, tx, term.ID, categoryIDs); err != nil {
+		return fmt.Errorf("PostgresTermRepository.UpdateTerm: %w", err)
+	}
+
+	// 追加修订版本, 内容没有变化时放弃整个事务
+	inserted, err := insertTermRevision(ctx, tx, term.ID, term, categoryIDs, change)
+	if err != nil {
+		return fmt.Errorf("PostgresTermRepository.UpdateTerm: %w", err)
+	}
+	if !inserted {
+		return nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("PostgresTermRepository.UpdateTerm: %w", err)
+	}
+	return nil
+}
+
+// RefreshSearchColumns 重新计算最多 limit 个检索列版本较旧的术语, 返回处理的术语数
+func (r *PostgresTermRepository) RefreshSearchColumns(ctx context.Context, limit int) (int, error) {
+	n, err := refreshSearchColumns(ctx, r.db, limit)
+	if err != nil {
+		return 0, fmt.Errorf("PostgresTermRepository.RefreshSearchColumns: %w", err)
+	}
+	return n, nil
+}
+
+// insertCategoryRelations 在事务中插入术语与分类的关联, 分类不存在时返回 KindValidation 错误
+func (r *PostgresTermRepository) insertCategoryRelations(ctx context.Context, tx *sqlx.Tx, termID int64, categoryIDs []int64) error {
+	for _, categoryID := range categoryIDs {
+		_, err := execContext(ctx, tx, "term_category_relations.insert",
+			`INSERT INTO term_category_relations (term_id, category_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+			termID, categoryID)
+		if err != nil {
+			return categoryRelationError(err)
+		}
+	}
+	return nil
+}
+
+// ListTermRevisions 列出术语的全部修订版本, 按版本号从新到旧排序, 不包含快照的内容
+func (r *PostgresTermRepository) ListTermRevisions(ctx context.Context, termID int64) ([]model.TermRevision, error) {
+	revisions, err := listTermRevisions(ctx, r.db, termID)
+	if err != nil {
+		return nil, fmt.Errorf("PostgresTermRepository.ListTermRevisions: %w", err)
+	}
+	return revisions, nil
+}
+
+// GetTermRevision 获取术语的一个修订版本, 不存在时返回 nil
+func (r *PostgresTermRepository) GetTermRevision(ctx context.Context, termID int64, revision int) (*model.TermRevision, error) {
+	result, err := getTermRevision(ctx, r.db, termID, revision)
+	if err != nil {
+		return nil, fmt.Errorf("PostgresTermRepository.GetTermRevision: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"skymates-api/internal/model"
+	"slices"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 修订版本由 CreateTerm 和 UpdateTerm 在同一个事务中写入, 三种数据库的 SQL 相同, 占位符由 Rebind 转换
+
+// termRevisionRow 修订版本的查询结果, 列表形式的列解码后放入 TermRevision 的对应字段
+type termRevisionRow struct {
+	model.TermRevision
+	AliasList         string `db:"aliases"`
+	CategoryList      string `db:"category_ids"`
+	ChangedFieldsList string `db:"changed_fields"`
+}
+
+func (row termRevisionRow) revision() model.TermRevision {
+	revision := row.TermRevision
+	revision.Aliases = decodeList(row.AliasList)
+	revision.ChangedFields = decodeList(row.ChangedFieldsList)
+	// 迁移前没有分类的术语 category_list 可能是 NULL 或 "null"
+	_ = json.Unmarshal([]byte(row.CategoryList), &revision.CategoryIDs)
+	if revision.CategoryIDs == nil {
+		revision.CategoryIDs = []int64{}
+	}
+	return revision
+}
+
+// insertTermRevision 在事务 tx 中为术语追加一个修订版本, 快照为写入后的 term 和 categoryIDs, 返回是否写入了版本
+// 修改了的字段与最新的版本比较得出; 内容与最新的版本完全相同时不写入, 调用方应放弃整个事务
+// 读取最新版本之前先锁定术语的行, 并发修改同一术语的事务在这里排队, 读到的版本已经包含先提交的修改;
+// 锁要在事务的第一次普通读取之前取得, 否则 MySQL 的可重复读仍然读到旧的快照
+func insertTermRevision(ctx context.Context, tx *sqlx.Tx, termID int64, term *model.Term, categoryIDs []int64, change model.TermChange) (bool, error) {
+	query := tx.Rebind(`UPDATE terms SET updated_at = updated_at WHERE id = ?`)
+	if _, err := execContext(ctx, tx, "terms.lock_for_revision", query, termID); err != nil {
+		return false, err
+	}
+
+	sorted := slices.Compact(slices.Sorted(slices.Values(categoryIDs)))
+	if sorted == nil {
+		sorted = []int64{}
+	}
+	next := model.TermRevision{
+		Revision:    1,
+		Name:        term.Name,
+		Aliases:     term.Aliases,
+		Explanation: term.Explanation,
+		SourceURL:   term.SourceURL,
+		CategoryIDs: sorted,
+	}
+	changed := model.TermFields
+
+	// 新建的术语还没有版本, 所有字段都记为修改了
+	var latest termRevisionRow
+	query = tx.Rebind(`SELECT revision, name, aliases, explanation, source_url, category_ids
+		FROM term_revisions WHERE term_id = ? ORDER BY revision DESC LIMIT 1`)
+	err := getContext(ctx, tx, "term_revisions.get_latest", &latest, query, termID)
+	switch {
+	case err == nil:
+		previous := latest.revision()
+		next.Revision = previous.Revision + 1
+		if changed = changedFields(&previous, &next); len(changed) == 0 {
+			return false, nil
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		return false, err
+	}
+
+	categoryList, _ := json.Marshal(sorted)
+	query = tx.Rebind(`INSERT INTO term_revisions (term_id, revision, name, aliases, explanation, source_url, category_ids,
+			changed_fields, summary, restored_revision, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	_, err = execContext(ctx, tx, "term_revisions.insert", query,
+		termID, next.Revision, term.Name, encodeList(term.Aliases), term.Explanation, term.SourceURL, string(categoryList),
+		encodeList(changed), change.Summary, change.RestoredRevision, change.AuthorID, time.Now(),
+	)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// changedFields 返回 next 与 previous 相比值不同的字段, 两者的分类 ID 都已按 ID 排序且没有重复
+func changedFields(previous, next *model.TermRevision) []string {
+	var fields []string
+	if next.Name != previous.Name {
+		fields = append(fields, model.TermFieldName)
+	}
+	if !slices.Equal(next.Aliases, previous.Aliases) {
+		fields = append(fields, model.TermFieldAliases)
+	}
+	if next.Explanation != previous.Explanation {
+		fields = append(fields, model.TermFieldExplanation)
+	}
+	if next.SourceURL != previous.SourceURL {
+		fields = append(fields, model.TermFieldSourceURL)
+	}
+	if !slices.Equal(next.CategoryIDs, previous.CategoryIDs) {
+		fields = append(fields, model.TermFieldCategoryIDs)
+	}
+	return fields
+}
+
+// listTermRevisions 实现 ListTermRevisions, 按版本号从新到旧排序, 不包含快照的内容
+func listTermRevisions(ctx context.Context, db *sqlx.DB, termID int64) ([]model.TermRevision, error) {
+	query := db.Rebind(`SELECT id, term_id, revision, changed_fields, summary, restored_revision, created_by, created_at
+		FROM term_revisions WHERE term_id = ? ORDER BY revision DESC`)
+	var rows []termRevisionRow
+	if err := selectContext(ctx, db, "term_revisions.list_by_term", &rows, query, termID); err != nil {
+		return nil, err
+	}
+	revisions := make([]model.TermRevision, len(rows))
+	for i, row := range rows {
+		revisions[i] = row.TermRevision
+		revisions[i].ChangedFields = decodeList(row.ChangedFieldsList)
+	}
+	return revisions, nil
+}
+
+// getTermRevision 实现 GetTermRevision, 版本不存在时返回 nil
+func getTermRevision(ctx context.Context, db *sqlx.DB, termID int64, revision int) (*model.TermRevision, error) {
+	query := db.Rebind(`SELECT id, term_id, revision, name, aliases, explanation, source_url, category_ids,
+			changed_fields, summary, restored_revision, created_by, created_at
+		FROM term_revisions WHERE term_id = ? AND revision = ?`)
+	var row termRevisionRow
+	if err := getContext(ctx, db, "term_revisions.get", &row, query, termID, revision); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	result := row.revision()
+	return &result, nil
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	servererrors "skymates-api/errors"
+	"skymates-api/internal/model"
+	"time"
+)
+
+// PostgresTokenRepository 实现了 TokenRepository 接口, 使用 PostgreSQL 数据库
+type PostgresTokenRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresTokenRepository 返回一个基于 PostgreSQL 的令牌存储库
+func NewPostgresTokenRepository(db *sqlx.DB) TokenRepository {
+	return &PostgresTokenRepository{db: db}
+}
+
+// CreateRefreshToken 保存新的刷新令牌, 成功后回填 ID
+func (r *PostgresTokenRepository) CreateRefreshToken(ctx context.Context, token *model.RefreshToken) error {
+	return r.insertRefreshToken(ctx, r.db, token)
+}
+
+// GetRefreshTokenByHash 根据令牌哈希查询刷新令牌
+// 如果未找到, 返回 NotFoundError
+func (r *PostgresTokenRepository) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	query := `SELECT id, user_id, family_id, token_hash, expires_at, used_at, revoked_at, created_at
+		FROM refresh_tokens WHERE token_hash = $1`
+
+	var token model.RefreshToken
+	err := getContext(ctx, r.db, "refresh_tokens.get_by_hash", &token, query, tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, servererrors.NewNotFoundError("刷新令牌不存在", err)
+		}
+		return nil, servererrors.NewInternalError("查询刷新令牌失败", err)
+	}
+	return &token, nil
+}
+
+// RotateRefreshToken 在同一事务中将旧令牌标记为已使用并保存新令牌
+// 如果旧令牌已被使用或吊销 (比如并发刷新), 返回 false 且不保存新令牌
+func (r *PostgresTokenRepository) RotateRefreshToken(ctx context.Context, oldID int64, next *model.RefreshToken) (bool, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, servererrors.NewInternalError("开启事务失败", err)
+	}
+	defer func(tx *sqlx.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	result, err := execContext(ctx, tx, "refresh_tokens.mark_used",
+		`UPDATE refresh_tokens SET used_at = $1 WHERE id = $2 AND used_at IS NULL AND revoked_at IS NULL`,
+		time.Now(), oldID)
+	if err != nil {
+		return false, servererrors.NewInternalError("更新刷新令牌失败", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, servererrors.NewInternalError("更新刷新令牌失败", err)
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	if err := r.insertRefreshToken(ctx, tx, next); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, servererrors.NewInternalError("提交事务失败", err)
+	}
+	return true, nil
+}
+
+// RevokeRefreshTokenFamily 吊销同一 family 下所有尚未吊销的刷新令牌
+func (r *PostgresTokenRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	_, err := execContext(ctx, r.db, "refresh_tokens.revoke_family",
+		`UPDATE refresh_tokens SET revoked_at = $1 WHERE family_id = $2 AND revoked_at IS NULL`,
+		time.Now(), familyID)
+	if err != nil {
+		return servererrors.NewInternalError("吊销刷新令牌失败", err)
+	}
+	return nil
+}
+
+// RevokeUserRefreshTokens 吊销用户所有尚未吊销的刷新令牌, 即结束用户的所有会话
+// exceptFamilyID 不为空时保留该会话, 用于修改密码后只让其他设备重新登录
+func (r *PostgresTokenRepository) RevokeUserRefreshTokens(ctx context.Context, userID int64, exceptFamilyID string) error {
+	_, err := execContext(ctx, r.db, "refresh_tokens.revoke_user",
+		`UPDATE refresh_tokens SET revoked_at = $1 WHERE user_id = $2 AND family_id <> $3 AND revoked_at IS NULL`,
+		time.Now(), userID, exceptFamilyID)
+	if err != nil {
+		return servererrors.NewInternalError("吊销刷新令牌失败", err)
+	}
+	return nil
+}
+
+// RevokeAccessToken 记录被吊销的访问令牌 jti, 保存到令牌过期为止
+func (r *PostgresTokenRepository) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	_, err := execContext(ctx, r.db, "revoked_access_tokens.insert",
+		`INSERT INTO revoked_access_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING`,
+		jti, expiresAt)
+	if err != nil {
+		return servererrors.NewInternalError("吊销访问令牌失败", err)
+	}
+	return nil
+}
+
+// IsAccessTokenRevoked 检查访问令牌是否已被吊销
+func (r *PostgresTokenRepository) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+	err := getContext(ctx, r.db, "revoked_access_tokens.exists", &revoked, `SELECT EXISTS (SELECT 1 FROM revoked_access_tokens WHERE jti = $1)`, jti)
+	if err != nil {
+		return false, servererrors.NewInternalError("查询访问令牌状态失败", err)
+	}
+	return revoked, nil
+}
+
+// IsSessionRevoked 检查会话 (刷新令牌族) 是否已被吊销: 吊销会话时族中尚未吊销的刷新令牌都会记录吊销时间
+// 吊销记录随刷新令牌一起保存到刷新令牌过期为止, 比会话中的访问令牌保存得更久
+func (r *PostgresTokenRepository) IsSessionRevoked(ctx context.Context, familyID string) (bool, error) {
+	var revoked bool
+	err := getContext(ctx, r.db, "refresh_tokens.family_revoked", &revoked,
+		`SELECT EXISTS (SELECT 1 FROM refresh_tokens WHERE family_id = $1 AND revoked_at IS NOT NULL)`, familyID)
+	if err != nil {
+		return false, servererrors.NewInternalError("查询会话状态失败", err)
+	}
+	return revoked, nil
+}
+
+// DeleteExpiredTokens 删除 before 之前过期的刷新令牌和吊销记录, 返回删除的行数
+// 过期的访问令牌本身已无法通过验证, 不再需要吊销记录
+func (r *PostgresTokenRepository) DeleteExpiredTokens(ctx context.Context, before time.Time) (int64, error) {
+	var deleted int64
+	for _, statement := range []struct{ name, query string }{
+		{"refresh_tokens.delete_expired", `DELETE FROM refresh_tokens WHERE expires_at < $1`},
+		{"revoked_access_tokens.delete_expired", `DELETE FROM revoked_access_tokens WHERE expires_at < $1`},
+	} {
+		result, err := execContext(ctx, r.db, statement.name, statement.query, before)
+		if err != nil {
+			return deleted, servererrors.NewInternalError("清理过期令牌失败", err)
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return deleted, servererrors.NewInternalError("清理过期令牌失败", err)
+		}
+		deleted += affected
+	}
+	return deleted, nil
+}
+
+// insertRefreshToken 插入刷新令牌, 可以在事务内或事务外执行
+func (r *PostgresTokenRepository) insertRefreshToken(ctx context.Context, db sqlx.QueryerContext, token *model.RefreshToken) error {
+	if token.CreatedAt.IsZero() {
+		token.CreatedAt = time.Now()
+	}
+
+	query := `INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	err := getContext(ctx, db, "refresh_tokens.insert", &token.ID, query, token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt, token.CreatedAt)
+	if err != nil {
+		return servererrors.NewInternalError("保存刷新令牌失败", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"skymates-api/pkg/tracing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 以下函数包装 sqlx 的同名函数, 为每条 SQL 语句创建一个 span
+// statement 是语句的名称, 格式为 表名.操作, 例如 terms.get_by_id, 作为 span 名称和 db.statement.name 属性;
+// 不记录 SQL 参数, 避免把密码哈希和令牌写入追踪系统
+
+func getContext(ctx context.Context, q sqlx.QueryerContext, statement string, dest interface{}, query string, args ...interface{}) error {
+	ctx, span := startStatement(ctx, q, statement)
+	err := sqlx.GetContext(ctx, q, dest, query, args...)
+	endStatement(span, err)
+	return err
+}
+
+func selectContext(ctx context.Context, q sqlx.QueryerContext, statement string, dest interface{}, query string, args ...interface{}) error {
+	ctx, span := startStatement(ctx, q, statement)
+	err := sqlx.SelectContext(ctx, q, dest, query, args...)
+	endStatement(span, err)
+	return err
+}
+
+func execContext(ctx context.Context, e sqlx.ExecerContext, statement string, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startStatement(ctx, e, statement)
+	result, err := e.ExecContext(ctx, query, args...)
+	endStatement(span, err)
+	return result, err
+}
+
+func namedExecContext(ctx context.Context, e sqlx.ExtContext, statement string, query string, arg interface{}) (sql.Result, error) {
+	ctx, span := startStatement(ctx, e, statement)
+	result, err := sqlx.NamedExecContext(ctx, e, query, arg)
+	endStatement(span, err)
+	return result, err
+}
+
+// dbSystems database/sql 驱动名对应的 db.system 属性, 取值遵循 OpenTelemetry 语义约定
+var dbSystems = map[string]string{
+	sqlDriverNames[DriverMySQL]:    "mysql",
+	sqlDriverNames[DriverPostgres]: "postgresql",
+	sqlDriverNames[DriverSQLite]:   "sqlite",
+}
+
+func startStatement(ctx context.Context, db interface{}, statement string) (context.Context, *tracing.Span) {
+	attrs := []tracing.Attribute{tracing.String("db.statement.name", statement)}
+	// *sqlx.DB 和 *sqlx.Tx 都可以返回驱动名
+	if d, ok := db.(interface{ DriverName() string }); ok {
+		attrs = append(attrs, tracing.String("db.system", dbSystems[d.DriverName()]))
+	}
+	return tracing.Start(ctx, statement, tracing.WithKind(tracing.SpanKindClient), tracing.WithAttributes(attrs...))
+}
+
+// endStatement 结束 span, 查询不到记录是正常结果, 不标记为失败
+func endStatement(span *tracing.Span, err error) {
+	if !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+	}
+	span.End()
+}
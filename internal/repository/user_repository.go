@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"github.com/jmoiron/sqlx"
@@ -21,9 +22,22 @@ const (
 
 // UserRepository 定义用户存储库接口
 type UserRepository interface {
-	Create(user *model.User) error
-	GetUserBy(queryType QueryType, value string) (*model.User, error)
-	CheckExists(queryType QueryType, value string) (bool, error)
+	Create(ctx context.Context, user *model.User) error
+	GetUserBy(ctx context.Context, queryType QueryType, value string) (*model.User, error)
+	CheckExists(ctx context.Context, queryType QueryType, value string) (bool, error)
+
+	// RecordLoginFailure 将用户连续登录失败的次数加一, 返回加一后的次数
+	// 计数在数据库中原子地递增, 并发的失败登录不会丢失计数
+	RecordLoginFailure(ctx context.Context, id int64) (int, error)
+	// LockUser 将用户锁定到 until, 锁定期间不允许登录
+	LockUser(ctx context.Context, id int64, until time.Time) error
+	// ResetLoginFailures 清空用户的登录失败次数和锁定时间
+	ResetLoginFailures(ctx context.Context, id int64) error
+
+	ChangePassword(ctx context.Context, id int64, hashedPassword, exceptSessionID string) error
+	MarkEmailVerified(ctx context.Context, id int64, at time.Time) error
+	// UpdateProfile 保存用户的用户名, 邮箱, 邮箱确认时间和头像
+	UpdateProfile(ctx context.Context, user *model.User) error
 }
 
 // MySQLUserRepository 实现了 UserRepository 接口, 使用 MySQL 数据库
@@ -37,10 +51,9 @@ func NewUserRepository(db *sqlx.DB) UserRepository {
 }
 
 // Create 创建新用户
-// 如果用户ID为空, 会生成一个新的 UUID
-// 设置创建和更新时间, 并插入到 users 表
+// 设置创建和更新时间, 并插入到 users 表, 成功后回填用户 ID
 // 如果用户名或邮箱已存在, 返回 AlreadyExistsError
-func (r *MySQLUserRepository) Create(user *model.User) error {
+func (r *MySQLUserRepository) Create(ctx context.Context, user *model.User) error {
 	// 处理时间戳
 	now := time.Now()
 	// 如果模型没有设置创建时间, 则填充
@@ -49,13 +62,26 @@ func (r *MySQLUserRepository) Create(user *model.User) error {
 	}
 	user.UpdatedAt = now
 
+	// 未指定角色时默认为普通用户
+	if user.Role == "" {
+		user.Role = model.RoleUser
+	}
+
 	// 执行插入操作
-	query := `INSERT INTO users (username, hashed_password, email, avatar_url, created_at, updated_at)
-		VALUES (:username, :hashed_password, :email, :avatar_url, :created_at, :updated_at)`
-	_, err := r.db.NamedExec(query, user)
+	query := `INSERT INTO users (username, hashed_password, email, avatar_url, role, created_at, updated_at)
+		VALUES (:username, :hashed_password, :email, :avatar_url, :role, :created_at, :updated_at)`
+	result, err := namedExecContext(ctx, r.db, "users.insert", query, user)
 	if err != nil {
+		if isDuplicateKey(err) {
+			return servererrors.NewAlreadyExistsError("用户名或邮箱已存在", err)
+		}
 		return servererrors.NewInternalError("创建用户失败", err)
 	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return servererrors.NewInternalError("获取用户 ID 失败", err)
+	}
+	user.ID = id
 	return nil
 }
 
@@ -63,24 +89,24 @@ func (r *MySQLUserRepository) Create(user *model.User) error {
 // 支持按用户名, 邮箱或ID查询
 // 如果未找到, 返回 NotFoundError
 // 查询失败时, 返回 InternalError
-func (r *MySQLUserRepository) GetUserBy(queryType QueryType, value string) (*model.User, error) {
+func (r *MySQLUserRepository) GetUserBy(ctx context.Context, queryType QueryType, value string) (*model.User, error) {
 	var query string
 	switch queryType {
 	case QueryByUsername:
-		query = `SELECT id, username, hashed_password, email, avatar_url, created_at, updated_at
+		query = `SELECT id, username, hashed_password, email, email_verified_at, avatar_url, role, failed_login_attempts, locked_until, created_at, updated_at
 			FROM users WHERE username = ?`
 	case QueryByEmail:
-		query = `SELECT id, username, hashed_password, email, avatar_url, created_at, updated_at
+		query = `SELECT id, username, hashed_password, email, email_verified_at, avatar_url, role, failed_login_attempts, locked_until, created_at, updated_at
 			FROM users WHERE email = ?`
 	case QueryByID:
-		query = `SELECT id, username, hashed_password, email, avatar_url, created_at, updated_at
+		query = `SELECT id, username, hashed_password, email, email_verified_at, avatar_url, role, failed_login_attempts, locked_until, created_at, updated_at
 			FROM users WHERE id = ?`
 	default:
 		return nil, servererrors.NewInternalError("无效的查询类型", nil)
 	}
 
 	var user model.User
-	err := r.db.Get(&user, query, value)
+	err := getContext(ctx, r.db, "users.get_by", &user, query, value)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, servererrors.NewNotFoundError("用户未找到", err)
@@ -93,7 +119,7 @@ func (r *MySQLUserRepository) GetUserBy(queryType QueryType, value string) (*mod
 // CheckExists 检查用户是否存在
 // 使用 COUNT(1) 判断是否有匹配记录
 // 如果查询失败, 返回 InternalError
-func (r *MySQLUserRepository) CheckExists(queryType QueryType, value string) (bool, error) {
+func (r *MySQLUserRepository) CheckExists(ctx context.Context, queryType QueryType, value string) (bool, error) {
 	var query string
 	switch queryType {
 	case QueryByUsername:
@@ -105,7 +131,7 @@ func (r *MySQLUserRepository) CheckExists(queryType QueryType, value string) (bo
 	}
 
 	var count int
-	err := r.db.Get(&count, query, value)
+	err := getContext(ctx, r.db, "users.exists", &count, query, value)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return false, nil
@@ -114,3 +140,104 @@ func (r *MySQLUserRepository) CheckExists(queryType QueryType, value string) (bo
 	}
 	return count > 0, nil
 }
+
+// RecordLoginFailure 将用户连续登录失败的次数加一, 返回加一后的次数
+// MySQL 不支持 RETURNING, 通过 LAST_INSERT_ID(expr) 在同一条语句中取回递增后的值
+func (r *MySQLUserRepository) RecordLoginFailure(ctx context.Context, id int64) (int, error) {
+	result, err := execContext(ctx, r.db, "users.record_login_failure",
+		`UPDATE users SET failed_login_attempts = LAST_INSERT_ID(failed_login_attempts + 1) WHERE id = ?`, id)
+	if err != nil {
+		return 0, servererrors.NewInternalError("记录登录失败次数失败", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, servererrors.NewInternalError("记录登录失败次数失败", err)
+	}
+	if affected == 0 {
+		return 0, servererrors.NewNotFoundError("用户未找到", nil)
+	}
+	attempts, err := result.LastInsertId()
+	if err != nil {
+		return 0, servererrors.NewInternalError("记录登录失败次数失败", err)
+	}
+	return int(attempts), nil
+}
+
+// LockUser 将用户锁定到 until
+func (r *MySQLUserRepository) LockUser(ctx context.Context, id int64, until time.Time) error {
+	_, err := execContext(ctx, r.db, "users.lock",
+		`UPDATE users SET locked_until = ? WHERE id = ?`, until, id)
+	if err != nil {
+		return servererrors.NewInternalError("锁定用户失败", err)
+	}
+	return nil
+}
+
+// ResetLoginFailures 清空用户的登录失败次数和锁定时间
+func (r *MySQLUserRepository) ResetLoginFailures(ctx context.Context, id int64) error {
+	_, err := execContext(ctx, r.db, "users.reset_login_failures",
+		`UPDATE users SET failed_login_attempts = 0, locked_until = NULL WHERE id = ?`, id)
+	if err != nil {
+		return servererrors.NewInternalError("清空登录失败次数失败", err)
+	}
+	return nil
+}
+
+// ChangePassword 在同一事务中更新用户的密码哈希, 并吊销用户除 exceptSessionID 之外的所有会话 (刷新令牌族);
+// exceptSessionID 为空时吊销全部会话。会话被吊销后, 其中已签发的访问令牌也不再通过认证
+func (r *MySQLUserRepository) ChangePassword(ctx context.Context, id int64, hashedPassword, exceptSessionID string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return servererrors.NewInternalError("开启事务失败", err)
+	}
+	defer func(tx *sqlx.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	now := time.Now()
+	_, err = execContext(ctx, tx, "users.update_password",
+		`UPDATE users SET hashed_password = ?, updated_at = ? WHERE id = ?`,
+		hashedPassword, now, id)
+	if err != nil {
+		return servererrors.NewInternalError("更新密码失败", err)
+	}
+	_, err = execContext(ctx, tx, "refresh_tokens.revoke_user",
+		`UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND family_id <> ? AND revoked_at IS NULL`,
+		now, id, exceptSessionID)
+	if err != nil {
+		return servererrors.NewInternalError("吊销刷新令牌失败", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return servererrors.NewInternalError("提交事务失败", err)
+	}
+	return nil
+}
+
+// MarkEmailVerified 记录用户确认邮箱的时间, 已经确认过的用户保留第一次确认的时间
+func (r *MySQLUserRepository) MarkEmailVerified(ctx context.Context, id int64, at time.Time) error {
+	_, err := execContext(ctx, r.db, "users.mark_email_verified",
+		`UPDATE users SET email_verified_at = ?, updated_at = ? WHERE id = ? AND email_verified_at IS NULL`,
+		at, at, id)
+	if err != nil {
+		return servererrors.NewInternalError("确认邮箱失败", err)
+	}
+	return nil
+}
+
+// UpdateProfile 保存用户的用户名, 邮箱, 邮箱确认时间和头像, 并更新 updated_at
+// 用户名或邮箱已被其他用户使用时返回 AlreadyExistsError
+func (r *MySQLUserRepository) UpdateProfile(ctx context.Context, user *model.User) error {
+	user.UpdatedAt = time.Now()
+	_, err := namedExecContext(ctx, r.db, "users.update_profile",
+		`UPDATE users SET username = :username, email = :email, email_verified_at = :email_verified_at,
+			avatar_url = :avatar_url, updated_at = :updated_at
+		WHERE id = :id`, user)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return servererrors.NewAlreadyExistsError("用户名或邮箱已存在", err)
+		}
+		return servererrors.NewInternalError("更新用户资料失败", err)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	servererrors "skymates-api/errors"
+	"skymates-api/internal/model"
+	"strconv"
+	"time"
+)
+
+// PostgresUserRepository 实现了 UserRepository 接口, 使用 PostgreSQL 数据库
+type PostgresUserRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresUserRepository 返回一个基于 PostgreSQL 的用户存储库
+func NewPostgresUserRepository(db *sqlx.DB) UserRepository {
+	return &PostgresUserRepository{db: db}
+}
+
+// Create 创建新用户
+// 设置创建和更新时间, 并插入到 users 表, 通过 RETURNING 回填用户 ID
+// 如果用户名或邮箱已存在, 返回 AlreadyExistsError
+func (r *PostgresUserRepository) Create(ctx context.Context, user *model.User) error {
+	now := time.Now()
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	user.UpdatedAt = now
+
+	if user.Role == "" {
+		user.Role = model.RoleUser
+	}
+
+	query := `INSERT INTO users (username, hashed_password, email, avatar_url, role, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	err := getContext(ctx, r.db, "users.insert", &user.ID, query,
+		user.Username, user.Password, user.Email, user.AvatarURL, user.Role, user.CreatedAt, user.UpdatedAt,
+	)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return servererrors.NewAlreadyExistsError("用户名或邮箱已存在", err)
+		}
+		return servererrors.NewInternalError("创建用户失败", err)
+	}
+	return nil
+}
+
+// GetUserBy 根据查询类型和值检索用户
+// 如果未找到, 返回 NotFoundError
+// 查询失败时, 返回 InternalError
+func (r *PostgresUserRepository) GetUserBy(ctx context.Context, queryType QueryType, value string) (*model.User, error) {
+	var query string
+	var arg interface{} = value
+	switch queryType {
+	case QueryByUsername:
+		query = `SELECT id, username, hashed_password, email, email_verified_at, avatar_url, role, failed_login_attempts, locked_until, created_at, updated_at
+			FROM users WHERE username = $1`
+	case QueryByEmail:
+		query = `SELECT id, username, hashed_password, email, email_verified_at, avatar_url, role, failed_login_attempts, locked_until, created_at, updated_at
+			FROM users WHERE email = $1`
+	case QueryByID:
+		// PostgreSQL 不会把文本参数隐式转换为 BIGINT, 需要先解析
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, servererrors.NewNotFoundError("用户未找到", err)
+		}
+		arg = id
+		query = `SELECT id, username, hashed_password, email, email_verified_at, avatar_url, role, failed_login_attempts, locked_until, created_at, updated_at
+			FROM users WHERE id = $1`
+	default:
+		return nil, servererrors.NewInternalError("无效的查询类型", nil)
+	}
+
+	var user model.User
+	err := getContext(ctx, r.db, "users.get_by", &user, query, arg)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, servererrors.NewNotFoundError("用户未找到", err)
+		}
+		return nil, servererrors.NewInternalError("查询用户失败", err)
+	}
+	return &user, nil
+}
+
+// CheckExists 检查用户是否存在
+// 如果查询失败, 返回 InternalError
+func (r *PostgresUserRepository) CheckExists(ctx context.Context, queryType QueryType, value string) (bool, error) {
+	var query string
+	switch queryType {
+	case QueryByUsername:
+		query = `SELECT EXISTS (SELECT 1 FROM users WHERE username = $1)`
+	case QueryByEmail:
+		query = `SELECT EXISTS (SELECT 1 FROM users WHERE email = $1)`
+	default:
+		return false, servererrors.NewInternalError("无效的查询类型", nil)
+	}
+
+	var exists bool
+	if err := getContext(ctx, r.db, "users.exists", &exists, query, value); err != nil {
+		return false, servererrors.NewInternalError("检查用户存在性失败", err)
+	}
+	return exists, nil
+}
+
+// RecordLoginFailure 将用户连续登录失败的次数加一, 通过 RETURNING 返回加一后的次数
+func (r *PostgresUserRepository) RecordLoginFailure(ctx context.Context, id int64) (int, error) {
+	var attempts int
+	err := getContext(ctx, r.db, "users.record_login_failure", &attempts,
+		`UPDATE users SET failed_login_attempts = failed_login_attempts + 1 WHERE id = $1 RETURNING failed_login_attempts`, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, servererrors.NewNotFoundError("用户未找到", err)
+		}
+		return 0, servererrors.NewInternalError("记录登录失败次数失败", err)
+	}
+	return attempts, nil
+}
+
+// LockUser 将用户锁定到 until
+func (r *PostgresUserRepository) LockUser(ctx context.Context, id int64, until time.Time) error {
+	_, err := execContext(ctx, r.db, "users.lock",
+		`UPDATE users SET locked_until = $1 WHERE id = $2`, until, id)
+	if err != nil {
+		return servererrors.NewInternalError("锁定用户失败", err)
+	}
+	return nil
+}
+
+// ResetLoginFailures 清空用户的登录失败次数和锁定时间
+func (r *PostgresUserRepository) ResetLoginFailures(ctx context.Context, id int64) error {
+	_, err := execContext(ctx, r.db, "users.reset_login_failures",
+		`UPDATE users SET failed_login_attempts = 0, locked_until = NULL WHERE id = $1`, id)
+	if err != nil {
+		return servererrors.NewInternalError("清空登录失败次数失败", err)
+	}
+	return nil
+}
+
+// ChangePassword 在同一事务中更新用户的密码哈希, 并吊销用户除 exceptSessionID 之外的所有会话 (刷新令牌族);
+// exceptSessionID 为空时吊销全部会话。会话被吊销后, 其中已签发的访问令牌也不再通过认证
+func (r *PostgresUserRepository) ChangePassword(ctx context.Context, id int64, hashedPassword, exceptSessionID string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return servererrors.NewInternalError("开启事务失败", err)
+	}
+	defer func(tx *sqlx.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	now := time.Now()
+	_, err = execContext(ctx, tx, "users.update_password",
+		`UPDATE users SET hashed_password = $1, updated_at = $2 WHERE id = $3`,
+		hashedPassword, now, id)
+	if err != nil {
+		return servererrors.NewInternalError("更新密码失败", err)
+	}
+	_, err = execContext(ctx, tx, "refresh_tokens.revoke_user",
+		`UPDATE refresh_tokens SET revoked_at = $1 WHERE user_id = $2 AND family_id <> $3 AND revoked_at IS NULL`,
+		now, id, exceptSessionID)
+	if err != nil {
+		return servererrors.NewInternalError("吊销刷新令牌失败", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return servererrors.NewInternalError("提交事务失败", err)
+	}
+	return nil
+}
+
+// MarkEmailVerified 记录用户确认邮箱的时间, 已经确认过的用户保留第一次确认的时间
+func (r *PostgresUserRepository) MarkEmailVerified(ctx context.Context, id int64, at time.Time) error {
+	_, err := execContext(ctx, r.db, "users.mark_email_verified",
+		`UPDATE users SET email_verified_at = $1, updated_at = $2 WHERE id = $3 AND email_verified_at IS NULL`,
+		at, at, id)
+	if err != nil {
+		return servererrors.NewInternalError("确认邮箱失败", err)
+	}
+	return nil
+}
+
+// UpdateProfile 保存用户的用户名, 邮箱, 邮箱确认时间和头像, 并更新 updated_at
+// 用户名或邮箱已被其他用户使用时返回 AlreadyExistsError
+func (r *PostgresUserRepository) UpdateProfile(ctx context.Context, user *model.User) error {
+	user.UpdatedAt = time.Now()
+	_, err := execContext(ctx, r.db, "users.update_profile",
+		`UPDATE users SET username = $1, email = $2, email_verified_at = $3, avatar_url = $4, updated_at = $5
+		WHERE id = $6`,
+		user.Username, user.Email, user.EmailVerifiedAt, user.AvatarURL, user.UpdatedAt, user.ID)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return servererrors.NewAlreadyExistsError("用户名或邮箱已存在", err)
+		}
+		return servererrors.NewInternalError("更新用户资料失败", err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	servererrors "skymates-api/errors"
+	"skymates-api/internal/model"
+	"time"
+)
+
+// UserTokenRepository 定义通过邮件发送的一次性令牌 (重置密码, 确认邮箱) 的存储库接口
+type UserTokenRepository interface {
+	Create(ctx context.Context, token *model.UserToken) error
+	Consume(ctx context.Context, purpose, tokenHash string, now time.Time) (*model.UserToken, error)
+	ResetPassword(ctx context.Context, tokenHash, hashedPassword string, now time.Time) (*model.UserToken, error)
+	Invalidate(ctx context.Context, userID int64, purpose string, now time.Time) error
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+// MySQLUserTokenRepository 实现了 UserTokenRepository 接口, 使用 MySQL 数据库
+type MySQLUserTokenRepository struct {
+	db *sqlx.DB
+}
+
+// NewUserTokenRepository 返回一个基于 MySQL 的一次性令牌存储库
+func NewUserTokenRepository(db *sqlx.DB) UserTokenRepository {
+	return &MySQLUserTokenRepository{db: db}
+}
+
+// Create 保存新的令牌, 成功后回填 ID
+func (r *MySQLUserTokenRepository) Create(ctx context.Context, token *model.UserToken) error {
+	if token.CreatedAt.IsZero() {
+		token.CreatedAt = time.Now()
+	}
+	result, err := namedExecContext(ctx, r.db, "user_tokens.insert",
+		`INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at, created_at)
+		VALUES (:user_id, :purpose, :token_hash, :expires_at, :created_at)`, token)
+	if err != nil {
+		return servererrors.NewInternalError("保存令牌失败", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return servererrors.NewInternalError("获取令牌 ID 失败", err)
+	}
+	token.ID = id
+	return nil
+}
+
+// Consume 将未使用且未过期的令牌标记为已使用并返回, 每个令牌只能成功使用一次
+// 令牌不存在, 已使用或已过期时返回 NotFoundError
+// 标记和判断在同一条 UPDATE 中完成, 并发使用同一个令牌时只有一个请求成功
+func (r *MySQLUserTokenRepository) Consume(ctx context.Context, purpose, tokenHash string, now time.Time) (*model.UserToken, error) {
+	return r.consume(ctx, r.db, purpose, tokenHash, now)
+}
+
+// ResetPassword 在同一事务中使用重置密码的令牌并设置新密码: 更新密码哈希, 清空登录失败次数,
+// 将邮箱标记为已验证, 吊销用户所有的刷新令牌并作废其他重置密码的令牌
+// 令牌不存在, 已使用或已过期时返回 NotFoundError, 不做任何修改
+func (r *MySQLUserTokenRepository) ResetPassword(ctx context.Context, tokenHash, hashedPassword string, now time.Time) (*model.UserToken, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, servererrors.NewInternalError("开启事务失败", err)
+	}
+	defer func(tx *sqlx.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	token, err := r.consume(ctx, tx, model.TokenPurposePasswordReset, tokenHash, now)
+	if err != nil {
+		return nil, err
+	}
+	// 能收到邮件说明用户拥有这个邮箱, 已经验证过的保留第一次验证的时间
+	_, err = execContext(ctx, tx, "users.reset_password",
+		`UPDATE users SET hashed_password = ?, failed_login_attempts = 0, locked_until = NULL,
+			email_verified_at = COALESCE(email_verified_at, ?), updated_at = ?
+		WHERE id = ?`,
+		hashedPassword, now, now, token.UserID)
+	if err != nil {
+		return nil, servererrors.NewInternalError("更新密码失败", err)
+	}
+	_, err = execContext(ctx, tx, "refresh_tokens.revoke_user",
+		`UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL`,
+		now, token.UserID)
+	if err != nil {
+		return nil, servererrors.NewInternalError("吊销刷新令牌失败", err)
+	}
+	if err := r.invalidate(ctx, tx, token.UserID, model.TokenPurposePasswordReset, now); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, servererrors.NewInternalError("提交事务失败", err)
+	}
+	return token, nil
+}
+
+// Invalidate 作废用户某种用途的所有未使用的令牌, 比如重新发送邮件时旧邮件中的链接随即失效
+func (r *MySQLUserTokenRepository) Invalidate(ctx context.Context, userID int64, purpose string, now time.Time) error {
+	return r.invalidate(ctx, r.db, userID, purpose, now)
+}
+
+// DeleteExpired 删除 before 之前过期的令牌, 返回删除的行数
+// 已使用但未过期的令牌保留到过期, 方便排查问题
+func (r *MySQLUserTokenRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	result, err := execContext(ctx, r.db, "user_tokens.delete_expired",
+		`DELETE FROM user_tokens WHERE expires_at < ?`, before)
+	if err != nil {
+		return 0, servererrors.NewInternalError("清理过期令牌失败", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, servererrors.NewInternalError("清理过期令牌失败", err)
+	}
+	return deleted, nil
+}
+
+// consume 标记并返回令牌, 可以在事务内或事务外执行
+func (r *MySQLUserTokenRepository) consume(ctx context.Context, db sqlx.ExtContext, purpose, tokenHash string, now time.Time) (*model.UserToken, error) {
+	result, err := execContext(ctx, db, "user_tokens.consume",
+		`UPDATE user_tokens SET used_at = ?
+		WHERE token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?`,
+		now, tokenHash, purpose, now)
+	if err != nil {
+		return nil, servererrors.NewInternalError("使用令牌失败", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, servererrors.NewInternalError("使用令牌失败", err)
+	}
+	if affected == 0 {
+		return nil, servererrors.NewNotFoundError("令牌不存在或已失效", nil)
+	}
+
+	var token model.UserToken
+	err = getContext(ctx, db, "user_tokens.get_by_hash", &token,
+		`SELECT id, user_id, purpose, token_hash, expires_at, used_at, created_at FROM user_tokens WHERE token_hash = ?`,
+		tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, servererrors.NewNotFoundError("令牌不存在或已失效", err)
+		}
+		return nil, servererrors.NewInternalError("查询令牌失败", err)
+	}
+	return &token, nil
+}
+
+// invalidate 作废令牌, 可以在事务内或事务外执行
+func (r *MySQLUserTokenRepository) invalidate(ctx context.Context, db sqlx.ExecerContext, userID int64, purpose string, now time.Time) error {
+	_, err := execContext(ctx, db, "user_tokens.invalidate",
+		`UPDATE user_tokens SET used_at = ? WHERE user_id = ? AND purpose = ? AND used_at IS NULL`,
+		now, userID, purpose)
+	if err != nil {
+		return servererrors.NewInternalError("作废令牌失败", err)
+	}
+	return nil
+}
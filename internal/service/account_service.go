@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"net/url"
+	"skymates-api/config"
+	servererrors "skymates-api/errors"
+	v1 "skymates-api/internal/dto/v1"
+	"skymates-api/internal/i18n"
+	"skymates-api/internal/model"
+	"skymates-api/internal/repository"
+	"skymates-api/pkg/auth"
+	"skymates-api/pkg/logging"
+	"skymates-api/pkg/mail"
+	"skymates-api/pkg/tracing"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccountService 定义找回密码和验证邮箱相关的业务逻辑接口
+// 两个流程都通过邮件发送一次性令牌, 数据库只保存令牌的哈希, 令牌使用一次或过期后失效
+type AccountService interface {
+	RequestPasswordReset(ctx context.Context, email string) error
+	SendPasswordResetMails(ctx context.Context) error
+	ResetPassword(ctx context.Context, resetDto v1.ResetPasswordDto) error
+	SendEmailVerification(ctx context.Context, userID int64) error
+	VerifyEmail(ctx context.Context, token string) error
+	OnEmailChanged(ctx context.Context, userID int64) error
+	CleanupExpiredTokens(ctx context.Context) error
+}
+
+// passwordResetQueueSize 等待处理的重置密码申请的数量上限
+const passwordResetQueueSize = 100
+
+// passwordResetRequest 排队等待处理的重置密码申请, ctx 是申请时请求的上下文
+type passwordResetRequest struct {
+	ctx   context.Context
+	email string
+}
+
+// accountService 实现 AccountService 接口
+type accountService struct {
+	userRepository      repository.UserRepository
+	userTokenRepository repository.UserTokenRepository
+	tokenRepository     repository.TokenRepository
+	mailer              mail.Mailer
+	cfg                 config.AccountConfig
+	passwordResets      chan passwordResetRequest
+}
+
+// NewAccountService 创建 AccountService 实例, 邮件中的链接和令牌有效期由 cfg 决定
+func NewAccountService(
+	userRepository repository.UserRepository,
+	userTokenRepository repository.UserTokenRepository,
+	tokenRepository repository.TokenRepository,
+	mailer mail.Mailer,
+	cfg config.AccountConfig,
+) AccountService {
+	return &accountService{
+		userRepository:      userRepository,
+		userTokenRepository: userTokenRepository,
+		tokenRepository:     tokenRepository,
+		mailer:              mailer,
+		cfg:                 cfg,
+		passwordResets:      make(chan passwordResetRequest, passwordResetQueueSize),
+	}
+}
+
+// RequestPasswordReset 将重置密码的申请放入队列后立即返回, 由 SendPasswordResetMails 查询用户并发送邮件
+// 邮箱是否注册, 邮件是否发送成功都不影响返回值和响应时间, 不能通过这个接口判断邮箱是否已注册
+// 队列满时丢弃申请, 接口本身有限流, 正常情况下不会发生
+func (s *accountService) RequestPasswordReset(ctx context.Context, email string) error {
+	ctx, span := tracing.Start(ctx, "AccountService.RequestPasswordReset")
+	defer span.End()
+
+	// 保留请求的语言, request_id 和 trace, 但不随请求结束而取消
+	request := passwordResetRequest{ctx: context.WithoutCancel(ctx), email: email}
+	select {
+	case s.passwordResets <- request:
+	default:
+		logging.FromContext(ctx).Warn("password reset queue is full, request dropped")
+	}
+	return nil
+}
+
+// SendPasswordResetMails 依次处理 RequestPasswordReset 放入队列的申请, 一直运行到 ctx 被取消, 由后台任务调用
+// 退出时还在队列中的申请被丢弃, 用户需要重新申请
+func (s *accountService) SendPasswordResetMails(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case request := <-s.passwordResets:
+			// 使用申请时的上下文读取语言等信息, 服务退出时取消
+			requestCtx, cancel := context.WithCancel(request.ctx)
+			stop := context.AfterFunc(ctx, cancel)
+			s.sendPasswordReset(requestCtx, request.email)
+			stop()
+			cancel()
+		}
+	}
+}
+
+// sendPasswordReset 生成重置密码的令牌并发送邮件, 邮箱未注册时什么都不做, 出错时只记录日志
+func (s *accountService) sendPasswordReset(ctx context.Context, email string) {
+	ctx, span := tracing.Start(ctx, "AccountService.SendPasswordResetMail")
+	defer span.End()
+
+	user, err := s.userRepository.GetUserBy(ctx, repository.QueryByEmail, email)
+	if err != nil {
+		if !errors.Is(err, &servererrors.ServerError{Kind: servererrors.KindNotFound}) {
+			logging.FromContext(ctx).Error("get user for password reset failed", "error", err)
+		}
+		return
+	}
+
+	token, err := s.issueToken(ctx, user.ID, model.TokenPurposePasswordReset, s.cfg.PasswordResetTTL)
+	if err != nil {
+		logging.FromContext(ctx).Error("issue password reset token failed", "user_id", user.ID, "error", err)
+		return
+	}
+	msg := s.newMessage(ctx, user, i18n.MailPasswordResetSubject, i18n.MailPasswordResetBody, "reset-password", token)
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		logging.FromContext(ctx).Error("send password reset mail failed", "user_id", user.ID, "error", err)
+	}
+}
+
+// ResetPassword 使用邮件中的令牌设置新密码
+// 成功后清空登录失败次数, 结束用户的所有会话并作废其他重置密码的链接;
+// 能收到邮件说明用户拥有这个邮箱, 同时将邮箱标记为已验证
+// 以上修改在同一事务中完成, 任何一步失败都不会生效
+func (s *accountService) ResetPassword(ctx context.Context, resetDto v1.ResetPasswordDto) error {
+	ctx, span := tracing.Start(ctx, "AccountService.ResetPassword")
+	defer span.End()
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(resetDto.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return servererrors.NewInternalError("密码加密失败", err)
+	}
+	// 已签发的访问令牌在过期前仍然有效, 刷新令牌全部吊销后最多 jwt.access_token_ttl 后所有会话结束
+	token, err := s.userTokenRepository.ResetPassword(ctx, auth.HashToken(resetDto.Token), string(hashedPassword), time.Now())
+	if err != nil {
+		if errors.Is(err, &servererrors.ServerError{Kind: servererrors.KindNotFound}) {
+			return servererrors.NewValidationError(i18n.MsgInvalidResetToken, nil)
+		}
+		return servererrors.NewInternalError("重置密码失败", err)
+	}
+
+	logging.FromContext(ctx).Info("password reset", "user_id", token.UserID)
+	return nil
+}
+
+// SendEmailVerification 向用户的邮箱发送验证链接, 之前发送的链接随即失效
+// 邮箱已经验证过时返回 ConflictError
+func (s *accountService) SendEmailVerification(ctx context.Context, userID int64) error {
+	ctx, span := tracing.Start(ctx, "AccountService.SendEmailVerification")
+	defer span.End()
+
+	user, err := s.userRepository.GetUserBy(ctx, repository.QueryByID, strconv.FormatInt(userID, 10))
+	if err != nil {
+		if errors.Is(err, &servererrors.ServerError{Kind: servererrors.KindNotFound}) {
+			return servererrors.NewNotFoundError(i18n.MsgUserNotFound, nil)
+		}
+		return servererrors.NewInternalError("获取用户失败", err)
+	}
+	if user.IsEmailVerified() {
+		return servererrors.NewConflictError(i18n.MsgEmailAlreadyVerified, nil)
+	}
+
+	token, err := s.issueToken(ctx, user.ID, model.TokenPurposeEmailVerification, s.cfg.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+	msg := s.newMessage(ctx, user, i18n.MailEmailVerificationSubject, i18n.MailEmailVerificationBody, "verify-email", token)
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		return servererrors.NewInternalError("发送验证邮件失败", err)
+	}
+	return nil
+}
+
+// VerifyEmail 使用邮件中的令牌验证邮箱
+func (s *accountService) VerifyEmail(ctx context.Context, token string) error {
+	ctx, span := tracing.Start(ctx, "AccountService.VerifyEmail")
+	defer span.End()
+
+	now := time.Now()
+	record, err := s.userTokenRepository.Consume(ctx, model.TokenPurposeEmailVerification, auth.HashToken(token), now)
+	if err != nil {
+		if errors.Is(err, &servererrors.ServerError{Kind: servererrors.KindNotFound}) {
+			return servererrors.NewValidationError(i18n.MsgInvalidVerificationToken, nil)
+		}
+		return servererrors.NewInternalError("使用令牌失败", err)
+	}
+	if err := s.userRepository.MarkEmailVerified(ctx, record.UserID, now); err != nil {
+		return servererrors.NewInternalError("更新邮箱验证状态失败", err)
+	}
+	return nil
+}
+
+// OnEmailChanged 在用户修改邮箱后调用: 作废发往旧邮箱的重置密码链接, 并向新邮箱发送验证链接
+func (s *accountService) OnEmailChanged(ctx context.Context, userID int64) error {
+	ctx, span := tracing.Start(ctx, "AccountService.OnEmailChanged")
+	defer span.End()
+
+	if err := s.userTokenRepository.Invalidate(ctx, userID, model.TokenPurposePasswordReset, time.Now()); err != nil {
+		return servererrors.NewInternalError("作废令牌失败", err)
+	}
+	return s.SendEmailVerification(ctx, userID)
+}
+
+// CleanupExpiredTokens 删除已经过期的一次性令牌, 由后台任务定期调用
+func (s *accountService) CleanupExpiredTokens(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "AccountService.CleanupExpiredTokens")
+	defer span.End()
+
+	deleted, err := s.userTokenRepository.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		logging.FromContext(ctx).Info("deleted expired user tokens", "count", deleted)
+	}
+	return nil
+}
+
+// issueToken 作废用户同一用途的旧令牌并生成新令牌, 返回原始令牌
+func (s *accountService) issueToken(ctx context.Context, userID int64, purpose string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	if err := s.userTokenRepository.Invalidate(ctx, userID, purpose, now); err != nil {
+		return "", servererrors.NewInternalError("作废令牌失败", err)
+	}
+
+	token, err := auth.GenerateToken()
+	if err != nil {
+		return "", servererrors.NewInternalError("生成令牌失败", err)
+	}
+	record := &model.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	if err := s.userTokenRepository.Create(ctx, record); err != nil {
+		return "", servererrors.NewInternalError("保存令牌失败", err)
+	}
+	return token, nil
+}
+
+// newMessage 按请求的语言生成邮件, 链接为 AppURL/<page>?token=<token>
+func (s *accountService) newMessage(ctx context.Context, user *model.User, subjectID, bodyID, page, token string) mail.Message {
+	link, _ := url.Parse(s.cfg.AppURL) // 配置校验时已经检查过格式
+	link = link.JoinPath(page)
+	link.RawQuery = url.Values{"token": {token}}.Encode()
+
+	body := strings.NewReplacer("{username}", user.Username, "{link}", link.String()).Replace(i18n.T(ctx, bodyID))
+	return mail.Message{
+		To:      user.Email,
+		Subject: i18n.T(ctx, subjectID),
+		Body:    body,
+	}
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"errors"
+	servererrors "skymates-api/errors"
+	"skymates-api/internal/i18n"
+	"skymates-api/internal/model"
+	"skymates-api/internal/repository"
+	"skymates-api/pkg/tracing"
+)
+
+// CategoryService 定义分类相关的业务逻辑接口
+type CategoryService interface {
+	ListCategories(ctx context.Context) ([]model.Category, error)
+	GetCategoryTree(ctx context.Context) ([]model.CategoryNode, error)
+	GetCategoryByID(ctx context.Context, id int64) (*model.Category, error)
+	CreateCategory(ctx context.Context, category *model.Category) (int64, error)
+	UpdateCategory(ctx context.Context, category *model.Category) error
+	DeleteCategory(ctx context.Context, id int64) error
+}
+
+// categoryService 实现 CategoryService 接口
+// 只有管理员可以修改分类, 由路由上的授权中间件检查
+type categoryService struct {
+	categoryRepository repository.CategoryRepository
+}
+
+// NewCategoryService 创建 CategoryService 实例
+func NewCategoryService(categoryRepository repository.CategoryRepository) CategoryService {
+	return &categoryService{categoryRepository: categoryRepository}
+}
+
+// ListCategories 列出全部分类, 按 sort_order 和 ID 排序
+func (s *categoryService) ListCategories(ctx context.Context) ([]model.Category, error) {
+	ctx, span := tracing.Start(ctx, "CategoryService.ListCategories")
+	defer span.End()
+
+	categories, err := s.categoryRepository.ListCategories(ctx)
+	if err != nil {
+		return nil, servererrors.NewInternalError("列出分类失败", err)
+	}
+	return categories, nil
+}
+
+// GetCategoryTree 返回由顶级分类组成的分类树, 每一层按 sort_order 和 ID 排序
+func (s *categoryService) GetCategoryTree(ctx context.Context) ([]model.CategoryNode, error) {
+	ctx, span := tracing.Start(ctx, "CategoryService.GetCategoryTree")
+	defer span.End()
+
+	tree, err := loadCategoryTree(ctx, s.categoryRepository)
+	if err != nil {
+		return nil, err
+	}
+	return tree.nodes(0), nil
+}
+
+// GetCategoryByID 根据 ID 获取分类
+func (s *categoryService) GetCategoryByID(ctx context.Context, id int64) (*model.Category, error) {
+	ctx, span := tracing.Start(ctx, "CategoryService.GetCategoryByID")
+	defer span.End()
+
+	category, err := s.categoryRepository.GetCategoryByID(ctx, id)
+	if err != nil {
+		return nil, servererrors.NewInternalError("获取分类失败", err)
+	}
+	// 存储库在分类不存在时返回 nil, nil
+	if category == nil {
+		return nil, servererrors.NewNotFoundError(i18n.MsgCategoryNotFound, nil)
+	}
+	return category, nil
+}
+
+// CreateCategory 创建分类, 父分类必须存在, slug 不能与其他分类重复
+func (s *categoryService) CreateCategory(ctx context.Context, category *model.Category) (int64, error) {
+	ctx, span := tracing.Start(ctx, "CategoryService.CreateCategory")
+	defer span.End()
+
+	tree, err := loadCategoryTree(ctx, s.categoryRepository)
+	if err != nil {
+		return 0, err
+	}
+	if err := tree.check(category); err != nil {
+		return 0, err
+	}
+
+	id, err := s.categoryRepository.CreateCategory(ctx, category)
+	if err != nil {
+		return 0, categoryWriteError("创建分类失败", err)
+	}
+	return id, nil
+}
+
+// UpdateCategory 更新分类, 可以移动到其他父分类下, 但不能移动到自身或其子分类下
+func (s *categoryService) UpdateCategory(ctx context.Context, category *model.Category) error {
+	ctx, span := tracing.Start(ctx, "CategoryService.UpdateCategory")
+	defer span.End()
+
+	tree, err := loadCategoryTree(ctx, s.categoryRepository)
+	if err != nil {
+		return err
+	}
+	if _, ok := tree.byID[category.ID]; !ok {
+		return servererrors.NewNotFoundError(i18n.MsgCategoryNotFound, nil)
+	}
+	if err := tree.check(category); err != nil {
+		return err
+	}
+
+	if err := s.categoryRepository.UpdateCategory(ctx, category); err != nil {
+		return categoryWriteError("更新分类失败", err)
+	}
+	return nil
+}
+
+// DeleteCategory 删除分类, 有子分类或术语的分类不能删除
+func (s *categoryService) DeleteCategory(ctx context.Context, id int64) error {
+	ctx, span := tracing.Start(ctx, "CategoryService.DeleteCategory")
+	defer span.End()
+
+	tree, err := loadCategoryTree(ctx, s.categoryRepository)
+	if err != nil {
+		return err
+	}
+	if _, ok := tree.byID[id]; !ok {
+		return servererrors.NewNotFoundError(i18n.MsgCategoryNotFound, nil)
+	}
+	if len(tree.children[id]) > 0 {
+		return servererrors.NewConflictError(i18n.MsgCategoryHasChildren, nil)
+	}
+
+	deleted, err := s.categoryRepository.DeleteCategory(ctx, id)
+	if err != nil {
+		// 检查之后其他请求添加了子分类或关联了术语, 由外键拒绝
+		if errors.Is(err, &servererrors.ServerError{Kind: servererrors.KindConflict}) {
+			return s.categoryInUse(ctx, id)
+		}
+		return servererrors.NewInternalError("删除分类失败", err)
+	}
+	// 分类存在但没有被删除, 说明分类下还有术语
+	if !deleted {
+		return servererrors.NewConflictError(i18n.MsgCategoryHasTerms, nil)
+	}
+	return nil
+}
+
+// categoryInUse 返回外键阻止删除分类时的错误, 重新加载分类树判断分类下是子分类还是术语
+func (s *categoryService) categoryInUse(ctx context.Context, id int64) error {
+	tree, err := loadCategoryTree(ctx, s.categoryRepository)
+	if err != nil {
+		return err
+	}
+	if len(tree.children[id]) > 0 {
+		return servererrors.NewConflictError(i18n.MsgCategoryHasChildren, nil)
+	}
+	return servererrors.NewConflictError(i18n.MsgCategoryHasTerms, nil)
+}
+
+// categoryWriteError 转换创建或更新分类时存储库返回的错误
+// 检查之后其他请求抢先使用了同一个 slug 或删除了父分类时, 由唯一索引和外键拒绝
+func categoryWriteError(msg string, err error) error {
+	switch {
+	case errors.Is(err, &servererrors.ServerError{Kind: servererrors.KindAlreadyExists}):
+		return servererrors.NewAlreadyExistsError(i18n.MsgCategorySlugExists, err)
+	case errors.Is(err, &servererrors.ServerError{Kind: servererrors.KindValidation}):
+		return servererrors.NewValidationError(i18n.MsgInvalidParent, err)
+	}
+	return servererrors.NewInternalError(msg, err)
+}
+
+// categoryTree 内存中的分类树, 分类数量很少, 每次使用时从数据库加载全部分类
+type categoryTree struct {
+	byID     map[int64]model.Category
+	children map[int64][]model.Category // 键为父分类 ID, 顶级分类的键为 0; 保持 ListCategories 的顺序
+}
+
+func loadCategoryTree(ctx context.Context, categoryRepository repository.CategoryRepository) (*categoryTree, error) {
+	categories, err := categoryRepository.ListCategories(ctx)
+	if err != nil {
+		return nil, servererrors.NewInternalError("列出分类失败", err)
+	}
+	tree := &categoryTree{byID: map[int64]model.Category{}, children: map[int64][]model.Category{}}
+	for _, category := range categories {
+		tree.byID[category.ID] = category
+		var parentID int64
+		if category.ParentID != nil {
+			parentID = *category.ParentID
+		}
+		tree.children[parentID] = append(tree.children[parentID], category)
+	}
+	return tree, nil
+}
+
+// check 检查创建或更新后的分类: 父分类必须存在, 不能是分类自身或其子分类, slug 不能与其他分类重复
+func (t *categoryTree) check(category *model.Category) error {
+	if category.ParentID != nil {
+		if _, ok := t.byID[*category.ParentID]; !ok {
+			return servererrors.NewValidationError(i18n.MsgInvalidParent, nil)
+		}
+		// 新建的分类 ID 为 0, 没有子分类
+		if category.ID != 0 {
+			for _, id := range t.descendants(category.ID) {
+				if id == *category.ParentID {
+					return servererrors.NewValidationError(i18n.MsgCategoryCycle, nil)
+				}
+			}
+		}
+	}
+	for _, other := range t.byID {
+		if other.Slug == category.Slug && other.ID != category.ID {
+			return servererrors.NewAlreadyExistsError(i18n.MsgCategorySlugExists, nil)
+		}
+	}
+	return nil
+}
+
+// descendants 返回分类自身和它的全部子孙分类的 ID, 按层次遍历的顺序
+func (t *categoryTree) descendants(id int64) []int64 {
+	ids := []int64{id}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range t.children[ids[i]] {
+			ids = append(ids, child.ID)
+		}
+	}
+	return ids
+}
+
+// nodes 返回 parentID 的子分类组成的子树, parentID 为 0 时返回整棵树
+func (t *categoryTree) nodes(parentID int64) []model.CategoryNode {
+	nodes := make([]model.CategoryNode, 0, len(t.children[parentID]))
+	for _, category := range t.children[parentID] {
+		nodes = append(nodes, model.CategoryNode{Category: category, Children: t.nodes(category.ID)})
+	}
+	return nodes
+}
@@ -0,0 +1,37 @@
+package service
+
+import "skymates-api/pkg/metrics"
+
+// 登录结果, 作为 user_logins_total 的 result 标签
+const (
+	loginSuccess = "success"
+	loginFailure = "failure" // 用户不存在或密码错误
+	loginLocked  = "locked"  // 账号因连续登录失败被锁定
+	loginError   = "error"   // 内部错误, 与凭证无关
+)
+
+// Metrics 业务指标
+type Metrics struct {
+	registrations *metrics.CounterVec
+	logins        *metrics.CounterVec
+	termsCreated  *metrics.CounterVec
+	termSearches  *metrics.CounterVec
+}
+
+// NewMetrics 在 registry 中注册业务指标
+// 所有序列预先创建, 在第一次发生之前就以 0 输出, 否则 rate() 等查询会缺少起点
+func NewMetrics(registry *metrics.Registry) *Metrics {
+	m := &Metrics{
+		registrations: registry.NewCounterVec("skymates_user_registrations_total", "Total number of successful user registrations."),
+		logins:        registry.NewCounterVec("skymates_user_logins_total", "Total number of login attempts by result.", "result"),
+		termsCreated:  registry.NewCounterVec("skymates_terms_created_total", "Total number of terms created."),
+		termSearches:  registry.NewCounterVec("skymates_term_searches_total", "Total number of term searches."),
+	}
+	m.registrations.With()
+	for _, result := range []string{loginSuccess, loginFailure, loginLocked, loginError} {
+		m.logins.With(result)
+	}
+	m.termsCreated.With()
+	m.termSearches.With()
+	return m
+}
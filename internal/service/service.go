@@ -1,18 +1,38 @@
 package service
 
-import "skymates-api/internal/repository"
+import (
+	"skymates-api/config"
+	"skymates-api/internal/repository"
+	"skymates-api/pkg/auth"
+	"skymates-api/pkg/mail"
+)
 
 type Services struct {
-	UserService UserService
-	TermService TermService
+	UserService     UserService
+	TermService     TermService
+	CategoryService CategoryService
+	TokenService    TokenService
+	AccountService  AccountService
 }
 
 func NewServices(
 	userRepository repository.UserRepository,
 	termRepository repository.TermRepository,
+	categoryRepository repository.CategoryRepository,
+	tokenRepository repository.TokenRepository,
+	userTokenRepository repository.UserTokenRepository,
+	keys *auth.KeyManager,
+	mailer mail.Mailer,
+	cfg *config.Config,
+	metrics *Metrics,
 ) *Services {
+	tokenService := NewTokenService(tokenRepository, userRepository, keys, cfg.JWT.RefreshTokenTTL)
+	accountService := NewAccountService(userRepository, userTokenRepository, tokenRepository, mailer, cfg.Account)
 	return &Services{
-		UserService: NewUserService(userRepository),
-		TermService: NewTermService(termRepository),
+		UserService:     NewUserService(userRepository, tokenService, accountService, cfg.Lockout, metrics),
+		TermService:     NewTermService(termRepository, categoryRepository, metrics),
+		CategoryService: NewCategoryService(categoryRepository),
+		TokenService:    tokenService,
+		AccountService:  accountService,
 	}
 }
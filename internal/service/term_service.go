@@ -2,62 +2,253 @@ package service
 
 import (
 	"context"
-	"log"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	servererrors "skymates-api/errors"
+	"skymates-api/internal/authz"
+	"skymates-api/internal/i18n"
 	"skymates-api/internal/model"
 	"skymates-api/internal/repository"
+	"skymates-api/pkg/auth"
+	"skymates-api/pkg/diff"
+	"skymates-api/pkg/logging"
+	"skymates-api/pkg/search"
+	"skymates-api/pkg/tracing"
+	"strconv"
+	"strings"
 )
 
 // TermService 定义术语相关的业务逻辑接口
 type TermService interface {
-	SearchTerms(ctx context.Context, keyword string) ([]model.TermSummary, error)
+	SearchTerms(ctx context.Context, keyword, mode string, categoryID *int64, cursor string, limit int) (*model.TermSearchResult, error)
+	RefreshSearchColumns(ctx context.Context) error
+	SuggestTerms(ctx context.Context, query string, limit int) ([]model.TermSuggestion, error)
+	RebuildSuggestIndex(ctx context.Context) error
 	GetTermByID(ctx context.Context, id int64) (*model.TermDetail, error)
-	ListTermsByCategory(ctx context.Context, categoryID int64, lastID *int64, limit int) ([]model.TermSummary, bool, error)
-	CreateTerm(ctx context.Context, term *model.Term, categoryIDs []int64) (int64, error)
-	UpdateTerm(ctx context.Context, term *model.Term, categoryIDs []int64) error
+	ListTermsByCategory(ctx context.Context, categoryID int64, includeDescendants bool, lastID *int64, limit int) ([]model.TermSummary, bool, error)
+	CreateTerm(ctx context.Context, principal *auth.Principal, term *model.Term, categoryIDs []int64, summary string) (int64, error)
+	UpdateTerm(ctx context.Context, principal *auth.Principal, term *model.Term, categoryIDs []int64, summary string) error
+	ListTermRevisions(ctx context.Context, termID int64) ([]model.TermRevision, error)
+	GetTermRevision(ctx context.Context, termID int64, revision int) (*model.TermRevision, error)
+	DiffTermRevisions(ctx context.Context, termID int64, from, to int) (*model.TermRevisionDiff, error)
+	RollbackTerm(ctx context.Context, principal *auth.Principal, termID int64, revision int) error
 }
 
 // termService 实现 TermService 接口
 type termService struct {
-	termRepository repository.TermRepository
+	termRepository     repository.TermRepository
+	categoryRepository repository.CategoryRepository
+	suggestIndex       *search.Index
+	metrics            *Metrics
 }
 
-// NewTermService 创建 TermService 实例
-func NewTermService(termRepository repository.TermRepository) TermService {
+// NewTermService 创建 TermService 实例, 搜索建议的索引为空, 需要调用 RebuildSuggestIndex 从数据库加载
+func NewTermService(termRepository repository.TermRepository, categoryRepository repository.CategoryRepository, metrics *Metrics) TermService {
 	return &termService{
-		termRepository: termRepository,
+		termRepository:     termRepository,
+		categoryRepository: categoryRepository,
+		suggestIndex:       search.NewIndex(),
+		metrics:            metrics,
 	}
 }
 
-// SearchTerms 根据关键字搜索术语
-func (s *termService) SearchTerms(ctx context.Context, keyword string) ([]model.TermSummary, error) {
-	terms, err := s.termRepository.SearchTerms(ctx, keyword)
+// 搜索结果的分页参数
+const (
+	searchDefaultLimit = 20
+	searchMaxLimit     = 50
+	searchMaxResults   = 200 // 最多只能翻到第 200 条结果, 避免深分页的 OFFSET 扫描大量行
+	snippetWidth       = 120 // 摘要的最大字符数
+)
+
+// SearchTerms 搜索术语, 结果按相关度排序
+// mode 为 model.SearchModeText (默认) 或 model.SearchModePinyin; cursor 为上一页返回的 NextCursor,
+// 第一页传空字符串; limit 不在 (0, 50] 内时使用默认值 20
+func (s *termService) SearchTerms(ctx context.Context, keyword, mode string, categoryID *int64, cursor string, limit int) (*model.TermSearchResult, error) {
+	ctx, span := tracing.Start(ctx, "TermService.SearchTerms")
+	defer span.End()
+
+	switch mode {
+	case "":
+		mode = model.SearchModeText
+	case model.SearchModeText, model.SearchModePinyin:
+	default:
+		return nil, servererrors.NewValidationError(i18n.MsgInvalidSearchMode, nil)
+	}
+	offset, err := decodeSearchCursor(cursor)
+	if err != nil {
+		return nil, servererrors.NewValidationError(i18n.MsgInvalidCursor, err)
+	}
+	if limit <= 0 || limit > searchMaxLimit {
+		limit = searchDefaultLimit
+	}
+	limit = min(limit, searchMaxResults-offset)
+
+	s.metrics.termSearches.With().Inc()
+	result, err := s.termRepository.SearchTerms(ctx, model.TermSearchQuery{
+		Keyword:    keyword,
+		Mode:       mode,
+		CategoryID: categoryID,
+		Offset:     offset,
+		Limit:      limit,
+	})
 	if err != nil {
-		log.Printf("TermService.SearchTerms: %v", err)
 		return nil, servererrors.NewInternalError("搜索术语失败", err)
 	}
-	summaries := make([]model.TermSummary, len(terms))
-	for i, term := range terms {
-		summaries[i] = model.TermSummary{ID: term.ID, Name: term.Name}
+
+	for i := range result.Hits {
+		hit := &result.Hits[i]
+		if mode == model.SearchModePinyin {
+			hit.Highlight = search.HighlightPinyin(hit.Name, keyword)
+		} else {
+			hit.Highlight = search.Highlight(hit.Name, keyword)
+		}
+		hit.Snippet = search.Snippet(hit.Explanation, keyword, snippetWidth)
+	}
+	if next := offset + len(result.Hits); next < min(result.Total, searchMaxResults) {
+		result.NextCursor = encodeSearchCursor(next)
+	}
+	return result, nil
+}
+
+// encodeSearchCursor 把下一页的偏移量编码为不透明的游标
+// 搜索按相关度排序, 分数由数据库在查询时计算, 各数据库的浮点值不能可靠地用于 keyset 比较, 所以按偏移量分页;
+// 深分页的代价由 searchMaxResults 限制, 翻页期间术语被修改时可能出现重复或遗漏的结果
+func encodeSearchCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+// decodeSearchCursor 解码游标, 空游标表示第一页
+func decodeSearchCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	offset, err := strconv.Atoi(string(raw))
+	if err != nil {
+		return 0, err
+	}
+	if offset <= 0 || offset >= searchMaxResults {
+		return 0, fmt.Errorf("cursor offset %d out of range", offset)
+	}
+	return offset, nil
+}
+
+// 搜索建议的参数
+const (
+	suggestDefaultLimit = 10
+	suggestMaxLimit     = 20
+	termNamesBatchSize  = 1000 // 重建索引和更新检索列时每批处理的术语数
+)
+
+// SuggestTerms 从内存中的前缀索引查找输入框的搜索建议, 不访问数据库
+// limit 不在 (0, 20] 内时使用默认值 10
+func (s *termService) SuggestTerms(ctx context.Context, query string, limit int) ([]model.TermSuggestion, error) {
+	_, span := tracing.Start(ctx, "TermService.SuggestTerms")
+	defer span.End()
+
+	if limit <= 0 || limit > suggestMaxLimit {
+		limit = suggestDefaultLimit
 	}
-	return summaries, nil
+	matches := s.suggestIndex.Suggest(query, limit)
+	suggestions := make([]model.TermSuggestion, len(matches))
+	for i, match := range matches {
+		suggestions[i] = model.TermSuggestion{ID: match.ID, Name: match.Name, Alias: match.Alias}
+	}
+	return suggestions, nil
+}
+
+// RebuildSuggestIndex 从数据库重新加载全部术语的名称和别名, 启动时和后台任务中调用;
+// 本实例创建和更新的术语随时写入索引, 定期重建用于加载其他实例的修改
+func (s *termService) RebuildSuggestIndex(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "TermService.RebuildSuggestIndex")
+	defer span.End()
+
+	err := s.suggestIndex.Rebuild(func() ([]search.Document, error) {
+		var docs []search.Document
+		var afterID int64
+		for {
+			batch, err := s.termRepository.ListTermNames(ctx, afterID, termNamesBatchSize)
+			if err != nil {
+				return nil, err
+			}
+			for _, term := range batch {
+				docs = append(docs, search.Document{ID: term.ID, Name: term.Name, Aliases: term.Aliases})
+			}
+			if len(batch) < termNamesBatchSize {
+				return docs, nil
+			}
+			afterID = batch[len(batch)-1].ID
+		}
+	})
+	if err != nil {
+		return servererrors.NewInternalError("重建搜索建议索引失败", err)
+	}
+	logging.FromContext(ctx).Debug("rebuilt term suggest index", "terms", s.suggestIndex.Len())
+	return nil
+}
+
+// RefreshSearchColumns 重新计算检索列版本较旧的术语, 启动时调用;
+// 升级后第一次启动时计算已有术语的简体和拼音形式, 之后只有一次查询
+func (s *termService) RefreshSearchColumns(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "TermService.RefreshSearchColumns")
+	defer span.End()
+
+	total := 0
+	for {
+		n, err := s.termRepository.RefreshSearchColumns(ctx, termNamesBatchSize)
+		if err != nil {
+			return servererrors.NewInternalError("更新术语检索列失败", err)
+		}
+		total += n
+		if n < termNamesBatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		logging.FromContext(ctx).Info("refreshed term search columns", "terms", total)
+	}
+	return nil
 }
 
 // GetTermByID 根据 ID 获取术语详情
 func (s *termService) GetTermByID(ctx context.Context, id int64) (*model.TermDetail, error) {
+	ctx, span := tracing.Start(ctx, "TermService.GetTermByID")
+	defer span.End()
+
 	term, err := s.termRepository.GetTermByID(ctx, id)
 	if err != nil {
-		log.Printf("TermService.GetTermByID: %v", err)
 		return nil, servererrors.NewInternalError("获取术语详情失败", err)
 	}
+	// 存储库在术语不存在时返回 nil, nil
+	if term == nil {
+		return nil, servererrors.NewNotFoundError(i18n.MsgTermNotFound, nil)
+	}
 	return term, nil
 }
 
-// ListTermsByCategory 列出指定分类下的术语
-func (s *termService) ListTermsByCategory(ctx context.Context, categoryID int64, lastID *int64, limit int) ([]model.TermSummary, bool, error) {
-	terms, hasMore, err := s.termRepository.ListTermsByCategory(ctx, categoryID, lastID, limit)
+// ListTermsByCategory 列出指定分类下的术语, includeDescendants 为 true 时也包括子孙分类下的术语
+func (s *termService) ListTermsByCategory(ctx context.Context, categoryID int64, includeDescendants bool, lastID *int64, limit int) ([]model.TermSummary, bool, error) {
+	ctx, span := tracing.Start(ctx, "TermService.ListTermsByCategory")
+	defer span.End()
+
+	tree, err := loadCategoryTree(ctx, s.categoryRepository)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, ok := tree.byID[categoryID]; !ok {
+		return nil, false, servererrors.NewNotFoundError(i18n.MsgCategoryNotFound, nil)
+	}
+	categoryIDs := []int64{categoryID}
+	if includeDescendants {
+		categoryIDs = tree.descendants(categoryID)
+	}
+
+	terms, hasMore, err := s.termRepository.ListTermsByCategory(ctx, categoryIDs, lastID, limit)
 	if err != nil {
-		log.Printf("TermService.ListTermsByCategory: %v", err)
 		return nil, false, servererrors.NewInternalError("列出分类下的术语失败", err)
 	}
 	summaries := make([]model.TermSummary, len(terms))
@@ -67,22 +258,232 @@ func (s *termService) ListTermsByCategory(ctx context.Context, categoryID int64,
 	return summaries, hasMore, nil
 }
 
-// CreateTerm 创建术语并关联分类
-func (s *termService) CreateTerm(ctx context.Context, term *model.Term, categoryIDs []int64) (int64, error) {
-	id, err := s.termRepository.CreateTerm(ctx, term, categoryIDs)
+// CreateTerm 创建术语并关联分类, 当前用户记录为术语的创建者和第 1 个修订版本的作者
+// summary 为修改说明, 可以为空
+func (s *termService) CreateTerm(ctx context.Context, principal *auth.Principal, term *model.Term, categoryIDs []int64, summary string) (int64, error) {
+	ctx, span := tracing.Start(ctx, "TermService.CreateTerm")
+	defer span.End()
+
+	if err := authz.RequireAuthenticated(principal); err != nil {
+		return 0, err
+	}
+	categoryIDs = uniqueCategoryIDs(categoryIDs)
+	if err := s.checkCategoryIDs(ctx, categoryIDs); err != nil {
+		return 0, err
+	}
+	term.CreatedBy = &principal.UserID
+	term.Aliases = normalizeAliases(term.Name, term.Aliases)
+
+	change := model.TermChange{AuthorID: &principal.UserID, Summary: summary}
+	id, err := s.termRepository.CreateTerm(ctx, term, categoryIDs, change)
 	if err != nil {
-		log.Printf("TermService.CreateTerm: %v", err)
-		return 0, servererrors.NewInternalError("创建术语失败", err)
+		return 0, termWriteError("创建术语失败", err)
 	}
+	s.metrics.termsCreated.With().Inc()
+	s.suggestIndex.Put(search.Document{ID: id, Name: term.Name, Aliases: term.Aliases})
 	return id, nil
 }
 
-// UpdateTerm 更新术语并更新关联分类
-func (s *termService) UpdateTerm(ctx context.Context, term *model.Term, categoryIDs []int64) error {
-	err := s.termRepository.UpdateTerm(ctx, term, categoryIDs)
+// UpdateTerm 更新术语并更新关联分类, 只有创建者或管理员可以更新; 每次更新都追加一个修订版本, 当前用户记录为作者
+// 内容与当前版本相同时不追加版本, 也不修改术语
+func (s *termService) UpdateTerm(ctx context.Context, principal *auth.Principal, term *model.Term, categoryIDs []int64, summary string) error {
+	ctx, span := tracing.Start(ctx, "TermService.UpdateTerm")
+	defer span.End()
+
+	existing, err := s.termRepository.GetTermByID(ctx, term.ID)
 	if err != nil {
-		log.Printf("TermService.UpdateTerm: %v", err)
-		return servererrors.NewInternalError("更新术语失败", err)
+		return servererrors.NewInternalError("获取术语详情失败", err)
+	}
+	if existing == nil {
+		return servererrors.NewNotFoundError(i18n.MsgTermNotFound, nil)
+	}
+	if err := authz.RequireOwnerOrAdmin(principal, existing.CreatedBy); err != nil {
+		return err
+	}
+	categoryIDs = uniqueCategoryIDs(categoryIDs)
+	if err := s.checkCategoryIDs(ctx, categoryIDs); err != nil {
+		return err
 	}
+
+	term.Aliases = normalizeAliases(term.Name, term.Aliases)
+	change := model.TermChange{AuthorID: &principal.UserID, Summary: summary}
+	err = s.termRepository.UpdateTerm(ctx, term, categoryIDs, change)
+	if err != nil {
+		return termWriteError("更新术语失败", err)
+	}
+	s.suggestIndex.Put(search.Document{ID: term.ID, Name: term.Name, Aliases: term.Aliases})
 	return nil
 }
+
+// ListTermRevisions 列出术语的全部修订版本, 按版本号从新到旧排序, 不包含快照的内容
+func (s *termService) ListTermRevisions(ctx context.Context, termID int64) ([]model.TermRevision, error) {
+	ctx, span := tracing.Start(ctx, "TermService.ListTermRevisions")
+	defer span.End()
+
+	revisions, err := s.termRepository.ListTermRevisions(ctx, termID)
+	if err != nil {
+		return nil, servererrors.NewInternalError("列出术语修订版本失败", err)
+	}
+	// 每个术语至少有一个版本, 没有版本说明术语不存在
+	if len(revisions) == 0 {
+		return nil, servererrors.NewNotFoundError(i18n.MsgTermNotFound, nil)
+	}
+	return revisions, nil
+}
+
+// GetTermRevision 获取术语的一个修订版本
+func (s *termService) GetTermRevision(ctx context.Context, termID int64, revision int) (*model.TermRevision, error) {
+	ctx, span := tracing.Start(ctx, "TermService.GetTermRevision")
+	defer span.End()
+
+	result, err := s.termRepository.GetTermRevision(ctx, termID, revision)
+	if err != nil {
+		return nil, servererrors.NewInternalError("获取术语修订版本失败", err)
+	}
+	// 存储库在版本不存在时返回 nil, nil
+	if result == nil {
+		return nil, servererrors.NewNotFoundError(i18n.MsgRevisionNotFound, nil)
+	}
+	return result, nil
+}
+
+// DiffTermRevisions 按词比较术语的两个修订版本, 只返回值不同的字段
+func (s *termService) DiffTermRevisions(ctx context.Context, termID int64, from, to int) (*model.TermRevisionDiff, error) {
+	ctx, span := tracing.Start(ctx, "TermService.DiffTermRevisions")
+	defer span.End()
+
+	old, err := s.GetTermRevision(ctx, termID, from)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.GetTermRevision(ctx, termID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.TermRevisionDiff{From: from, To: to, Fields: []model.FieldDiff{}}
+	oldFields, currentFields := revisionText(old), revisionText(current)
+	for _, field := range model.TermFields {
+		if oldFields[field] == currentFields[field] {
+			continue
+		}
+		var changes []model.TextChange
+		for _, change := range diff.Words(oldFields[field], currentFields[field]) {
+			changes = append(changes, model.TextChange{Op: diffOps[change.Op], Text: change.Text})
+		}
+		result.Fields = append(result.Fields, model.FieldDiff{Field: field, Changes: changes})
+	}
+	return result, nil
+}
+
+var diffOps = map[diff.Op]string{
+	diff.Equal:  model.DiffEqual,
+	diff.Insert: model.DiffInsert,
+	diff.Delete: model.DiffDelete,
+}
+
+// revisionText 返回修订版本中各个字段用于比较的文本, 别名每行一个, 分类 ID 以逗号分隔
+func revisionText(revision *model.TermRevision) map[string]string {
+	categoryIDs := make([]string, len(revision.CategoryIDs))
+	for i, id := range revision.CategoryIDs {
+		categoryIDs[i] = strconv.FormatInt(id, 10)
+	}
+	return map[string]string{
+		model.TermFieldName:        revision.Name,
+		model.TermFieldAliases:     strings.Join(revision.Aliases, "\n"),
+		model.TermFieldExplanation: revision.Explanation,
+		model.TermFieldSourceURL:   revision.SourceURL,
+		model.TermFieldCategoryIDs: strings.Join(categoryIDs, ", "),
+	}
+}
+
+// RollbackTerm 把术语恢复为一个修订版本的内容, 恢复本身作为一个新的修订版本记录, 不删除之后的版本
+// 只有管理员可以回滚; 内容与当前版本相同时不做任何修改
+func (s *termService) RollbackTerm(ctx context.Context, principal *auth.Principal, termID int64, revision int) error {
+	ctx, span := tracing.Start(ctx, "TermService.RollbackTerm")
+	defer span.End()
+
+	if err := authz.RequireAdmin(principal); err != nil {
+		return err
+	}
+	target, err := s.GetTermRevision(ctx, termID, revision)
+	if err != nil {
+		return err
+	}
+	// 版本中的分类可能已经被删除; 修复之前写入的版本可能有重复的分类 ID
+	categoryIDs := uniqueCategoryIDs(target.CategoryIDs)
+	if err := s.checkCategoryIDs(ctx, categoryIDs); err != nil {
+		return err
+	}
+
+	term := &model.Term{
+		ID:          termID,
+		Name:        target.Name,
+		Aliases:     target.Aliases,
+		Explanation: target.Explanation,
+		SourceURL:   target.SourceURL,
+	}
+	change := model.TermChange{AuthorID: &principal.UserID, RestoredRevision: &revision}
+	if err := s.termRepository.UpdateTerm(ctx, term, categoryIDs, change); err != nil {
+		return termWriteError("回滚术语失败", err)
+	}
+	s.suggestIndex.Put(search.Document{ID: term.ID, Name: term.Name, Aliases: term.Aliases})
+	return nil
+}
+
+// uniqueCategoryIDs 去掉重复的分类 ID, 保留第一次出现的顺序
+// 三种数据库对重复的关联处理不同 (MySQL 的插入会违反主键), 统一在写入之前去重
+func uniqueCategoryIDs(categoryIDs []int64) []int64 {
+	seen := make(map[int64]bool, len(categoryIDs))
+	var unique []int64
+	for _, id := range categoryIDs {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// checkCategoryIDs 检查术语关联的分类都存在, 不存在时返回校验错误
+// 检查之后分类被并发删除时由 term_category_relations 的外键拒绝, 见 termWriteError
+func (s *termService) checkCategoryIDs(ctx context.Context, categoryIDs []int64) error {
+	if len(categoryIDs) == 0 {
+		return nil
+	}
+	tree, err := loadCategoryTree(ctx, s.categoryRepository)
+	if err != nil {
+		return err
+	}
+	for _, id := range categoryIDs {
+		if _, ok := tree.byID[id]; !ok {
+			return servererrors.NewValidationError(i18n.MsgUnknownCategory, fmt.Errorf("category %d does not exist", id))
+		}
+	}
+	return nil
+}
+
+// termWriteError 转换保存术语失败时存储库返回的错误
+// 关联的分类在 checkCategoryIDs 之后被删除时外键使插入失败, 存储库返回 KindValidation 错误
+func termWriteError(msg string, err error) error {
+	if errors.Is(err, &servererrors.ServerError{Kind: servererrors.KindValidation}) {
+		return servererrors.NewValidationError(i18n.MsgUnknownCategory, err)
+	}
+	return servererrors.NewInternalError(msg, err)
+}
+
+// normalizeAliases 合并别名中的连续空白, 去掉空别名, 与名称相同的别名和重复的别名, 比较时不区分大小写
+func normalizeAliases(name string, aliases []string) []string {
+	seen := map[string]bool{strings.ToLower(name): true}
+	var normalized []string
+	for _, alias := range aliases {
+		alias = strings.Join(strings.Fields(alias), " ")
+		key := strings.ToLower(alias)
+		if alias == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		normalized = append(normalized, alias)
+	}
+	return normalized
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	servererrors "skymates-api/errors"
+	"skymates-api/internal/authz"
+	"skymates-api/internal/i18n"
+	"skymates-api/internal/model"
+	"skymates-api/internal/repository"
+	"skymates-api/pkg/auth"
+	"skymates-api/pkg/logging"
+	"skymates-api/pkg/tracing"
+	"strconv"
+	"time"
+)
+
+// TokenService 定义令牌签发、刷新和吊销相关的业务逻辑接口
+type TokenService interface {
+	IssueTokens(ctx context.Context, user *model.User) (*model.TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string) (*model.TokenPair, error)
+	Logout(ctx context.Context, principal *auth.Principal) error
+	IsTokenRevoked(ctx context.Context, jti, sessionID string) (bool, error)
+	CleanupExpiredTokens(ctx context.Context) error
+}
+
+// tokenService 实现 TokenService 接口
+type tokenService struct {
+	tokenRepository repository.TokenRepository
+	userRepository  repository.UserRepository
+	keys            *auth.KeyManager
+	refreshTokenTTL time.Duration
+}
+
+// NewTokenService 创建 TokenService 实例, refreshTokenTTL 不大于 0 时使用 auth.RefreshTokenExpiry
+func NewTokenService(tokenRepository repository.TokenRepository, userRepository repository.UserRepository, keys *auth.KeyManager, refreshTokenTTL time.Duration) TokenService {
+	if refreshTokenTTL <= 0 {
+		refreshTokenTTL = auth.RefreshTokenExpiry
+	}
+	return &tokenService{
+		tokenRepository: tokenRepository,
+		userRepository:  userRepository,
+		keys:            keys,
+		refreshTokenTTL: refreshTokenTTL,
+	}
+}
+
+// IssueTokens 为登录的用户开启一个新的会话 (刷新令牌 family) 并签发令牌
+func (s *tokenService) IssueTokens(ctx context.Context, user *model.User) (*model.TokenPair, error) {
+	ctx, span := tracing.Start(ctx, "TokenService.IssueTokens")
+	defer span.End()
+
+	familyID := uuid.NewString()
+
+	refreshToken, record, err := newRefreshToken(user.ID, familyID, s.refreshTokenTTL)
+	if err != nil {
+		return nil, servererrors.NewInternalError("生成令牌失败", err)
+	}
+	if err := s.tokenRepository.CreateRefreshToken(ctx, record); err != nil {
+		return nil, servererrors.NewInternalError("保存令牌失败", err)
+	}
+
+	return s.issueAccessToken(user, familyID, refreshToken)
+}
+
+// Refresh 使用刷新令牌换取新的令牌, 旧的刷新令牌随即失效
+// 如果一个已经轮换过的刷新令牌被再次使用, 说明令牌可能已经泄露,
+// 此时吊销整个 family, 攻击者和合法用户都需要重新登录
+func (s *tokenService) Refresh(ctx context.Context, refreshToken string) (*model.TokenPair, error) {
+	ctx, span := tracing.Start(ctx, "TokenService.Refresh")
+	defer span.End()
+
+	record, err := s.tokenRepository.GetRefreshTokenByHash(ctx, auth.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, &servererrors.ServerError{Kind: servererrors.KindNotFound}) {
+			return nil, servererrors.NewUnauthorizedError(i18n.MsgInvalidRefreshToken, nil)
+		}
+		return nil, servererrors.NewInternalError("获取令牌失败", err)
+	}
+
+	if record.RevokedAt != nil || record.UsedAt != nil {
+		s.revokeFamilyOnReuse(ctx, record)
+		return nil, servererrors.NewUnauthorizedError(i18n.MsgInvalidRefreshToken, nil)
+	}
+	if time.Now().After(record.ExpiresAt) {
+		return nil, servererrors.NewUnauthorizedError(i18n.MsgRefreshTokenExpired, nil)
+	}
+
+	user, err := s.userRepository.GetUserBy(ctx, repository.QueryByID, strconv.FormatInt(record.UserID, 10))
+	if err != nil {
+		if errors.Is(err, &servererrors.ServerError{Kind: servererrors.KindNotFound}) {
+			return nil, servererrors.NewUnauthorizedError(i18n.MsgInvalidRefreshToken, nil)
+		}
+		return nil, servererrors.NewInternalError("获取用户失败", err)
+	}
+
+	nextToken, nextRecord, err := newRefreshToken(user.ID, record.FamilyID, s.refreshTokenTTL)
+	if err != nil {
+		return nil, servererrors.NewInternalError("生成令牌失败", err)
+	}
+
+	rotated, err := s.tokenRepository.RotateRefreshToken(ctx, record.ID, nextRecord)
+	if err != nil {
+		return nil, servererrors.NewInternalError("保存令牌失败", err)
+	}
+	if !rotated {
+		// 并发请求抢先使用了同一个刷新令牌, 同样视为重用
+		s.revokeFamilyOnReuse(ctx, record)
+		return nil, servererrors.NewUnauthorizedError(i18n.MsgInvalidRefreshToken, nil)
+	}
+
+	return s.issueAccessToken(user, record.FamilyID, nextToken)
+}
+
+// Logout 吊销当前会话的所有刷新令牌以及当前使用的访问令牌
+func (s *tokenService) Logout(ctx context.Context, principal *auth.Principal) error {
+	ctx, span := tracing.Start(ctx, "TokenService.Logout")
+	defer span.End()
+
+	if err := authz.RequireAuthenticated(principal); err != nil {
+		return err
+	}
+
+	if principal.SessionID != "" {
+		if err := s.tokenRepository.RevokeRefreshTokenFamily(ctx, principal.SessionID); err != nil {
+			return servererrors.NewInternalError("登出失败", err)
+		}
+	}
+	if principal.TokenID != "" {
+		if err := s.tokenRepository.RevokeAccessToken(ctx, principal.TokenID, principal.TokenExpiresAt); err != nil {
+			return servererrors.NewInternalError("登出失败", err)
+		}
+	}
+	return nil
+}
+
+// IsTokenRevoked 检查访问令牌本身或它所属的会话是否已被吊销, 实现 auth.RevocationChecker
+// 登出, 修改密码, 重置密码和刷新令牌被重用时吊销会话, 会话中已签发的访问令牌随即失效, 不用等到过期
+func (s *tokenService) IsTokenRevoked(ctx context.Context, jti, sessionID string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "TokenService.IsTokenRevoked")
+	defer span.End()
+
+	if jti != "" {
+		revoked, err := s.tokenRepository.IsAccessTokenRevoked(ctx, jti)
+		if err != nil {
+			return false, servererrors.NewInternalError("检查令牌状态失败", err)
+		}
+		if revoked {
+			return true, nil
+		}
+	}
+	if sessionID == "" {
+		return false, nil
+	}
+	revoked, err := s.tokenRepository.IsSessionRevoked(ctx, sessionID)
+	if err != nil {
+		return false, servererrors.NewInternalError("检查会话状态失败", err)
+	}
+	return revoked, nil
+}
+
+// CleanupExpiredTokens 删除已经过期的刷新令牌和访问令牌吊销记录, 由后台任务定期调用
+func (s *tokenService) CleanupExpiredTokens(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "TokenService.CleanupExpiredTokens")
+	defer span.End()
+
+	deleted, err := s.tokenRepository.DeleteExpiredTokens(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		logging.FromContext(ctx).Info("deleted expired tokens", "count", deleted)
+	}
+	return nil
+}
+
+// issueAccessToken 签发访问令牌并与刷新令牌组成 TokenPair
+func (s *tokenService) issueAccessToken(user *model.User, familyID, refreshToken string) (*model.TokenPair, error) {
+	subject := auth.Subject{UserID: user.ID, Role: user.Role, EmailVerified: user.IsEmailVerified()}
+	accessToken, expiresAt, err := s.keys.GenerateJwtToken(subject, familyID)
+	if err != nil {
+		return nil, servererrors.NewInternalError("生成令牌失败", err)
+	}
+
+	return &model.TokenPair{
+		AccessToken:          accessToken,
+		AccessTokenExpiresAt: expiresAt,
+		RefreshToken:         refreshToken,
+	}, nil
+}
+
+// revokeFamilyOnReuse 检测到刷新令牌被重用时吊销整个 family
+func (s *tokenService) revokeFamilyOnReuse(ctx context.Context, record *model.RefreshToken) {
+	logger := logging.FromContext(ctx).With("user_id", record.UserID, "family_id", record.FamilyID)
+	logger.Warn("refresh token reuse detected, revoking token family")
+	// 调用方已经返回 401, 吊销失败只能记录下来
+	if err := s.tokenRepository.RevokeRefreshTokenFamily(ctx, record.FamilyID); err != nil {
+		logger.Error("revoke refresh token family failed", "error", err)
+	}
+}
+
+// newRefreshToken 生成新的刷新令牌, 返回原始令牌和待保存的记录
+func newRefreshToken(userID int64, familyID string, ttl time.Duration) (string, *model.RefreshToken, error) {
+	token, err := auth.GenerateRefreshToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, &model.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}, nil
+}
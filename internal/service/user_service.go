@@ -1,61 +1,77 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"golang.org/x/crypto/bcrypt"
-	"log"
+	"skymates-api/config"
 	servererrors "skymates-api/errors"
 	v1 "skymates-api/internal/dto/v1"
+	"skymates-api/internal/i18n"
 	"skymates-api/internal/model"
 	"skymates-api/internal/repository"
 	"skymates-api/pkg/auth"
+	"skymates-api/pkg/logging"
+	"skymates-api/pkg/tracing"
 	"strconv"
+	"sync"
+	"time"
 )
 
 // UserService 定义用户相关的业务逻辑接口
 type UserService interface {
-	Register(registerDto v1.RegisterDto) (*model.User, error)
-	Login(loginDto v1.LoginDto) (*model.User, string, error)
-	GetUserById(id int64) (*model.User, error)
+	Register(ctx context.Context, registerDto v1.RegisterDto) (*model.User, error)
+	Login(ctx context.Context, loginDto v1.LoginDto) (*model.User, *model.TokenPair, error)
+	GetUserById(ctx context.Context, id int64) (*model.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*model.User, error)
+	UpdateProfile(ctx context.Context, id int64, profileDto v1.UpdateProfileDto) (*model.User, error)
+	ChangePassword(ctx context.Context, principal *auth.Principal, passwordDto v1.ChangePasswordDto) error
 }
 
 // userService 实现 UserService 接口
 type userService struct {
 	userRepository repository.UserRepository
+	tokenService   TokenService
+	accountService AccountService
+	lockout        config.LockoutConfig
+	metrics        *Metrics
 }
 
-// NewUserService 创建 UserService 实例
-func NewUserService(userRepository repository.UserRepository) UserService {
+// NewUserService 创建 UserService 实例, lockout 决定连续登录失败后锁定账号的策略
+func NewUserService(userRepository repository.UserRepository, tokenService TokenService, accountService AccountService, lockout config.LockoutConfig, metrics *Metrics) UserService {
 	return &userService{
 		userRepository: userRepository,
+		tokenService:   tokenService,
+		accountService: accountService,
+		lockout:        lockout,
+		metrics:        metrics,
 	}
 }
 
 // Register 处理用户注册业务逻辑
-// 成功时返回创建的用户，失败时返回错误
-// user_service.go
-func (s *userService) Register(registerDto v1.RegisterDto) (*model.User, error) {
-	exists, err := s.userRepository.CheckExists(repository.QueryByUsername, registerDto.Username)
+// 成功时返回创建的用户并发送验证邮件，失败时返回错误
+func (s *userService) Register(ctx context.Context, registerDto v1.RegisterDto) (*model.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.Register")
+	defer span.End()
+
+	exists, err := s.userRepository.CheckExists(ctx, repository.QueryByUsername, registerDto.Username)
 	if err != nil {
-		log.Printf("UserService.Register: failed to check username exists: %v", err)
 		return nil, servererrors.NewInternalError("检查用户名是否存在失败", err)
 	}
 	if exists {
-		return nil, servererrors.NewAlreadyExistsError("用户名已存在", nil)
+		return nil, servererrors.NewAlreadyExistsError(i18n.MsgUsernameExists, nil)
 	}
 
-	exists, err = s.userRepository.CheckExists(repository.QueryByEmail, registerDto.Email)
+	exists, err = s.userRepository.CheckExists(ctx, repository.QueryByEmail, registerDto.Email)
 	if err != nil {
-		log.Printf("UserService.Register: failed to check email exists: %v", err)
 		return nil, servererrors.NewInternalError("检查邮箱是否存在失败", err)
 	}
 	if exists {
-		return nil, servererrors.NewAlreadyExistsError("邮箱已存在", nil)
+		return nil, servererrors.NewAlreadyExistsError(i18n.MsgEmailExists, nil)
 	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(registerDto.Password), bcrypt.DefaultCost)
 	if err != nil {
-		log.Printf("UserService.Register: failed to hash password: %v", err)
 		return nil, servererrors.NewInternalError("密码加密失败", err)
 	}
 
@@ -63,57 +79,253 @@ func (s *userService) Register(registerDto v1.RegisterDto) (*model.User, error)
 		Username: registerDto.Username,
 		Password: string(hashedPassword),
 		Email:    registerDto.Email,
+		Role:     model.RoleUser,
 	}
-	if err := s.userRepository.Create(user); err != nil {
-		log.Printf("UserService.Register: failed to create user: %v", err)
+	if err := s.userRepository.Create(ctx, user); err != nil {
+		// 检查之后其他请求可能抢先注册了同一个用户名或邮箱, 由唯一索引拒绝
+		if errors.Is(err, &servererrors.ServerError{Kind: servererrors.KindAlreadyExists}) {
+			return nil, s.userConflict(ctx, user.Username, true, true)
+		}
+		var se *servererrors.ServerError
+		if errors.As(err, &se) {
+			return nil, err
+		}
 		return nil, servererrors.NewInternalError("创建用户失败", err)
 	}
 
+	s.metrics.registrations.With().Inc()
+
+	// 用户已经创建成功, 验证邮件发送失败时用户可以稍后重新申请
+	if err := s.accountService.SendEmailVerification(ctx, user.ID); err != nil {
+		logging.FromContext(ctx).Error("send verification mail failed", "user_id", user.ID, "error", err)
+	}
 	return user, nil
 }
 
+// dummyPasswordHash 用户不存在时用来比较密码的哈希, 使响应时间与密码错误时一致
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("skymates-dummy-password"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
 // Login 处理用户登录业务逻辑
-// 成功时返回用户信息和JWT令牌，失败时返回错误
-func (s *userService) Login(loginDto v1.LoginDto) (*model.User, string, error) {
+// 成功时返回用户信息和访问令牌、刷新令牌，失败时返回错误
+// 用户不存在, 密码错误和账号被锁定都返回相同的错误, 不能通过登录接口判断邮箱是否已注册
+func (s *userService) Login(ctx context.Context, loginDto v1.LoginDto) (*model.User, *model.TokenPair, error) {
+	ctx, span := tracing.Start(ctx, "UserService.Login")
+	defer span.End()
+
 	// 1. 查询用户
-	user, err := s.userRepository.GetUserBy(repository.QueryByEmail, loginDto.Email)
+	user, err := s.userRepository.GetUserBy(ctx, repository.QueryByEmail, loginDto.Email)
 	if err != nil {
-		// 如果是未找到，映射成 NotFoundError
 		var se *servererrors.ServerError
 		if errors.As(err, &se) && se.Kind == servererrors.KindNotFound {
-			return nil, "", servererrors.NewNotFoundError("用户不存在", nil)
+			_ = bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(loginDto.Password))
+			s.metrics.logins.With(loginFailure).Inc()
+			return nil, nil, servererrors.NewUnauthorizedError(i18n.MsgInvalidCredentials, nil)
 		}
 		// 其他视为内部错误
-		log.Printf("UserService.Login: failed to get user by email: %v", err)
-		return nil, "", servererrors.NewInternalError("获取用户失败", err)
+		s.metrics.logins.With(loginError).Inc()
+		return nil, nil, servererrors.NewInternalError("获取用户失败", err)
 	}
 
-	// 2. 验证密码
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginDto.Password)); err != nil {
-		// 密码不匹配当作 Unauthorized
-		return nil, "", servererrors.NewUnauthorizedError("凭证无效", nil)
+	// 2. 验证密码, 锁定期间即使密码正确也不允许登录, 也不再累计失败次数
+	passwordErr := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginDto.Password))
+	now := time.Now()
+	if user.IsLocked(now) {
+		s.metrics.logins.With(loginLocked).Inc()
+		logging.FromContext(ctx).Warn("login attempt on locked account", "user_id", user.ID, "locked_until", *user.LockedUntil)
+		return nil, nil, servererrors.NewUnauthorizedError(i18n.MsgInvalidCredentials, nil)
+	}
+	if passwordErr != nil {
+		s.metrics.logins.With(loginFailure).Inc()
+		if err := s.recordLoginFailure(ctx, user, now); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, servererrors.NewUnauthorizedError(i18n.MsgInvalidCredentials, nil)
+	}
+	if user.FailedLoginAttempts > 0 || user.LockedUntil != nil {
+		if err := s.userRepository.ResetLoginFailures(ctx, user.ID); err != nil {
+			s.metrics.logins.With(loginError).Inc()
+			return nil, nil, servererrors.NewInternalError("清空登录失败次数失败", err)
+		}
 	}
 
-	// 3. 生成 JWT
-	jwtToken, err := auth.GenerateJwtToken(user)
+	// 3. 开启新会话并签发令牌
+	tokens, err := s.tokenService.IssueTokens(ctx, user)
 	if err != nil {
-		log.Printf("UserService.Login: failed to generate jwt token: %v", err)
-		return nil, "", servererrors.NewInternalError("生成令牌失败", err)
+		s.metrics.logins.With(loginError).Inc()
+		return nil, nil, err
 	}
 
-	return user, jwtToken, nil
+	s.metrics.logins.With(loginSuccess).Inc()
+	return user, tokens, nil
 }
 
-func (s *userService) GetUserById(id int64) (*model.User, error) {
-	user, err := s.userRepository.GetUserBy(repository.QueryByID, strconv.FormatInt(id, 10))
+// recordLoginFailure 记录一次登录失败, 连续失败 MaxAttempts 次后锁定账号
+// 之后每多失败一次 (只有锁定结束后才会再次失败) 锁定时长翻倍, 最长 MaxDuration
+func (s *userService) recordLoginFailure(ctx context.Context, user *model.User, now time.Time) error {
+	if s.lockout.MaxAttempts == 0 {
+		return nil
+	}
+	attempts, err := s.userRepository.RecordLoginFailure(ctx, user.ID)
+	if err != nil {
+		return servererrors.NewInternalError("记录登录失败次数失败", err)
+	}
+	if attempts < s.lockout.MaxAttempts {
+		return nil
+	}
+
+	duration := s.lockout.Duration
+	for i := s.lockout.MaxAttempts; i < attempts && duration < s.lockout.MaxDuration; i++ {
+		duration *= 2
+	}
+	until := now.Add(min(duration, s.lockout.MaxDuration))
+	if err := s.userRepository.LockUser(ctx, user.ID, until); err != nil {
+		return servererrors.NewInternalError("锁定用户失败", err)
+	}
+	logging.FromContext(ctx).Warn("account locked after failed logins", "user_id", user.ID, "attempts", attempts, "locked_until", until)
+	return nil
+}
+
+// GetUserById 根据 ID 获取用户
+func (s *userService) GetUserById(ctx context.Context, id int64) (*model.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUserById")
+	defer span.End()
+
+	user, err := s.userRepository.GetUserBy(ctx, repository.QueryByID, strconv.FormatInt(id, 10))
 	if err != nil {
 		var se *servererrors.ServerError
 		if errors.As(err, &se) && se.Kind == servererrors.KindNotFound {
-			return nil, servererrors.NewNotFoundError("用户不存在", nil)
+			return nil, servererrors.NewNotFoundError(i18n.MsgUserNotFound, nil)
 		}
-		log.Printf("UserService.GetUserById: failed to get user by id: %v", err)
 		return nil, servererrors.NewInternalError("获取用户失败", err)
 	}
 
 	return user, nil
 }
+
+// GetUserByUsername 根据用户名获取用户
+func (s *userService) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUserByUsername")
+	defer span.End()
+
+	user, err := s.userRepository.GetUserBy(ctx, repository.QueryByUsername, username)
+	if err != nil {
+		if errors.Is(err, &servererrors.ServerError{Kind: servererrors.KindNotFound}) {
+			return nil, servererrors.NewNotFoundError(i18n.MsgUserNotFound, nil)
+		}
+		return nil, servererrors.NewInternalError("获取用户失败", err)
+	}
+	return user, nil
+}
+
+// UpdateProfile 修改用户的用户名, 邮箱和头像, 返回修改后的用户
+// 新的用户名或邮箱已被其他用户使用时返回 AlreadyExistsError;
+// 修改邮箱时当前密码不正确返回 ValidationError: 新邮箱可以用来重置密码, 不验证密码时拿到会话就能接管账号
+// 修改邮箱后需要重新验证, 验证邮件发送失败时只记录日志
+func (s *userService) UpdateProfile(ctx context.Context, id int64, profileDto v1.UpdateProfileDto) (*model.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.UpdateProfile")
+	defer span.End()
+
+	user, err := s.GetUserById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	emailChanged := profileDto.Email != nil && *profileDto.Email != user.Email
+	if emailChanged {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(profileDto.CurrentPassword)); err != nil {
+			return nil, servererrors.NewValidationError(i18n.MsgWrongPassword, nil)
+		}
+	}
+
+	usernameChanged := profileDto.Username != nil && *profileDto.Username != user.Username
+	if usernameChanged {
+		exists, err := s.userRepository.CheckExists(ctx, repository.QueryByUsername, *profileDto.Username)
+		if err != nil {
+			return nil, servererrors.NewInternalError("检查用户名是否存在失败", err)
+		}
+		if exists {
+			return nil, servererrors.NewAlreadyExistsError(i18n.MsgUsernameExists, nil)
+		}
+		user.Username = *profileDto.Username
+	}
+
+	if emailChanged {
+		exists, err := s.userRepository.CheckExists(ctx, repository.QueryByEmail, *profileDto.Email)
+		if err != nil {
+			return nil, servererrors.NewInternalError("检查邮箱是否存在失败", err)
+		}
+		if exists {
+			return nil, servererrors.NewAlreadyExistsError(i18n.MsgEmailExists, nil)
+		}
+		user.Email = *profileDto.Email
+		user.EmailVerifiedAt = nil
+	}
+
+	if profileDto.AvatarURL != nil {
+		user.AvatarURL = profileDto.AvatarURL
+		if *profileDto.AvatarURL == "" {
+			user.AvatarURL = nil
+		}
+	}
+
+	if err := s.userRepository.UpdateProfile(ctx, user); err != nil {
+		// 检查之后其他请求可能抢先使用了同一个用户名或邮箱, 由唯一索引拒绝
+		if errors.Is(err, &servererrors.ServerError{Kind: servererrors.KindAlreadyExists}) {
+			return nil, s.userConflict(ctx, user.Username, usernameChanged, emailChanged)
+		}
+		return nil, servererrors.NewInternalError("更新用户资料失败", err)
+	}
+
+	if emailChanged {
+		if err := s.accountService.OnEmailChanged(ctx, user.ID); err != nil {
+			logging.FromContext(ctx).Error("send verification mail failed", "user_id", user.ID, "error", err)
+		}
+	}
+	return user, nil
+}
+
+// userConflict 返回注册或保存资料时唯一索引冲突对应的错误
+// 唯一索引冲突不区分字段, 用户名和邮箱都写入时重新检查用户名是否被占用
+func (s *userService) userConflict(ctx context.Context, username string, usernameChanged, emailChanged bool) error {
+	if usernameChanged && emailChanged {
+		exists, err := s.userRepository.CheckExists(ctx, repository.QueryByUsername, username)
+		if err != nil {
+			return servererrors.NewInternalError("检查用户名是否存在失败", err)
+		}
+		usernameChanged = exists
+	}
+	if usernameChanged {
+		return servererrors.NewAlreadyExistsError(i18n.MsgUsernameExists, nil)
+	}
+	return servererrors.NewAlreadyExistsError(i18n.MsgEmailExists, nil)
+}
+
+// ChangePassword 验证当前密码后修改密码, 并在同一事务中吊销当前会话之外的所有会话
+// 其他会话的访问令牌随会话一起失效, 当前会话可以继续使用
+func (s *userService) ChangePassword(ctx context.Context, principal *auth.Principal, passwordDto v1.ChangePasswordDto) error {
+	ctx, span := tracing.Start(ctx, "UserService.ChangePassword")
+	defer span.End()
+
+	user, err := s.GetUserById(ctx, principal.UserID)
+	if err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(passwordDto.CurrentPassword)); err != nil {
+		return servererrors.NewValidationError(i18n.MsgWrongPassword, nil)
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(passwordDto.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return servererrors.NewInternalError("密码加密失败", err)
+	}
+	if err := s.userRepository.ChangePassword(ctx, user.ID, string(hashedPassword), principal.SessionID); err != nil {
+		return servererrors.NewInternalError("修改密码失败", err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package validator
+
+import (
+	"net/url"
+	"regexp"
+	"unicode"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+)
+
+// 密码长度限制, bcrypt 只使用前 72 个字节
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72
+)
+
+// usernamePattern 用户名只能包含字母、数字、下划线和连字符, 长度由 min, max 规则限制
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// slugPattern slug 由小写字母和数字组成的词以单个连字符连接, 例如 flight-rules
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+
+// rule 自定义校验规则及其各语言的错误描述, {0} 为字段名
+type rule struct {
+	tag string
+	fn  validator.Func
+	zh  string
+	en  string
+}
+
+var rules = []rule{
+	{
+		tag: "password",
+		fn:  isStrongPassword,
+		zh:  "{0}长度必须在8到72个字符之间, 且同时包含字母和数字",
+		en:  "{0} must be 8 to 72 characters long and contain both letters and digits",
+	},
+	{
+		tag: "username",
+		fn:  isUsername,
+		zh:  "{0}只能包含字母、数字、下划线和连字符",
+		en:  "{0} can only contain letters, digits, underscores and hyphens",
+	},
+	{
+		tag: "https_url",
+		fn:  isHTTPSURL,
+		zh:  "{0}必须是一个有效的 HTTPS 链接",
+		en:  "{0} must be a valid HTTPS URL",
+	},
+	{
+		tag: "slug",
+		fn:  isSlug,
+		zh:  "{0}只能包含小写字母、数字和单个连字符, 且不能以连字符开头或结尾",
+		en:  "{0} can only contain lowercase letters, digits and single hyphens, and cannot start or end with a hyphen",
+	},
+}
+
+// registerRules 注册自定义校验规则和对应的翻译
+func registerRules(zhTrans, enTrans ut.Translator) {
+	for _, r := range rules {
+		mustRegister(validate.RegisterValidation(r.tag, r.fn))
+		mustRegister(validate.RegisterTranslation(r.tag, zhTrans, registerMessage(r.tag, r.zh), translateMessage))
+		mustRegister(validate.RegisterTranslation(r.tag, enTrans, registerMessage(r.tag, r.en), translateMessage))
+	}
+}
+
+func registerMessage(tag, message string) validator.RegisterTranslationsFunc {
+	return func(trans ut.Translator) error {
+		return trans.Add(tag, message, false)
+	}
+}
+
+func translateMessage(trans ut.Translator, fe validator.FieldError) string {
+	message, err := trans.T(fe.Tag(), fe.Field())
+	if err != nil {
+		return fe.Error()
+	}
+	return message
+}
+
+// isStrongPassword 密码长度为 8 到 72 个字节, 且至少包含一个字母和一个数字
+func isStrongPassword(fl validator.FieldLevel) bool {
+	password := fl.Field().String()
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return false
+	}
+	var hasLetter, hasDigit bool
+	for _, c := range password {
+		switch {
+		case unicode.IsLetter(c):
+			hasLetter = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		}
+	}
+	return hasLetter && hasDigit
+}
+
+func isUsername(fl validator.FieldLevel) bool {
+	return usernamePattern.MatchString(fl.Field().String())
+}
+
+func isSlug(fl validator.FieldLevel) bool {
+	return slugPattern.MatchString(fl.Field().String())
+}
+
+// isHTTPSURL 只接受带主机名的 https 链接, 避免在页面中展示不安全或伪造协议的链接
+// 空字符串视为没有链接, 必填时需要同时使用 required; 指针字段可以用空字符串清除链接
+func isHTTPSURL(fl validator.FieldLevel) bool {
+	if fl.Field().String() == "" {
+		return true
+	}
+	u, err := url.Parse(fl.Field().String())
+	return err == nil && u.Scheme == "https" && u.Host != ""
+}
@@ -2,52 +2,126 @@ package validator
 
 import (
 	"errors"
-	"fmt"
+	"reflect"
+	servererrors "skymates-api/errors"
 	"strings"
 	"sync"
 
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
+	entranslations "github.com/go-playground/validator/v10/translations/en"
+	zhtranslations "github.com/go-playground/validator/v10/translations/zh"
 )
 
+// DefaultLocale 不支持请求的语言时使用的语言
+const DefaultLocale = "zh"
+
 var (
 	// validate 单例实例
 	validate *validator.Validate
-	once     sync.Once
+	// translators 支持的语言, 由 Translator 按请求的语言选择
+	translators *ut.UniversalTranslator
+	once        sync.Once
 )
 
 func init() {
 	once.Do(func() {
 		validate = validator.New(validator.WithRequiredStructEnabled())
+		// 错误中的字段名使用 JSON 字段名, 客户端可以直接对应到表单字段
+		validate.RegisterTagNameFunc(jsonFieldName)
+
+		translators = ut.New(zh.New(), zh.New(), en.New())
+		zhTrans, _ := translators.GetTranslator("zh")
+		enTrans, _ := translators.GetTranslator("en")
+		mustRegister(zhtranslations.RegisterDefaultTranslations(validate, zhTrans))
+		mustRegister(entranslations.RegisterDefaultTranslations(validate, enTrans))
+		registerRules(zhTrans, enTrans)
 	})
 }
 
-// ValidateRequest 校验传入的结构体 req
-// 如果校验不通过，返回格式化后的错误字符串；
-// 如果出现非校验类错误，则返回原始 error
-// 校验通过时返回空字符串和 nil
-func ValidateRequest(req interface{}) (string, error) {
-	if err := validate.Struct(req); err != nil {
-		var errs validator.ValidationErrors
-		if errors.As(err, &errs) {
-			return buildErrorString(errs), err
-		}
-		return "验证字段发生未知错误", err
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	Field   string `json:"field"`           // JSON 字段路径, 如 email, category_ids[0]
+	Rule    string `json:"rule"`            // 未通过的校验规则, 如 required, min
+	Param   string `json:"param,omitempty"` // 规则的参数, 如 min=6 中的 6
+	Message string `json:"message"`         // 按请求语言翻译的错误描述
+}
+
+// FieldErrors 请求中所有字段的校验错误, 作为 ServerError 的底层错误返回
+type FieldErrors []FieldError
+
+// Error 实现 error 接口, 拼接所有字段的错误描述
+func (e FieldErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldError := range e {
+		messages[i] = fieldError.Message
 	}
-	return "", nil
+	return strings.Join(messages, "; ")
 }
 
-// buildErrorString 将 validator.ValidationErrors 切片拼接为多行错误描述字符串
-// 每行格式: 完整路径 (字段名): failed '校验标签' [ param=参数]
-func buildErrorString(errs validator.ValidationErrors) string {
-	var sb strings.Builder
-	for _, e := range errs {
-		// e.StructNamespace() 返回类似 Parent.Child.Field 的完整路径
-		sb.WriteString(fmt.Sprintf("%s (%s): failed '%s'", e.StructNamespace(), e.Field(), e.Tag()))
-		// 某些校验标签带参数，如 max=10
-		if p := e.Param(); p != "" {
-			sb.WriteString(" param=" + p)
+// ValidateRequest 校验传入的结构体 req, locale 为 BCP 47 语言标签 (如 zh-CN, en), 决定错误描述的语言
+// 校验不通过时返回 KindValidation 的 ServerError, 可以通过 errors.As 取出其中的 FieldErrors;
+// 出现非校验类错误时 (比如 req 不是结构体) 返回内部错误
+// 校验通过时返回 nil
+func ValidateRequest(req interface{}, locale string) error {
+	err := validate.Struct(req)
+	if err == nil {
+		return nil
+	}
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return servererrors.NewInternalError("验证字段发生未知错误", err)
+	}
+
+	trans := Translator(locale)
+	fields := make(FieldErrors, len(errs))
+	for i, e := range errs {
+		fields[i] = FieldError{
+			Field:   fieldPath(e),
+			Rule:    e.Tag(),
+			Param:   e.Param(),
+			Message: e.Translate(trans),
 		}
-		sb.WriteByte('\n')
 	}
-	return sb.String()
+	return servererrors.NewValidationError(fields.Error(), fields)
+}
+
+// Translator 返回 locale 对应的翻译器, 匹配时忽略地区, zh-CN, zh-TW 都使用 zh
+// 不支持的语言使用 DefaultLocale
+func Translator(locale string) ut.Translator {
+	base, _, _ := strings.Cut(locale, "-")
+	if trans, found := translators.GetTranslator(strings.ToLower(base)); found {
+		return trans
+	}
+	trans, _ := translators.GetTranslator(DefaultLocale)
+	return trans
+}
+
+// jsonFieldName 返回结构体字段的 JSON 名称, 没有 json 标签时使用字段名
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+	return name
+}
+
+// fieldPath 返回去掉顶层结构体名的字段路径, 如 RegisterDto.email -> email
+func fieldPath(e validator.FieldError) string {
+	_, path, found := strings.Cut(e.Namespace(), ".")
+	if !found {
+		return e.Field()
+	}
+	return path
+}
+
+func mustRegister(err error) {
+	if err != nil {
+		panic(err)
+	}
 }
@@ -4,56 +4,88 @@ import (
 	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
-	"os"
-	"skymates-api/internal/model"
+	"github.com/google/uuid"
 	"time"
 )
 
-// TokenExpiry 是令牌的有效期，设为24小时
-const TokenExpiry = 24 * time.Hour
+// AccessTokenExpiry 是访问令牌的默认有效期，设为15分钟, 可以通过 jwt.access_token_ttl 配置
+// 访问令牌过期后客户端使用刷新令牌换取新的令牌
+const AccessTokenExpiry = 15 * time.Minute
 
 // Claims 是 JWT Payload 部分，明文的，不要存储敏感信息
 // JWT 的结构:
-// 1. Header: 描述签名算法(如 HS256)
+// 1. Header: 描述签名算法(如 EdDSA)以及签名密钥的 kid
 // 2. Payload: 存储 Claims 信息
 // 3. Signature: 签名，用于验证 token 完整性
+// RegisteredClaims.ID 即 jti, 用于吊销单个访问令牌
 type Claims struct {
-	Username string `json:"username"`
+	UserID    int64  `json:"uid"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"` // 对应刷新令牌的 family, 用于登出时吊销整个会话
+	// EmailVerified 签发令牌时用户是否已验证邮箱, 验证后刷新令牌即可获得新的值
+	EmailVerified bool `json:"email_verified,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateJwtToken 生成JWT令牌
-func GenerateJwtToken(user *model.User) (string, error) {
-	expirationTime := time.Now().Add(TokenExpiry)
+// Principal 根据 Claims 构造当前用户
+func (c *Claims) Principal() *Principal {
+	principal := &Principal{
+		UserID:    c.UserID,
+		Role:      c.Role,
+		SessionID: c.SessionID,
+		TokenID:   c.ID,
+
+		EmailVerified: c.EmailVerified,
+	}
+	if c.ExpiresAt != nil {
+		principal.TokenExpiresAt = c.ExpiresAt.Time
+	}
+	return principal
+}
+
+// Subject 访问令牌代表的用户, 由调用方根据用户记录填写
+// 令牌中只保存不会由用户自己修改的信息, 用户名等资料修改后已签发的令牌不会过时
+type Subject struct {
+	UserID        int64
+	Role          string
+	EmailVerified bool
+}
+
+// GenerateJwtToken 为 sessionID 对应的会话生成访问令牌, 返回令牌及其过期时间
+func (m *KeyManager) GenerateJwtToken(subject Subject, sessionID string) (string, time.Time, error) {
+	expirationTime := time.Now().Add(m.accessTokenTTL)
 
 	claims := &Claims{
-		Username: user.Username,
+		UserID:    subject.UserID,
+		Role:      subject.Role,
+		SessionID: sessionID,
+
+		EmailVerified: subject.EmailVerified,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "skymates",
+			Issuer:    m.issuer,
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	secretKey := []byte(os.Getenv("JWT_SECRET"))
-	return token.SignedString(secretKey)
+	signed, err := m.Sign(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expirationTime, nil
 }
 
 // ValidateJwtToken 验证JWT令牌并返回Claims
-func ValidateJwtToken(tokenString string) (*Claims, error) {
+func (m *KeyManager) ValidateJwtToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(
 		tokenString,
 		claims,
-		func(token *jwt.Token) (interface{}, error) {
-			// 验证签名算法是否属于 HMAC 家族, HS256, HS384 和 HS512 等所有 HMAC 算法
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		},
+		m.keyfunc,
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(m.issuer),
 	)
 
 	if err != nil {
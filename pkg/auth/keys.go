@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"log/slog"
+	"math/big"
+	"os"
+	"skymates-api/config"
+	"sort"
+	"time"
+)
+
+// 支持的签名算法
+const (
+	AlgorithmEdDSA = "EdDSA"
+	AlgorithmRS256 = "RS256"
+)
+
+// Key 表示一个由 kid 标识的 JWT 密钥
+// 只有 private 不为空的密钥可以用于签名, 所有密钥都可以用于验证
+type Key struct {
+	ID        string
+	Algorithm string
+	method    jwt.SigningMethod
+	private   crypto.Signer
+	public    crypto.PublicKey
+}
+
+// KeyManager 管理签发和验证 JWT 所需的密钥
+// 签发时使用当前激活的密钥并在 Header 中写入 kid, 验证时根据 kid 查找对应的公钥,
+// 这样轮换密钥时旧密钥签发的令牌在过期前依然有效
+type KeyManager struct {
+	issuer         string
+	accessTokenTTL time.Duration
+	active         *Key
+	keys           map[string]*Key
+}
+
+// NewKeyManager 根据配置从文件加载密钥
+// 如果没有配置任何密钥, 会生成一个临时的 Ed25519 密钥, 仅适用于本地开发, 重启后所有令牌失效
+func NewKeyManager(cfg config.JWTConfig) (*KeyManager, error) {
+	m := &KeyManager{
+		issuer:         cfg.Issuer,
+		accessTokenTTL: cfg.AccessTokenTTL,
+		keys:           make(map[string]*Key),
+	}
+	if m.accessTokenTTL <= 0 {
+		m.accessTokenTTL = AccessTokenExpiry
+	}
+
+	if len(cfg.Keys) == 0 {
+		slog.Warn("no jwt keys configured, using an ephemeral Ed25519 key")
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("generate ephemeral key: %w", err)
+		}
+		key := &Key{ID: "ephemeral", Algorithm: AlgorithmEdDSA, method: jwt.SigningMethodEdDSA, private: private, public: private.Public()}
+		m.keys[key.ID] = key
+		m.active = key
+		return m, nil
+	}
+
+	for _, keyCfg := range cfg.Keys {
+		key, err := loadKey(keyCfg)
+		if err != nil {
+			return nil, fmt.Errorf("load jwt key %q: %w", keyCfg.ID, err)
+		}
+		if _, ok := m.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate jwt key id %q", key.ID)
+		}
+		m.keys[key.ID] = key
+	}
+
+	active, ok := m.keys[cfg.ActiveKey]
+	if !ok {
+		return nil, fmt.Errorf("active jwt key %q is not configured", cfg.ActiveKey)
+	}
+	if active.private == nil {
+		return nil, fmt.Errorf("active jwt key %q has no private key", cfg.ActiveKey)
+	}
+	m.active = active
+	return m, nil
+}
+
+// Issuer 返回令牌的签发者
+func (m *KeyManager) Issuer() string {
+	return m.issuer
+}
+
+// Sign 使用当前激活的密钥签名 claims
+func (m *KeyManager) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(m.active.method, claims)
+	token.Header["kid"] = m.active.ID
+	return token.SignedString(m.active.private)
+}
+
+// keyfunc 根据令牌 Header 中的 kid 查找验证用的公钥, 并确认签名算法与密钥一致
+// 必须校验算法, 否则攻击者可以把 alg 改成其他算法绕过签名验证
+func (m *KeyManager) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := m.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+// JWK 是 RFC 7517 定义的 JSON Web Key, 只包含公钥部分
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	Curve     string `json:"crv,omitempty"` // OKP
+	X         string `json:"x,omitempty"`   // OKP
+	N         string `json:"n,omitempty"`   // RSA
+	E         string `json:"e,omitempty"`   // RSA
+}
+
+// JWKSet 是 /.well-known/jwks.json 返回的密钥集合
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS 返回所有验证密钥的公钥集合, 其他服务可以据此独立验证令牌
+func (m *KeyManager) JWKS() JWKSet {
+	set := JWKSet{Keys: make([]JWK, 0, len(m.keys))}
+	for _, key := range m.keys {
+		jwk := JWK{KeyID: key.ID, Algorithm: key.Algorithm, Use: "sig"}
+		switch public := key.public.(type) {
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	// map 遍历顺序不固定, 按 kid 排序保证输出稳定
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
+}
+
+// loadKey 根据配置读取 PEM 格式的私钥或公钥
+func loadKey(cfg config.JWTKeyConfig) (*Key, error) {
+	if cfg.ID == "" {
+		return nil, fmt.Errorf("kid is required")
+	}
+	key := &Key{ID: cfg.ID, Algorithm: cfg.Algorithm}
+
+	switch cfg.Algorithm {
+	case AlgorithmEdDSA:
+		key.method = jwt.SigningMethodEdDSA
+	case AlgorithmRS256:
+		key.method = jwt.SigningMethodRS256
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", cfg.Algorithm)
+	}
+
+	if cfg.PrivateKeyFile != "" {
+		data, err := os.ReadFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		var private crypto.Signer
+		if cfg.Algorithm == AlgorithmEdDSA {
+			parsed, err := jwt.ParseEdPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			private = parsed.(ed25519.PrivateKey)
+		} else {
+			private, err = jwt.ParseRSAPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+		}
+		key.private = private
+		key.public = private.Public()
+		return key, nil
+	}
+
+	if cfg.PublicKeyFile != "" {
+		data, err := os.ReadFile(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Algorithm == AlgorithmEdDSA {
+			key.public, err = jwt.ParseEdPublicKeyFromPEM(data)
+		} else {
+			key.public, err = jwt.ParseRSAPublicKeyFromPEM(data)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("either private_key_file or public_key_file is required")
+}
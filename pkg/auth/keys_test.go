@@ -0,0 +1,240 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"skymates-api/config"
+	"skymates-api/pkg/auth"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// keyFiles 在临时目录中生成一对 PEM 格式的私钥和公钥文件
+func keyFiles(t *testing.T, algorithm string) (privateFile, publicFile string) {
+	t.Helper()
+	var private crypto.Signer
+	switch algorithm {
+	case auth.AlgorithmEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		private = key
+	case auth.AlgorithmRS256:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		private = key
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	privateFile = filepath.Join(dir, "private.pem")
+	publicFile = filepath.Join(dir, "public.pem")
+	if err := os.WriteFile(privateFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(publicFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return privateFile, publicFile
+}
+
+func newKeyManager(t *testing.T, active string, keys ...config.JWTKeyConfig) *auth.KeyManager {
+	t.Helper()
+	m, err := auth.NewKeyManager(config.JWTConfig{Issuer: "test", ActiveKey: active, Keys: keys})
+	if err != nil {
+		t.Fatalf("new key manager: %v", err)
+	}
+	return m
+}
+
+func generate(t *testing.T, m *auth.KeyManager) string {
+	t.Helper()
+	token, _, err := m.GenerateJwtToken(auth.Subject{UserID: 7, Role: "user"}, "session")
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	return token
+}
+
+func kid(t *testing.T, token string) string {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &auth.Claims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldPrivate, oldPublic := keyFiles(t, auth.AlgorithmEdDSA)
+	newPrivate, _ := keyFiles(t, auth.AlgorithmRS256)
+
+	before := newKeyManager(t, "old", config.JWTKeyConfig{ID: "old", Algorithm: auth.AlgorithmEdDSA, PrivateKeyFile: oldPrivate})
+	oldToken := generate(t, before)
+	if got := kid(t, oldToken); got != "old" {
+		t.Fatalf("kid = %q, want old", got)
+	}
+
+	// 轮换后旧密钥只保留公钥用于验证, 新令牌由新密钥签发
+	after := newKeyManager(t, "new",
+		config.JWTKeyConfig{ID: "old", Algorithm: auth.AlgorithmEdDSA, PublicKeyFile: oldPublic},
+		config.JWTKeyConfig{ID: "new", Algorithm: auth.AlgorithmRS256, PrivateKeyFile: newPrivate},
+	)
+	claims, err := after.ValidateJwtToken(oldToken)
+	if err != nil {
+		t.Fatalf("validate old token after rotation: %v", err)
+	}
+	if claims.UserID != 7 || claims.SessionID != "session" {
+		t.Fatalf("claims = %+v, want user 7 in session", claims)
+	}
+	newToken := generate(t, after)
+	if got := kid(t, newToken); got != "new" {
+		t.Fatalf("kid = %q, want new", got)
+	}
+	if _, err := after.ValidateJwtToken(newToken); err != nil {
+		t.Fatalf("validate new token: %v", err)
+	}
+	// 还没有加入新密钥的实例不认识新的 kid
+	if _, err := before.ValidateJwtToken(newToken); err == nil {
+		t.Fatal("expected a token with an unknown kid to be rejected")
+	}
+
+	// 移除旧密钥后旧令牌失效
+	retired := newKeyManager(t, "new", config.JWTKeyConfig{ID: "new", Algorithm: auth.AlgorithmRS256, PrivateKeyFile: newPrivate})
+	if _, err := retired.ValidateJwtToken(oldToken); err == nil {
+		t.Fatal("expected a token signed by a removed key to be rejected")
+	}
+}
+
+func TestValidateRejectsForgedHeaders(t *testing.T) {
+	edPrivate, _ := keyFiles(t, auth.AlgorithmEdDSA)
+	rsaPrivate, _ := keyFiles(t, auth.AlgorithmRS256)
+	m := newKeyManager(t, "ed",
+		config.JWTKeyConfig{ID: "ed", Algorithm: auth.AlgorithmEdDSA, PrivateKeyFile: edPrivate},
+		config.JWTKeyConfig{ID: "rsa", Algorithm: auth.AlgorithmRS256, PrivateKeyFile: rsaPrivate},
+	)
+	other := newKeyManager(t, "ed", config.JWTKeyConfig{ID: "ed", Algorithm: auth.AlgorithmEdDSA, PrivateKeyFile: edPrivate})
+
+	// 用 rsa 密钥签名但在 Header 中声明其他 kid
+	rsaSigned := newKeyManager(t, "rsa", config.JWTKeyConfig{ID: "rsa", Algorithm: auth.AlgorithmRS256, PrivateKeyFile: rsaPrivate})
+	token := generate(t, rsaSigned)
+	parts := strings.Split(token, ".")
+
+	for _, c := range []struct {
+		name   string
+		header string
+	}{
+		{"unknown kid", `{"alg":"RS256","kid":"missing","typ":"JWT"}`},
+		{"missing kid", `{"alg":"RS256","typ":"JWT"}`},
+		{"algorithm of another key", `{"alg":"RS256","kid":"ed","typ":"JWT"}`},
+		{"none algorithm", `{"alg":"none","kid":"ed","typ":"JWT"}`},
+	} {
+		forged := base64.RawURLEncoding.EncodeToString([]byte(c.header)) + "." + parts[1] + "." + parts[2]
+		if _, err := m.ValidateJwtToken(forged); err == nil {
+			t.Errorf("%s: expected the token to be rejected", c.name)
+		}
+	}
+
+	// 签名者相同但 issuer 不同
+	foreign, err := auth.NewKeyManager(config.JWTConfig{Issuer: "other", ActiveKey: "ed", Keys: []config.JWTKeyConfig{
+		{ID: "ed", Algorithm: auth.AlgorithmEdDSA, PrivateKeyFile: edPrivate},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.ValidateJwtToken(generate(t, foreign)); err == nil {
+		t.Error("expected a token from another issuer to be rejected")
+	}
+}
+
+func TestNewKeyManagerErrors(t *testing.T) {
+	edPrivate, edPublic := keyFiles(t, auth.AlgorithmEdDSA)
+	rsaPrivate, _ := keyFiles(t, auth.AlgorithmRS256)
+
+	for _, c := range []struct {
+		name   string
+		active string
+		keys   []config.JWTKeyConfig
+		want   string
+	}{
+		{"active not configured", "missing", []config.JWTKeyConfig{{ID: "ed", Algorithm: auth.AlgorithmEdDSA, PrivateKeyFile: edPrivate}}, "not configured"},
+		{"active without private key", "ed", []config.JWTKeyConfig{{ID: "ed", Algorithm: auth.AlgorithmEdDSA, PublicKeyFile: edPublic}}, "no private key"},
+		{"duplicate kid", "ed", []config.JWTKeyConfig{
+			{ID: "ed", Algorithm: auth.AlgorithmEdDSA, PrivateKeyFile: edPrivate},
+			{ID: "ed", Algorithm: auth.AlgorithmEdDSA, PublicKeyFile: edPublic},
+		}, "duplicate"},
+		{"missing kid", "", []config.JWTKeyConfig{{Algorithm: auth.AlgorithmEdDSA, PrivateKeyFile: edPrivate}}, "kid is required"},
+		{"unsupported algorithm", "hs", []config.JWTKeyConfig{{ID: "hs", Algorithm: "HS256", PrivateKeyFile: edPrivate}}, "unsupported algorithm"},
+		{"no key file", "ed", []config.JWTKeyConfig{{ID: "ed", Algorithm: auth.AlgorithmEdDSA}}, "required"},
+		{"missing file", "ed", []config.JWTKeyConfig{{ID: "ed", Algorithm: auth.AlgorithmEdDSA, PrivateKeyFile: filepath.Join(t.TempDir(), "none.pem")}}, "no such file"},
+		{"algorithm does not match key", "ed", []config.JWTKeyConfig{{ID: "ed", Algorithm: auth.AlgorithmEdDSA, PrivateKeyFile: rsaPrivate}}, "not a valid Ed25519 private key"},
+	} {
+		_, err := auth.NewKeyManager(config.JWTConfig{ActiveKey: c.active, Keys: c.keys})
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: err = %v, want it to contain %q", c.name, err, c.want)
+		}
+	}
+}
+
+func TestEphemeralKey(t *testing.T) {
+	m, err := auth.NewKeyManager(config.JWTConfig{Issuer: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, expiresAt, err := m.GenerateJwtToken(auth.Subject{UserID: 1}, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 没有配置有效期时使用默认值
+	if d := time.Until(expiresAt); d <= auth.AccessTokenExpiry-time.Minute || d > auth.AccessTokenExpiry {
+		t.Fatalf("token expires in %v, want about %v", d, auth.AccessTokenExpiry)
+	}
+	if _, err := m.ValidateJwtToken(token); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if keys := m.JWKS().Keys; len(keys) != 1 || keys[0].KeyID != "ephemeral" {
+		t.Fatalf("jwks = %+v, want the ephemeral key", keys)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	edPrivate, _ := keyFiles(t, auth.AlgorithmEdDSA)
+	_, rsaPublic := keyFiles(t, auth.AlgorithmRS256)
+	m := newKeyManager(t, "b-ed",
+		config.JWTKeyConfig{ID: "b-ed", Algorithm: auth.AlgorithmEdDSA, PrivateKeyFile: edPrivate},
+		config.JWTKeyConfig{ID: "a-rsa", Algorithm: auth.AlgorithmRS256, PublicKeyFile: rsaPublic},
+	)
+
+	keys := m.JWKS().Keys
+	if len(keys) != 2 || keys[0].KeyID != "a-rsa" || keys[1].KeyID != "b-ed" {
+		t.Fatalf("jwks = %+v, want a-rsa then b-ed", keys)
+	}
+	if rsaKey := keys[0]; rsaKey.KeyType != "RSA" || rsaKey.Algorithm != auth.AlgorithmRS256 || rsaKey.N == "" || rsaKey.E != "AQAB" || rsaKey.X != "" {
+		t.Fatalf("rsa jwk = %+v", rsaKey)
+	}
+	if edKey := keys[1]; edKey.KeyType != "OKP" || edKey.Curve != "Ed25519" || edKey.Algorithm != auth.AlgorithmEdDSA || len(edKey.X) != 43 || edKey.Use != "sig" {
+		t.Fatalf("ed25519 jwk = %+v", edKey)
+	}
+}
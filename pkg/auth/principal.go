@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"context"
+	"time"
+)
+
+// Principal 表示已认证的当前用户, 由 Auth 中间件根据 JWT Claims 构造并放入 context
+// 服务层可以直接根据它做归属判断, 无需再查询数据库
+type Principal struct {
+	UserID int64
+	Role   string
+	// EmailVerified 签发访问令牌时用户是否已验证邮箱
+	EmailVerified bool
+
+	// 当前访问令牌的信息, 用于登出时吊销令牌
+	SessionID      string
+	TokenID        string
+	TokenExpiresAt time.Time
+}
+
+// principalKey 是 context 中存放 Principal 的 key
+// 使用未导出的类型, 避免与其他包的 key 冲突
+type principalKey struct{}
+
+// WithPrincipal 返回携带 principal 的新 context
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFrom 从 context 中取出当前用户, 未认证时返回 nil, false
+func PrincipalFrom(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
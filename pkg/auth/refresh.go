@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+// RefreshTokenExpiry 是刷新令牌的默认有效期，设为30天, 可以通过 jwt.refresh_token_ttl 配置
+const RefreshTokenExpiry = 30 * 24 * time.Hour
+
+// RevocationChecker 检查访问令牌或它所属的会话是否已被吊销, 由 Auth 中间件在验证签名后调用
+// jti 和 sessionID 分别来自令牌的 jti 和 sid, 为空时不检查
+type RevocationChecker interface {
+	IsTokenRevoked(ctx context.Context, jti, sessionID string) (bool, error)
+}
+
+// GenerateRefreshToken 生成一个随机的刷新令牌
+// 刷新令牌是不透明的随机字符串, 不是 JWT, 只能通过数据库校验
+func GenerateRefreshToken() (string, error) {
+	return GenerateToken()
+}
+
+// GenerateToken 生成 32 字节的随机令牌, 编码为 URL 安全的 base64, 可以直接放在链接中
+func GenerateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken 返回令牌的 SHA-256 哈希, 数据库中只保存哈希值
+// GenerateToken 生成的令牌本身有足够的熵, 不需要像密码一样使用 bcrypt
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
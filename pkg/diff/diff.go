@@ -0,0 +1,194 @@
+// Package diff 按词比较两段文本, 用于展示术语修订版本之间的差异
+package diff
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// maxEdits 差异计算允许的最大编辑次数 (插入和删除的词数之和), 超过时把中间不同的部分整体作为删除和插入,
+// 避免两段差别很大的长文本占用过多的时间和内存
+const maxEdits = 1000
+
+// Op 一段文本的类型
+type Op int
+
+const (
+	Equal  Op = iota // 两段文本中都有
+	Insert           // 只在新文本中
+	Delete           // 只在旧文本中
+)
+
+// Change 差异中的一段文本, 相邻的同类型的词合并为一段
+type Change struct {
+	Op   Op
+	Text string
+}
+
+// Words 返回从 a 到 b 按词的差异, 依次拼接 Equal 和 Delete 得到 a, 拼接 Equal 和 Insert 得到 b
+// 相邻的删除和插入中删除总是在前; 两段文本相同时返回一个 Equal, 都为空时返回 nil
+func Words(a, b string) []Change {
+	x, y := tokens(a), tokens(b)
+
+	// 去掉相同的开头和结尾, 只比较中间的部分
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+
+	var ops []Op
+	ops = appendOps(ops, Equal, prefix)
+	ops = append(ops, shortestEdit(x[prefix:len(x)-suffix], y[prefix:len(y)-suffix])...)
+	ops = appendOps(ops, Equal, suffix)
+	return merge(ops, x, y)
+}
+
+// tokens 把文本拆分为词: 连续的字母和数字, 连续的空白各为一个词, 汉字和其他字符每个字符为一个词
+// 中文不以空格分词, 逐字比较
+func tokens(s string) []string {
+	var result []string
+	start, class := 0, classOther
+	for i, r := range s {
+		c := classify(r)
+		if i > start && (c != class || c == classOther) {
+			result = append(result, s[start:i])
+			start = i
+		}
+		class = c
+	}
+	if start < len(s) {
+		result = append(result, s[start:])
+	}
+	return result
+}
+
+const (
+	classOther = iota
+	classWord
+	classSpace
+)
+
+func classify(r rune) int {
+	switch {
+	case unicode.Is(unicode.Han, r):
+		return classOther
+	case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r):
+		return classWord
+	case unicode.IsSpace(r):
+		return classSpace
+	default:
+		return classOther
+	}
+}
+
+func appendOps(ops []Op, op Op, n int) []Op {
+	for range n {
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+// shortestEdit 用 Myers 差分算法求把 x 变为 y 的最短编辑序列, 每个词对应一个操作;
+// 编辑次数超过 maxEdits 时返回删除全部 x 再插入全部 y
+func shortestEdit(x, y []string) []Op {
+	n, m := len(x), len(y)
+	limit := min(n+m, maxEdits)
+	// v[offset+k] 是对角线 k (x 的位置减 y 的位置) 上当前能到达的最远的 x 的位置
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	// trace[d] 保存第 d 步开始前对角线 -d 到 d 上的 v, 用于回溯
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, slices.Clone(v[offset-d:offset+d+1]))
+		for k := -d; k <= d; k += 2 {
+			var i int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				i = v[offset+k+1] // 从对角线 k+1 向下: 插入 y 的一个词
+			} else {
+				i = v[offset+k-1] + 1 // 从对角线 k-1 向右: 删除 x 的一个词
+			}
+			j := i - k
+			for i < n && j < m && x[i] == y[j] {
+				i, j = i+1, j+1
+			}
+			v[offset+k] = i
+			if i >= n && j >= m {
+				return backtrack(trace, n, m)
+			}
+		}
+	}
+	return appendOps(appendOps(nil, Delete, n), Insert, m)
+}
+
+// backtrack 从终点沿 trace 回到起点, 按从前到后的顺序返回操作
+func backtrack(trace [][]int, n, m int) []Op {
+	var reversed []Op
+	i, j := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d] // 第 d-1 步结束后的 v, prev[d+k] 对应对角线 k
+		k := i - j
+		var prevK int
+		if k == -d || (k != d && prev[d+k-1] < prev[d+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevI := prev[d+prevK]
+		prevJ := prevI - prevK
+		// 第 d 步先插入或删除一个词, 再沿对角线经过相同的词
+		for i > prevI && j > prevJ {
+			reversed = append(reversed, Equal)
+			i, j = i-1, j-1
+		}
+		if i == prevI {
+			reversed = append(reversed, Insert)
+		} else {
+			reversed = append(reversed, Delete)
+		}
+		i, j = prevI, prevJ
+	}
+	// 第 0 步只有相同的词
+	reversed = appendOps(reversed, Equal, i)
+	slices.Reverse(reversed)
+	return reversed
+}
+
+// merge 按操作取出对应的词, 合并为 Change; 连续的删除和插入合并为一段删除和一段插入
+func merge(ops []Op, x, y []string) []Change {
+	var changes []Change
+	var equal, deleted, inserted strings.Builder
+	flush := func(op Op, b *strings.Builder) {
+		if b.Len() > 0 {
+			changes = append(changes, Change{op, b.String()})
+			b.Reset()
+		}
+	}
+
+	i, j := 0, 0
+	for _, op := range ops {
+		switch op {
+		case Equal:
+			flush(Delete, &deleted)
+			flush(Insert, &inserted)
+			equal.WriteString(x[i])
+			i, j = i+1, j+1
+		case Delete:
+			flush(Equal, &equal)
+			deleted.WriteString(x[i])
+			i++
+		case Insert:
+			flush(Equal, &equal)
+			inserted.WriteString(y[j])
+			j++
+		}
+	}
+	flush(Equal, &equal)
+	flush(Delete, &deleted)
+	flush(Insert, &inserted)
+	return changes
+}
@@ -0,0 +1,178 @@
+package diff
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// rebuild 拼接 Equal 和 op 类型的文本, op 为 Delete 时得到旧文本, 为 Insert 时得到新文本
+func rebuild(changes []Change, op Op) string {
+	var b strings.Builder
+	for _, c := range changes {
+		if c.Op == Equal || c.Op == op {
+			b.WriteString(c.Text)
+		}
+	}
+	return b.String()
+}
+
+// checkChanges 检查 Words 的结果可以还原两段文本, 相邻的 Change 类型不同, 相邻的删除和插入中删除在前
+func checkChanges(t *testing.T, a, b string, changes []Change) {
+	t.Helper()
+	if got := rebuild(changes, Delete); got != a {
+		t.Fatalf("Words(%q, %q): Equal+Delete = %q, want %q", a, b, got, a)
+	}
+	if got := rebuild(changes, Insert); got != b {
+		t.Fatalf("Words(%q, %q): Equal+Insert = %q, want %q", a, b, got, b)
+	}
+	for i, c := range changes {
+		if c.Text == "" {
+			t.Fatalf("Words(%q, %q): change %d is empty", a, b, i)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := changes[i-1].Op
+		if prev == c.Op || (prev == Insert && c.Op == Delete) {
+			t.Fatalf("Words(%q, %q): change %d %v follows %v", a, b, i, c.Op, prev)
+		}
+	}
+}
+
+func TestWords(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		a, b string
+		want []Change
+	}{
+		{"empty", "", "", nil},
+		{"identical", "A hinged surface", "A hinged surface", []Change{{Equal, "A hinged surface"}}},
+		{"insert into empty", "", "flap down", []Change{{Insert, "flap down"}}},
+		{"delete to empty", "flap down", "", []Change{{Delete, "flap down"}}},
+		{"pure insert", "A hinged surface on the wing", "A hinged surface on the trailing edge of the wing", []Change{
+			{Equal, "A hinged surface on the "}, {Insert, "trailing edge of the "}, {Equal, "wing"},
+		}},
+		{"pure delete", "A hinged surface on the trailing edge of the wing", "A hinged surface on the wing", []Change{
+			{Equal, "A hinged surface on the "}, {Delete, "trailing edge of the "}, {Equal, "wing"},
+		}},
+		{"replace", "flap up", "flap down", []Change{{Equal, "flap "}, {Delete, "up"}, {Insert, "down"}}},
+		{"whole words", "takeoff", "takeoffs", []Change{{Delete, "takeoff"}, {Insert, "takeoffs"}}},
+		{"punctuation", "wing, flap", "wing; flap", []Change{{Equal, "wing"}, {Delete, ","}, {Insert, ";"}, {Equal, " flap"}}},
+		// 汉字逐字比较
+		{"chinese", "飞行计划", "飞行规划", []Change{{Equal, "飞行"}, {Delete, "计"}, {Insert, "规"}, {Equal, "划"}}},
+		{"chinese insert", "目视规则", "目视飞行规则", []Change{{Equal, "目视"}, {Insert, "飞行"}, {Equal, "规则"}}},
+		{"mixed", "VFR 飞行", "IFR 飞行", []Change{{Delete, "VFR"}, {Insert, "IFR"}, {Equal, " 飞行"}}},
+	} {
+		got := Words(c.a, c.b)
+		if !slices.Equal(got, c.want) {
+			t.Errorf("%s: Words(%q, %q) = %+v, want %+v", c.name, c.a, c.b, got, c.want)
+			continue
+		}
+		checkChanges(t, c.a, c.b, got)
+	}
+}
+
+func TestTokens(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"flap", []string{"flap"}},
+		{"Hello, world", []string{"Hello", ",", " ", "world"}},
+		{"a  b\n", []string{"a", "  ", "b", "\n"}},
+		{"飞行 VFR", []string{"飞", "行", " ", "VFR"}},
+		{"A320机型", []string{"A320", "机", "型"}},
+		{"café", []string{"café"}},
+		{"--", []string{"-", "-"}},
+	} {
+		if got := tokens(c.in); !slices.Equal(got, c.want) {
+			t.Errorf("tokens(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+// lcs 用动态规划求 x 和 y 的最长公共子序列的长度
+func lcs(x, y []string) int {
+	dp := make([][]int, len(x)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else {
+				dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+			}
+		}
+	}
+	return dp[0][0]
+}
+
+func TestShortestEditIsMinimal(t *testing.T) {
+	vocabulary := []string{"a", "b", "c", " ", "飞"}
+	r := rand.New(rand.NewPCG(1, 2))
+	random := func() []string {
+		s := make([]string, r.IntN(12))
+		for i := range s {
+			s[i] = vocabulary[r.IntN(len(vocabulary))]
+		}
+		return s
+	}
+	for range 500 {
+		x, y := random(), random()
+		ops := shortestEdit(x, y)
+		equal, deleted, inserted := 0, 0, 0
+		for _, op := range ops {
+			switch op {
+			case Equal:
+				equal++
+			case Delete:
+				deleted++
+			case Insert:
+				inserted++
+			}
+		}
+		if equal+deleted != len(x) || equal+inserted != len(y) {
+			t.Fatalf("shortestEdit(%q, %q) = %v, does not cover both sides", x, y, ops)
+		}
+		if want := lcs(x, y); equal != want {
+			t.Fatalf("shortestEdit(%q, %q) keeps %d tokens, want %d", x, y, equal, want)
+		}
+
+		a, b := strings.Join(x, ""), strings.Join(y, "")
+		checkChanges(t, a, b, Words(a, b))
+	}
+}
+
+func TestWordsFallsBackAfterMaxEdits(t *testing.T) {
+	words := func(prefix string, n int) string {
+		parts := make([]string, n)
+		for i := range parts {
+			parts[i] = prefix + strings.Repeat("x", i%7) + string(rune('a'+i%26))
+		}
+		return strings.Join(parts, " ")
+	}
+	// 中间部分只有词之间的空格相同, 删除和插入的词数之和超过 maxEdits
+	oldMiddle, newMiddle := words("old", maxEdits/2+1), words("new", maxEdits/2+1)
+	a := "start " + oldMiddle + " end"
+	b := "start " + newMiddle + " end"
+	got := Words(a, b)
+	checkChanges(t, a, b, got)
+	want := []Change{{Equal, "start "}, {Delete, oldMiddle}, {Insert, newMiddle}, {Equal, " end"}}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Words = %d changes, want the middle deleted and inserted as a whole", len(got))
+	}
+
+	// 编辑次数在限制之内时仍然逐词比较
+	a = words("w", maxEdits/4)
+	b = strings.ReplaceAll(a, "wa", "za")
+	got = Words(a, b)
+	checkChanges(t, a, b, got)
+	if len(got) < 3 {
+		t.Fatalf("Words = %+v, want word level changes", got)
+	}
+}
@@ -0,0 +1,176 @@
+// Package health 提供存活和就绪检查
+// 各个子系统实现 HealthChecker 并注册到 Registry, /readyz 会并发执行所有检查并返回每一项的结果
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 检查结果状态
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// HealthChecker 检查一个依赖是否可用, 返回 nil 表示可用
+// Check 应该尊重 ctx 的超时, 不要长时间阻塞
+type HealthChecker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+type checkerFunc struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+func (c checkerFunc) Name() string                    { return c.name }
+func (c checkerFunc) Check(ctx context.Context) error { return c.check(ctx) }
+
+// NewChecker 使用函数创建 HealthChecker
+func NewChecker(name string, check func(ctx context.Context) error) HealthChecker {
+	return checkerFunc{name: name, check: check}
+}
+
+// Pinger 是可以测试连通性的依赖, *sql.DB 和 *sqlx.DB 都满足
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// PingChecker 检查数据库等依赖能否连通
+func PingChecker(name string, pinger Pinger) HealthChecker {
+	return NewChecker(name, pinger.PingContext)
+}
+
+// PoolChecker 检查连接池是否饱和: 使用中的连接数达到最大连接数的 threshold (0~1) 时视为不可用,
+// 让负载均衡器暂时把请求转发给其他实例
+func PoolChecker(name string, db *sql.DB, threshold float64) HealthChecker {
+	return NewChecker(name, func(ctx context.Context) error {
+		stats := db.Stats()
+		if stats.MaxOpenConnections <= 0 {
+			return nil // 没有限制最大连接数
+		}
+		usage := float64(stats.InUse) / float64(stats.MaxOpenConnections)
+		if usage >= threshold {
+			return fmt.Errorf("connection pool saturated: %d/%d connections in use, %d waits so far",
+				stats.InUse, stats.MaxOpenConnections, stats.WaitCount)
+		}
+		return nil
+	})
+}
+
+// CheckResult 单个检查的结果
+type CheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report 所有检查的结果, 任意一项不可用时 Status 为 down
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Registry 保存所有就绪检查
+type Registry struct {
+	timeout time.Duration
+
+	mu       sync.RWMutex
+	checkers []HealthChecker
+}
+
+// NewRegistry 创建 Registry, timeout 为每个检查的超时时间
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{timeout: timeout}
+}
+
+// Register 注册就绪检查, 可以在服务运行期间调用
+func (r *Registry) Register(checkers ...HealthChecker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checkers = append(r.checkers, checkers...)
+}
+
+// Check 并发执行所有检查
+func (r *Registry) Check(ctx context.Context) Report {
+	r.mu.RLock()
+	checkers := append([]HealthChecker(nil), r.checkers...)
+	r.mu.RUnlock()
+
+	report := Report{Status: StatusUp, Checks: make(map[string]CheckResult, len(checkers))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, checker := range checkers {
+		wg.Add(1)
+		go func(checker HealthChecker) {
+			defer wg.Done()
+			result := r.run(ctx, checker)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[checker.Name()] = result
+			if result.Status != StatusUp {
+				report.Status = StatusDown
+			}
+		}(checker)
+	}
+	wg.Wait()
+	return report
+}
+
+// run 在超时时间内执行单个检查, 检查函数 panic 时视为不可用
+func (r *Registry) run(ctx context.Context, checker HealthChecker) (result CheckResult) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	defer func() {
+		result.Duration = time.Since(start).Round(time.Microsecond).String()
+		if p := recover(); p != nil {
+			result.Status, result.Error = StatusDown, fmt.Sprintf("panic: %v", p)
+		}
+	}()
+
+	if err := checker.Check(ctx); err != nil {
+		return CheckResult{Status: StatusDown, Error: err.Error()}
+	}
+	return CheckResult{Status: StatusUp}
+}
+
+// LivenessHandler 处理 GET /healthz, 只要进程能响应请求就返回 200, 不检查任何依赖,
+// 避免数据库故障时编排系统反复重启所有实例
+func LivenessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, http.StatusOK, Report{Status: StatusUp})
+	}
+}
+
+// ReadinessHandler 处理 GET /readyz, 所有检查通过时返回 200, 否则返回 503
+func (r *Registry) ReadinessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		report := r.Check(req.Context())
+		status := http.StatusOK
+		if report.Status != StatusUp {
+			status = http.StatusServiceUnavailable
+		}
+		writeReport(w, status, report)
+	}
+}
+
+func writeReport(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	// 健康检查结果不能被缓存
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		slog.Error("encode health report failed", "error", err)
+	}
+}
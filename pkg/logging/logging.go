@@ -0,0 +1,55 @@
+// Package logging 基于 log/slog 创建日志记录器, 并通过 context.Context 传递请求级别的日志上下文
+//
+// 请求中间件把带有 request_id, trace_id 的 logger 放入 context, 处理请求的代码使用 FromContext 取出,
+// 同一个请求在各层的日志因此可以关联起来
+//
+// 错误只在请求边界 (handler) 记录一次: 存储库和服务层返回包装后的错误, 不自行记录
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"skymates-api/config"
+)
+
+// New 根据配置创建 logger, 格式为 text 或 json
+func New(w io.Writer, cfg config.LogConfig) *slog.Logger {
+	var level slog.Level
+	_ = level.UnmarshalText([]byte(cfg.Level)) // 配置已经校验过, 不会失败
+
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler = slog.NewTextHandler(w, options)
+	if cfg.Format == "json" {
+		handler = slog.NewJSONHandler(w, options)
+	}
+	return slog.New(handler)
+}
+
+// Setup 创建 logger 并设置为 slog 的默认 logger
+// 标准库 log 的输出也会转发到该 logger, 以 info 级别记录
+func Setup(w io.Writer, cfg config.LogConfig) *slog.Logger {
+	logger := New(w, cfg)
+	slog.SetDefault(logger)
+	return logger
+}
+
+type loggerKey struct{}
+
+// WithContext 返回携带 logger 的 context
+func WithContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext 返回 ctx 中的 logger, 没有时返回默认 logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// With 返回一个 context, 其中的 logger 在 ctx 的 logger 基础上附加 args
+func With(ctx context.Context, args ...any) context.Context {
+	return WithContext(ctx, FromContext(ctx).With(args...))
+}
@@ -0,0 +1,40 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileMailer 把每封邮件写成 dir 下的一个 .eml 文件, 不真正发送, 适合本地开发
+// 文件名以发送时间开头, 按文件名排序即为发送顺序, 可以直接用邮件客户端打开
+type FileMailer struct {
+	dir  string
+	from *mail.Address
+}
+
+// NewFileMailer 创建 FileMailer, dir 不存在时在第一次发送时创建
+func NewFileMailer(dir string, from *mail.Address) *FileMailer {
+	return &FileMailer{dir: dir, from: from}
+}
+
+// Send 将邮件写入文件
+func (m *FileMailer) Send(_ context.Context, msg Message) error {
+	now := time.Now()
+	data, err := compose(m.from, msg, now)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return fmt.Errorf("create mail dir: %w", err)
+	}
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000Z"), randomID()[:8])
+	// 邮件中包含重置密码等链接, 只允许当前用户读取
+	if err := os.WriteFile(filepath.Join(m.dir, name), data, 0o600); err != nil {
+		return fmt.Errorf("write mail: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import "database/sql"
+
+// dbStatsCollector 在抓取时读取 sql.DB.Stats(), 不需要后台轮询
+type dbStatsCollector struct {
+	db *sql.DB
+}
+
+// NewDBStatsCollector 创建数据库连接池指标的 Collector
+func NewDBStatsCollector(db *sql.DB) Collector {
+	return dbStatsCollector{db: db}
+}
+
+// Collect 实现 Collector
+func (c dbStatsCollector) Collect(w *Writer) {
+	stats := c.db.Stats()
+	gauges := []struct {
+		name, help string
+		value      float64
+	}{
+		{"db_max_open_connections", "Maximum number of open connections to the database.", float64(stats.MaxOpenConnections)},
+		{"db_open_connections", "The number of established connections both in use and idle.", float64(stats.OpenConnections)},
+		{"db_in_use_connections", "The number of connections currently in use.", float64(stats.InUse)},
+		{"db_idle_connections", "The number of idle connections.", float64(stats.Idle)},
+	}
+	for _, g := range gauges {
+		w.Header(g.name, g.help, "gauge")
+		w.Sample(g.name, nil, nil, g.value)
+	}
+
+	counters := []struct {
+		name, help string
+		value      float64
+	}{
+		{"db_wait_count_total", "The total number of connections waited for.", float64(stats.WaitCount)},
+		{"db_wait_duration_seconds_total", "The total time blocked waiting for a new connection.", stats.WaitDuration.Seconds()},
+		{"db_max_idle_closed_total", "The total number of connections closed due to SetMaxIdleConns.", float64(stats.MaxIdleClosed)},
+		{"db_max_idle_time_closed_total", "The total number of connections closed due to SetConnMaxIdleTime.", float64(stats.MaxIdleTimeClosed)},
+		{"db_max_lifetime_closed_total", "The total number of connections closed due to SetConnMaxLifetime.", float64(stats.MaxLifetimeClosed)},
+	}
+	for _, c := range counters {
+		w.Header(c.name, c.help, "counter")
+		w.Sample(c.name, nil, nil, c.value)
+	}
+}
@@ -0,0 +1,311 @@
+// Package metrics 实现一个最小的指标注册表, 以 Prometheus 文本格式 (0.0.4) 暴露指标
+// Prometheus 定期抓取 /metrics, 不需要额外运行任何采集进程
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefBuckets 默认的直方图分桶 (秒), 覆盖 5ms 到 10s 的请求耗时
+var DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Collector 在每次抓取时输出一组指标
+type Collector interface {
+	Collect(w *Writer)
+}
+
+// Registry 保存所有指标, 按注册顺序输出
+type Registry struct {
+	mu         sync.RWMutex
+	names      map[string]bool
+	collectors []Collector
+}
+
+// NewRegistry 创建空的 Registry
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// Register 注册自定义的 Collector, 例如数据库连接池指标
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// register 注册指标并检查名称是否重复, 重复注册属于编程错误, 直接 panic
+func (r *Registry) register(name string, c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic(fmt.Sprintf("metrics: duplicate metric %q", name))
+	}
+	r.names[name] = true
+	r.collectors = append(r.collectors, c)
+}
+
+// NewCounterVec 注册一个只增不减的计数器, labels 为标签名
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{desc: desc{name: name, help: help, labels: labels}, series: make(map[string]*Counter)}
+	r.register(name, c)
+	return c
+}
+
+// NewHistogramVec 注册一个直方图, buckets 为升序排列的分桶上限
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{desc: desc{name: name, help: help, labels: labels}, buckets: buckets, series: make(map[string]*Histogram)}
+	r.register(name, h)
+	return h
+}
+
+// Write 以 Prometheus 文本格式输出所有指标
+func (r *Registry) Write(out io.Writer) error {
+	r.mu.RLock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mu.RUnlock()
+
+	w := &Writer{w: bufio.NewWriter(out)}
+	for _, c := range collectors {
+		c.Collect(w)
+	}
+	return w.w.Flush()
+}
+
+// Handler 返回暴露指标的 HTTP 处理器
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := r.Write(w); err != nil {
+			slog.Error("write metrics failed", "error", err)
+		}
+	})
+}
+
+// desc 指标的名称, 说明和标签名
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+// key 将标签值拼接为 map 的键, 标签值不会包含 \xff
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// Counter 单个计数器
+type Counter struct {
+	values []string
+	bits   atomic.Uint64
+}
+
+// Inc 计数加一
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add 增加计数, v 不能为负数
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	for {
+		old := c.bits.Load()
+		next := math.Float64bits(math.Float64frombits(old) + v)
+		if c.bits.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+// CounterVec 按标签值区分的一组计数器
+type CounterVec struct {
+	desc
+	mu     sync.RWMutex
+	series map[string]*Counter
+}
+
+// With 返回标签值对应的计数器, 不存在时创建
+func (v *CounterVec) With(values ...string) *Counter {
+	key := v.key(values)
+	v.mu.RLock()
+	c, ok := v.series[key]
+	v.mu.RUnlock()
+	if ok {
+		return c
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if c, ok = v.series[key]; !ok {
+		c = &Counter{values: append([]string(nil), values...)}
+		v.series[key] = c
+	}
+	return c
+}
+
+// Collect 实现 Collector
+func (v *CounterVec) Collect(w *Writer) {
+	w.Header(v.name, v.help, "counter")
+	v.mu.RLock()
+	series := make([]*Counter, 0, len(v.series))
+	for _, c := range v.series {
+		series = append(series, c)
+	}
+	v.mu.RUnlock()
+
+	sort.Slice(series, func(i, j int) bool { return lessValues(series[i].values, series[j].values) })
+	for _, c := range series {
+		w.Sample(v.name, v.labels, c.values, math.Float64frombits(c.bits.Load()))
+	}
+}
+
+// Histogram 单个直方图
+type Histogram struct {
+	values  []string
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64 // 每个分桶内的数量, 输出时再累加
+	sum     float64
+	count   uint64
+}
+
+// Observe 记录一个观测值
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v) // 第一个 >= v 的分桶
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.sum += v
+	h.count++
+}
+
+// HistogramVec 按标签值区分的一组直方图
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.RWMutex
+	series  map[string]*Histogram
+}
+
+// With 返回标签值对应的直方图, 不存在时创建
+func (v *HistogramVec) With(values ...string) *Histogram {
+	key := v.key(values)
+	v.mu.RLock()
+	h, ok := v.series[key]
+	v.mu.RUnlock()
+	if ok {
+		return h
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if h, ok = v.series[key]; !ok {
+		h = &Histogram{values: append([]string(nil), values...), buckets: v.buckets, counts: make([]uint64, len(v.buckets))}
+		v.series[key] = h
+	}
+	return h
+}
+
+// Collect 实现 Collector
+func (v *HistogramVec) Collect(w *Writer) {
+	w.Header(v.name, v.help, "histogram")
+	v.mu.RLock()
+	series := make([]*Histogram, 0, len(v.series))
+	for _, h := range v.series {
+		series = append(series, h)
+	}
+	v.mu.RUnlock()
+
+	sort.Slice(series, func(i, j int) bool { return lessValues(series[i].values, series[j].values) })
+	labels := append(append([]string(nil), v.labels...), "le")
+	values := make([]string, len(labels))
+	for _, h := range series {
+		h.mu.Lock()
+		counts, sum, count := append([]uint64(nil), h.counts...), h.sum, h.count
+		h.mu.Unlock()
+
+		// 复制到单独的切片中再追加 le, 不能修改 h.values
+		copy(values, h.values)
+		var cumulative uint64
+		for i, upper := range v.buckets {
+			cumulative += counts[i]
+			values[len(values)-1] = formatFloat(upper)
+			w.Sample(v.name+"_bucket", labels, values, float64(cumulative))
+		}
+		values[len(values)-1] = "+Inf"
+		w.Sample(v.name+"_bucket", labels, values, float64(count))
+		w.Sample(v.name+"_sum", v.labels, h.values, sum)
+		w.Sample(v.name+"_count", v.labels, h.values, float64(count))
+	}
+}
+
+// Writer 输出 Prometheus 文本格式, 供自定义 Collector 使用
+type Writer struct {
+	w *bufio.Writer
+}
+
+// Header 输出指标的 HELP 和 TYPE 行, typ 为 counter, gauge 或 histogram
+func (w *Writer) Header(name, help, typ string) {
+	help = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+	fmt.Fprintf(w.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// Sample 输出一个样本
+func (w *Writer) Sample(name string, labels, values []string, value float64) {
+	w.w.WriteString(name)
+	if len(labels) > 0 {
+		w.w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.w.WriteByte(',')
+			}
+			w.w.WriteString(label)
+			w.w.WriteString(`="`)
+			w.w.WriteString(labelEscaper.Replace(values[i]))
+			w.w.WriteByte('"')
+		}
+		w.w.WriteByte('}')
+	}
+	w.w.WriteByte(' ')
+	w.w.WriteString(formatFloat(value))
+	w.w.WriteByte('\n')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func lessValues(a, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore 在进程内存中保存桶的状态, 只适用于单实例部署
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// NewMemoryStore 创建 MemoryStore, 需要定期调用 Sweep 清理已经补充满的桶
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+// Take 从 key 对应的桶中取一个令牌
+func (s *MemoryStore) Take(_ context.Context, key string, rule Rule, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{}
+		s.buckets[key] = b
+	}
+	return b.take(rule, now), nil
+}
+
+// Sweep 删除已经补充满的桶, 满桶和不存在的桶等价, 删除不影响限流结果
+func (s *MemoryStore) Sweep(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, b := range s.buckets {
+		if !b.full.After(now) {
+			delete(s.buckets, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+// Package ratelimit 使用令牌桶算法限制请求频率
+//
+// 每个 key (如 IP, 用户 ID) 对应一个容量为 Limit 的桶, 桶在 Period 内从空补充到满,
+// 每个请求取走一个令牌, 桶空时拒绝请求。桶的状态保存在 Store 中, 单实例部署使用 MemoryStore,
+// 多实例部署可以实现基于 Redis 等共享存储的 Store
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Rule 令牌桶的参数
+type Rule struct {
+	Limit  int           // 桶的容量, 即允许的突发请求数
+	Period time.Duration // 桶从空补充到满的时间
+}
+
+// interval 补充一个令牌的时间
+func (r Rule) interval() time.Duration {
+	return r.Period / time.Duration(r.Limit)
+}
+
+// Result 一次取令牌的结果
+type Result struct {
+	Allowed    bool
+	Limit      int           // 桶的容量
+	Remaining  int           // 取令牌后桶中剩余的令牌数
+	RetryAfter time.Duration // 被拒绝时, 距离桶中有一个令牌的时间
+	Reset      time.Duration // 距离桶补充满的时间
+}
+
+// Store 保存所有桶的状态
+// Take 必须是原子的, 同一个 key 的并发请求不能取走超过桶中数量的令牌
+type Store interface {
+	// Take 从 key 对应的桶中取一个令牌, 桶不存在时按满桶处理
+	Take(ctx context.Context, key string, rule Rule, now time.Time) (Result, error)
+}
+
+// bucket 桶的状态, 只记录桶补充满的时间: 满桶之前每缺一个令牌, full 就晚 interval
+// 与记录令牌数和上次补充时间相比只需要保存一个值, 方便在共享存储中实现
+type bucket struct {
+	full time.Time
+}
+
+// take 按 rule 从桶中取一个令牌
+func (b *bucket) take(rule Rule, now time.Time) Result {
+	interval := rule.interval()
+	full := b.full
+	if full.Before(now) {
+		full = now
+	}
+	// 取走一个令牌后桶补充满的时间
+	next := full.Add(interval)
+	// 桶中最多缺 Limit 个令牌, 超过时说明桶已经空了
+	if deficit := next.Sub(now); deficit > rule.Period {
+		return Result{
+			Limit:      rule.Limit,
+			RetryAfter: deficit - rule.Period,
+			Reset:      full.Sub(now),
+		}
+	}
+	b.full = next
+	missing := int(math.Ceil(float64(next.Sub(now)) / float64(interval)))
+	return Result{
+		Allowed:   true,
+		Limit:     rule.Limit,
+		Remaining: rule.Limit - missing,
+		Reset:     next.Sub(now),
+	}
+}
@@ -0,0 +1,113 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBucketTake(t *testing.T) {
+	// 容量 3, 每秒补充一个令牌
+	rule := Rule{Limit: 3, Period: 3 * time.Second}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	var b bucket
+	for i, c := range []struct {
+		now  time.Time
+		want Result
+	}{
+		// 新桶是满的, 可以突发 Limit 个请求
+		{at(0), Result{Allowed: true, Limit: 3, Remaining: 2, Reset: time.Second}},
+		{at(0), Result{Allowed: true, Limit: 3, Remaining: 1, Reset: 2 * time.Second}},
+		{at(0), Result{Allowed: true, Limit: 3, Remaining: 0, Reset: 3 * time.Second}},
+		// 桶空了, 1 秒后补充一个令牌
+		{at(0), Result{Limit: 3, RetryAfter: time.Second, Reset: 3 * time.Second}},
+		{at(500 * time.Millisecond), Result{Limit: 3, RetryAfter: 500 * time.Millisecond, Reset: 2500 * time.Millisecond}},
+		// 被拒绝的请求不消耗令牌
+		{at(time.Second), Result{Allowed: true, Limit: 3, Remaining: 0, Reset: 3 * time.Second}},
+		// 补充了 1.5 个令牌, 取走一个后剩余的不足一个
+		{at(2500 * time.Millisecond), Result{Allowed: true, Limit: 3, Remaining: 0, Reset: 2500 * time.Millisecond}},
+		{at(3 * time.Second), Result{Allowed: true, Limit: 3, Remaining: 0, Reset: 3 * time.Second}},
+		// 很久之后桶已经满了, 多余的时间不会让桶超过容量
+		{at(time.Minute), Result{Allowed: true, Limit: 3, Remaining: 2, Reset: time.Second}},
+	} {
+		if got := b.take(rule, c.now); got != c.want {
+			t.Fatalf("take %d at %v: got %+v, want %+v", i, c.now.Sub(start), got, c.want)
+		}
+	}
+}
+
+func TestBucketTakeLimitOne(t *testing.T) {
+	rule := Rule{Limit: 1, Period: time.Hour}
+	now := time.Now()
+	var b bucket
+	if got := b.take(rule, now); !got.Allowed || got.Remaining != 0 || got.Reset != time.Hour {
+		t.Fatalf("first take = %+v", got)
+	}
+	got := b.take(rule, now.Add(20*time.Minute))
+	if got.Allowed || got.RetryAfter != 40*time.Minute || got.Reset != 40*time.Minute {
+		t.Fatalf("second take = %+v, want retry after 40m", got)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	rule := Rule{Limit: 1, Period: time.Hour}
+	now := time.Now()
+
+	if result, _ := store.Take(ctx, "a", rule, now); !result.Allowed {
+		t.Fatal("first take for a should be allowed")
+	}
+	if result, _ := store.Take(ctx, "a", rule, now); result.Allowed {
+		t.Fatal("second take for a should be rejected")
+	}
+	// 每个 key 的桶互相独立
+	if result, _ := store.Take(ctx, "b", rule, now); !result.Allowed {
+		t.Fatal("first take for b should be allowed")
+	}
+
+	// 没有补充满的桶不会被清理
+	if err := store.Sweep(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.buckets) != 2 {
+		t.Fatalf("buckets = %d, want 2 after sweeping", len(store.buckets))
+	}
+	store.Take(ctx, "c", Rule{Limit: 1, Period: time.Nanosecond}, now.Add(-time.Second))
+	if err := store.Sweep(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.buckets["c"]; ok || len(store.buckets) != 2 {
+		t.Fatalf("buckets = %v, want c to be swept", store.buckets)
+	}
+}
+
+func TestMemoryStoreConcurrentTakes(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	rule := Rule{Limit: 10, Period: time.Hour}
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, _ := store.Take(ctx, "key", rule, now)
+			if result.Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != rule.Limit {
+		t.Fatalf("allowed = %d, want %d", allowed, rule.Limit)
+	}
+}
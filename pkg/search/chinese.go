@@ -0,0 +1,122 @@
+package search
+
+import (
+	_ "embed"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+//go:embed tschars.txt
+var tsCharsData string
+
+// tsChars 繁体字到简体字的映射, 第一次使用时解析 tschars.txt
+var tsChars = sync.OnceValue(func() map[rune]rune {
+	chars := map[rune]rune{}
+	for _, line := range strings.Split(tsCharsData, "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		traditional, simplified, _ := strings.Cut(line, "\t")
+		t, _ := utf8.DecodeRuneInString(traditional)
+		s, _ := utf8.DecodeRuneInString(simplified)
+		chars[t] = s
+	}
+	return chars
+})
+
+// pinyinArgs 不带声调, 多音字只取最常用的读音; 没有拼音的字符返回空
+var pinyinArgs = pinyin.NewArgs()
+
+// Simplify 把繁体字逐字转换为简体字, 其余字符不变
+// 逐字转换不考虑词语 (例如 "乾" 总是转换为 "干"), 但不改变字符个数, 位置可以直接对应回原文
+func Simplify(s string) string {
+	return strings.Map(simplifyRune, s)
+}
+
+func simplifyRune(r rune) rune {
+	if s, ok := tsChars()[r]; ok {
+		return s
+	}
+	return r
+}
+
+// Pinyin 返回文本的全拼和拼音首字母, 例如 "飛行" -> "feixing", "fx"
+// 汉字转换为不带声调的拼音, 多音字取最常用的读音; 字母和数字经过 Fold 规范化后原样保留, 在首字母中也不缩写,
+// 例如 "GPS导航" -> "gpsdaohang", "gpsdh"; 其余字符 (包括空格和撇号) 被忽略
+func Pinyin(s string) (full, initials string) {
+	var f, i strings.Builder
+	for _, syllable := range syllables([]rune(s)) {
+		f.WriteString(syllable.full)
+		i.WriteString(syllable.initial)
+	}
+	return f.String(), i.String()
+}
+
+// syllable 一个字符对应的拼音, 字母和数字的 full 和 initial 相同, 被忽略的字符两者都为空字符串
+type syllable struct {
+	full, initial string
+}
+
+func syllables(runes []rune) []syllable {
+	result := make([]syllable, len(runes))
+	for i, r := range runes {
+		if unicode.Is(unicode.Han, r) {
+			if readings := pinyin.SinglePinyin(r, pinyinArgs); len(readings) > 0 && readings[0] != "" {
+				result[i] = syllable{readings[0], readings[0][:1]}
+			}
+			continue
+		}
+		folded := strings.ReplaceAll(Fold(string(r)), " ", "")
+		result[i] = syllable{folded, folded}
+	}
+	return result
+}
+
+// pinyinMatches 返回 keyword 的全拼在 runes 的全拼中出现的位置, 没有时改为在拼音首字母中查找;
+// 匹配到一个字的部分拼音时标出整个字
+func pinyinMatches(runes []rune, keyword string) []span {
+	target, _ := Pinyin(keyword)
+	if target == "" {
+		return nil
+	}
+	parts := syllables(runes)
+	if spans := locate(parts, target, func(s syllable) string { return s.full }); len(spans) > 0 {
+		return spans
+	}
+	return locate(parts, target, func(s syllable) string { return s.initial })
+}
+
+// locate 把每个字符的拼音连接起来, 查找 target 不重叠的出现位置并换算为字符的位置
+func locate(parts []syllable, target string, text func(syllable) string) []span {
+	var joined strings.Builder
+	var owners []int // joined 中每个字节所属字符的位置
+	for i, part := range parts {
+		s := text(part)
+		joined.WriteString(s)
+		for range len(s) {
+			owners = append(owners, i)
+		}
+	}
+
+	var spans []span
+	haystack := joined.String()
+	for from := 0; ; {
+		i := strings.Index(haystack[from:], target)
+		if i < 0 {
+			return spans
+		}
+		start, end := from+i, from+i+len(target)
+		match := span{owners[start], owners[end-1] + 1}
+		// 两处匹配可能落在同一个字的拼音上, 合并为一段
+		if n := len(spans); n > 0 && match.start < spans[n-1].end {
+			spans[n-1].end = match.end
+		} else {
+			spans = append(spans, match)
+		}
+		from = end
+	}
+}
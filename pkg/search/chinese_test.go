@@ -0,0 +1,115 @@
+package search
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSimplify(t *testing.T) {
+	for _, c := range []struct {
+		in, want string
+	}{
+		{"飛行", "飞行"},
+		{"飛行計劃", "飞行计划"},
+		{"飞行", "飞行"},
+		{"VFR 飛行 Rules", "VFR 飞行 Rules"},
+		// 逐字转换, 不考虑词语
+		{"乾燥", "干燥"},
+		{"", ""},
+	} {
+		if got := Simplify(c.in); got != c.want {
+			t.Errorf("Simplify(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestPinyin(t *testing.T) {
+	for _, c := range []struct {
+		in, full, initials string
+	}{
+		{"飞行", "feixing", "fx"},
+		{"飛行", "feixing", "fx"},
+		{"目视飞行规则", "mushifeixingguize", "msfxgz"},
+		// 字母和数字原样保留, 在首字母中也不缩写
+		{"GPS导航", "gpsdaohang", "gpsdh"},
+		{"Café 飞行", "cafefeixing", "cafefx"},
+		{"A320 机型", "a320jixing", "a320jx"},
+		// 空格和撇号被忽略
+		{"fei'xing", "feixing", "feixing"},
+		{"fei xing", "feixing", "feixing"},
+		{"!!", "", ""},
+	} {
+		full, initials := Pinyin(c.in)
+		if full != c.full || initials != c.initials {
+			t.Errorf("Pinyin(%q) = %q, %q, want %q, %q", c.in, full, initials, c.full, c.initials)
+		}
+	}
+}
+
+func TestPinyinMatches(t *testing.T) {
+	for _, c := range []struct {
+		text, keyword string
+		want          []span
+	}{
+		{"飞行", "feixing", []span{{0, 2}}},
+		{"飞行", "fei xing", []span{{0, 2}}},
+		{"飞行", "fx", []span{{0, 2}}},
+		{"飞行", "飛行", []span{{0, 2}}},
+		{"飞行计划", "xing", []span{{1, 2}}},
+		{"飞行计划", "jh", []span{{2, 4}}},
+		// 匹配到部分拼音时标出整个字
+		{"飞行计划", "eix", []span{{0, 2}}},
+		{"飞行飞行", "fx", []span{{0, 2}, {2, 4}}},
+		// 字母和汉字混合
+		{"GPS导航", "gpsdh", []span{{0, 5}}},
+		{"VFR 飞行", "fx", []span{{4, 6}}},
+		{"VFR 飞行", "vfrfei", []span{{0, 5}}},
+		// 全拼没有匹配时才按首字母匹配
+		{"飞行计划", "fxjh", []span{{0, 4}}},
+		{"飞行", "xyz", nil},
+		{"飞行", "!!", nil},
+	} {
+		if got := pinyinMatches([]rune(c.text), c.keyword); !slices.Equal(got, c.want) {
+			t.Errorf("pinyinMatches(%q, %q) = %v, want %v", c.text, c.keyword, got, c.want)
+		}
+	}
+}
+
+func TestLocate(t *testing.T) {
+	full := func(s syllable) string { return s.full }
+	for _, c := range []struct {
+		parts  []syllable
+		target string
+		want   []span
+	}{
+		{[]syllable{{"ab", "a"}, {"c", "c"}}, "bc", []span{{0, 2}}},
+		// 被忽略的字符没有拼音, 匹配可以跨过它
+		{[]syllable{{"ab", "a"}, {"", ""}, {"c", "c"}}, "bc", []span{{0, 3}}},
+		// 两处匹配落在同一个字上时合并
+		{[]syllable{{"abab", "a"}, {"c", "c"}}, "ab", []span{{0, 1}}},
+		{[]syllable{{"ab", "a"}, {"ab", "a"}}, "ab", []span{{0, 1}, {1, 2}}},
+		{[]syllable{{"ab", "a"}}, "x", nil},
+	} {
+		if got := locate(c.parts, c.target, full); !slices.Equal(got, c.want) {
+			t.Errorf("locate(%v, %q) = %v, want %v", c.parts, c.target, got, c.want)
+		}
+	}
+}
+
+func TestHighlightChinese(t *testing.T) {
+	for _, c := range []struct {
+		name, got, want string
+	}{
+		{"traditional text", Highlight("飛行計劃", "飞行"), "<mark>飛行</mark>計劃"},
+		{"traditional keyword", Highlight("飞行计划", "飛行"), "<mark>飞行</mark>计划"},
+		{"mixed", Highlight("VFR 飛行規則", "vfr 飞行"), "<mark>VFR 飛行</mark>規則"},
+		{"mixed words", Highlight("VFR 飛行規則", "飞行 vfr"), "<mark>VFR</mark> <mark>飛行</mark>規則"},
+		{"pinyin", HighlightPinyin("目视飞行规则", "feixing"), "目视<mark>飞行</mark>规则"},
+		{"pinyin initials", HighlightPinyin("目视飞行规则", "fx"), "目视<mark>飞行</mark>规则"},
+		{"pinyin mixed", HighlightPinyin("GPS<导航>", "gpsdh"), "<mark>GPS&lt;导航</mark>&gt;"},
+	} {
+		if c.got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, c.got, c.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Fold 把文本规范化为索引和查询使用的形式:
+// 兼容分解后去掉变音符号 (é -> e, 全角 Ａ -> a), 转为小写, 字母和数字以外的字符视为分隔符,
+// 连续的分隔符合并为一个空格, 去掉首尾的分隔符
+func Fold(s string) string {
+	var b strings.Builder
+	separator := false
+	for _, r := range norm.NFKD.String(s) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if separator && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			separator = false
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			separator = true
+		}
+	}
+	return b.String()
+}
+
+// words 返回折叠后的文本中的词
+func words(folded string) []string {
+	return strings.Split(folded, " ")
+}
+
+// abbreviation 返回由各个词首字母组成的缩写, 例如 "visual flight rules" -> "vfr"; 少于两个词时返回空字符串
+func abbreviation(folded string) string {
+	parts := words(folded)
+	if len(parts) < 2 {
+		return ""
+	}
+	var b strings.Builder
+	for _, word := range parts {
+		b.WriteRune([]rune(word)[0])
+	}
+	return b.String()
+}
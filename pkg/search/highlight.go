@@ -0,0 +1,121 @@
+// Package search 提供术语搜索用到的文本处理和内存索引
+//
+// Highlight 和 Snippet 在文本中标出关键字, 输出已经做过 HTML 转义, 客户端可以直接作为 HTML 渲染;
+// Simplify 和 Pinyin 计算按繁简和拼音搜索用到的中文形式; Index 是为输入框提供搜索建议的前缀索引
+package search
+
+import (
+	"html"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const (
+	markOpen  = "<mark>"
+	markClose = "</mark>"
+	ellipsis  = "…"
+)
+
+// span 文本中一段匹配, 以 rune 为单位的半开区间 [start, end)
+type span struct {
+	start, end int
+}
+
+// Highlight 对 text 做 HTML 转义, 并用 <mark></mark> 标出 keyword 及其中每个词, 匹配不区分大小写和繁简
+func Highlight(text, keyword string) string {
+	runes := []rune(text)
+	return render(runes, matches(runes, keyword), 0, len(runes))
+}
+
+// HighlightPinyin 与 Highlight 相同, 但按拼音匹配: 标出全拼或拼音首字母与 keyword 的全拼相同的字,
+// 例如 keyword 为 "fx" 时标出 "飞行"
+func HighlightPinyin(text, keyword string) string {
+	runes := []rune(text)
+	return render(runes, pinyinMatches(runes, keyword), 0, len(runes))
+}
+
+// Snippet 截取 text 中第一处匹配附近最多 width 个字符并高亮, 被截断的一端加省略号;
+// 没有匹配时截取开头
+func Snippet(text, keyword string, width int) string {
+	runes := []rune(text)
+	spans := matches(runes, keyword)
+	if len(runes) <= width {
+		return render(runes, spans, 0, len(runes))
+	}
+
+	start := 0
+	if len(spans) > 0 {
+		// 匹配之前保留约三分之一的上下文
+		start = max(0, spans[0].start-width/3)
+	}
+	end := min(len(runes), start+width)
+	start = max(0, end-width)
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString(ellipsis)
+	}
+	b.WriteString(render(runes, spans, start, end))
+	if end < len(runes) {
+		b.WriteString(ellipsis)
+	}
+	return b.String()
+}
+
+// matches 返回 keyword 及其中每个词在 runes 中出现的位置, 按位置排序且互不重叠, 同一位置优先匹配较长的词
+func matches(runes []rune, keyword string) []span {
+	var terms [][]rune
+	for _, term := range append([]string{keyword}, strings.Fields(keyword)...) {
+		if term = strings.TrimSpace(term); term != "" {
+			terms = append(terms, foldRunes([]rune(term)))
+		}
+	}
+	slices.SortFunc(terms, func(a, b []rune) int { return len(b) - len(a) })
+
+	lowered := foldRunes(slices.Clone(runes))
+	var spans []span
+	for i := 0; i < len(lowered); {
+		matched := 0
+		for _, term := range terms {
+			if len(term) <= len(lowered)-i && slices.Equal(lowered[i:i+len(term)], term) {
+				matched = len(term)
+				break
+			}
+		}
+		if matched == 0 {
+			i++
+			continue
+		}
+		spans = append(spans, span{i, i + matched})
+		i += matched
+	}
+	return spans
+}
+
+// foldRunes 把每个字符转为小写和简体, 不改变字符个数, 匹配位置可以直接对应回原文
+func foldRunes(runes []rune) []rune {
+	for i, r := range runes {
+		runes[i] = simplifyRune(unicode.ToLower(r))
+	}
+	return runes
+}
+
+// render 转义 runes[from:to] 并标出其中的匹配, 跨越边界的匹配只标出边界内的部分
+func render(runes []rune, spans []span, from, to int) string {
+	var b strings.Builder
+	pos := from
+	for _, s := range spans {
+		start, end := max(s.start, from), min(s.end, to)
+		if start >= end {
+			continue
+		}
+		b.WriteString(html.EscapeString(string(runes[pos:start])))
+		b.WriteString(markOpen)
+		b.WriteString(html.EscapeString(string(runes[start:end])))
+		b.WriteString(markClose)
+		pos = end
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:to])))
+	return b.String()
+}
@@ -0,0 +1,363 @@
+package search
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"sync"
+	"unicode/utf8"
+)
+
+// maxCandidates 一次查询最多检查的索引项数, 短前缀命中大量术语时也能在固定时间内返回
+const maxCandidates = 256
+
+// keyKind 索引键的来源, 编辑距离和是否完全匹配相同时按 keyKind 从小到大排序
+type keyKind int
+
+const (
+	kindName         keyKind = iota // 名称
+	kindAlias                       // 别名
+	kindWord                        // 名称中从第二个词开始的部分, 例如 "flight rules"
+	kindAbbreviation                // 名称各个词的首字母, 例如 "vfr"
+)
+
+// Document 加入索引的术语
+type Document struct {
+	ID      int64
+	Name    string
+	Aliases []string
+}
+
+// Suggestion 一条搜索建议
+type Suggestion struct {
+	ID    int64
+	Name  string
+	Alias string // 通过别名命中时为命中的别名, 否则为空
+}
+
+// key 一个索引键和它指向的术语
+type key struct {
+	text  string
+	entry entry
+}
+
+type entry struct {
+	id    int64
+	kind  keyKind
+	alias string
+}
+
+// node trie 的节点, entries 是以根到该节点的路径为键的索引项
+type node struct {
+	children []edge // 按字符排序
+	entries  []entry
+}
+
+type edge struct {
+	r     rune
+	child *node
+}
+
+// candidate 查询过程中的候选术语
+type candidate struct {
+	entry
+	distance int  // 与查询的编辑距离
+	exact    bool // 索引键与查询完全相同 (或在编辑距离内), 而不只是以查询开头
+	name     string
+	length   int // 名称的字符数
+}
+
+// Index 内存中的前缀索引 (trie), 为输入框提供搜索建议, 可以并发使用
+//
+// 每个术语以名称, 别名, 名称中从第二个词开始的部分和首字母缩写作为键, 键和查询都先经过 Fold 规范化。
+// 查询先找以查询开头的键, 结果不足时再按编辑距离找拼写接近的键
+type Index struct {
+	mu      sync.RWMutex
+	root    *node
+	docs    map[int64]Document
+	pending map[int64]Document // 重建期间 Put 的文档, 不在重建时为 nil
+}
+
+// NewIndex 创建空索引
+func NewIndex() *Index {
+	return &Index{root: &node{}, docs: map[int64]Document{}}
+}
+
+// Len 返回索引中的术语数
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.docs)
+}
+
+// Put 加入术语, ID 相同的术语会被替换
+func (idx *Index) Put(doc Document) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.put(idx.root, idx.docs, doc)
+	if idx.pending != nil {
+		idx.pending[doc.ID] = doc
+	}
+}
+
+func (idx *Index) put(root *node, docs map[int64]Document, doc Document) {
+	if old, ok := docs[doc.ID]; ok {
+		for _, k := range keys(old) {
+			root.remove(k)
+		}
+	}
+	for _, k := range keys(doc) {
+		root.add(k)
+	}
+	docs[doc.ID] = doc
+}
+
+// Rebuild 用 load 返回的全部术语重建索引, 重建期间仍然可以查询和 Put;
+// 重建期间 Put 的术语在新索引建好后重新写入, 不会被 load 读到的旧数据覆盖
+func (idx *Index) Rebuild(load func() ([]Document, error)) error {
+	idx.mu.Lock()
+	if idx.pending != nil {
+		idx.mu.Unlock()
+		return errors.New("search: index is already being rebuilt")
+	}
+	idx.pending = map[int64]Document{}
+	idx.mu.Unlock()
+
+	docs, err := load()
+	root, byID := &node{}, make(map[int64]Document, len(docs))
+	if err == nil {
+		for _, doc := range docs {
+			idx.put(root, byID, doc)
+		}
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	pending := idx.pending
+	idx.pending = nil
+	if err != nil {
+		return err
+	}
+	for _, doc := range pending {
+		idx.put(root, byID, doc)
+	}
+	idx.root, idx.docs = root, byID
+	return nil
+}
+
+// Suggest 返回最多 limit 条与 query 匹配的术语, 排序依次按:
+// 编辑距离, 是否完全匹配, 键的来源 (名称, 别名, 名称中的词, 缩写), 名称长度, 名称, ID
+func (idx *Index) Suggest(query string, limit int) []Suggestion {
+	q := []rune(Fold(query))
+	if len(q) == 0 || limit <= 0 {
+		return nil
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var candidates []candidate
+	budget := maxCandidates
+	offer := func(n *node, distance int) {
+		budget = n.collect(distance, budget, func(c candidate) {
+			c.name = idx.docs[c.id].Name
+			c.length = utf8.RuneCountInString(c.name)
+			candidates = append(candidates, c)
+		})
+	}
+
+	if n := idx.root.find(q); n != nil {
+		offer(n, 0)
+	}
+	// 模糊匹配要求第一个字符相同, 输入的第一个字符很少出错, 这样也只需要查找一棵子树
+	if maxDistance := maxEditDistance(len(q)); len(candidates) < limit && maxDistance > 0 {
+		if first := idx.root.child(q[0], false); first != nil {
+			rows := make([][]int, len(q)+maxDistance+2)
+			for i := range rows {
+				rows[i] = make([]int, len(q)+1)
+			}
+			for j := range rows[0] {
+				rows[0][j] = j
+			}
+			editStep(q, rows[0], rows[1], q[0])
+			first.fuzzy(q, rows, 2, maxDistance, func(n *node, distance int) bool {
+				offer(n, distance)
+				return budget > 0
+			})
+		}
+	}
+
+	// 同一术语的多个键都命中时只保留排在最前的一个
+	slices.SortFunc(candidates, compareCandidates)
+	suggestions := make([]Suggestion, 0, min(limit, len(candidates)))
+	for _, c := range candidates {
+		if len(suggestions) == limit {
+			break
+		}
+		if slices.ContainsFunc(suggestions, func(s Suggestion) bool { return s.ID == c.id }) {
+			continue
+		}
+		suggestion := Suggestion{ID: c.id, Name: c.name}
+		if c.kind == kindAlias {
+			suggestion.Alias = c.alias
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions
+}
+
+// compareCandidates 比较候选的顺序, a 应该排在 b 之前时返回负数
+func compareCandidates(a, b candidate) int {
+	if a.exact != b.exact && a.distance == b.distance {
+		if a.exact {
+			return -1
+		}
+		return 1
+	}
+	return cmp.Or(
+		cmp.Compare(a.distance, b.distance),
+		cmp.Compare(a.kind, b.kind),
+		cmp.Compare(a.length, b.length),
+		cmp.Compare(a.name, b.name),
+		cmp.Compare(a.id, b.id),
+	)
+}
+
+// maxEditDistance 查询允许的编辑距离, 查询越长允许的拼写错误越多, 太短的查询不做模糊匹配
+func maxEditDistance(length int) int {
+	switch {
+	case length < 4:
+		return 0
+	case length < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// keys 返回术语的全部索引键
+func keys(doc Document) []key {
+	var result []key
+	name := Fold(doc.Name)
+	if name != "" {
+		result = append(result, key{name, entry{id: doc.ID, kind: kindName}})
+		// 从第二个词开始的每个后缀, 输入名称中间的词也能找到术语
+		pos := 0
+		for i, word := range words(name) {
+			if i > 0 {
+				result = append(result, key{name[pos:], entry{id: doc.ID, kind: kindWord}})
+			}
+			pos += len(word) + 1
+		}
+		if abbr := abbreviation(name); abbr != "" {
+			result = append(result, key{abbr, entry{id: doc.ID, kind: kindAbbreviation}})
+		}
+	}
+	for _, alias := range doc.Aliases {
+		if folded := Fold(alias); folded != "" {
+			result = append(result, key{folded, entry{id: doc.ID, kind: kindAlias, alias: alias}})
+		}
+	}
+	return result
+}
+
+// child 返回字符 r 对应的子节点, create 为 true 时不存在则创建
+func (n *node) child(r rune, create bool) *node {
+	i, found := slices.BinarySearchFunc(n.children, r, func(e edge, r rune) int { return cmp.Compare(e.r, r) })
+	if found {
+		return n.children[i].child
+	}
+	if !create {
+		return nil
+	}
+	child := &node{}
+	n.children = slices.Insert(n.children, i, edge{r, child})
+	return child
+}
+
+func (n *node) add(k key) {
+	for _, r := range k.text {
+		n = n.child(r, true)
+	}
+	n.entries = append(n.entries, k.entry)
+}
+
+// remove 删除索引项, 空节点留到下次重建时清理
+func (n *node) remove(k key) {
+	for _, r := range k.text {
+		if n = n.child(r, false); n == nil {
+			return
+		}
+	}
+	n.entries = slices.DeleteFunc(n.entries, func(e entry) bool { return e == k.entry })
+}
+
+// find 返回以 q 为路径的节点, 不存在时返回 nil
+func (n *node) find(q []rune) *node {
+	for _, r := range q {
+		if n = n.child(r, false); n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// collect 广度优先遍历以 n 为根的子树, 最多检查 budget 个索引项, 返回剩余的 budget;
+// 短前缀命中大量术语时不会遍历整个子树, 检查到的是最短的键 (同样长度时按字典序), 与排序时名称短的优先一致
+func (n *node) collect(distance, budget int, visit func(candidate)) int {
+	level := []*node{n}
+	for len(level) > 0 {
+		var next []*node
+		for _, current := range level {
+			for _, e := range current.entries {
+				if budget == 0 {
+					return 0
+				}
+				budget--
+				visit(candidate{entry: e, distance: distance, exact: current == n})
+			}
+			for _, c := range current.children {
+				next = append(next, c.child)
+			}
+		}
+		level = next
+	}
+	return budget
+}
+
+// fuzzy 在 trie 上逐层计算与 q 的编辑距离 (Levenshtein), rows[depth-1] 是父节点对应的一行距离, 其余各行用作缓冲;
+// 路径与 q 的距离不超过 maxDistance 时把该节点交给 visit, 不再向下查找, 子树由 visit 收集;
+// 一行中的最小距离超过 maxDistance 时剪枝。visit 返回 false 时停止查找
+func (n *node) fuzzy(q []rune, rows [][]int, depth, maxDistance int, visit func(*node, int) bool) bool {
+	row, next := rows[depth-1], rows[depth]
+	for _, e := range n.children {
+		minimum := editStep(q, row, next, e.r)
+		if distance := next[len(q)]; distance <= maxDistance {
+			if !visit(e.child, distance) {
+				return false
+			}
+			continue
+		}
+		// 路径比 q 长出 maxDistance 之后距离只会更大, rows 的行数保证不会越界
+		if minimum <= maxDistance && !e.child.fuzzy(q, rows, depth+1, maxDistance, visit) {
+			return false
+		}
+	}
+	return true
+}
+
+// editStep 由路径 p 对应的一行距离 row 计算路径 p+r 对应的一行 next, next[j] 是 p+r 与 q[:j] 的编辑距离;
+// 返回这一行的最小值
+func editStep(q []rune, row, next []int, r rune) int {
+	next[0] = row[0] + 1
+	minimum := next[0]
+	for j := 1; j < len(row); j++ {
+		cost := 1
+		if q[j-1] == r {
+			cost = 0
+		}
+		next[j] = min(row[j]+1, next[j-1]+1, row[j-1]+cost)
+		minimum = min(minimum, next[j])
+	}
+	return minimum
+}
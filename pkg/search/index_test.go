@@ -0,0 +1,342 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func testIndex() *Index {
+	idx := NewIndex()
+	for _, doc := range []Document{
+		{ID: 1, Name: "Visual Flight Rules", Aliases: []string{"VFR"}},
+		{ID: 2, Name: "Instrument Flight Rules", Aliases: []string{"IFR"}},
+		{ID: 3, Name: "Flap"},
+		{ID: 4, Name: "Flaperon"},
+		{ID: 5, Name: "Wing Flap"},
+		{ID: 6, Name: "Crosswind"},
+		{ID: 7, Name: "Café"},
+	} {
+		idx.Put(doc)
+	}
+	return idx
+}
+
+func suggestionIDs(suggestions []Suggestion) []int64 {
+	ids := []int64{}
+	for _, s := range suggestions {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+func TestFold(t *testing.T) {
+	for _, c := range []struct {
+		in, want string
+	}{
+		{"Visual Flight Rules", "visual flight rules"},
+		{"  Café—au lait! ", "cafe au lait"},
+		{"ＡＢＣ１２", "abc12"},
+		{"V.F.R.", "v f r"},
+		{"飞行 计划", "飞行 计划"},
+		{"--", ""},
+		{"", ""},
+	} {
+		if got := Fold(c.in); got != c.want {
+			t.Errorf("Fold(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestAbbreviation(t *testing.T) {
+	for _, c := range []struct {
+		in, want string
+	}{
+		{"visual flight rules", "vfr"},
+		{"wing flap", "wf"},
+		{"飞行 计划", "飞计"},
+		{"flap", ""},
+	} {
+		if got := abbreviation(c.in); got != c.want {
+			t.Errorf("abbreviation(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+// editDistance 用 editStep 逐个字符计算 p 与 q 的编辑距离
+func editDistance(q, p string) int {
+	qr := []rune(q)
+	row, next := make([]int, len(qr)+1), make([]int, len(qr)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for _, r := range p {
+		minimum := editStep(qr, row, next, r)
+		if want := slices.Min(next); minimum != want {
+			panic(fmt.Sprintf("editStep minimum = %d, want %d", minimum, want))
+		}
+		row, next = next, row
+	}
+	return row[len(qr)]
+}
+
+func TestEditStep(t *testing.T) {
+	for _, c := range []struct {
+		q, p string
+		want int
+	}{
+		{"flap", "flap", 0},
+		{"flap", "flp", 1},
+		{"flap", "flaps", 1},
+		{"flap", "flep", 1},
+		{"flap", "flpa", 2},
+		{"kitten", "sitting", 3},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"飞行", "飛行", 1},
+	} {
+		if got := editDistance(c.q, c.p); got != c.want {
+			t.Errorf("distance(%q, %q) = %d, want %d", c.q, c.p, got, c.want)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	idx := testIndex()
+	for _, c := range []struct {
+		query string
+		limit int
+		want  []int64
+	}{
+		// 完全匹配排在前缀匹配之前, 同样完全匹配时名称排在名称中的词之前
+		{"flap", 10, []int64{3, 5, 4}},
+		{"fla", 10, []int64{3, 4, 5}},
+		{"fla", 1, []int64{3}},
+		// 名称中间的词, 名称短的在前
+		{"flight", 10, []int64{1, 2}},
+		{"rules", 10, []int64{1, 2}},
+		// 别名和首字母缩写
+		{"ifr", 10, []int64{2}},
+		{"if", 10, []int64{2}},
+		// 大小写和变音符号
+		{"CAFÉ", 10, []int64{7}},
+		// 拼写错误: 4 到 7 个字符允许一处, 8 个字符以上允许两处
+		{"flpa", 10, []int64{3, 4, 5}},
+		{"crosswnd", 10, []int64{6}},
+		{"crswnd", 10, []int64{}},
+		{"cros", 10, []int64{6}},
+		{"crs", 10, []int64{}},
+		// 第一个字符不同时不做模糊匹配
+		{"glap", 10, []int64{}},
+		{"xyz", 10, []int64{}},
+		{"", 10, []int64{}},
+		{"!!", 10, []int64{}},
+		{"flap", 0, []int64{}},
+	} {
+		if got := suggestionIDs(idx.Suggest(c.query, c.limit)); !slices.Equal(got, c.want) {
+			t.Errorf("Suggest(%q, %d) = %v, want %v", c.query, c.limit, got, c.want)
+		}
+	}
+}
+
+func TestSuggestAlias(t *testing.T) {
+	idx := testIndex()
+	// 别名和缩写都是 "vfr" 时按别名命中
+	got := idx.Suggest("vfr", 10)
+	want := []Suggestion{{ID: 1, Name: "Visual Flight Rules", Alias: "VFR"}}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Suggest(vfr) = %+v, want %+v", got, want)
+	}
+	got = idx.Suggest("visual", 10)
+	want = []Suggestion{{ID: 1, Name: "Visual Flight Rules"}}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Suggest(visual) = %+v, want %+v", got, want)
+	}
+}
+
+func TestPutReplaces(t *testing.T) {
+	idx := testIndex()
+	idx.Put(Document{ID: 3, Name: "Aileron", Aliases: []string{"Roll Surface"}})
+	if got := idx.Len(); got != 7 {
+		t.Fatalf("Len = %d, want 7", got)
+	}
+	for query, want := range map[string][]int64{
+		"flap":    {5, 4},
+		"aileron": {3},
+		"roll":    {3},
+	} {
+		if got := suggestionIDs(idx.Suggest(query, 10)); !slices.Equal(got, want) {
+			t.Errorf("Suggest(%q) = %v, want %v", query, got, want)
+		}
+	}
+}
+
+func TestRebuild(t *testing.T) {
+	idx := testIndex()
+	err := idx.Rebuild(func() ([]Document, error) {
+		return []Document{{ID: 8, Name: "Spoiler"}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := idx.Len(); got != 1 {
+		t.Fatalf("Len = %d, want 1", got)
+	}
+	if got := suggestionIDs(idx.Suggest("flap", 10)); len(got) != 0 {
+		t.Fatalf("Suggest(flap) = %v, want none after rebuild", got)
+	}
+
+	// 加载失败时保留原来的索引
+	if err := idx.Rebuild(func() ([]Document, error) { return nil, errors.New("boom") }); err == nil {
+		t.Fatal("Rebuild should return the load error")
+	}
+	if got := suggestionIDs(idx.Suggest("spoiler", 10)); !slices.Equal(got, []int64{8}) {
+		t.Fatalf("Suggest(spoiler) = %v, want [8]", got)
+	}
+}
+
+func TestRebuildKeepsConcurrentPuts(t *testing.T) {
+	idx := testIndex()
+	err := idx.Rebuild(func() ([]Document, error) {
+		// 重建期间旧索引仍然可以查询
+		if got := suggestionIDs(idx.Suggest("flap", 10)); !slices.Equal(got, []int64{3, 5, 4}) {
+			t.Errorf("Suggest(flap) during rebuild = %v", got)
+		}
+		if err := idx.Rebuild(func() ([]Document, error) { return nil, nil }); err == nil {
+			t.Error("nested Rebuild should fail")
+		}
+		// load 读到的是 Put 之前的数据
+		idx.Put(Document{ID: 3, Name: "Aileron"})
+		idx.Put(Document{ID: 9, Name: "Rudder"})
+		return []Document{{ID: 3, Name: "Flap"}, {ID: 6, Name: "Crosswind"}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := idx.Len(); got != 3 {
+		t.Fatalf("Len = %d, want 3", got)
+	}
+	for query, want := range map[string][]int64{
+		"flap":      {},
+		"aileron":   {3},
+		"rudder":    {9},
+		"crosswind": {6},
+	} {
+		if got := suggestionIDs(idx.Suggest(query, 10)); !slices.Equal(got, want) {
+			t.Errorf("Suggest(%q) = %v, want %v", query, got, want)
+		}
+	}
+
+	// 重建结束后可以再次重建
+	if err := idx.Rebuild(func() ([]Document, error) { return nil, nil }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndexConcurrentUse(t *testing.T) {
+	// 与服务的用法相同: 先写入 store 再 Put, 重建时从 store 加载
+	idx := NewIndex()
+	var mu sync.Mutex
+	store := map[int64]Document{}
+	load := func() ([]Document, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		docs := make([]Document, 0, len(store))
+		for _, doc := range store {
+			docs = append(docs, doc)
+		}
+		return docs, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			doc := Document{ID: int64(100 + i), Name: fmt.Sprintf("Flap %d", i)}
+			mu.Lock()
+			store[doc.ID] = doc
+			mu.Unlock()
+			idx.Put(doc)
+		}()
+		go func() {
+			defer wg.Done()
+			idx.Suggest("flap", 5)
+		}()
+		go func() {
+			defer wg.Done()
+			_ = idx.Rebuild(load)
+		}()
+	}
+	wg.Wait()
+	// 每次 Put 都在某次重建之前或期间, 不会丢失
+	for i := range 8 {
+		if got := suggestionIDs(idx.Suggest(fmt.Sprintf("flap %d", i), 1)); !slices.Equal(got, []int64{int64(100 + i)}) {
+			t.Errorf("Suggest(flap %d) = %v", i, got)
+		}
+	}
+}
+
+func TestSuggestBudget(t *testing.T) {
+	// 字典序排在前面的长键比预算多, 短键仍然在预算内被检查到
+	idx := NewIndex()
+	for i := range maxCandidates + 50 {
+		idx.Put(Document{ID: int64(i + 1), Name: fmt.Sprintf("aa%04d", i)})
+	}
+	idx.Put(Document{ID: 1000, Name: "Ab"})
+
+	if got := suggestionIDs(idx.Suggest("a", 1)); !slices.Equal(got, []int64{1000}) {
+		t.Fatalf("Suggest(a) = %v, want [1000]", got)
+	}
+
+	visited := 0
+	n := idx.root.find([]rune("a"))
+	if remaining := n.collect(0, maxCandidates, func(candidate) { visited++ }); remaining != 0 || visited != maxCandidates {
+		t.Fatalf("collect visited %d, remaining %d, want %d and 0", visited, remaining, maxCandidates)
+	}
+	// 预算足够时检查全部索引项
+	if remaining := idx.root.find([]rune("ab")).collect(0, maxCandidates, func(candidate) {}); remaining != maxCandidates-1 {
+		t.Fatalf("collect remaining = %d, want %d", remaining, maxCandidates-1)
+	}
+}
+
+// benchmarkCorpus 生成由常见航空词汇组成的术语, 名称一到三个词, 部分术语有缩写别名
+func benchmarkCorpus(size int) []Document {
+	vocabulary := strings.Fields(`visual instrument flight rules approach landing takeoff runway taxiway
+		altitude airspeed heading attitude indicator flap aileron elevator rudder spoiler trim tab
+		crosswind headwind tailwind glide slope localizer beacon transponder squawk clearance
+		holding pattern missed procedure departure arrival vector radar tower ground control
+		fuel mixture throttle propeller engine magneto carburetor icing stall spin turbulence`)
+	r := rand.New(rand.NewPCG(1, 2))
+	docs := make([]Document, size)
+	for i := range docs {
+		parts := make([]string, 1+r.IntN(3))
+		for j := range parts {
+			parts[j] = vocabulary[r.IntN(len(vocabulary))]
+		}
+		doc := Document{ID: int64(i + 1), Name: strings.Join(parts, " ")}
+		if len(parts) > 1 && r.IntN(3) == 0 {
+			doc.Aliases = []string{strings.ToUpper(abbreviation(Fold(doc.Name)))}
+		}
+		docs[i] = doc
+	}
+	return docs
+}
+
+func BenchmarkSuggest(b *testing.B) {
+	idx := NewIndex()
+	if err := idx.Rebuild(func() ([]Document, error) { return benchmarkCorpus(20000), nil }); err != nil {
+		b.Fatal(err)
+	}
+	for _, query := range []string{"f", "fl", "flap", "flpa", "visul fligt", "ifr", "crosswind landing"} {
+		b.Run(query, func(b *testing.B) {
+			for range b.N {
+				idx.Suggest(query, 10)
+			}
+		})
+	}
+}
@@ -1,42 +1,207 @@
 package server
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
-	"skymates-api/internal/repositories"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"skymates-api/config"
 )
 
-// Server 表示 HTTP 服务器
-type Server struct {
-	handler http.Handler
+// Worker 是随服务一起启动的后台任务
+// Run 应该一直运行到 ctx 被取消, 返回 nil 表示正常退出
+type Worker interface {
+	Run(ctx context.Context) error
 }
 
-//// NewServer 创建并配置一个新的服务器实例
-//func NewServer(repos *Repositories) *Server {
-//	// 创建基础路由器
-//	mux := http.NewServeMux()
-//
-//	// 创建服务层
-//	services = service.NewServices()
-//
-//	// 注册 API v1 版本的路由
-//	v1.RegisterRoutes(mux, services)
-//
-//	// 应用全局中间件
-//	// 注意：中间件的应用顺序是从下到上，最后应用的中间件最先执行
-//	var handler http.Handler = mux
+// WorkerFunc 将普通函数适配为 Worker
+type WorkerFunc func(ctx context.Context) error
+
+// Run 实现 Worker 接口
+func (f WorkerFunc) Run(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Every 返回一个每隔 interval 执行一次 fn 的 Worker, 启动后先执行一次
+// fn 返回的错误只记录日志, 不会让任务退出
+func Every(interval time.Duration, fn func(ctx context.Context) error) Worker {
+	return every(interval, fn, true)
+}
+
+// EveryAfter 与 Every 相同, 但启动后先等待 interval 再第一次执行, 用于启动时已经执行过的任务
+func EveryAfter(interval time.Duration, fn func(ctx context.Context) error) Worker {
+	return every(interval, fn, false)
+}
+
+func every(interval time.Duration, fn func(ctx context.Context) error, immediate bool) Worker {
+	return WorkerFunc(func(ctx context.Context) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for run := immediate; ; run = true {
+			if run {
+				if err := fn(ctx); err != nil && ctx.Err() == nil {
+					slog.Error("background task failed", "error", err)
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+type namedWorker struct {
+	name   string
+	worker Worker
+}
+
+type shutdownHook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Server 管理 HTTP 服务及其依赖的生命周期
 //
-//	return &Server{handler: handler}
-//}
+// Run 启动后台任务和 HTTP 服务, 收到 SIGINT/SIGTERM 后按以下顺序退出:
+//  1. 标记为未就绪, 等待 ShutdownDelay, 让负载均衡器停止转发新请求
+//  2. 停止接收新连接, 在 ShutdownTimeout 内等待处理中的请求完成
+//  3. 取消后台任务并等待退出
+//  4. 按注册的相反顺序执行 OnShutdown 注册的清理函数, 例如关闭数据库连接池
+type Server struct {
+	httpServer      *http.Server
+	shutdownTimeout time.Duration
+	shutdownDelay   time.Duration
 
-// Start 启动 HTTP 服务器
-func (s *Server) Start(addr string) error {
-	return http.ListenAndServe(addr, s.handler)
+	ready   atomic.Bool
+	workers []namedWorker
+	hooks   []shutdownHook
 }
 
-// Repositories 包含所有仓库实例
-type Repositories struct {
-	UserRepository    repositories.UserRepository
-	TermRepository    repositories.TermRepository
-	PostRepository    repositories.PostRepository
-	CommentRepository repositories.CommentRepository
+// New 根据配置创建 Server
+func New(cfg config.ServerConfig, handler http.Handler) *Server {
+	return &Server{
+		httpServer: &http.Server{
+			Addr:              cfg.Addr(),
+			Handler:           handler,
+			ReadTimeout:       cfg.ReadTimeout,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+		},
+		shutdownTimeout: cfg.ShutdownTimeout,
+		shutdownDelay:   cfg.ShutdownDelay,
+	}
+}
+
+// Ready 返回服务是否可以接收新请求, 开始退出后返回 false
+func (s *Server) Ready() bool {
+	return s.ready.Load()
+}
+
+// AddWorker 注册后台任务, 必须在 Run 之前调用
+func (s *Server) AddWorker(name string, worker Worker) {
+	s.workers = append(s.workers, namedWorker{name: name, worker: worker})
+}
+
+// OnShutdown 注册退出时执行的清理函数, 必须在 Run 之前调用
+// 清理函数在 HTTP 请求处理完成, 后台任务退出后按注册的相反顺序执行
+func (s *Server) OnShutdown(name string, fn func(ctx context.Context) error) {
+	s.hooks = append(s.hooks, shutdownHook{name: name, fn: fn})
+}
+
+// Run 启动服务并阻塞, 直到收到退出信号, ctx 被取消或者 HTTP 服务启动失败
+// 无论哪种情况都会执行完整的退出流程, 返回过程中遇到的错误
+func (s *Server) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 先监听端口, 端口被占用时直接返回错误, 不启动后台任务
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		s.runHooks()
+		return fmt.Errorf("listen on %s: %w", s.httpServer.Addr, err)
+	}
+
+	// 1. 启动后台任务
+	workerCtx, cancelWorkers := context.WithCancel(context.Background())
+	defer cancelWorkers()
+	var workers sync.WaitGroup
+	for _, w := range s.workers {
+		workers.Add(1)
+		go func(w namedWorker) {
+			defer workers.Done()
+			if err := w.worker.Run(workerCtx); err != nil {
+				slog.Error("worker stopped", "worker", w.name, "error", err)
+			}
+		}(w)
+	}
+
+	// 2. 启动 HTTP 服务
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.httpServer.Serve(listener)
+	}()
+	s.ready.Store(true)
+	slog.Info("server listening", "addr", listener.Addr().String())
+
+	// 3. 等待退出信号或服务异常退出
+	var errs []error
+	select {
+	case <-ctx.Done():
+		slog.Info("server shutting down")
+	case err := <-serveErr:
+		errs = append(errs, fmt.Errorf("serve: %w", err))
+	}
+	stop() // 再次收到信号时使用默认行为, 立即退出进程
+
+	// 4. 标记为未就绪, 等待负载均衡器摘除实例
+	s.ready.Store(false)
+	if s.shutdownDelay > 0 {
+		time.Sleep(s.shutdownDelay)
+	}
+
+	// 5. 等待处理中的请求完成, 超时后强制关闭连接
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	if err := s.httpServer.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("drain http requests: %w", err))
+		_ = s.httpServer.Close()
+	}
+
+	// 6. 停止后台任务
+	cancelWorkers()
+	workers.Wait()
+
+	// 7. 执行清理函数
+	if err := s.runHooks(); err != nil {
+		errs = append(errs, err)
+	}
+	slog.Info("server stopped")
+	return errors.Join(errs...)
+}
+
+// runHooks 按注册的相反顺序执行清理函数, 每个函数共用 ShutdownTimeout
+func (s *Server) runHooks() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	for i := len(s.hooks) - 1; i >= 0; i-- {
+		hook := s.hooks[i]
+		if err := hook.fn(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("shutdown %s: %w", hook.name, err))
+		}
+	}
+	return errors.Join(errs...)
 }
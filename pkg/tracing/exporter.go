@@ -0,0 +1,209 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Exporter 将结束的 span 发送到存储后端
+type Exporter interface {
+	// Export 导出一批 span, 由 Tracer 串行调用
+	Export(ctx context.Context, spans []SpanData) error
+	// Shutdown 在最后一次 Export 之后调用, 释放文件等资源
+	Shutdown(ctx context.Context) error
+}
+
+// writerExporter 每批 span 写为一行 OTLP JSON, 与 OpenTelemetry Collector 的文件格式相同,
+// 可以用 otlpjsonfile receiver 重新导入
+type writerExporter struct {
+	serviceName string
+	mu          sync.Mutex
+	w           io.Writer
+	closer      io.Closer
+}
+
+// NewWriterExporter 创建写入 w 的 Exporter, 例如 os.Stdout
+func NewWriterExporter(w io.Writer, serviceName string) Exporter {
+	return &writerExporter{serviceName: serviceName, w: w}
+}
+
+// NewFileExporter 创建追加写入 path 的 Exporter, Shutdown 时关闭文件
+func NewFileExporter(path, serviceName string) (Exporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open trace file: %w", err)
+	}
+	return &writerExporter{serviceName: serviceName, w: file, closer: file}, nil
+}
+
+// Export 实现 Exporter
+func (e *writerExporter) Export(ctx context.Context, spans []SpanData) error {
+	line, err := json.Marshal(newOTLPRequest(e.serviceName, spans))
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err = e.w.Write(append(line, '\n'))
+	return err
+}
+
+// Shutdown 实现 Exporter
+func (e *writerExporter) Shutdown(ctx context.Context) error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+// otlpExporter 通过 OTLP/HTTP 以 JSON 编码发送 span
+type otlpExporter struct {
+	endpoint    string
+	serviceName string
+	client      *http.Client
+}
+
+// NewOTLPExporter 创建发送到 endpoint 的 Exporter, endpoint 为完整地址,
+// 例如 http://localhost:4318/v1/traces
+func NewOTLPExporter(endpoint, serviceName string, client *http.Client) Exporter {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &otlpExporter{endpoint: endpoint, serviceName: serviceName, client: client}
+}
+
+// Export 实现 Exporter
+func (e *otlpExporter) Export(ctx context.Context, spans []SpanData) error {
+	body, err := json.Marshal(newOTLPRequest(e.serviceName, spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("otlp endpoint %s returned %s", e.endpoint, resp.Status)
+	}
+	return nil
+}
+
+// Shutdown 实现 Exporter
+func (e *otlpExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// 以下类型对应 OTLP ExportTraceServiceRequest 的 JSON 编码
+// 规范要求 TraceID 和 SpanID 使用十六进制, 64 位整数使用字符串
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    StatusCode `json:"code,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+func newOTLPRequest(serviceName string, spans []SpanData) otlpRequest {
+	converted := make([]otlpSpan, len(spans))
+	for i, span := range spans {
+		converted[i] = otlpSpan{
+			TraceID:           span.TraceID.String(),
+			SpanID:            span.SpanID.String(),
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+			Status:            otlpStatus{Code: span.Status, Message: span.StatusMessage},
+		}
+		if span.ParentSpanID.IsValid() {
+			converted[i].ParentSpanID = span.ParentSpanID.String()
+		}
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttributes([]Attribute{String("service.name", serviceName)})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "skymates-api/pkg/tracing"}, Spans: converted}},
+	}}}
+}
+
+func otlpAttributes(attrs []Attribute) []otlpKeyValue {
+	converted := make([]otlpKeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		var value otlpValue
+		switch v := attr.Value.(type) {
+		case string:
+			value.StringValue = &v
+		case int64:
+			s := strconv.FormatInt(v, 10)
+			value.IntValue = &s
+		case float64:
+			value.DoubleValue = &v
+		case bool:
+			value.BoolValue = &v
+		default:
+			s := fmt.Sprint(v)
+			value.StringValue = &s
+		}
+		converted = append(converted, otlpKeyValue{Key: attr.Key, Value: value})
+	}
+	return converted
+}
@@ -0,0 +1,408 @@
+// Package tracing 实现 OpenTelemetry 风格的分布式追踪
+//
+// 中间件为每个请求创建服务端 span, span 通过 context.Context 传递给服务层和存储库,
+// 每一层使用 Start 创建子 span. 结束的 span 由 Tracer 批量交给 Exporter 导出,
+// 导出格式与 OTLP/HTTP JSON 兼容, 可以直接发送给 OpenTelemetry Collector 或 Jaeger
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceID 标识一条完整的调用链
+type TraceID [16]byte
+
+// String 返回 32 位小写十六进制
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid 全 0 的 TraceID 无效
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// SpanID 标识调用链中的一个 span
+type SpanID [8]byte
+
+// String 返回 16 位小写十六进制
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid 全 0 的 SpanID 无效
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// SpanContext 需要跨进程传递的 span 信息
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid 返回 TraceID 和 SpanID 是否都有效
+func (sc SpanContext) IsValid() bool { return sc.TraceID.IsValid() && sc.SpanID.IsValid() }
+
+// SpanKind span 的类型, 取值与 OTLP 一致
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// StatusCode span 的状态, 取值与 OTLP 一致
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+// Attribute span 的属性, Value 为 string, int64, float64 或 bool
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// String 创建字符串属性
+func String(key, value string) Attribute { return Attribute{Key: key, Value: value} }
+
+// Int 创建整数属性
+func Int(key string, value int) Attribute { return Attribute{Key: key, Value: int64(value)} }
+
+// Int64 创建整数属性
+func Int64(key string, value int64) Attribute { return Attribute{Key: key, Value: value} }
+
+// Bool 创建布尔属性
+func Bool(key string, value bool) Attribute { return Attribute{Key: key, Value: value} }
+
+// SpanData 结束后交给 Exporter 的 span 快照
+type SpanData struct {
+	Name          string
+	Kind          SpanKind
+	TraceID       TraceID
+	SpanID        SpanID
+	ParentSpanID  SpanID // 根 span 为全 0
+	Start         time.Time
+	End           time.Time
+	Attributes    []Attribute
+	Status        StatusCode
+	StatusMessage string
+}
+
+// Span 一次操作的耗时和属性, End 之后的修改会被忽略
+// 所有方法都可以在 nil 上调用, 追踪关闭或未采样时 Start 返回的 span 不会被导出
+type Span struct {
+	tracer *Tracer
+	sc     SpanContext
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// SpanContext 返回用于传播的 span 信息
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetName 修改 span 名称, 例如路由匹配完成后使用路由模式命名
+func (s *Span) SetName(name string) {
+	if !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.data.Name = name
+	}
+}
+
+// SetAttributes 添加属性
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.data.Attributes = append(s.data.Attributes, attrs...)
+	}
+}
+
+// SetStatus 设置 span 状态
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.data.Status, s.data.StatusMessage = code, message
+	}
+}
+
+// RecordError 将 span 标记为失败, err 为 nil 时什么都不做
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.SetStatus(StatusError, err.Error())
+}
+
+// End 结束 span 并交给 Tracer 导出, 重复调用无效
+func (s *Span) End() {
+	if !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	s.tracer.enqueue(data)
+}
+
+func (s *Span) recording() bool {
+	return s != nil && s.tracer != nil && s.sc.Sampled
+}
+
+type spanKey struct{}
+
+// ContextWithSpan 返回携带 span 的 context
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext 返回 ctx 中的当前 span, 没有时返回 nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// remoteKey 保存从请求头中解析出的上游 span, 作为本进程根 span 的父 span
+type remoteKey struct{}
+
+// ContextWithRemoteSpanContext 返回携带上游 span 信息的 context
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// StartOption 配置新建的 span
+type StartOption func(*SpanData)
+
+// WithKind 设置 span 类型, 默认为 SpanKindInternal
+func WithKind(kind SpanKind) StartOption {
+	return func(d *SpanData) { d.Kind = kind }
+}
+
+// WithAttributes 设置 span 的初始属性
+func WithAttributes(attrs ...Attribute) StartOption {
+	return func(d *SpanData) { d.Attributes = append(d.Attributes, attrs...) }
+}
+
+// Options Tracer 的配置
+type Options struct {
+	// SampleRatio 根 span 的采样比例 (0~1), 有父 span 时沿用父 span 的采样决定
+	SampleRatio float64
+	// QueueSize 等待导出的 span 数量上限, 队列满时丢弃新的 span
+	QueueSize int
+	// BatchSize 每批导出的 span 数量上限
+	BatchSize int
+	// FlushInterval 两次导出之间的最长间隔
+	FlushInterval time.Duration
+}
+
+// Tracer 创建 span 并批量导出
+// Run 作为后台任务运行, 定期导出结束的 span, 退出前导出剩余的 span 并关闭 Exporter
+type Tracer struct {
+	exporter Exporter
+	options  Options
+
+	queue   chan SpanData
+	dropped atomic.Int64
+}
+
+// NewTracer 创建 Tracer, exporter 为 nil 时不创建任何 span
+func NewTracer(exporter Exporter, options Options) *Tracer {
+	if options.QueueSize <= 0 {
+		options.QueueSize = 2048
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = 512
+	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = 5 * time.Second
+	}
+	return &Tracer{
+		exporter: exporter,
+		options:  options,
+		queue:    make(chan SpanData, options.QueueSize),
+	}
+}
+
+// Start 创建 span, 父 span 取自 ctx; 没有父 span 时开始一条新的调用链
+// 返回的 context 携带新 span, 调用方必须调用 span.End()
+func (t *Tracer) Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	if t == nil || t.exporter == nil {
+		return ctx, nil
+	}
+
+	var parent SpanContext
+	if span := SpanFromContext(ctx); span != nil {
+		parent = span.SpanContext()
+	} else if remote, ok := ctx.Value(remoteKey{}).(SpanContext); ok {
+		parent = remote
+	}
+
+	sc := SpanContext{SpanID: newSpanID()}
+	if parent.IsValid() {
+		sc.TraceID, sc.Sampled = parent.TraceID, parent.Sampled
+	} else {
+		sc.TraceID = newTraceID()
+		sc.Sampled = t.sample(sc.TraceID)
+	}
+
+	span := &Span{tracer: t, sc: sc}
+	if sc.Sampled {
+		span.data = SpanData{
+			Name:         name,
+			Kind:         SpanKindInternal,
+			TraceID:      sc.TraceID,
+			SpanID:       sc.SpanID,
+			ParentSpanID: parent.SpanID,
+			Start:        time.Now(),
+		}
+		for _, opt := range opts {
+			opt(&span.data)
+		}
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+// sample 根据 TraceID 的低 8 字节决定是否采样, 同一条调用链在所有服务中的决定一致
+func (t *Tracer) sample(id TraceID) bool {
+	switch {
+	case t.options.SampleRatio >= 1:
+		return true
+	case t.options.SampleRatio <= 0:
+		return false
+	}
+	bound := uint64(t.options.SampleRatio * (1 << 63))
+	return binary.BigEndian.Uint64(id[8:])>>1 < bound
+}
+
+func (t *Tracer) enqueue(data SpanData) {
+	select {
+	case t.queue <- data:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+// Run 实现 server.Worker, 定期导出 span, ctx 取消后导出剩余的 span 并关闭 Exporter
+func (t *Tracer) Run(ctx context.Context) error {
+	if t.exporter == nil {
+		return nil
+	}
+	ticker := time.NewTicker(t.options.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err := t.Flush(shutdownCtx)
+			if shutdownErr := t.exporter.Shutdown(shutdownCtx); shutdownErr != nil && err == nil {
+				err = shutdownErr
+			}
+			if err != nil {
+				return fmt.Errorf("tracing: export spans: %w", err)
+			}
+			return nil
+		case <-ticker.C:
+			// 后端暂时不可用时丢弃这一批 span, 不影响后续导出
+			if err := t.Flush(ctx); err != nil && ctx.Err() == nil {
+				slog.Warn("export spans failed", "error", err)
+			}
+		}
+	}
+}
+
+// Flush 立即导出队列中所有的 span
+func (t *Tracer) Flush(ctx context.Context) error {
+	if dropped := t.dropped.Swap(0); dropped > 0 {
+		slog.Warn("tracing export queue full, spans dropped", "count", dropped)
+	}
+	for {
+		batch := t.drain()
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := t.exporter.Export(ctx, batch); err != nil {
+			return err
+		}
+	}
+}
+
+func (t *Tracer) drain() []SpanData {
+	var batch []SpanData
+	for len(batch) < t.options.BatchSize {
+		select {
+		case data := <-t.queue:
+			batch = append(batch, data)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+var defaultTracer atomic.Pointer[Tracer]
+
+// SetDefault 设置 Start 使用的 Tracer, 在启动时调用一次
+func SetDefault(t *Tracer) {
+	defaultTracer.Store(t)
+}
+
+// Default 返回 Start 使用的 Tracer, 未设置时为 nil, 不创建任何 span
+func Default() *Tracer {
+	return defaultTracer.Load()
+}
+
+// Start 使用默认的 Tracer 创建 span, 用法:
+//
+//	ctx, span := tracing.Start(ctx, "TermService.GetTermByID")
+//	defer span.End()
+func Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	return Default().Start(ctx, name, opts...)
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}